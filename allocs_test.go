@@ -0,0 +1,175 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+)
+
+// The allocation budgets guard the hot paths against regressions: a change making the
+// records, or the values they hold, escape to the heap fails these tests rather than
+// silently multiplying the allocations per record. The budgets are the allocations
+// measured when written; lower them when a change saves allocations.
+
+// allocsRuns is the number of runs averaged by the allocation tests.
+const allocsRuns = 100
+
+// nopLogger discards the messages, leaving the allocations of the instance loggers.
+type nopLogger struct{}
+
+func (nopLogger) Error(format string, a ...any) {}
+func (nopLogger) Warn(format string, a ...any)  {}
+func (nopLogger) Info(format string, a ...any)  {}
+func (nopLogger) Debug(format string, a ...any) {}
+
+// skipAllocs skips the allocation tests under the race detector, which drops the items
+// put in the pools at random and allocates for its own bookkeeping.
+func skipAllocs(t *testing.T) {
+	t.Helper()
+
+	if raceEnabled {
+		t.Skip("allocations are not measured under the race detector")
+	}
+}
+
+// allocsPerRecord returns the average allocations of f per record of a chunk, past the
+// perChunk allocations of the chunk itself.
+func allocsPerRecord(records int, perChunk float64, f func()) float64 {
+	return (testing.AllocsPerRun(allocsRuns, f) - perChunk) / float64(records)
+}
+
+func TestLoggerAllocs(t *testing.T) {
+	skipAllocs(t)
+
+	logger := newInstanceLogger(nopLogger{}, "dummy", InstanceInfo{Name: "dummy.0"})
+	n := 42
+
+	// the line, the line recorded for the crash reports, and the arguments boxed for the
+	// loggers.
+	allocs := testing.AllocsPerRun(allocsRuns, func() {
+		logger.Info("flushed %d records", n)
+	})
+	if allocs > 4 {
+		t.Errorf("logger: %v allocations per line, want at most 4", allocs)
+	}
+
+	allocs = testing.AllocsPerRun(allocsRuns, func() {
+		recordLog("info", "flushed 42 records")
+	})
+	if allocs > 1 {
+		t.Errorf("recordLog: %v allocations per line, want at most 1", allocs)
+	}
+
+	allocs = testing.AllocsPerRun(allocsRuns, func() {
+		_ = logMessage("%s", []any{"[dummy] flushed 42 records"})
+	})
+	if allocs > 0 {
+		t.Errorf("logMessage: %v allocations per line, want none", allocs)
+	}
+}
+
+func TestDecodeAllocs(t *testing.T) {
+	skipAllocs(t)
+
+	// the budgets per record, fresh and pooled, of the shapes of the benchmarks, the
+	// decoder being the allocation of the chunk. The keys are not interned, as the hits
+	// of the key cache vary with its random seed.
+	budgets := map[string]struct{ fresh, pooled float64 }{
+		"flat":    {fresh: 9, pooled: 7},
+		"k8s":     {fresh: 60, pooled: 58},
+		"long":    {fresh: 7, pooled: 5},
+		"strings": {fresh: 13, pooled: 11},
+	}
+
+	const records = 100
+	for _, shape := range benchShapes {
+		budget, ok := budgets[shape.name]
+		if !ok {
+			t.Fatalf("no allocation budget for the %s shape", shape.name)
+		}
+
+		t.Run(shape.name, func(t *testing.T) {
+			b, err := encodeMessages(MsgpackCodec{}, nil, benchMessages(shape, records))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decodeAll := func(pooled bool) func() {
+				return func() {
+					dec := newRecordDecoder(MsgpackCodec{}, nil, b, pooled)
+					for {
+						_, record, err := dec.Next()
+						if err != nil {
+							return
+						}
+
+						if pooled {
+							clear(record)
+							recordPool.Put(record)
+						}
+					}
+				}
+			}
+
+			if allocs := allocsPerRecord(records, 1, decodeAll(false)); allocs > budget.fresh {
+				t.Errorf("decode: %v allocations per record, want at most %v", allocs, budget.fresh)
+			}
+			if allocs := allocsPerRecord(records, 1, decodeAll(true)); allocs > budget.pooled {
+				t.Errorf("pooled decode: %v allocations per record, want at most %v", allocs, budget.pooled)
+			}
+		})
+	}
+}
+
+func TestEncodeAllocs(t *testing.T) {
+	skipAllocs(t)
+
+	const records = 100
+	for _, shape := range benchShapes {
+		t.Run(shape.name, func(t *testing.T) {
+			msgs := benchMessages(shape, records)
+			buf := getBuffer()
+			defer putBuffer(buf)
+
+			// the records are encoded into a grown buffer without allocating.
+			allocs := allocsPerRecord(records, 0, func() {
+				buf.Reset()
+				for _, msg := range msgs {
+					if err := encodeRecordTo(MsgpackCodec{}, buf, msg); err != nil {
+						t.Fatal(err)
+					}
+				}
+			})
+			if allocs > 0 {
+				t.Errorf("encode: %v allocations per record, want none", allocs)
+			}
+		})
+	}
+}
+
+func TestEmitAllocs(t *testing.T) {
+	skipAllocs(t)
+
+	const records = 100
+	r := prepareInput(testPluginInputCallbackCtrlC{})
+	r.prepareInputCollector()
+	defer r.runCancel()
+
+	msgs := benchMessages(benchShapes[1], records)
+	for i := range msgs {
+		msgs[i].Time = time.Now()
+	}
+
+	// the messages are sent by the input and collected into a chunk, their records
+	// encoded in place into the input buffer.
+	allocs := allocsPerRecord(records, 0, func() {
+		for _, msg := range msgs {
+			r.channel <- msg
+		}
+		if _, _, err := r.collectLogs(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 0.5 {
+		t.Errorf("emit: %v allocations per record, want at most 0.5", allocs)
+	}
+}
@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+)
+
+// WithBackpressure stops accepting the records of the input once high records are buffered,
+// so the sends of the collector block, until the collect callbacks drain the buffer down to
+// low records. The collect callback returns FLB_RETRY while the input is held back.
+// It panics when used to register other kinds of plugins or when low is not below high.
+func WithBackpressure(high, low int) RegisterOption {
+	return func(r *registration) {
+		if r.kind != inputKind {
+			panic(fmt.Sprintf("backpressure set on %s plugin: %q", r.kind, r.name))
+		}
+
+		if low < 0 || high <= low {
+			panic(fmt.Sprintf("invalid backpressure water marks high=%d low=%d: %q", high, low, r.name))
+		}
+
+		r.highWater, r.lowWater = high, low
+	}
+}
+
+// holdInput waits for the buffer to be drained below the low-water mark once it reached
+// the high-water mark, it returns false when the context is cancelled while waiting.
+func (r *registration) holdInput(ctx context.Context, buffered int) bool {
+	if r.highWater == 0 || buffered < r.highWater {
+		return true
+	}
+
+	r.backpressure.Store(true)
+	if r.logger != nil {
+		r.logger.Warn("input paused: buffered=%d high_water_mark=%d", buffered, r.highWater)
+	}
+
+	select {
+	case <-r.drained:
+	case <-ctx.Done():
+		return false
+	}
+
+	if r.logger != nil {
+		r.logger.Info("input resumed: low_water_mark=%d", r.lowWater)
+	}
+
+	return true
+}
+
+// releaseInput resumes an input held back once its buffer is drained below the low-water mark.
+func (r *registration) releaseInput() {
+	if r.bufferedInput() > r.lowWater || !r.backpressure.CompareAndSwap(true, false) {
+		return
+	}
+
+	select {
+	case r.drained <- struct{}{}:
+	default:
+	}
+}
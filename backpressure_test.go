@@ -0,0 +1,70 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestWithBackpressure(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	RegisterInput("dummy-input", "", testPluginInputCallbackCtrlC{}, WithBackpressure(100, 10))
+	r := registrationOf(inputKind)
+	assert.Equal(t, 100, r.highWater)
+	assert.Equal(t, 10, r.lowWater)
+	assert.True(t, r.relayed())
+
+	assert.Panics(t, func() {
+		RegisterOutput("dummy-output", "", &testOutputCounter{}, WithBackpressure(100, 10))
+	})
+	assert.Panics(t, func() {
+		resetRegistry()
+		RegisterInput("dummy-input", "", testPluginInputCallbackCtrlC{}, WithBackpressure(10, 10))
+	})
+}
+
+func TestBackpressure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := &registration{highWater: 2, lowWater: 0, drained: make(chan struct{}, 1)}
+	r.channel = make(chan Message, 10)
+	in := make(chan Message)
+	go r.relayInput(ctx, in, r.channel, nil)
+
+	in <- Message{Record: 1}
+	in <- Message{Record: 2}
+
+	sent := make(chan struct{})
+	go func() {
+		in <- Message{Record: 3}
+		close(sent)
+	}()
+
+	select {
+	case <-sent:
+		t.Fatal("record accepted above the high-water mark")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.True(t, r.backpressure.Load())
+
+	// draining only part of the buffer keeps the input held back.
+	<-r.channel
+	r.releaseInput()
+	assert.True(t, r.backpressure.Load())
+
+	<-r.channel
+	r.releaseInput()
+	assert.False(t, r.backpressure.Load())
+
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("input not resumed")
+	}
+	assert.Equal(t, any(3), (<-r.channel).Record)
+}
@@ -0,0 +1,26 @@
+package plugin
+
+import "context"
+
+// BatchFlusher is an optional interface output plugins implement to receive
+// all the messages of a flushed chunk at once, in a single call, instead of
+// one by one through the Flush channel. It allows chunk-level semantics such
+// as a single request per chunk or transactional writes.
+// FlushBatch is preferred over Flush when implemented, returning ErrRetry makes
+// fluent-bit retry the chunk later.
+type BatchFlusher interface {
+	FlushBatch(ctx context.Context, tag string, msgs []Message) error
+}
+
+func (o *outputInstance) pluginFlushBatch(ctx context.Context, worker int, tag string, b []byte) error {
+	chunk, err := o.decodeChunk(tag, b)
+	if err != nil {
+		return err
+	}
+
+	for i := range chunk.Messages {
+		chunk.Messages[i].worker = worker
+	}
+
+	return o.batchOutput.FlushBatch(ctx, tag, chunk.Messages)
+}
@@ -0,0 +1,49 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type testBatchOutput struct {
+	testOutputCounter
+	tag  string
+	msgs []Message
+}
+
+func (plug *testBatchOutput) FlushBatch(ctx context.Context, tag string, msgs []Message) error {
+	plug.tag = tag
+	plug.msgs = msgs
+	return nil
+}
+
+func TestFlushBatch(t *testing.T) {
+	plug := &testBatchOutput{}
+	o := (&registration{name: "test-output", output: plug}).newOutputInstance()
+	assert.True(t, o.batchOutput == plug)
+
+	// batch outputs do not run the Flush goroutine.
+	assert.NoError(t, o.run())
+	assert.Zero(t, o.channel)
+
+	var b []byte
+	for i := 0; i < 3; i++ {
+		rec, err := msgpack.Marshal([]any{&EventTime{time.Now()}, map[string]any{"idx": i}})
+		assert.NoError(t, err)
+		b = append(b, rec...)
+	}
+
+	assert.NoError(t, o.pluginFlushBatch(context.Background(), 1, "foobar", b))
+	assert.Equal(t, "foobar", plug.tag)
+	assert.Equal(t, 3, len(plug.msgs))
+	for i, msg := range plug.msgs {
+		assert.Equal(t, "foobar", msg.Tag())
+		assert.Equal(t, 1, msg.Worker())
+		record := assertType[map[string]any](t, msg.Record)
+		assert.Equal(t, int8(i), assertType[int8](t, record["idx"]))
+	}
+}
@@ -0,0 +1,377 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/calyptia/plugin/output"
+)
+
+// The benchmarks of the encode, decode, input callback and flush paths run over the record
+// shapes below, so their results compare across changes of the SDK. Compare two revisions
+// with scripts/benchcmp.sh.
+
+// benchShape is a record shape of the benchmarks.
+type benchShape struct {
+	name   string
+	record func(i int) map[string]any
+}
+
+var benchShapes = []benchShape{
+	{name: "flat", record: func(i int) map[string]any {
+		return map[string]any{
+			"log":    fmt.Sprintf("127.0.0.1 - - GET /index.html?id=%d HTTP/1.1 200 512", i),
+			"stream": "stdout",
+			"status": 200,
+		}
+	}},
+	{name: "k8s", record: func(i int) map[string]any {
+		return map[string]any{
+			"log":        fmt.Sprintf("127.0.0.1 - - GET /index.html?id=%d HTTP/1.1 200 512", i),
+			"stream":     "stdout",
+			"time":       "2024-01-01T00:00:00.000000000Z",
+			"status":     200,
+			"bytes":      512,
+			"latency_ms": 1.25,
+			"kubernetes": map[string]any{
+				"pod_name":        fmt.Sprintf("web-5d9c7b7f6d-%05d", i),
+				"namespace_name":  "default",
+				"pod_id":          "0f2e8d4c-3b1a-4e5f-9a8b-7c6d5e4f3a2b",
+				"host":            "ip-10-0-1-23.eu-west-1.compute.internal",
+				"container_name":  "web",
+				"docker_id":       "4b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c",
+				"container_image": "registry.example.com/web:1.27.3",
+				"labels": map[string]any{
+					"app":               "web",
+					"tier":              "frontend",
+					"pod-template-hash": "5d9c7b7f6d",
+				},
+				"annotations": map[string]any{
+					"prometheus.io/scrape": "true",
+					"prometheus.io/port":   "9090",
+				},
+			},
+		}
+	}},
+	{name: "long", record: func(i int) map[string]any {
+		return map[string]any{
+			"log":    fmt.Sprintf("%d %s", i, strings.Repeat("lorem ipsum dolor sit amet ", 300)),
+			"stream": "stderr",
+		}
+	}},
+	{name: "strings", record: func(i int) map[string]any {
+		return map[string]any{
+			"log":    fmt.Sprintf("127.0.0.1 - - GET /index.html?id=%d HTTP/1.1 200 512", i),
+			"stream": "stdout",
+			"time":   "2024-01-01T00:00:00.000000000Z",
+			"host":   "ip-10-0-1-23",
+			"level":  "info",
+		}
+	}},
+}
+
+// benchMessages returns n messages of the shape.
+func benchMessages(shape benchShape, n int) []Message {
+	now := time.Now()
+	msgs := make([]Message, n)
+	for i := range msgs {
+		msgs[i] = Message{Time: now, Record: shape.record(i)}
+	}
+
+	return msgs
+}
+
+// benchCodecs are the codecs compared by the encode and decode benchmarks.
+var benchCodecs = []struct {
+	name  string
+	codec Codec
+}{
+	{name: "msgpack", codec: MsgpackCodec{}},
+	{name: "ugorji", codec: UgorjiCodec{}},
+}
+
+func BenchmarkEncode(b *testing.B) {
+	for _, c := range benchCodecs {
+		for _, shape := range benchShapes {
+			b.Run(c.name+"/"+shape.name, func(b *testing.B) {
+				msg := benchMessages(shape, 1)[0]
+				buf := getBuffer()
+				defer putBuffer(buf)
+
+				b.ReportAllocs()
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					buf.Reset()
+					if err := encodeRecordTo(c.codec, buf, msg); err != nil {
+						b.Fatal(err)
+					}
+				}
+
+				b.SetBytes(int64(buf.Len()))
+			})
+		}
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	const records = 100
+
+	for _, c := range benchCodecs {
+		for _, shape := range benchShapes {
+			b.Run(c.name+"/"+shape.name, func(b *testing.B) {
+				chunk, err := encodeMessages(MsgpackCodec{}, nil, benchMessages(shape, records))
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				b.ReportAllocs()
+				b.SetBytes(int64(len(chunk)))
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					dec := c.codec.Decode(chunk)
+					for j := 0; j < records; j++ {
+						if _, _, err := dec.Next(); err != nil {
+							b.Fatal(err)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkInputCallback(b *testing.B) {
+	const records = 100
+
+	for _, shape := range benchShapes {
+		b.Run(shape.name, func(b *testing.B) {
+			defer resetRegistry()
+
+			r := prepareInput(testPluginInputCallbackCtrlC{})
+			r.maxBufferedMessages = records
+			r.prepareInputCollector()
+			defer r.runCancel()
+
+			msgs := benchMessages(shape, records)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			var n int
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				for _, msg := range msgs {
+					r.channel <- msg
+				}
+				b.StartTimer()
+
+				buf, err := testFLBPluginInputCallback()
+				if err != nil {
+					b.Fatal(err)
+				}
+				n += len(buf)
+			}
+
+			b.SetBytes(int64(n / b.N))
+		})
+	}
+}
+
+// benchOutput counts the messages it is flushed, signaling every records of them. The
+// messages are done once counted, for the outputs loaning them.
+type benchOutput struct {
+	records int
+	done    chan struct{}
+}
+
+func (o *benchOutput) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (o *benchOutput) Flush(ctx context.Context, ch <-chan Message) error {
+	var n int
+	for {
+		select {
+		case msg := <-ch:
+			msg.Done()
+			if n++; n == o.records {
+				n = 0
+				o.done <- struct{}{}
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func BenchmarkFlush(b *testing.B) {
+	const records = 100
+
+	for _, shape := range benchShapes {
+		b.Run(shape.name, func(b *testing.B) {
+			defer resetRegistry()
+
+			out := &benchOutput{records: records, done: make(chan struct{})}
+			o := prepareOutputFlush(out)
+
+			chunk, err := encodeMessages(MsgpackCodec{}, nil, benchMessages(shape, records))
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.SetBytes(int64(len(chunk)))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if err := o.pluginFlush(0, "tag", chunk); err != nil {
+					b.Fatal(err)
+				}
+				<-out.done
+			}
+		})
+	}
+}
+
+// BenchmarkFlushCallback flushes chunks of long records from C memory, decoded in place or
+// copied first.
+func BenchmarkFlushCallback(b *testing.B) {
+	const records = 1000
+
+	for _, copyChunks := range []bool{false, true} {
+		b.Run(fmt.Sprintf("copy=%t", copyChunks), func(b *testing.B) {
+			defer resetRegistry()
+
+			out := &benchOutput{records: records, done: make(chan struct{})}
+			o := prepareOutputFlush(out)
+			o.copyChunks = copyChunks
+
+			chunk, err := encodeMessages(MsgpackCodec{}, nil, benchMessages(benchShapes[2], records))
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.SetBytes(int64(len(chunk)))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if ret := testFlush(o, "tag", chunk); ret != output.FLB_OK {
+					b.Fatalf("flush: %d", ret)
+				}
+				<-out.done
+			}
+		})
+	}
+}
+
+// BenchmarkInputIngest measures the throughput of the input buffer under contention,
+// several goroutines sending records to the collect channel while the input callback
+// drains it, buffered in the channel or in the ring of WithRingBuffer.
+func BenchmarkInputIngest(b *testing.B) {
+	const records = 10000
+
+	for _, design := range []string{"channel", "ring"} {
+		for _, producers := range []int{1, 4, 16} {
+			b.Run(fmt.Sprintf("buffer=%s/producers=%d", design, producers), func(b *testing.B) {
+				benchmarkInputIngest(b, design == "ring", producers, records)
+			})
+		}
+	}
+}
+
+func benchmarkInputIngest(b *testing.B, ring bool, producers, records int) {
+	defer resetRegistry()
+
+	r := prepareInput(testPluginInputCallbackCtrlC{})
+	r.maxBufferedMessages, r.ringBuffer = 1024, ring
+	r.prepareInputCollector()
+	defer r.runCancel()
+
+	r.inputBuf = inputBuffer(testConfigLoader{})
+	defer r.inputBuf.Close()
+
+	msg := Message{Time: time.Now(), Record: map[string]any{"log": "127.0.0.1 - - GET /index.html HTTP/1.1 200 512"}}
+	size, err := encodeRecord(defaultCodec, msg)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for p := 0; p < producers; p++ {
+			go func() {
+				for j := 0; j < records/producers; j++ {
+					r.channel <- msg
+				}
+			}()
+		}
+
+		for collected := 0; collected < records/producers*producers; {
+			buf, _, err := r.collectLogs()
+			if err != nil {
+				b.Fatal(err)
+			}
+			collected += len(buf) / len(size)
+		}
+	}
+
+	b.ReportMetric(float64(records*b.N)/b.Elapsed().Seconds(), "records/s")
+}
+
+// BenchmarkEncodeTime encodes entries without record, measuring the encoding of their time.
+func BenchmarkEncodeTime(b *testing.B) {
+	for _, c := range benchCodecs {
+		b.Run(c.name, func(b *testing.B) {
+			buf := getBuffer()
+			defer putBuffer(buf)
+
+			now := time.Now()
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				if err := encodeRecordTo(c.codec, buf, Message{Time: now.Add(time.Duration(i))}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkEncodeStrings encodes the flat string records of the strings shape, as the
+// map[string]string many inputs emit and as the equivalent map[string]any.
+func BenchmarkEncodeStrings(b *testing.B) {
+	record := benchShapes[3].record(0)
+	strs := make(map[string]string, len(record))
+	for k, v := range record {
+		strs[k] = v.(string)
+	}
+
+	for _, r := range []struct {
+		name   string
+		record any
+	}{{name: "map[string]string", record: strs}, {name: "map[string]any", record: record}} {
+		b.Run(r.name, func(b *testing.B) {
+			buf := getBuffer()
+			defer putBuffer(buf)
+
+			now := time.Now()
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				if err := encodeRecordTo(MsgpackCodec{}, buf, Message{Time: now, Record: r.record}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
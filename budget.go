@@ -0,0 +1,165 @@
+package plugin
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// cgroupRoot is the mount point of the cgroups, holding the CPU quota of the container.
+const cgroupRoot = "/sys/fs/cgroup"
+
+// WithGoroutineBudget caps the goroutines the SDK starts to run an output instance
+// concurrently: its Flush goroutines per tag and the workers decoding large chunks each
+// stay within the budget, so plugins in constrained agents do not steal CPU from fluent-bit.
+// Instances can override it with the go.GoroutineBudget config key. A zero budget means no
+// limit. It panics when used to register other kinds of plugins or with a negative budget.
+func WithGoroutineBudget(n int) RegisterOption {
+	return func(r *registration) {
+		if r.kind != outputKind {
+			panic(fmt.Sprintf("goroutine budget set on %s plugin: %q", r.kind, r.name))
+		}
+
+		if n < 0 {
+			panic(fmt.Sprintf("invalid goroutine budget %d: %q", n, r.name))
+		}
+
+		r.goroutineBudget = n
+	}
+}
+
+// goroutineBudgetConfig reads the go.GoroutineBudget config key, defaulting to the budget
+// set at registration.
+func goroutineBudgetConfig(conf ConfigLoader, budget int) int {
+	s := conf.String("go.GoroutineBudget")
+	if s == "" {
+		return budget
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		fmt.Fprintf(os.Stderr, "invalid go.GoroutineBudget %q, using %d\n", s, budget)
+		return budget
+	}
+
+	return n
+}
+
+// withinBudget returns n capped by the budget, a zero n or budget meaning no limit.
+func withinBudget(n, budget int) int {
+	if budget == 0 {
+		return n
+	}
+
+	if n == 0 {
+		return budget
+	}
+
+	return min(n, budget)
+}
+
+// maxProcsConfig reads the go.MaxProcs config key, the GOMAXPROCS of the process: a number
+// of CPUs, or auto for the CPU quota of the cgroup of the process, read from fsys. It returns
+// zero when unset, or when auto finds no quota.
+func maxProcsConfig(conf ConfigLoader, fsys fs.FS) int {
+	s := conf.String("go.MaxProcs")
+	if s == "" {
+		return 0
+	}
+
+	if s == "auto" {
+		quota, ok := cgroupCPUQuota(fsys)
+		if !ok {
+			return 0
+		}
+
+		// the quota is rounded down, so the process never runs past it.
+		return max(1, min(int(quota), runtime.NumCPU()))
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		fmt.Fprintf(os.Stderr, "invalid go.MaxProcs %q, ignored\n", s)
+		return 0
+	}
+
+	return n
+}
+
+// applyMaxProcs sets the GOMAXPROCS of the process following the go.MaxProcs config key.
+// GOMAXPROCS is shared by all the plugins of the process, the last instance setting it wins.
+func applyMaxProcs(logger Logger, conf ConfigLoader) {
+	n := maxProcsConfig(conf, os.DirFS(cgroupRoot))
+	if n == 0 {
+		return
+	}
+
+	if prev := runtime.GOMAXPROCS(n); prev != n && logger != nil {
+		logger.Info("GOMAXPROCS set to %d, was %d", n, prev)
+	}
+}
+
+// cgroupCPUQuota returns the CPU quota of the cgroup, in CPUs, from its cgroup v2 cpu.max
+// file or its cgroup v1 cpu.cfs_quota_us and cpu.cfs_period_us files. It reports false
+// without quota.
+func cgroupCPUQuota(fsys fs.FS) (float64, bool) {
+	if b, err := fs.ReadFile(fsys, "cpu.max"); err == nil {
+		// cpu.max holds the quota and the period, or max without quota.
+		fields := strings.Fields(string(b))
+		if len(fields) != 2 || fields[0] == "max" {
+			return 0, false
+		}
+
+		return cpuQuota(fields[0], fields[1])
+	}
+
+	quota, err := readFirstLine(fsys, "cpu/cpu.cfs_quota_us")
+	if err != nil {
+		return 0, false
+	}
+
+	period, err := readFirstLine(fsys, "cpu/cpu.cfs_period_us")
+	if err != nil {
+		return 0, false
+	}
+
+	return cpuQuota(quota, period)
+}
+
+// cpuQuota returns the number of CPUs of a quota over a period, both in microseconds.
+// Negative quotas mean no quota.
+func cpuQuota(quota, period string) (float64, bool) {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+
+	return float64(q) / float64(p), true
+}
+
+func readFirstLine(fsys fs.FS, name string) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	if !s.Scan() {
+		if err := s.Err(); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("%s: empty", name)
+	}
+
+	return strings.TrimSpace(s.Text()), nil
+}
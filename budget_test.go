@@ -0,0 +1,112 @@
+package plugin
+
+import (
+	"runtime"
+	"testing"
+	"testing/fstest"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestWithGoroutineBudget(t *testing.T) {
+	r := &registration{kind: outputKind, name: "dummy"}
+	WithGoroutineBudget(4)(r)
+	assert.Equal(t, 4, r.goroutineBudget)
+
+	assert.Panics(t, func() {
+		WithGoroutineBudget(4)(&registration{kind: inputKind, name: "dummy"})
+	})
+	assert.Panics(t, func() {
+		WithGoroutineBudget(-1)(&registration{kind: outputKind, name: "dummy"})
+	})
+}
+
+func TestGoroutineBudgetConfig(t *testing.T) {
+	assert.Equal(t, 4, goroutineBudgetConfig(testConfigLoader{}, 4))
+	assert.Equal(t, 2, goroutineBudgetConfig(testConfigLoader{"go.GoroutineBudget": "2"}, 4))
+	assert.Equal(t, 0, goroutineBudgetConfig(testConfigLoader{"go.GoroutineBudget": "0"}, 4))
+	assert.Equal(t, 4, goroutineBudgetConfig(testConfigLoader{"go.GoroutineBudget": "-1"}, 4))
+	assert.Equal(t, 4, goroutineBudgetConfig(testConfigLoader{"go.GoroutineBudget": "few"}, 4))
+
+	assert.Equal(t, 8, withinBudget(8, 0))
+	assert.Equal(t, 2, withinBudget(8, 2))
+	assert.Equal(t, 2, withinBudget(0, 2))
+	assert.Equal(t, 1, withinBudget(1, 2))
+}
+
+func TestOutputFlushPerTagBudget(t *testing.T) {
+	o := &outputInstance{
+		reg:             &registration{name: "test-output", flushPerTag: true},
+		output:          &testOutputCounter{ch: make(chan Message)},
+		goroutineBudget: 2,
+	}
+	assert.NoError(t, o.run())
+	defer o.stop()
+
+	// the tags share the goroutines past the budget.
+	for _, tag := range []string{"a", "b", "c", "d"} {
+		ch, _, release := o.tagChannel(tag)
+		assert.NotZero(t, ch)
+		release()
+	}
+	assert.Equal(t, 2, len(o.tagLanes))
+	assert.Equal(t, 4, len(o.tagChannels))
+}
+
+func TestCgroupCPUQuota(t *testing.T) {
+	tests := []struct {
+		name  string
+		fsys  fstest.MapFS
+		quota float64
+		ok    bool
+	}{
+		{name: "v2", fsys: fstest.MapFS{"cpu.max": {Data: []byte("150000 100000\n")}}, quota: 1.5, ok: true},
+		{name: "v2 unlimited", fsys: fstest.MapFS{"cpu.max": {Data: []byte("max 100000\n")}}},
+		{name: "v2 invalid", fsys: fstest.MapFS{"cpu.max": {Data: []byte("lots\n")}}},
+		{name: "v1", fsys: fstest.MapFS{
+			"cpu/cpu.cfs_quota_us":  {Data: []byte("200000\n")},
+			"cpu/cpu.cfs_period_us": {Data: []byte("100000\n")},
+		}, quota: 2, ok: true},
+		{name: "v1 unlimited", fsys: fstest.MapFS{
+			"cpu/cpu.cfs_quota_us":  {Data: []byte("-1\n")},
+			"cpu/cpu.cfs_period_us": {Data: []byte("100000\n")},
+		}},
+		{name: "none", fsys: fstest.MapFS{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quota, ok := cgroupCPUQuota(tt.fsys)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.quota, quota)
+		})
+	}
+}
+
+func TestMaxProcsConfig(t *testing.T) {
+	fsys := fstest.MapFS{"cpu.max": {Data: []byte("50000 100000\n")}}
+
+	assert.Equal(t, 0, maxProcsConfig(testConfigLoader{}, fsys))
+	assert.Equal(t, 3, maxProcsConfig(testConfigLoader{"go.MaxProcs": "3"}, fsys))
+	assert.Equal(t, 0, maxProcsConfig(testConfigLoader{"go.MaxProcs": "0"}, fsys))
+	assert.Equal(t, 0, maxProcsConfig(testConfigLoader{"go.MaxProcs": "all"}, fsys))
+
+	// quotas below a CPU still get one.
+	assert.Equal(t, 1, maxProcsConfig(testConfigLoader{"go.MaxProcs": "auto"}, fsys))
+	assert.Equal(t, 0, maxProcsConfig(testConfigLoader{"go.MaxProcs": "auto"}, fstest.MapFS{}))
+
+	// quotas are bounded by the CPUs of the host.
+	fsys = fstest.MapFS{"cpu.max": {Data: []byte("100000000 100000\n")}}
+	assert.Equal(t, runtime.NumCPU(), maxProcsConfig(testConfigLoader{"go.MaxProcs": "auto"}, fsys))
+}
+
+func TestApplyMaxProcs(t *testing.T) {
+	prev := runtime.GOMAXPROCS(0)
+	t.Cleanup(func() { runtime.GOMAXPROCS(prev) })
+
+	applyMaxProcs(nil, testConfigLoader{"go.MaxProcs": "2"})
+	assert.Equal(t, 2, runtime.GOMAXPROCS(0))
+
+	applyMaxProcs(nil, testConfigLoader{})
+	assert.Equal(t, 2, runtime.GOMAXPROCS(0))
+}
@@ -0,0 +1,44 @@
+package plugin
+
+import (
+	"path"
+	"runtime/debug"
+)
+
+// BuildName and BuildDescription override the name and description of the plugins
+// registered with WithBuildName, they are meant to be set at build time so a single
+// codebase can produce differently named shared libraries:
+//
+//	go build -buildmode c-shared -ldflags "-X github.com/calyptia/plugin.BuildName=out_staging" .
+var (
+	BuildName        string
+	BuildDescription string
+)
+
+// readBuildInfo is swapped by tests.
+var readBuildInfo = debug.ReadBuildInfo
+
+// WithBuildName derives the plugin name and description at build time, from the BuildName
+// and BuildDescription variables, falling back to the last element of the main package path
+// found in the build info. The values given at registration are kept when neither is available.
+// The version, when not set with WithVersion, is taken from the main module version.
+func WithBuildName() RegisterOption {
+	return func(r *registration) {
+		info, ok := readBuildInfo()
+
+		switch {
+		case BuildName != "":
+			r.name = BuildName
+		case ok && info.Path != "" && info.Path != "command-line-arguments":
+			r.name = path.Base(info.Path)
+		}
+
+		if BuildDescription != "" {
+			r.desc = BuildDescription
+		}
+
+		if r.meta.Version == "" && ok && semverRegexp.MatchString(info.Main.Version) {
+			r.meta.Version = info.Main.Version
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package plugin
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestWithBuildName(t *testing.T) {
+	defer func() {
+		BuildName, BuildDescription = "", ""
+		readBuildInfo = debug.ReadBuildInfo
+	}()
+
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{
+			Path: "github.com/calyptia/out_dummy",
+			Main: debug.Module{Path: "github.com/calyptia/out_dummy", Version: "v1.4.0"},
+		}, true
+	}
+
+	r := &registration{name: "dummy", desc: "dummy output"}
+	WithBuildName()(r)
+	assert.Equal(t, "out_dummy", r.name)
+	assert.Equal(t, "dummy output", r.desc)
+	assert.Equal(t, "v1.4.0", r.meta.Version)
+
+	BuildName, BuildDescription = "out_staging", "staging output"
+	r = &registration{name: "dummy", desc: "dummy output"}
+	WithVersion("v2.0.0")(r)
+	WithBuildName()(r)
+	assert.Equal(t, "out_staging", r.name)
+	assert.Equal(t, "staging output", r.desc)
+	assert.Equal(t, "v2.0.0", r.meta.Version)
+
+	BuildName, BuildDescription = "", ""
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{Main: debug.Module{Version: "(devel)"}}, true
+	}
+	r = &registration{name: "dummy", desc: "dummy output"}
+	WithBuildName()(r)
+	assert.Equal(t, "dummy", r.name)
+	assert.Equal(t, "", r.meta.Version)
+}
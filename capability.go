@@ -0,0 +1,79 @@
+package plugin
+
+import (
+	"sync/atomic"
+)
+
+// APIVersion is the version of the plugin ABI implemented by this SDK, it is
+// bumped whenever the exported callbacks or the proxy definition change.
+const APIVersion = 2
+
+// Feature is an optional capability of the plugin ABI.
+type Feature uint32
+
+const (
+	// FeatureEventType is the event_type field of the output proxy definition,
+	// required by the metrics and traces outputs.
+	FeatureEventType Feature = 1 << iota
+	// FeatureMetricsInput is the FLBPluginInputMetricsCallback input callback.
+	FeatureMetricsInput
+	// FeatureTracesInput is the FLBPluginInputTracesCallback input callback.
+	FeatureTracesInput
+	// FeatureFlushCtx is the FLBPluginFlushCtx output callback, required to
+	// configure an output multiple times.
+	FeatureFlushCtx
+	// FeatureReload is the FLBPluginReload callback.
+	FeatureReload
+	// FeatureHealth is the FLBPluginHealth callback.
+	FeatureHealth
+	// FeatureServiceConfig is the FLBPluginServiceConfig callback.
+	FeatureServiceConfig
+	// FeatureInstanceInfo is the FLBPluginInstanceInfo callback.
+	FeatureInstanceInfo
+	// FeatureCollectInterval is the FLBPluginInputCollectInterval callback, hosts
+	// supporting it schedule the input callbacks at the returned interval.
+	FeatureCollectInterval
+	// FeatureInputDone is the FLBPluginInputDone callback, hosts supporting it
+	// stop one-shot inputs once they are done.
+	FeatureInputDone
+	// FeatureOutputPause is the FLBPluginOutputPause and FLBPluginOutputResume
+	// callbacks, and their Ctx variants.
+	FeatureOutputPause
+)
+
+// sdkFeatures are the features implemented by this SDK.
+const sdkFeatures = FeatureEventType | FeatureMetricsInput | FeatureTracesInput |
+	FeatureFlushCtx | FeatureReload | FeatureHealth | FeatureServiceConfig |
+	FeatureInstanceInfo | FeatureCollectInterval | FeatureInputDone | FeatureOutputPause
+
+// hostFeatures are the features announced by fluent-bit during the handshake, handshake
+// being set once it happened. The features of hosts not performing the handshake are
+// unknown.
+var (
+	hostFeatures atomic.Uint32
+	handshake    atomic.Bool
+)
+
+// HostSupports reports whether both fluent-bit and the SDK support the given features,
+// plugins can use it to disable functionality not available on older fluent-bit versions.
+// It reports false when fluent-bit did not perform the handshake, its features being
+// unknown.
+func HostSupports(f Feature) bool {
+	return Feature(hostFeatures.Load())&sdkFeatures&f == f
+}
+
+// hostLacks reports whether fluent-bit performed the handshake without announcing the
+// given features. The SDK only disables the features fluent-bit lacks, hosts not
+// performing the handshake being assumed to support them, as before the handshake.
+// Features writing to the memory of fluent-bit must check HostSupports instead.
+func hostLacks(f Feature) bool {
+	return handshake.Load() && !HostSupports(f)
+}
+
+// negotiate records the features announced by fluent-bit, it returns the features
+// both sides support.
+func negotiate(features Feature) Feature {
+	hostFeatures.Store(uint32(features))
+	handshake.Store(true)
+	return features & sdkFeatures
+}
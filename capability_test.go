@@ -0,0 +1,67 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/calyptia/plugin/output"
+)
+
+func TestNegotiate(t *testing.T) {
+	defer func() {
+		hostFeatures.Store(0)
+		handshake.Store(false)
+	}()
+
+	// the features of hosts not performing the handshake are unknown.
+	assert.False(t, HostSupports(FeatureEventType))
+	assert.False(t, hostLacks(FeatureEventType))
+
+	const unknownFeature Feature = 1 << 31
+	assert.Equal(t, FeatureEventType|FeatureReload, negotiate(FeatureEventType|FeatureReload|unknownFeature))
+	assert.True(t, HostSupports(FeatureEventType))
+	assert.True(t, HostSupports(FeatureEventType|FeatureReload))
+	assert.False(t, HostSupports(FeatureHealth))
+	assert.False(t, HostSupports(unknownFeature))
+	assert.False(t, hostLacks(FeatureEventType))
+	assert.True(t, hostLacks(FeatureHealth))
+}
+
+func TestRegisterEventTypes(t *testing.T) {
+	defer func() {
+		hostFeatures.Store(0)
+		handshake.Store(false)
+	}()
+
+	logs := &registration{name: "dummy", output: &testOutputCounter{}}
+	metrics := &registration{name: "dummy", metricsOutput: &testMetricsOutput{}}
+	both := &registration{name: "dummy", output: &testLogsAndMetricsOutput{}}
+
+	// the event types are only set once fluent-bit announced them.
+	for _, features := range []Feature{0, FeatureReload} {
+		if features != 0 {
+			negotiate(features)
+		}
+
+		types, err := logs.registerEventTypes()
+		assert.NoError(t, err)
+		assert.Equal(t, output.FLB_OUTPUT_LOGS, types)
+
+		_, err = metrics.registerEventTypes()
+		assert.EqualError(t, err, `name="dummy": fluent-bit does not support metrics and traces outputs`)
+
+		types, err = both.registerEventTypes()
+		assert.NoError(t, err)
+		assert.Equal(t, output.FLB_OUTPUT_LOGS, types)
+	}
+
+	negotiate(FeatureEventType)
+	types, err := metrics.registerEventTypes()
+	assert.NoError(t, err)
+	assert.Equal(t, output.FLB_OUTPUT_METRICS, types)
+
+	types, err = both.registerEventTypes()
+	assert.NoError(t, err)
+	assert.Equal(t, output.FLB_OUTPUT_LOGS|output.FLB_OUTPUT_METRICS, types)
+}
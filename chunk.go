@@ -0,0 +1,98 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Chunk struct to store a fluent-bit chunk as it was delivered to the output flush callback.
+// A chunk groups all the messages sharing a tag that fluent-bit flushes at once.
+type Chunk struct {
+	Tag string
+	// Records is the number of records decoded from the chunk.
+	Records int
+	// Size is the size in bytes of the msgpack encoded chunk.
+	Size int
+	// Worker is the id of the fluent-bit output worker flushing the chunk,
+	// see Message.Worker.
+	Worker   int
+	Messages []Message
+}
+
+// ChunkOutputPlugin interface to represent an output fluent-bit plugin that
+// processes a whole chunk per flush instead of a stream of messages.
+// It allows plugins to implement chunk-level batching and acknowledgment:
+// the chunk is only acknowledged to fluent-bit once Flush returns without error,
+// returning ErrRetry makes fluent-bit retry it later.
+// Flush is invoked concurrently when the output is configured with workers.
+type ChunkOutputPlugin interface {
+	Init(ctx context.Context, fbit *Fluentbit) error
+	Flush(ctx context.Context, chunk Chunk) error
+}
+
+// RegisterChunkOutput plugin.
+// A single output plugin can be registered per shared library.
+func RegisterChunkOutput(name, desc string, out ChunkOutputPlugin, opts ...RegisterOption) {
+	register(&registration{
+		kind:        outputKind,
+		name:        name,
+		desc:        desc,
+		chunkOutput: out,
+	}, opts...)
+}
+
+// copyChunksConfig reads the go.CopyChunks config key.
+func copyChunksConfig(conf ConfigLoader) bool {
+	enabled, err := BoolFromConf(conf, "go.CopyChunks", false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, chunks not copied\n", err)
+	}
+
+	return enabled
+}
+
+// decodeChunk decodes all the messages contained in a msgpack encoded chunk.
+func (o *outputInstance) decodeChunk(tag string, b []byte) (Chunk, error) {
+	chunk := Chunk{
+		Tag:  tag,
+		Size: len(b),
+	}
+
+	dec, wait := o.chunkDecoder(b)
+	defer wait()
+
+	for {
+		msg, err := decodeMessage(dec, &tag)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return chunk, err
+		}
+
+		o.loan(&msg)
+		chunk.Messages = append(chunk.Messages, msg)
+	}
+
+	chunk.Records = len(chunk.Messages)
+
+	return chunk, nil
+}
+
+func (o *outputInstance) pluginFlushChunk(ctx context.Context, worker int, tag string, b []byte) error {
+	chunk, err := o.decodeChunk(tag, b)
+	if err != nil {
+		return err
+	}
+
+	chunk.Worker = worker
+	for i := range chunk.Messages {
+		chunk.Messages[i].worker = worker
+	}
+
+	return o.chunkOutput.Flush(ctx, chunk)
+}
@@ -0,0 +1,104 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/calyptia/plugin/output"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type testChunkOutput struct {
+	chunks []Chunk
+}
+
+func (t *testChunkOutput) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (t *testChunkOutput) Flush(ctx context.Context, chunk Chunk) error {
+	t.chunks = append(t.chunks, chunk)
+	return nil
+}
+
+func TestChunkOutputFlush(t *testing.T) {
+	now := time.Now().UTC()
+
+	var b []byte
+	for i := 0; i < 3; i++ {
+		rec, err := msgpack.Marshal([]any{
+			&EventTime{now},
+			map[string]any{"idx": i},
+		})
+		assert.NoError(t, err)
+		b = append(b, rec...)
+	}
+
+	out := &testChunkOutput{}
+	o := &outputInstance{chunkOutput: out}
+
+	assert.NoError(t, o.pluginFlushChunk(context.Background(), 0, "foobar", b))
+	assert.Equal(t, 1, len(out.chunks))
+
+	chunk := out.chunks[0]
+	assert.Equal(t, "foobar", chunk.Tag)
+	assert.Equal(t, 3, chunk.Records)
+	assert.Equal(t, len(b), chunk.Size)
+	assert.Equal(t, 3, len(chunk.Messages))
+
+	for i, msg := range chunk.Messages {
+		assert.Equal(t, now, msg.Time)
+		assert.Equal(t, "foobar", msg.Tag())
+
+		record := assertType[map[string]any](t, msg.Record)
+		assert.Equal(t, int8(i), assertType[int8](t, record["idx"]))
+	}
+}
+
+func TestFlushBorrowedChunk(t *testing.T) {
+	defer resetRegistry()
+
+	now := time.Now().UTC()
+	record := map[string]any{
+		"log":        "GET /index.html HTTP/1.1",
+		"raw":        []byte("raw bytes"),
+		"time":       &EventTime{now.Local()},
+		"kubernetes": map[string]any{"labels": []any{"app", "web"}},
+	}
+
+	b, err := encodeMessages(defaultCodec, nil, []Message{{Time: now, Record: record}, {Time: now, Record: record}})
+	assert.NoError(t, err)
+
+	for _, copyChunks := range []bool{false, true} {
+		t.Run(fmt.Sprintf("copy=%t", copyChunks), func(t *testing.T) {
+			out := &testChunkOutput{}
+			resetRegistry()
+			RegisterChunkOutput("test-chunk-output", "", out)
+			o := registrationOf(outputKind).newOutputInstance()
+			o.copyChunks = copyChunks
+			FLBPluginOutputPreRun(0)
+
+			assert.Equal(t, output.FLB_OK, testFlush(o, "foobar", b))
+			assert.Equal(t, 1, len(out.chunks))
+
+			// the records outlive the memory of the chunk, overwritten once flushed.
+			chunk := out.chunks[0]
+			assert.Equal(t, "foobar", chunk.Tag)
+			assert.Equal(t, 2, len(chunk.Messages))
+			for _, msg := range chunk.Messages {
+				assert.Equal(t, now, msg.Time)
+				assert.Equal(t, "foobar", msg.Tag())
+				assert.Equal(t, record, msg.Record.(map[string]any))
+			}
+		})
+	}
+}
+
+func TestCopyChunksConfig(t *testing.T) {
+	assert.False(t, copyChunksConfig(testConfigLoader{}))
+	assert.True(t, copyChunksConfig(testConfigLoader{"go.CopyChunks": "true"}))
+	assert.False(t, copyChunksConfig(testConfigLoader{"go.CopyChunks": "maybe"}))
+}
@@ -0,0 +1,48 @@
+package plugin
+
+import "time"
+
+// clock is the time source of the SDK timers and timestamps: the collect waits and
+// schedules, the restart backoffs, the watchdogs, health checks and idle inputs.
+// Tests replace it with a fake clock to control time.
+type clock interface {
+	Now() time.Time
+	// NewTimer fires once after d.
+	NewTimer(d time.Duration) clockTimer
+	// NewTicker fires every d, dropping the ticks of slow receivers.
+	NewTicker(d time.Duration) clockTimer
+}
+
+// clockTimer is a timer or ticker of a clock.
+type clockTimer interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// sdkClock is the clock of the SDK.
+var sdkClock clock = realClock{}
+
+// realClock is the clock of the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) clockTimer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) clockTimer {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTimer struct{ t *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.t.C }
+func (t realTimer) Stop()               { t.t.Stop() }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
@@ -0,0 +1,198 @@
+package plugin
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+// useFakeClock replaces the clock of the SDK for the duration of the test.
+func useFakeClock(t *testing.T) *fakeClock {
+	t.Helper()
+
+	c := newFakeClock(time.Date(2024, time.January, 10, 10, 30, 0, 0, time.UTC))
+	prev := sdkClock
+	sdkClock = c
+	t.Cleanup(func() { sdkClock = prev })
+
+	return c
+}
+
+// fakeClock is a clock only moving forward when advanced, firing the timers
+// and tickers due by then.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+type fakeTimer struct {
+	c      chan time.Time
+	at     time.Time
+	period time.Duration
+	clock  *fakeClock
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) clockTimer {
+	return c.add(d, 0)
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) clockTimer {
+	return c.add(d, d)
+}
+
+func (c *fakeClock) add(d, period time.Duration) *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{c: make(chan time.Time, 1), at: c.now.Add(d), period: period, clock: c}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// timerCount returns the number of timers and tickers not yet fired or stopped,
+// so tests can wait for a goroutine to be waiting on the clock.
+func (c *fakeClock) timerCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// Advance moves the clock forward by d, firing the timers and tickers due
+// in order, tickers once per period elapsed.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.timers, func(i, j int) bool {
+			return c.timers[i].at.Before(c.timers[j].at)
+		})
+		if len(c.timers) == 0 || c.timers[0].at.After(end) {
+			break
+		}
+
+		t := c.timers[0]
+		c.now = t.at
+		select {
+		case t.c <- c.now:
+		default:
+		}
+
+		if t.period > 0 {
+			t.at = t.at.Add(t.period)
+			continue
+		}
+		c.timers = c.timers[1:]
+	}
+
+	c.now = end
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return
+		}
+	}
+}
+
+// awaitTimers waits for n timers to be set on the clock by other goroutines.
+func (c *fakeClock) awaitTimers(t *testing.T, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for c.timerCount() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d timers set, expected %d", c.timerCount(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	c := newFakeClock(time.Unix(0, 0))
+	timer := c.NewTimer(time.Second)
+	ticker := c.NewTicker(400 * time.Millisecond)
+
+	c.Advance(500 * time.Millisecond)
+	assert.Equal(t, time.Unix(0, 0).Add(400*time.Millisecond), <-ticker.C())
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	c.Advance(500 * time.Millisecond)
+	assert.Equal(t, time.Unix(1, 0), <-timer.C())
+	assert.Equal(t, time.Unix(0, 0).Add(800*time.Millisecond), <-ticker.C())
+	assert.Equal(t, time.Unix(1, 0), c.Now())
+
+	ticker.Stop()
+	assert.Equal(t, 0, c.timerCount())
+}
+
+func TestWatchdogFakeClock(t *testing.T) {
+	c := useFakeClock(t)
+
+	stalls := make(chan struct{}, 1)
+	w := &watchdog{
+		name:     "dummy",
+		callback: "collect",
+		timeout:  time.Minute,
+		onStall:  func() { stalls <- struct{}{} },
+	}
+	w.progress()
+	w.start()
+	defer w.stop()
+
+	// the watchdog sleeps until a goroutine gets busy.
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 0, c.timerCount())
+
+	w.begin()
+	c.awaitTimers(t, 1)
+	c.Advance(30 * time.Second)
+	select {
+	case <-stalls:
+		t.Fatal("stall reported before the timeout")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	c.Advance(30 * time.Second)
+	<-stalls
+
+	// and sleeps again once no goroutine is busy.
+	w.end()
+	c.Advance(30 * time.Second)
+	deadline := time.Now().Add(time.Second)
+	for c.timerCount() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("watchdog still ticking while idle")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
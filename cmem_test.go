@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/calyptia/plugin/input"
+	"github.com/calyptia/plugin/internal/cmem"
+)
+
+// checkLeaks fails the test when C memory allocated during the test was neither
+// handed over to fluent-bit nor freed.
+func checkLeaks(t *testing.T) {
+	t.Helper()
+
+	before := cmem.Outstanding()
+	t.Cleanup(func() {
+		assert.Equal(t, before, cmem.Outstanding(), "C memory leaked")
+	})
+}
+
+func TestInputCallbackMemory(t *testing.T) {
+	checkLeaks(t)
+
+	r := prepareInput(testPluginInputCallbackCtrlC{})
+	r.prepareInputCollector()
+	defer r.runCancel()
+
+	r.channel <- Message{Time: time.Now(), Record: map[string]string{"Foo": "BAR"}}
+
+	b, err := testFLBPluginInputCallback()
+	assert.NoError(t, err)
+	assert.NotZero(t, len(b))
+}
+
+func TestSetOutBufferMemory(t *testing.T) {
+	checkLeaks(t)
+
+	buf, size, err := testSetOutBuffer([]byte("foobar"))
+	assert.NoError(t, err)
+	assert.Equal(t, "foobar", string(cmem.Copy(buf, size)))
+
+	// fluent-bit frees the memory handed over.
+	testFree(buf)
+
+	buf, size, err = testSetOutBuffer(nil)
+	assert.NoError(t, err)
+	assert.Zero(t, buf)
+	assert.Zero(t, size)
+}
+
+func TestInputCallbackReusesBuffer(t *testing.T) {
+	checkLeaks(t)
+
+	r := prepareInput(testPluginInputCallbackCtrlC{})
+	r.inputBuf = inputBuffer(testConfigLoader{"go.InputBufferSize": "1k"})
+	defer r.inputBuf.Close()
+	r.prepareInputCollector()
+	defer r.runCancel()
+
+	var ptrs []unsafe.Pointer
+	for range 2 {
+		r.channel <- Message{Time: time.Now(), Record: map[string]string{"Foo": "BAR"}}
+
+		var ptr unsafe.Pointer
+		assert.Equal(t, input.FLB_OK, FLBPluginInputCallback(&ptr, nil))
+		assert.NotZero(t, ptr)
+		ptrs = append(ptrs, ptr)
+		FLBPluginInputCleanupCallback(ptr)
+	}
+	assert.Equal(t, ptrs[0], ptrs[1])
+
+	assert.Zero(t, inputBuffer(testConfigLoader{"go.InputBufferSize": "0"}))
+}
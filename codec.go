@@ -0,0 +1,175 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// Codec encodes the records handed to fluent-bit by inputs, filters and processors, and
+// decodes the chunks fluent-bit hands to outputs, filters and processors. Plugins trading
+// compatibility for performance can implement it, e.g. with code generated by tinylib/msgp
+// for their record types, and register it with WithCodec.
+type Codec interface {
+	// Encode encodes a record and its time as a fluent-bit entry: [time, record], the time
+	// being an EventTime extension.
+	Encode(t time.Time, record any) ([]byte, error)
+	// Decode returns a decoder of the entries of a chunk. Flushed chunks are read straight
+	// from the memory of fluent-bit, released once decoded, so the records must not
+	// reference the chunk.
+	Decode(chunk []byte) RecordDecoder
+}
+
+// RecordDecoder decodes the entries of a chunk, one at a time.
+type RecordDecoder interface {
+	// Next decodes the next entry, it returns io.EOF after the last one.
+	Next() (time.Time, map[string]any, error)
+}
+
+// WithCodec sets the codec of the records of the plugin, overriding the default codec
+// selected at build time: MsgpackCodec, or UgorjiCodec when built with the ugorji tag.
+func WithCodec(c Codec) RegisterOption {
+	return func(r *registration) {
+		if c == nil {
+			panic(fmt.Sprintf("invalid codec %v: %q", c, r.name))
+		}
+
+		r.codec = c
+	}
+}
+
+// recordCodec returns the codec of the plugin, the default one unless set.
+func (r *registration) recordCodec() Codec {
+	if r == nil || r.codec == nil {
+		return defaultCodec
+	}
+
+	return r.codec
+}
+
+// MsgpackCodec is the codec of the vmihailenco/msgpack package. Records are decoded
+// into the types msgpack.Unmarshal produces for a map[string]any, by a reader skipping
+// its reflection. It is the default codec.
+type MsgpackCodec struct{}
+
+func (c MsgpackCodec) Encode(t time.Time, record any) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := c.encodeTo(buf, t, record); err != nil {
+		return nil, err
+	}
+
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// encodeTo appends the entry to buf with an encoder from the msgpack pool, the entry
+// array and its time being written directly.
+func (MsgpackCodec) encodeTo(buf *bytes.Buffer, t time.Time, record any) error {
+	start := buf.Len()
+
+	var head [3 + eventTimeBytesLen]byte
+	head[0], head[1] = msgpcode.FixedArrayLow|2, msgpcode.FixExt8
+	binary.BigEndian.PutUint32(head[3:], uint32(t.Unix()))
+	binary.BigEndian.PutUint32(head[7:], uint32(t.Nanosecond()))
+	buf.Write(head[:])
+
+	enc := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(enc)
+
+	enc.Reset(buf)
+	if err := encodeValue(enc, record); err != nil {
+		buf.Truncate(start)
+		return err
+	}
+
+	return nil
+}
+
+// encodeValue encodes a record, writing the flat string records directly instead of going
+// through the reflection of the encoder.
+func encodeValue(enc *msgpack.Encoder, record any) error {
+	m, ok := record.(map[string]string)
+	if !ok {
+		return enc.Encode(record)
+	}
+
+	if m == nil {
+		return enc.EncodeNil()
+	}
+
+	if err := enc.EncodeMapLen(len(m)); err != nil {
+		return err
+	}
+
+	for k, v := range m {
+		if err := enc.EncodeString(k); err != nil {
+			return err
+		}
+
+		if err := enc.EncodeString(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (MsgpackCodec) Decode(chunk []byte) RecordDecoder {
+	return &recordReader{b: chunk}
+}
+
+// newRecordDecoder returns a decoder of the entries of a chunk, the msgpack reader
+// interning the record keys in keys, and reusing the records of the pool when pooled.
+func newRecordDecoder(c Codec, keys *keyCache, b []byte, pooled bool) RecordDecoder {
+	dec := c.Decode(b)
+	if rd, ok := dec.(*recordReader); ok {
+		rd.keys, rd.pooled = keys, pooled
+	}
+
+	return dec
+}
+
+// encodeRecord encodes a message as a fluent-bit record.
+func encodeRecord(c Codec, msg Message) ([]byte, error) {
+	return c.Encode(msg.Time, msg.Record)
+}
+
+// encodeRecordTo appends a message encoded as a fluent-bit record to buf, without
+// intermediate copies for the msgpack codec.
+func encodeRecordTo(c Codec, buf *bytes.Buffer, msg Message) error {
+	if c, ok := c.(MsgpackCodec); ok {
+		return c.encodeTo(buf, msg.Time, msg.Record)
+	}
+
+	b, err := c.Encode(msg.Time, msg.Record)
+	if err != nil {
+		return err
+	}
+
+	buf.Write(b)
+	return nil
+}
+
+// decodeMessage decodes the next message of a chunk, it returns io.EOF after the last one.
+func decodeMessage(dec RecordDecoder, tag *string) (Message, error) {
+	if rd, ok := dec.(*recordReader); ok && rd.lazy {
+		t, record, err := rd.nextLazy()
+		if err != nil {
+			return Message{}, err
+		}
+
+		return Message{Time: t.UTC(), Record: record, tag: tag}, nil
+	}
+
+	t, record, err := dec.Next()
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{Time: t.UTC(), Record: record, tag: tag}, nil
+}
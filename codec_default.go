@@ -0,0 +1,7 @@
+//go:build !ugorji
+
+package plugin
+
+// defaultCodec is the codec of the plugins registered without WithCodec,
+// build with the ugorji tag to use UgorjiCodec instead.
+var defaultCodec Codec = MsgpackCodec{}
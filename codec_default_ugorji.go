@@ -0,0 +1,6 @@
+//go:build ugorji
+
+package plugin
+
+// defaultCodec is the codec of the plugins registered without WithCodec.
+var defaultCodec Codec = UgorjiCodec{}
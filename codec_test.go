@@ -0,0 +1,161 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestWithCodec(t *testing.T) {
+	r := &registration{kind: filterKind, name: "dummy"}
+	assert.Equal(t, defaultCodec, r.recordCodec())
+
+	WithCodec(UgorjiCodec{})(r)
+	assert.Equal[Codec](t, UgorjiCodec{}, r.recordCodec())
+
+	assert.Panics(t, func() {
+		WithCodec(nil)(&registration{kind: filterKind, name: "dummy"})
+	})
+}
+
+func testDecodeCodec(t *testing.T, c Codec, b []byte) []Message {
+	t.Helper()
+
+	tag := "tag"
+	dec := c.Decode(b)
+
+	var msgs []Message
+	for {
+		msg, err := decodeMessage(dec, &tag)
+		if errors.Is(err, io.EOF) {
+			return msgs
+		}
+		assert.NoError(t, err)
+		msgs = append(msgs, msg)
+	}
+}
+
+func TestUgorjiCodec(t *testing.T) {
+	now := time.Unix(1700000000, 123456789).UTC()
+
+	b, err := encodeMessages(UgorjiCodec{}, nil, []Message{
+		{Time: now, Record: map[string]any{"msg": "hello", "count": 3, "delta": -3, "ratio": 0.5}},
+		{Time: now, Record: struct {
+			Level string `codec:"level"`
+		}{Level: "info"}},
+	})
+	assert.NoError(t, err)
+
+	// records encoded by the ugorji codec are read by fluent-bit as any other.
+	msgs := testDecodeCodec(t, MsgpackCodec{}, b)
+	assert.Equal(t, 2, len(msgs))
+	assert.Equal(t, now, msgs[0].Time)
+	assert.Equal(t, map[string]any{"msg": "hello", "count": int8(3), "delta": int8(-3), "ratio": 0.5}, msgs[0].Record.(map[string]any))
+	assert.Equal(t, map[string]any{"level": "info"}, msgs[1].Record.(map[string]any))
+
+	msgs = testDecodeCodec(t, UgorjiCodec{}, b)
+	assert.Equal(t, 2, len(msgs))
+	assert.Equal(t, now, msgs[0].Time)
+	assert.Equal(t, "tag", msgs[0].Tag())
+	assert.Equal(t, map[string]any{"msg": "hello", "count": int64(3), "delta": int64(-3), "ratio": 0.5}, msgs[0].Record.(map[string]any))
+	assert.Equal(t, map[string]any{"level": "info"}, msgs[1].Record.(map[string]any))
+
+	// and it reads the records encoded by the msgpack codec.
+	b, err = encodeMessages(MsgpackCodec{}, nil, []Message{{Time: now, Record: map[string]any{"nested": map[string]any{"ok": true}}}})
+	assert.NoError(t, err)
+
+	msgs = testDecodeCodec(t, UgorjiCodec{}, b)
+	assert.Equal(t, 1, len(msgs))
+	assert.Equal(t, map[string]any{"nested": map[string]any{"ok": true}}, msgs[0].Record.(map[string]any))
+
+	_, _, err = UgorjiCodec{}.Decode([]byte{0x92, 0x01, 0x80}).Next()
+	assert.Error(t, err)
+}
+
+// testCodec records its use, wrapping the default codec.
+type testCodec struct {
+	Codec
+	encoded, decoded int
+}
+
+func (c *testCodec) Encode(t time.Time, record any) ([]byte, error) {
+	c.encoded++
+	return c.Codec.Encode(t, record)
+}
+
+func (c *testCodec) Decode(chunk []byte) RecordDecoder {
+	c.decoded++
+	return c.Codec.Decode(chunk)
+}
+
+func TestFilterCodec(t *testing.T) {
+	c := &testCodec{Codec: defaultCodec}
+	r := &registration{kind: filterKind, name: "dummy", filter: testFilterDropOdd{}}
+	WithCodec(c)(r)
+
+	in, err := encodeMessages(defaultCodec, nil, []Message{
+		{Time: time.Now(), Record: map[string]any{"idx": 0}},
+		{Time: time.Now(), Record: map[string]any{"idx": 1}},
+	})
+	assert.NoError(t, err)
+
+	_, err = r.pluginFilter(context.Background(), "foobar", in, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, c.decoded)
+	assert.Equal(t, 1, c.encoded)
+}
+
+func TestEncodeStringRecords(t *testing.T) {
+	now := time.Unix(1700000000, 1).UTC()
+
+	// the flat string records are encoded as the maps of the same fields.
+	for _, record := range []map[string]string{{"log": "hello", "stream": "stdout", "empty": ""}, {}, nil} {
+		want, err := msgpack.Marshal([]any{&EventTime{now}, record})
+		assert.NoError(t, err)
+
+		got, err := MsgpackCodec{}.Encode(now, record)
+		assert.NoError(t, err)
+		assert.Equal(t, testDecodeCodec(t, MsgpackCodec{}, want), testDecodeCodec(t, MsgpackCodec{}, got))
+	}
+}
+
+func TestEncodeEventTime(t *testing.T) {
+	// consecutive records around second boundaries, in one chunk.
+	times := []time.Time{
+		time.Unix(1699999999, 999999999),
+		time.Unix(1700000000, 0),
+		time.Unix(1700000000, 0),
+		time.Unix(1700000000, 1),
+		time.Unix(1700000000, 999999999),
+		time.Unix(1700000001, 0),
+		time.Unix(0, 0),
+	}
+
+	msgs := make([]Message, len(times))
+	for i, ts := range times {
+		msgs[i] = Message{Time: ts, Record: map[string]any{"i": i}}
+	}
+
+	for _, c := range []Codec{MsgpackCodec{}, UgorjiCodec{}} {
+		b, err := encodeMessages(c, nil, msgs)
+		assert.NoError(t, err)
+
+		decoded := testDecodeCodec(t, MsgpackCodec{}, b)
+		assert.Equal(t, len(times), len(decoded))
+		for i, msg := range decoded {
+			assert.Equal(t, times[i].UTC(), msg.Time)
+		}
+	}
+
+	// the header written in place matches the EventTime extension.
+	want, err := msgpack.Marshal(&EventTime{times[0]})
+	assert.NoError(t, err)
+	b, err := MsgpackCodec{}.Encode(times[0], nil)
+	assert.NoError(t, err)
+	assert.Equal(t, want, b[1:1+len(want)])
+}
@@ -0,0 +1,96 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/ugorji/go/codec"
+)
+
+// UgorjiCodec is the codec of the ugorji/go package, the one of the input and output
+// packages. Records are decoded as by output.GetRecord, with string keys: integers are
+// decoded as int64, or uint64 when encoded unsigned, and floats as float64. Struct records
+// are encoded following their codec and json tags.
+type UgorjiCodec struct{}
+
+var ugorjiHandle = newUgorjiHandle()
+
+func newUgorjiHandle() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.RawToString = true
+	h.MapType = reflect.TypeOf(map[string]any(nil))
+	if err := h.SetBytesExt(reflect.TypeOf(EventTime{}), 0, ugorjiEventTime{}); err != nil {
+		panic(err)
+	}
+
+	return h
+}
+
+func (UgorjiCodec) Encode(t time.Time, record any) ([]byte, error) {
+	switch r := record.(type) {
+	case SchemaRecord:
+		record = r.Map()
+	case *LazyRecord:
+		m, err := r.Map()
+		if err != nil {
+			return nil, err
+		}
+		record = m
+	}
+
+	var b []byte
+	err := codec.NewEncoderBytes(&b, ugorjiHandle).Encode([]any{EventTime{t}, record})
+	return b, err
+}
+
+func (UgorjiCodec) Decode(chunk []byte) RecordDecoder {
+	return &ugorjiDecoder{dec: codec.NewDecoderBytes(chunk, ugorjiHandle), size: len(chunk)}
+}
+
+type ugorjiDecoder struct {
+	dec  *codec.Decoder
+	size int
+}
+
+func (d *ugorjiDecoder) Next() (time.Time, map[string]any, error) {
+	// the decoder reports the end of the chunk as unexpected.
+	if d.dec.NumBytesRead() >= d.size {
+		return time.Time{}, nil, io.EOF
+	}
+
+	var entry []any
+	if err := d.dec.Decode(&entry); err != nil {
+		return time.Time{}, nil, fmt.Errorf("msgpack unmarshal: %w", err)
+	}
+
+	if l := len(entry); l < 2 {
+		return time.Time{}, nil, fmt.Errorf("msgpack unmarshal: expected 2 elements, got %d", l)
+	}
+
+	ts, ok := entry[0].(EventTime)
+	if !ok {
+		return time.Time{}, nil, fmt.Errorf("msgpack unmarshal event time: unexpected %T", entry[0])
+	}
+
+	record, ok := entry[1].(map[string]any)
+	if !ok && entry[1] != nil {
+		return time.Time{}, nil, fmt.Errorf("msgpack unmarshal event record: unexpected %T", entry[1])
+	}
+
+	return ts.Time, record, nil
+}
+
+// ugorjiEventTime is the ugorji extension of EventTime.
+type ugorjiEventTime struct{}
+
+func (ugorjiEventTime) WriteExt(v any) []byte {
+	b, _ := v.(*EventTime).MarshalMsgpack()
+	return b
+}
+
+func (ugorjiEventTime) ReadExt(dst any, src []byte) {
+	// invalid times are left zero, ReadExt can't fail.
+	_ = dst.(*EventTime).UnmarshalMsgpack(src)
+}
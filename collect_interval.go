@@ -0,0 +1,93 @@
+package plugin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/calyptia/plugin/flbconf"
+)
+
+// defaultCollectInterval is the interval between the collect callbacks of inputs,
+// it can be changed with WithCollectInterval or the collect_interval config key.
+const defaultCollectInterval = time.Second
+
+// WithCollectInterval sets the default interval between the collect callbacks of the input,
+// instances can override it with the collect_interval config key. It panics when used to
+// register other kinds of plugins or with a non positive interval.
+func WithCollectInterval(d time.Duration) RegisterOption {
+	return func(r *registration) {
+		if r.kind != inputKind {
+			panic(fmt.Sprintf("collect interval set on %s plugin: %q", r.kind, r.name))
+		}
+
+		if d <= 0 {
+			panic(fmt.Sprintf("invalid collect interval %s: %q", d, r.name))
+		}
+
+		r.collectInterval = d
+	}
+}
+
+// collectIntervalFromConf reads the collect_interval config key.
+func collectIntervalFromConf(conf ConfigLoader, def time.Duration) (time.Duration, error) {
+	s := conf.String("collect_interval")
+	if s == "" {
+		return def, nil
+	}
+
+	d, err := flbconf.ParseTime(s)
+	if err != nil {
+		return 0, fmt.Errorf("collect_interval: %w", err)
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("collect_interval: must be positive: %q", s)
+	}
+
+	return d, nil
+}
+
+// awaitInput waits for the next message of an input without data, up to the collect
+// interval, so records are delivered as soon as they are collected. Paused and idle
+// inputs wait as well, rather than spinning through the callbacks until resumed. The
+// inputs running on the thread of the engine only wait when fluent-bit did not announce
+// FeatureCollectInterval, as it then invokes their callbacks continuously, else it
+// schedules them at the collect interval.
+func (r *registration) awaitInput() (Message, bool) {
+	if r.runCtx == nil || r.bufferedInput() > 0 {
+		return Message{}, false
+	}
+	if !r.inputThreaded && HostSupports(FeatureCollectInterval) {
+		return Message{}, false
+	}
+
+	timer := sdkClock.NewTimer(r.inputInterval)
+	defer timer.Stop()
+
+	// the collect goroutines of paused and idle inputs are cancelled, they get no
+	// records until resumed.
+	ch, done := r.channel, r.runCtx.Done()
+	var ready chan struct{}
+	if r.ring != nil {
+		ch, ready = nil, r.ring.ready
+	}
+	if r.runCtx.Err() != nil {
+		ch, ready, done = nil, nil, nil
+	}
+
+	for {
+		select {
+		case msg, ok := <-ch:
+			return msg, ok
+		case <-ready:
+			// the signal of a record already taken is stale.
+			if msg, ok := r.ring.pop(); ok {
+				return msg, true
+			}
+		case <-done:
+			return Message{}, false
+		case <-timer.C():
+			return Message{}, false
+		}
+	}
+}
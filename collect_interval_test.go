@@ -0,0 +1,145 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestWithCollectInterval(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	RegisterInput("dummy-input", "", testPluginInputCallbackCtrlC{})
+	assert.Equal(t, defaultCollectInterval, registrationOf(inputKind).collectInterval)
+
+	resetRegistry()
+	RegisterInput("dummy-input", "", testPluginInputCallbackCtrlC{}, WithCollectInterval(time.Minute))
+	assert.Equal(t, time.Minute, registrationOf(inputKind).collectInterval)
+
+	assert.Panics(t, func() {
+		RegisterOutput("dummy-output", "", &testOutputCounter{}, WithCollectInterval(time.Minute))
+	})
+	assert.Panics(t, func() {
+		resetRegistry()
+		RegisterInput("dummy-input", "", testPluginInputCallbackCtrlC{}, WithCollectInterval(0))
+	})
+}
+
+func TestCollectIntervalFromConf(t *testing.T) {
+	d, err := collectIntervalFromConf(testConfigLoader{}, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, d)
+
+	d, err = collectIntervalFromConf(testConfigLoader{"collect_interval": "5"}, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, d)
+
+	d, err = collectIntervalFromConf(testConfigLoader{"collect_interval": "250ms"}, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 250*time.Millisecond, d)
+
+	_, err = collectIntervalFromConf(testConfigLoader{"collect_interval": "0"}, time.Second)
+	assert.Error(t, err)
+
+	_, err = collectIntervalFromConf(testConfigLoader{"collect_interval": "soon"}, time.Second)
+	assert.Error(t, err)
+}
+
+func TestAwaitInput(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defer func() {
+		hostFeatures.Store(0)
+		handshake.Store(false)
+	}()
+
+	r := &registration{inputInterval: time.Minute}
+	r.runCtx = ctx
+	r.channel = make(chan Message, 1)
+
+	// the callbacks of inputs running on the engine thread do not wait once fluent-bit
+	// schedules them at the collect interval.
+	negotiate(FeatureCollectInterval)
+	_, ok := r.awaitInput()
+	assert.False(t, ok)
+
+	r.inputThreaded = true
+	go func() {
+		r.channel <- Message{Record: "foo"}
+	}()
+
+	msg, ok := r.awaitInput()
+	assert.True(t, ok)
+	assert.Equal(t, any("foo"), msg.Record)
+}
+
+func TestAwaitInputPaused(t *testing.T) {
+	clk := useFakeClock(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r := &registration{inputInterval: time.Second, inputThreaded: true}
+	r.runCtx = ctx
+	r.channel = make(chan Message, 1)
+
+	// paused threaded inputs wait out the interval instead of returning at once.
+	done := make(chan bool)
+	go func() {
+		_, ok := r.awaitInput()
+		done <- ok
+	}()
+
+	clk.awaitTimers(t, 1)
+	select {
+	case <-done:
+		t.Fatal("paused input returned before the interval")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clk.Advance(time.Second)
+	assert.False(t, <-done)
+}
+
+func TestAwaitInputEngineThread(t *testing.T) {
+	clk := useFakeClock(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := &registration{inputInterval: time.Second}
+	r.runCtx = ctx
+	r.channel = make(chan Message, 1)
+
+	// fluent-bit not scheduling the callbacks at the collect interval, an input without
+	// data is not polled again before the interval.
+	done := make(chan bool)
+	go func() {
+		_, ok := r.awaitInput()
+		done <- ok
+	}()
+
+	clk.awaitTimers(t, 1)
+	select {
+	case <-done:
+		t.Fatal("input polled again before the interval")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clk.Advance(time.Second)
+	assert.False(t, <-done)
+
+	// records are still delivered as soon as they are collected.
+	go func() {
+		_, ok := r.awaitInput()
+		done <- ok
+	}()
+
+	clk.awaitTimers(t, 1)
+	r.channel <- Message{Record: "foo"}
+	assert.True(t, <-done)
+}
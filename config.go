@@ -0,0 +1,318 @@
+package plugin
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/calyptia/plugin/flbconf"
+)
+
+// BoolFromConf returns the boolean value of the given key, parsed with flbconf.ParseBool,
+// def being returned when the key is not set. Invalid values are reported as an error.
+func BoolFromConf(conf ConfigLoader, key string, def bool) (bool, error) {
+	s := conf.String(key)
+	if s == "" {
+		return def, nil
+	}
+
+	v, err := flbconf.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", key, err)
+	}
+
+	return v, nil
+}
+
+// ConfigStrings returns the list value of the given key. Like the fluent-bit list
+// options, items are separated by commas, or by spaces when there is no comma, and
+// double quoted items can contain separators.
+func ConfigStrings(conf ConfigLoader, key string) []string {
+	return splitList(conf.String(key))
+}
+
+// ConfigStringMap returns the key/value pairs whose key starts with the given prefix,
+// with the prefix trimmed from the keys, e.g. the header.* options of an HTTP output.
+// It returns nil when the loader does not implement ConfigIterator.
+func ConfigStringMap(conf ConfigLoader, prefix string) map[string]string {
+	it, ok := conf.(ConfigIterator)
+	if !ok {
+		return nil
+	}
+
+	out := map[string]string{}
+	for _, k := range it.Keys() {
+		if len(k) > len(prefix) && strings.EqualFold(k[:len(prefix)], prefix) {
+			out[k[len(prefix):]] = conf.String(k)
+		}
+	}
+
+	return out
+}
+
+// splitList splits a list value, on commas when found outside quotes, else on spaces.
+func splitList(s string) []string {
+	sep := func(c byte) bool { return c == ' ' || c == '\t' }
+	if containsUnquoted(s, ',') {
+		sep = func(c byte) bool { return c == ',' }
+	}
+
+	var (
+		out    []string
+		item   strings.Builder
+		quoted bool
+	)
+	flush := func() {
+		if v := strings.TrimSpace(item.String()); v != "" {
+			out = append(out, v)
+		}
+		item.Reset()
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quoted && c == '\\' && i+1 < len(s):
+			i++
+			item.WriteByte(s[i])
+		case c == '"':
+			quoted = !quoted
+		case !quoted && sep(c):
+			flush()
+		default:
+			item.WriteByte(c)
+		}
+	}
+	flush()
+
+	return out
+}
+
+// containsUnquoted reports whether s contains c outside double quotes.
+func containsUnquoted(s string, c byte) bool {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == c && !quoted:
+			return true
+		}
+	}
+
+	return false
+}
+
+// configAll returns all the key/value pairs of a config iterator.
+func configAll(conf ConfigIterator) map[string]string {
+	out := map[string]string{}
+	for _, k := range conf.Keys() {
+		out[k] = conf.String(k)
+	}
+
+	return out
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Unmarshal loads the configuration into the struct pointed by v, driven by the
+// flb struct tags of its fields:
+//
+//	type config struct {
+//		Endpoint string        `flb:"endpoint,required"`
+//		Timeout  time.Duration `flb:"timeout,default=5s"`
+//		Tags     []string      `flb:"tags"`
+//	}
+//
+// The default option must be the last one, so the value can contain commas.
+// Tagged struct fields are loaded from the group of keys prefixed by their key,
+// see SubConfig.
+// Fields without tag are skipped, except embedded structs which are loaded too.
+// Strings, booleans, numbers, durations, parsed with flbconf.ParseTime, string
+// slices, split like ConfigStrings, and encoding.TextUnmarshaler values, like
+// flbconf.Size, are supported. All the invalid or missing
+// values are reported in the returned error.
+func Unmarshal(conf ConfigLoader, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("unmarshal config: expected a pointer to a struct, got %T", v)
+	}
+
+	var errs []error
+	unmarshalStruct(conf, "", rv.Elem(), &errs)
+
+	return errors.Join(errs...)
+}
+
+// unmarshalStruct loads the struct fields, prefix being the group of the
+// struct used to report the errors with the full keys.
+func unmarshalStruct(conf ConfigLoader, prefix string, rv reflect.Value, errs *[]error) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag, ok := field.Tag.Lookup("flb")
+		if !ok {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				unmarshalStruct(conf, prefix, rv.Field(i), errs)
+			}
+			continue
+		}
+
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		key, required, def := parseConfigTag(tag)
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+
+		if isConfigGroup(rv.Field(i)) {
+			unmarshalStruct(SubConfig(conf, key), prefix+key+".", rv.Field(i), errs)
+			continue
+		}
+
+		s := conf.String(key)
+		if s == "" {
+			if required {
+				*errs = append(*errs, fmt.Errorf("%s%s: required", prefix, key))
+				continue
+			}
+			s = def
+		}
+
+		if s == "" {
+			continue
+		}
+
+		if err := setConfigValue(rv.Field(i), s); err != nil {
+			*errs = append(*errs, fmt.Errorf("%s%s: %w", prefix, key, err))
+		}
+	}
+}
+
+// isConfigGroup reports whether the field is a struct loaded from a group of keys.
+func isConfigGroup(v reflect.Value) bool {
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+
+	_, ok := v.Addr().Interface().(encoding.TextUnmarshaler)
+	return !ok
+}
+
+// SubConfig returns the group of config keys sharing the given prefix, like the auth.*
+// keys: SubConfig(conf, "auth").String("username") reads the auth.username key.
+func SubConfig(conf ConfigLoader, prefix string) ConfigLoader {
+	return subConfigLoader{parent: conf, prefix: strings.TrimSuffix(prefix, ".") + "."}
+}
+
+type subConfigLoader struct {
+	parent ConfigLoader
+	prefix string
+}
+
+func (c subConfigLoader) String(key string) string {
+	return c.parent.String(c.prefix + key)
+}
+
+// Keys returns the keys of the group, without prefix, when the parent
+// loader implements ConfigIterator.
+func (c subConfigLoader) Keys() []string {
+	var keys []string
+	for k := range ConfigStringMap(c.parent, c.prefix) {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (c subConfigLoader) All() map[string]string {
+	return configAll(c)
+}
+
+// parseConfigTag splits a flb struct tag into the key and its options.
+func parseConfigTag(tag string) (key string, required bool, def string) {
+	key, opts, _ := strings.Cut(tag, ",")
+	for opts != "" {
+		var opt string
+		if strings.HasPrefix(opts, "default=") {
+			def = strings.TrimPrefix(opts, "default=")
+			break
+		}
+
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == "required" {
+			required = true
+		}
+	}
+
+	return key, required, def
+}
+
+func setConfigValue(v reflect.Value, s string) error {
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+
+	if v.Type() == durationType {
+		d, err := flbconf.ParseTime(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := flbconf.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+
+		parts := splitList(s)
+		out := reflect.MakeSlice(v.Type(), 0, len(parts))
+		for _, p := range parts {
+			out = reflect.Append(out, reflect.ValueOf(p).Convert(v.Type().Elem()))
+		}
+		v.Set(out)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
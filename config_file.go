@@ -0,0 +1,127 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxConfigFileSize is the size limit of the auxiliary config files.
+const maxConfigFileSize = 10 << 20
+
+// ConfigFile is an auxiliary file referenced by a config option, like a JSON credentials
+// file or a query file. It is loaded with LoadConfigFile and can be watched for changes.
+type ConfigFile struct {
+	// Path of the file, as set in the config.
+	Path string
+
+	mu      sync.Mutex
+	data    []byte
+	modTime time.Time
+}
+
+// LoadConfigFile loads the file referenced by the given config key. The file must be a
+// regular file of at most 10MiB.
+func LoadConfigFile(conf ConfigLoader, key string) (*ConfigFile, error) {
+	path := conf.String(key)
+	if path == "" {
+		return nil, fmt.Errorf("%s: required", key)
+	}
+
+	f := &ConfigFile{Path: path}
+	if _, err := f.Reload(); err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+
+	return f, nil
+}
+
+// Bytes returns the content of the file as last loaded.
+func (f *ConfigFile) Bytes() []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.data
+}
+
+// DecodeJSON decodes the content of the file into v.
+func (f *ConfigFile) DecodeJSON(v any) error {
+	return json.Unmarshal(f.Bytes(), v)
+}
+
+// Reload reads the file again, it reports whether its content changed.
+func (f *ConfigFile) Reload() (bool, error) {
+	fh, err := os.Open(f.Path)
+	if err != nil {
+		return false, err
+	}
+	defer fh.Close()
+
+	info, err := fh.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	if !info.Mode().IsRegular() {
+		return false, fmt.Errorf("%q is not a regular file", f.Path)
+	}
+
+	if info.Size() > maxConfigFileSize {
+		return false, fmt.Errorf("%q is larger than %d bytes", f.Path, maxConfigFileSize)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.data != nil && info.ModTime().Equal(f.modTime) && info.Size() == int64(len(f.data)) {
+		return false, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(fh, maxConfigFileSize+1))
+	if err != nil {
+		return false, err
+	}
+
+	if len(data) > maxConfigFileSize {
+		return false, fmt.Errorf("%q is larger than %d bytes", f.Path, maxConfigFileSize)
+	}
+
+	changed := f.data == nil || !bytes.Equal(data, f.data)
+	f.data, f.modTime = data, info.ModTime()
+
+	return changed, nil
+}
+
+// Watch checks the file every interval until the context is cancelled, calling onChange
+// with the new content whenever it changes. Files removed or failing to load keep their
+// last content and the error is passed to onError, when not nil.
+func (f *ConfigFile) Watch(ctx context.Context, interval time.Duration, onChange func(data []byte), onError func(err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := f.Reload()
+		if err != nil {
+			if onError != nil && !errors.Is(err, context.Canceled) {
+				onError(err)
+			}
+			continue
+		}
+
+		if changed {
+			onChange(f.Bytes())
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"user":"admin"}`), 0o600))
+
+	f, err := LoadConfigFile(testConfigLoader{"credentials_file": path}, "credentials_file")
+	assert.NoError(t, err)
+
+	var creds struct {
+		User string `json:"user"`
+	}
+	assert.NoError(t, f.DecodeJSON(&creds))
+	assert.Equal(t, "admin", creds.User)
+
+	changed, err := f.Reload()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	_, err = LoadConfigFile(testConfigLoader{}, "credentials_file")
+	assert.EqualError(t, err, "credentials_file: required")
+
+	_, err = LoadConfigFile(testConfigLoader{"credentials_file": filepath.Dir(path)}, "credentials_file")
+	assert.Error(t, err)
+}
+
+func TestConfigFileWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "query.sql")
+	assert.NoError(t, os.WriteFile(path, []byte("SELECT 1"), 0o600))
+
+	f, err := LoadConfigFile(testConfigLoader{"query_file": path}, "query_file")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan string, 1)
+	go f.Watch(ctx, 10*time.Millisecond, func(data []byte) {
+		changes <- string(data)
+	}, nil)
+
+	mtime := time.Now().Add(time.Second)
+	assert.NoError(t, os.WriteFile(path, []byte("SELECT 2"), 0o600))
+	assert.NoError(t, os.Chtimes(path, mtime, mtime))
+
+	select {
+	case got := <-changes:
+		assert.Equal(t, "SELECT 2", got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("change not detected")
+	}
+}
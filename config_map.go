@@ -0,0 +1,70 @@
+package plugin
+
+// ConfigType is the type of a configuration option, matching the fluent-bit
+// config_map types.
+type ConfigType int
+
+const (
+	ConfigString       ConfigType = 0
+	ConfigStringPrefix ConfigType = 1
+	ConfigInt          ConfigType = 2
+	ConfigBool         ConfigType = 3
+	ConfigDouble       ConfigType = 4
+	ConfigSize         ConfigType = 5
+	ConfigTime         ConfigType = 6
+	// ConfigCommaList is a comma separated list of strings.
+	ConfigCommaList ConfigType = 30
+	// ConfigSpaceList is a space separated list of strings.
+	ConfigSpaceList ConfigType = 40
+)
+
+// configMapMult is the flag of the options that can be set several times.
+const configMapMult = 1
+
+func (t ConfigType) String() string {
+	switch t {
+	case ConfigString:
+		return "string"
+	case ConfigStringPrefix:
+		return "prefixed string"
+	case ConfigInt:
+		return "integer"
+	case ConfigBool:
+		return "boolean"
+	case ConfigDouble:
+		return "double"
+	case ConfigSize:
+		return "size"
+	case ConfigTime:
+		return "time"
+	case ConfigCommaList:
+		return "comma separated list"
+	case ConfigSpaceList:
+		return "space separated list"
+	}
+	return "unknown"
+}
+
+// MarshalText encodes the type by name.
+func (t ConfigType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// ConfigOption describes a configuration option of a plugin.
+type ConfigOption struct {
+	Name        string     `json:"name"`
+	Type        ConfigType `json:"type"`
+	Default     string     `json:"default,omitempty"`
+	Description string     `json:"description,omitempty"`
+	// Multiple allows the option to be set several times.
+	Multiple bool `json:"multiple,omitempty"`
+}
+
+// WithConfigMap declares the configuration options of the plugin, they are
+// exported to fluent-bit as the plugin config_map, so they are listed by
+// fluent-bit --help, and included in the plugin metadata.
+func WithConfigMap(opts ...ConfigOption) RegisterOption {
+	return func(r *registration) {
+		r.configMap = append(r.configMap, opts...)
+	}
+}
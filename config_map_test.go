@@ -0,0 +1,31 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestWithConfigMap(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	RegisterOutput("dummy-output", "dummy output", &testOutputCounter{}, WithConfigMap(
+		ConfigOption{Name: "endpoint", Type: ConfigString, Description: "Endpoint to send the records to"},
+		ConfigOption{Name: "timeout", Type: ConfigTime, Default: "5s"},
+		ConfigOption{Name: "header", Type: ConfigSpaceList, Multiple: true},
+	))
+
+	b, err := registryMetadata()
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"name":"dummy-output","type":"output","description":"dummy output","config":[`+
+		`{"name":"endpoint","type":"string","description":"Endpoint to send the records to"},`+
+		`{"name":"timeout","type":"time","default":"5s"},`+
+		`{"name":"header","type":"space separated list","multiple":true}`+
+		`]}]`, string(b))
+
+	r := registrationOf(outputKind)
+	p := newNativeConfigMap(r.configMap)
+	assert.NotZero(t, p)
+	freeNativeConfigMap(p)
+}
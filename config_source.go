@@ -0,0 +1,115 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	fluentbitconfig "github.com/calyptia/go-fluentbit-config/v2"
+)
+
+// MapConfigLoader is a ConfigLoader backed by a map, so plugins can be constructed
+// and unit tested outside of fluent-bit. Like in fluent-bit, keys are case insensitive.
+type MapConfigLoader map[string]string
+
+func (m MapConfigLoader) String(key string) string {
+	if v, ok := m[key]; ok {
+		return v
+	}
+
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// Keys returns the keys of the map, sorted.
+func (m MapConfigLoader) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// All returns a copy of the map.
+func (m MapConfigLoader) All() map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+
+	return out
+}
+
+// FileConfigLoader is a ConfigLoader reading the properties of a plugin from a
+// fluent-bit configuration file, so plugins can run standalone with the same
+// configuration they get from fluent-bit.
+type FileConfigLoader struct {
+	MapConfigLoader
+	// Path of the configuration file.
+	Path string
+}
+
+// NewFileConfigLoader loads the properties of the plugin with the given name, or id like
+// "my_plugin.1", from a classic (.conf) or YAML (.yaml, .yml) fluent-bit configuration file.
+// The first input, filter or output section of the plugin is used.
+func NewFileConfigLoader(path, name string) (*FileConfigLoader, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var format fluentbitconfig.Format
+	switch filepath.Ext(path) {
+	case ".conf":
+		format = fluentbitconfig.FormatClassic
+	case ".yaml", ".yml":
+		format = fluentbitconfig.FormatYAML
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q", path)
+	}
+
+	conf, err := fluentbitconfig.ParseAs(string(b), format)
+	if err != nil {
+		return nil, fmt.Errorf("parse %q: %w", path, err)
+	}
+
+	for _, plugins := range []fluentbitconfig.Plugins{conf.Pipeline.Inputs, conf.Pipeline.Filters, conf.Pipeline.Outputs} {
+		for _, plug := range plugins {
+			if !strings.EqualFold(plug.Name, name) && !strings.EqualFold(plug.ID, name) {
+				continue
+			}
+
+			m := MapConfigLoader{}
+			for _, p := range plug.Properties {
+				m[p.Key] = propertyString(p.Value)
+			}
+
+			return &FileConfigLoader{MapConfigLoader: m, Path: path}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("plugin %q not found in %q", name, path)
+}
+
+// propertyString formats a property value, YAML lists are comma separated.
+func propertyString(v any) string {
+	if list, ok := v.([]any); ok {
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			items = append(items, fmt.Sprint(item))
+		}
+
+		return strings.Join(items, ",")
+	}
+
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestMapConfigLoader(t *testing.T) {
+	conf := MapConfigLoader{"Endpoint": "https://example.com", "timeout": "5s"}
+	assert.Equal(t, "https://example.com", conf.String("endpoint"))
+	assert.Equal(t, "5s", conf.String("Timeout"))
+	assert.Equal(t, "", conf.String("unknown"))
+	assert.Equal(t, []string{"Endpoint", "timeout"}, conf.Keys())
+}
+
+func TestFileConfigLoader(t *testing.T) {
+	dir := t.TempDir()
+
+	classic := filepath.Join(dir, "fluent-bit.conf")
+	assert.NoError(t, os.WriteFile(classic, []byte(`
+[INPUT]
+    Name dummy
+
+[OUTPUT]
+    Name     go-test-output
+    Match    *
+    endpoint https://example.com
+`), 0o600))
+
+	conf, err := NewFileConfigLoader(classic, "go-test-output")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com", conf.String("endpoint"))
+	assert.Equal(t, "*", conf.String("match"))
+	assert.Equal(t, classic, conf.Path)
+
+	yaml := filepath.Join(dir, "fluent-bit.yaml")
+	assert.NoError(t, os.WriteFile(yaml, []byte(`
+pipeline:
+  inputs:
+    - name: go-test-input
+      tags: [a, b]
+      interval: 10
+`), 0o600))
+
+	conf, err = NewFileConfigLoader(yaml, "go-test-input.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "a,b", conf.String("tags"))
+	assert.Equal(t, "10", conf.String("interval"))
+
+	var plug testInitConfig
+	assert.NoError(t, Unmarshal(conf, &plug))
+	assert.Equal(t, testInitConfig{Tags: []string{"a", "b"}, Interval: 10}, plug)
+
+	_, err = NewFileConfigLoader(yaml, "unknown")
+	assert.Error(t, err)
+	_, err = NewFileConfigLoader(filepath.Join(dir, "fluent-bit.json"), "unknown")
+	assert.Error(t, err)
+}
+
+type testInitConfig struct {
+	Tags     []string `flb:"tags"`
+	Interval int      `flb:"interval"`
+}
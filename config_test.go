@@ -0,0 +1,172 @@
+package plugin
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/calyptia/plugin/flbconf"
+)
+
+func TestBoolFromConf(t *testing.T) {
+	conf := testConfigLoader{"tls": "On", "debug": "no", "verify": "maybe"}
+
+	v, err := BoolFromConf(conf, "tls", false)
+	assert.NoError(t, err)
+	assert.True(t, v)
+
+	v, err = BoolFromConf(conf, "debug", true)
+	assert.NoError(t, err)
+	assert.False(t, v)
+
+	v, err = BoolFromConf(conf, "unset", true)
+	assert.NoError(t, err)
+	assert.True(t, v)
+
+	_, err = BoolFromConf(conf, "verify", true)
+	assert.EqualError(t, err, `verify: invalid value: boolean "maybe"`)
+}
+
+type testConfigBase struct {
+	Workers int `flb:"workers,default=2"`
+}
+
+type testConfig struct {
+	testConfigBase
+	Endpoint string        `flb:"endpoint,required"`
+	Timeout  time.Duration `flb:"timeout,default=5s"`
+	TLS      bool          `flb:"tls"`
+	Ratio    float64       `flb:"ratio"`
+	Tags     []string      `flb:"tags,default=a, b"`
+	Level    testLevel     `flb:"level"`
+	Buffer   flbconf.Size  `flb:"buffer,default=512k"`
+	Ignored  string        `flb:"-"`
+	NoTag    string
+}
+
+type testLevel int
+
+func (l *testLevel) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "debug":
+		*l = 4
+	case "info":
+		*l = 3
+	default:
+		return fmt.Errorf("unknown level %q", b)
+	}
+	return nil
+}
+
+func TestUnmarshal(t *testing.T) {
+	var cfg testConfig
+	err := Unmarshal(testConfigLoader{
+		"endpoint": "https://example.com",
+		"tls":      "on",
+		"ratio":    "0.5",
+		"level":    "debug",
+		"notag":    "foo",
+		"ignored":  "foo",
+	}, &cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, testConfig{
+		testConfigBase: testConfigBase{Workers: 2},
+		Endpoint:       "https://example.com",
+		Timeout:        5 * time.Second,
+		TLS:            true,
+		Ratio:          0.5,
+		Tags:           []string{"a", "b"},
+		Level:          4,
+		Buffer:         512 * flbconf.KB,
+	}, cfg)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var cfg testConfig
+	err := Unmarshal(testConfigLoader{
+		"workers": "many",
+		"timeout": "soon",
+		"level":   "loud",
+	}, &cfg)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "workers: ")
+	assert.Contains(t, err.Error(), "endpoint: required")
+	assert.Contains(t, err.Error(), "timeout: ")
+	assert.Contains(t, err.Error(), `level: unknown level "loud"`)
+
+	assert.Error(t, Unmarshal(testConfigLoader{}, cfg))
+}
+
+func TestConfigIterator(t *testing.T) {
+	var conf ConfigIterator = testConfigLoader{"header.x-api-key": "secret", "endpoint": "https://example.com"}
+	assert.Equal(t, []string{"endpoint", "header.x-api-key"}, conf.Keys())
+	assert.Equal(t, map[string]string{"endpoint": "https://example.com", "header.x-api-key": "secret"}, conf.All())
+
+	var (
+		_ ConfigIterator = (*flbInputConfigLoader)(nil)
+		_ ConfigIterator = (*flbOutputConfigLoader)(nil)
+		_ ConfigIterator = (*flbFilterConfigLoader)(nil)
+		_ ConfigIterator = (*flbProcessorConfigLoader)(nil)
+	)
+}
+
+func TestConfigStrings(t *testing.T) {
+	conf := testConfigLoader{
+		"comma":  `a, b ,,c`,
+		"space":  "a  b\tc",
+		"quoted": `"a, b", "c \"d\""`,
+		"words":  `"hello world" foo`,
+	}
+
+	assert.Equal(t, []string{"a", "b", "c"}, ConfigStrings(conf, "comma"))
+	assert.Equal(t, []string{"a", "b", "c"}, ConfigStrings(conf, "space"))
+	assert.Equal(t, []string{"a, b", `c "d"`}, ConfigStrings(conf, "quoted"))
+	assert.Equal(t, []string{"hello world", "foo"}, ConfigStrings(conf, "words"))
+	assert.Zero(t, ConfigStrings(conf, "unknown"))
+}
+
+func TestConfigStringMap(t *testing.T) {
+	conf := testConfigLoader{
+		"header.X-Api-Key": "abc",
+		"Header.Accept":    "application/json",
+		"header.":          "ignored",
+		"endpoint":         "https://example.com",
+	}
+
+	assert.Equal(t, map[string]string{"X-Api-Key": "abc", "Accept": "application/json"}, ConfigStringMap(conf, "header."))
+	assert.Zero(t, ConfigStringMap(struct{ ConfigLoader }{conf}, "header."))
+}
+
+func TestSubConfig(t *testing.T) {
+	conf := testConfigLoader{
+		"endpoint":           "https://example.com",
+		"auth.username":      "admin",
+		"auth.password":      "hunter2",
+		"auth.oauth2.scopes": "read,write",
+	}
+
+	auth := SubConfig(conf, "auth")
+	assert.Equal(t, "admin", auth.String("username"))
+	assert.Equal(t, "", auth.String("endpoint"))
+	assert.Equal(t, []string{"oauth2.scopes", "password", "username"}, auth.(ConfigIterator).Keys())
+	assert.Equal(t, "read,write", SubConfig(auth, "oauth2.").String("scopes"))
+
+	var cfg struct {
+		Endpoint string `flb:"endpoint"`
+		Auth     struct {
+			Username string `flb:"username,required"`
+			Password Secret `flb:"password"`
+			OAuth2   struct {
+				Scopes   []string `flb:"scopes"`
+				TokenURL string   `flb:"token_url,required"`
+			} `flb:"oauth2"`
+		} `flb:"auth"`
+	}
+	err := Unmarshal(conf, &cfg)
+	assert.EqualError(t, err, "auth.oauth2.token_url: required")
+	assert.Equal(t, "admin", cfg.Auth.Username)
+	assert.Equal(t, "hunter2", cfg.Auth.Password.Value())
+	assert.Equal(t, []string{"read", "write"}, cfg.Auth.OAuth2.Scopes)
+}
@@ -0,0 +1,56 @@
+package plugin
+
+import "context"
+
+// contextKey is the type of the keys of the context values set by the SDK.
+type contextKey int
+
+const (
+	instanceKey contextKey = iota
+	workerIDKey
+	tagKey
+)
+
+// InstanceFromContext returns the fluent-bit instance running the plugin, set on
+// the contexts given to Init, Collect, Flush and the other plugin callbacks.
+func InstanceFromContext(ctx context.Context) (InstanceInfo, bool) {
+	info, ok := ctx.Value(instanceKey).(InstanceInfo)
+	return info, ok
+}
+
+// WorkerIDFromContext returns the id of the fluent-bit worker flushing the chunk, set
+// on the contexts given to the outputs receiving whole chunks: ChunkOutputPlugin,
+// BatchFlusher, MetricsOutputPlugin and TracesOutputPlugin. OutputPlugin receives
+// the worker id with each message instead, see Message.Worker.
+func WorkerIDFromContext(ctx context.Context) (int, bool) {
+	id, ok := ctx.Value(workerIDKey).(int)
+	return id, ok
+}
+
+// TagFromContext returns the tag of the chunk being flushed, set on the same
+// contexts as the worker id. See Message.Tag for OutputPlugin.
+func TagFromContext(ctx context.Context) (string, bool) {
+	tag, ok := ctx.Value(tagKey).(string)
+	return tag, ok
+}
+
+// withInstance returns a copy of ctx carrying the instance running the plugin.
+func withInstance(ctx context.Context, info InstanceInfo) context.Context {
+	return context.WithValue(ctx, instanceKey, info)
+}
+
+// withChunk returns a copy of ctx carrying the worker flushing a chunk, and its tag.
+func withChunk(ctx context.Context, worker int, tag string) context.Context {
+	return context.WithValue(context.WithValue(ctx, workerIDKey, worker), tagKey, tag)
+}
+
+// baseContext returns the context carrying the instance, the contexts
+// given to the plugin derive from it.
+func (s *runState) baseContext() context.Context {
+	return withInstance(context.Background(), s.instance)
+}
+
+// newRunContext creates the run context of the plugin instance.
+func (s *runState) newRunContext() (context.Context, context.CancelFunc) {
+	return context.WithCancel(s.baseContext())
+}
@@ -0,0 +1,75 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/calyptia/plugin/output"
+)
+
+func TestContextValues(t *testing.T) {
+	_, ok := InstanceFromContext(context.Background())
+	assert.False(t, ok)
+	_, ok = WorkerIDFromContext(context.Background())
+	assert.False(t, ok)
+	_, ok = TagFromContext(context.Background())
+	assert.False(t, ok)
+
+	ctx := withChunk(withInstance(context.Background(), InstanceInfo{Name: "dummy.0"}), 2, "foo")
+
+	info, ok := InstanceFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "dummy.0", info.Name)
+
+	worker, ok := WorkerIDFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, 2, worker)
+
+	tag, ok := TagFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "foo", tag)
+}
+
+type testContextOutput struct {
+	ctx context.Context
+}
+
+func (t *testContextOutput) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (t *testContextOutput) Flush(ctx context.Context, chunk Chunk) error {
+	t.ctx = ctx
+	return nil
+}
+
+func TestFlushContextValues(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	out := &testContextOutput{}
+	RegisterChunkOutput("test-output", "", out)
+	o := registrationOf(outputKind).newOutputInstance()
+	o.instance = InstanceInfo{Name: "test-output.0", Alias: "out"}
+	FLBPluginOutputPreRun(0)
+	defer o.stop()
+
+	b, err := msgpack.Marshal([]any{&EventTime{}, map[string]string{"foo": "bar"}})
+	assert.NoError(t, err)
+	assert.Equal(t, output.FLB_OK, o.handleFlush(3, "my-tag", b))
+
+	info, ok := InstanceFromContext(out.ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "out", info.Label())
+
+	worker, ok := WorkerIDFromContext(out.ctx)
+	assert.True(t, ok)
+	assert.Equal(t, 3, worker)
+
+	tag, ok := TagFromContext(out.ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "my-tag", tag)
+}
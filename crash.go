@@ -0,0 +1,157 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// crashDirEnv sets the directory crash reports are written to, e.g. FLB_GO_CRASH_DIR=/var/log/fluent-bit.
+// Instances can set it with the go.CrashReportDir config key.
+const crashDirEnv = "FLB_GO_CRASH_DIR"
+
+// maxRecentLogs is the number of log lines kept for the crash reports.
+const maxRecentLogs = 50
+
+// crashReport describes a panic about to crash fluent-bit.
+type crashReport struct {
+	Time      time.Time `json:"time"`
+	Plugin    string    `json:"plugin,omitempty"`
+	Kind      string    `json:"kind,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	Instance  string    `json:"instance,omitempty"`
+	Callback  string    `json:"callback"`
+	Panic     string    `json:"panic"`
+	Stack     string    `json:"stack"`
+	Logs      []string  `json:"logs,omitempty"`
+	GoVersion string    `json:"go_version"`
+}
+
+// crashInfo identifies the plugin instance in the crash reports.
+type crashInfo struct {
+	dir      string
+	plugin   string
+	kind     string
+	version  string
+	instance string
+}
+
+// crashOnce makes sure a single report is written per crash, as the panic
+// propagates through the goroutine started by the SDK once reported.
+var crashOnce sync.Once
+
+// recentLogs are the last lines logged by the plugins, included in the crash reports.
+var recentLogs struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+}
+
+func recordLog(level, line string) {
+	var ts [len(time.RFC3339Nano)]byte
+	b := time.Now().UTC().AppendFormat(ts[:0], time.RFC3339Nano)
+
+	var sb strings.Builder
+	sb.Grow(len(b) + len(level) + len(line) + 4)
+	sb.Write(b)
+	sb.WriteString(" [")
+	sb.WriteString(level)
+	sb.WriteString("] ")
+	sb.WriteString(line)
+	line = sb.String()
+
+	recentLogs.mu.Lock()
+	defer recentLogs.mu.Unlock()
+
+	if len(recentLogs.lines) < maxRecentLogs {
+		recentLogs.lines = append(recentLogs.lines, line)
+		return
+	}
+
+	recentLogs.lines[recentLogs.next] = line
+	recentLogs.next = (recentLogs.next + 1) % maxRecentLogs
+}
+
+// lastLogs returns the recent log lines, oldest first.
+func lastLogs() []string {
+	recentLogs.mu.Lock()
+	defer recentLogs.mu.Unlock()
+
+	return append(append([]string(nil), recentLogs.lines[recentLogs.next:]...), recentLogs.lines[:recentLogs.next]...)
+}
+
+// crashDir reads the go.CrashReportDir config key, defaulting to FLB_GO_CRASH_DIR.
+func crashDir(conf ConfigLoader) string {
+	if dir := conf.String("go.CrashReportDir"); dir != "" {
+		return dir
+	}
+
+	return os.Getenv(crashDirEnv)
+}
+
+// reportCrash writes the crash report of an unrecovered panic, when a crash report
+// directory is configured. Only the first crash of the process is reported.
+func reportCrash(info crashInfo, callback string, v any, stack []byte) {
+	if info.dir == "" {
+		info.dir = os.Getenv(crashDirEnv)
+	}
+	if info.dir == "" {
+		return
+	}
+
+	crashOnce.Do(func() {
+		path, err := writeCrashReport(info.dir, crashReport{
+			Time:      time.Now().UTC(),
+			Plugin:    info.plugin,
+			Kind:      info.kind,
+			Version:   info.version,
+			Instance:  info.instance,
+			Callback:  callback,
+			Panic:     fmt.Sprint(v),
+			Stack:     string(stack),
+			Logs:      lastLogs(),
+			GoVersion: runtime.Version(),
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "crash report: %v\n", err)
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "crash report written to %s\n", path)
+	})
+}
+
+// writeCrashReport writes the report into a new file of dir, synced to disk
+// as the process is about to die.
+func writeCrashReport(dir string, rep crashReport) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	name := "go-plugin-crash-*.json"
+	if rep.Plugin != "" {
+		name = "go-plugin-crash-" + filepath.Base(rep.Plugin) + "-*.json"
+	}
+	f, err := os.CreateTemp(dir, name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rep); err != nil {
+		return "", err
+	}
+
+	if err := f.Sync(); err != nil {
+		return "", err
+	}
+
+	return f.Name(), f.Close()
+}
@@ -0,0 +1,88 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestRecentLogs(t *testing.T) {
+	for i := range maxRecentLogs + 5 {
+		recordLog("info", fmt.Sprintf("line %d", i))
+	}
+
+	logs := lastLogs()
+	assert.Equal(t, maxRecentLogs, len(logs))
+	assert.Contains(t, logs[0], "[info] line 5")
+	assert.Contains(t, logs[len(logs)-1], fmt.Sprintf("[info] line %d", maxRecentLogs+4))
+}
+
+func TestCrashDir(t *testing.T) {
+	t.Setenv(crashDirEnv, "/tmp/from-env")
+	assert.Equal(t, "/tmp/from-env", crashDir(testConfigLoader{}))
+	assert.Equal(t, "/tmp/from-conf", crashDir(testConfigLoader{"go.CrashReportDir": "/tmp/from-conf"}))
+}
+
+func TestWriteCrashReport(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "crashes")
+	path, err := writeCrashReport(dir, crashReport{
+		Plugin:   "dummy",
+		Version:  "1.2.3",
+		Callback: "flush",
+		Panic:    "boom",
+		Stack:    "goroutine 1",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, dir, filepath.Dir(path))
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	var rep crashReport
+	assert.NoError(t, json.Unmarshal(b, &rep))
+	assert.Equal(t, "dummy", rep.Plugin)
+	assert.Equal(t, "1.2.3", rep.Version)
+	assert.Equal(t, "boom", rep.Panic)
+}
+
+func TestProtectAbortReportsCrash(t *testing.T) {
+	crashOnce = sync.Once{}
+	defer func() { crashOnce = sync.Once{} }()
+
+	dir := t.TempDir()
+	r := &registration{kind: outputKind, name: "dummy", meta: Metadata{Version: "1.2.3"}, panicPolicy: PanicAbort}
+	var s runState
+	s.supervise(r, &Fluentbit{
+		Conf:     testConfigLoader{"go.CrashReportDir": dir},
+		Metrics:  testMetrics{gauge: &testGauge{values: map[string]float64{}}},
+		Instance: InstanceInfo{Name: "dummy.0"},
+	})
+
+	assert.Panics(t, func() {
+		_ = s.protect("flush", func() error {
+			panic("boom")
+		})
+	})
+
+	files, err := filepath.Glob(filepath.Join(dir, "go-plugin-crash-dummy-*.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(files))
+
+	b, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+
+	var rep crashReport
+	assert.NoError(t, json.Unmarshal(b, &rep))
+	assert.Equal(t, "dummy", rep.Plugin)
+	assert.Equal(t, "output", rep.Kind)
+	assert.Equal(t, "1.2.3", rep.Version)
+	assert.Equal(t, "dummy.0", rep.Instance)
+	assert.Equal(t, "flush", rep.Callback)
+	assert.Equal(t, "boom", rep.Panic)
+	assert.Contains(t, rep.Stack, "TestProtectAbortReportsCrash")
+}
@@ -2,53 +2,121 @@ package plugin
 
 /*
 #include <stdlib.h>
+
+// flbgo_config_map is a config_map entry, the array is terminated
+// by an entry without name.
+struct flbgo_config_map {
+    int type;
+    char *name;
+    char *def_value;
+    int flags;
+    char *desc;
+};
+
+// flbgo_test_output_plugin mirrors the output instances of fluent-bit, for the tests to
+// set their context.
+struct flbgo_test_proxy_context {
+    void *remote_context;
+};
+
+struct flbgo_test_output_plugin {
+    char *name;
+    void *api;
+    void *o_ins;
+    struct flbgo_test_proxy_context *context;
+};
 */
 import "C"
 
 import (
 	"bytes"
 	"context"
-	"errors"
 	"fmt"
-	"io"
-	"log"
 	"os"
-	"runtime"
+	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 	"unsafe"
 
-	"github.com/vmihailenco/msgpack/v5"
-
 	cmetrics "github.com/calyptia/cmetrics-go"
+	"github.com/calyptia/plugin/filter"
 	"github.com/calyptia/plugin/input"
+	"github.com/calyptia/plugin/internal/cmem"
 	metricbuilder "github.com/calyptia/plugin/metric/cmetric"
 	"github.com/calyptia/plugin/output"
+	"github.com/calyptia/plugin/processor"
 )
 
 const (
-	// maxBufferedMessages is the number of messages that will be buffered
-	// between each fluent-bit interval (approx 1 second).
+	// defaultMaxBufferedMessages is the number of messages buffered by inputs
+	// between the collect callbacks.
 	defaultMaxBufferedMessages = 300000
-	// collectInterval is set to the interval present before in core-fluent-bit.
-	collectInterval = 1000 * time.Nanosecond
 )
 
-var (
-	unregister          func()
-	cmt                 *cmetrics.Context
-	logger              Logger
-	maxBufferedMessages = defaultMaxBufferedMessages
-)
+// FLBPluginAPIVersion returns the version of the plugin ABI implemented by the SDK, fluent-bit
+// can use it to refuse loading plugins built against an incompatible SDK.
+//
+//export FLBPluginAPIVersion
+func FLBPluginAPIVersion() C.int {
+	return C.int(APIVersion)
+}
+
+// FLBPluginFeatures is the capability handshake, fluent-bit announces the features it supports
+// before registering the plugins and gets back the ones supported on both sides. Plugins loaded
+// by a fluent-bit version not calling it keep the features available before the handshake, but
+// the event types of the outputs, written to a field older versions lack.
+//
+//export FLBPluginFeatures
+func FLBPluginFeatures(hostFeatures C.uint) C.uint {
+	return C.uint(negotiate(Feature(hostFeatures)))
+}
+
+// FLBPluginServiceConfig is invoked by fluent-bit before initializing the plugins to share
+// its service configuration: the flush and grace periods in seconds, the storage path
+// and whether hot reload is enabled.
+//
+//export FLBPluginServiceConfig
+func FLBPluginServiceConfig(flush, grace C.double, storagePath *C.char, hotReload C.int) int {
+	setServiceConfig(func(c *ServiceConfig) {
+		if flush > 0 {
+			c.Flush = time.Duration(float64(flush) * float64(time.Second))
+		}
+		if grace > 0 {
+			c.Grace = time.Duration(float64(grace) * float64(time.Second))
+		}
+		if storagePath != nil {
+			c.StoragePath = C.GoString(storagePath)
+		}
+		c.HotReload = hotReload == C.int(1)
+	})
+
+	return input.FLB_OK
+}
+
+// FLBPluginInstanceInfo is invoked by fluent-bit before initializing a plugin instance
+// to share its name, e.g. "my_output.0", and its alias.
+//
+//export FLBPluginInstanceInfo
+func FLBPluginInstanceInfo(ptr unsafe.Pointer, name, alias *C.char) int {
+	setInstanceInfo(ptr, InstanceInfo{Name: C.GoString(name), Alias: C.GoString(alias)})
+	return input.FLB_OK
+}
 
 // FLBPluginPreRegister -
 //
 //export FLBPluginPreRegister
 func FLBPluginPreRegister(hotReloading C.int) int {
 	if hotReloading == C.int(1) {
-		registerWG.Add(1)
+		startup.reloading()
+
+		setServiceConfig(func(c *ServiceConfig) {
+			c.HotReload = true
+		})
+
+		registryMu.Lock()
+		nextRegister, initialized, exited = 0, 0, 0
+		registryMu.Unlock()
 	}
 
 	return input.FLB_OK
@@ -59,50 +127,131 @@ func FLBPluginPreRegister(hotReloading C.int) int {
 //
 //export FLBPluginRegister
 func FLBPluginRegister(def unsafe.Pointer) int {
-	defer registerWG.Done()
+	defer startup.registered()
 
-	if theInput == nil && theOutput == nil {
-		fmt.Fprintf(os.Stderr, "no input or output registered\n")
+	r := nextRegistration()
+	if r == nil {
+		fmt.Fprintf(os.Stderr, "no plugin registered\n")
 		return input.FLB_RETRY
 	}
 
-	if theInput != nil {
-		out := input.FLBPluginRegister(def, theName, theDesc)
-		unregister = func() {
+	switch r.kind {
+	case inputKind:
+		out := input.FLBPluginRegister(def, r.name, r.desc)
+		if flags := r.inputMode.flags(); flags != 0 {
+			input.FLBPluginSetFlags(def, flags)
+		}
+		r.unregister = func() {
 			input.FLBPluginUnregister(def)
 		}
 		return out
+	case filterKind:
+		out := filter.FLBPluginRegister(def, r.name, r.desc)
+		r.unregister = func() {
+			filter.FLBPluginUnregister(def)
+		}
+		return out
+	case processorKind:
+		out := processor.FLBPluginRegister(def, r.name, r.desc)
+		r.unregister = func() {
+			processor.FLBPluginUnregister(def)
+		}
+		return out
 	}
 
-	out := output.FLBPluginRegister(def, theName, theDesc)
-	unregister = func() {
+	types, err := r.registerEventTypes()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "register: %v\n", err)
+		return output.FLB_ERROR
+	}
+
+	out := output.FLBPluginRegister(def, r.name, r.desc)
+	// logs only outputs leave the event type unset, as older fluent-bit
+	// versions do not have it.
+	if types != output.FLB_OUTPUT_LOGS {
+		output.FLBPluginSetEventType(def, types)
+	}
+	r.unregister = func() {
 		output.FLBPluginUnregister(def)
 	}
 
 	return out
 }
 
-func cleanup() int {
-	if unregister != nil {
-		unregister()
-		unregister = nil
+// FLBPluginMetadata returns a JSON document describing the plugins registered by the shared library,
+// their name, type, description, version, license and author. The returned string must be released
+// by the caller with free.
+//
+//export FLBPluginMetadata
+func FLBPluginMetadata() *C.char {
+	b, err := registryMetadata()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "metadata: %s\n", err)
+		return nil
 	}
 
-	if runCancel != nil {
-		runCancel()
-		runCancel = nil
+	p, err := cmem.OwnString(string(b))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "metadata: %s\n", err)
+		return nil
 	}
+	cmem.HandOver(p)
 
-	if !theInputLock.TryLock() {
-		return input.FLB_OK
+	return (*C.char)(p)
+}
+
+// FLBPluginConfigMap returns the configuration options declared by the plugin with the given name,
+// as an array of config_map entries terminated by an entry without name. The array is owned by the
+// plugin and released when it gets unregistered.
+//
+//export FLBPluginConfigMap
+func FLBPluginConfigMap(name *C.char) unsafe.Pointer {
+	r := lookupRegistration(C.GoString(name))
+	if r == nil {
+		fmt.Fprintf(os.Stderr, "no plugin registered\n")
+		return nil
 	}
-	defer theInputLock.Unlock()
 
-	if theChannel != nil {
-		defer close(theChannel)
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if r.nativeConfigMap == nil {
+		r.nativeConfigMap = newNativeConfigMap(r.configMap)
 	}
 
-	return input.FLB_OK
+	return r.nativeConfigMap
+}
+
+func newNativeConfigMap(opts []ConfigOption) unsafe.Pointer {
+	p := C.calloc(C.size_t(len(opts)+1), C.sizeof_struct_flbgo_config_map)
+	entries := unsafe.Slice((*C.struct_flbgo_config_map)(p), len(opts)+1)
+	for i, opt := range opts {
+		entries[i]._type = C.int(opt.Type)
+		entries[i].name = C.CString(opt.Name)
+		if opt.Default != "" {
+			entries[i].def_value = C.CString(opt.Default)
+		}
+		if opt.Multiple {
+			entries[i].flags = configMapMult
+		}
+		entries[i].desc = C.CString(opt.Description)
+	}
+
+	return p
+}
+
+func freeNativeConfigMap(p unsafe.Pointer) {
+	for i := 0; ; i++ {
+		e := (*C.struct_flbgo_config_map)(unsafe.Add(p, i*C.sizeof_struct_flbgo_config_map))
+		if e.name == nil {
+			break
+		}
+
+		C.free(unsafe.Pointer(e.name))
+		C.free(unsafe.Pointer(e.def_value))
+		C.free(unsafe.Pointer(e.desc))
+	}
+	C.free(p)
 }
 
 // FLBPluginInit this method gets invoked once by the fluent-bit runtime at initialisation phase.
@@ -111,73 +260,71 @@ func cleanup() int {
 //
 //export FLBPluginInit
 func FLBPluginInit(ptr unsafe.Pointer) int {
-	initWG.Add(1)
-	defer initWG.Done()
+	if err := startup.awaitRegistered("init"); err != nil {
+		return input.FLB_ERROR
+	}
+
+	// all the go proxy plugin structures start with the plugin name.
+	name := input.FLBPluginName(ptr)
+	startup.beginInit(name)
+	defer startup.endInit(name)
 
-	if theInput == nil && theOutput == nil {
-		fmt.Fprintf(os.Stderr, "no input or output registered\n")
+	r := lookupRegistration(name)
+	if r == nil {
+		fmt.Fprintf(os.Stderr, "no plugin registered\n")
 		return input.FLB_RETRY
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	var err error
-	if theInput != nil {
-		conf := &flbInputConfigLoader{ptr: ptr}
+	var (
+		conf   ConfigLoader
+		logger Logger
+		cmt    *cmetrics.Context
+		err    error
+	)
+	switch r.kind {
+	case inputKind:
+		conf, logger = &flbInputConfigLoader{ptr: ptr}, &flbInputLogger{ptr: ptr}
 		cmt, err = input.FLBPluginGetCMetricsContext(ptr)
-		if err != nil {
-			return input.FLB_ERROR
-		}
-		logger = &flbInputLogger{ptr: ptr}
-		fbit := &Fluentbit{
-			Conf:    conf,
-			Metrics: makeMetrics(cmt),
-			Logger:  logger,
-		}
-
-		err = theInput.Init(ctx, fbit)
-		if maxbuffered := fbit.Conf.String("go.MaxBufferedMessages"); maxbuffered != "" {
-			maxbuffered, err := strconv.Atoi(maxbuffered)
-			if err != nil {
-				maxBufferedMessages = maxbuffered
-			}
-		}
-	} else {
-		conf := &flbOutputConfigLoader{ptr: ptr}
+	case filterKind:
+		conf, logger = &flbFilterConfigLoader{ptr: ptr}, &flbFilterLogger{ptr: ptr}
+		cmt, err = filter.FLBPluginGetCMetricsContext(ptr)
+	case processorKind:
+		conf, logger = &flbProcessorConfigLoader{ptr: ptr}, &flbProcessorLogger{ptr: ptr}
+		cmt, err = processor.FLBPluginGetCMetricsContext(ptr)
+	default:
+		conf, logger = &flbOutputConfigLoader{ptr: ptr}, &flbOutputLogger{ptr: ptr}
 		cmt, err = output.FLBPluginGetCMetricsContext(ptr)
-		if err != nil {
-			return output.FLB_ERROR
-		}
-		logger = &flbOutputLogger{ptr: ptr}
-		fbit := &Fluentbit{
-			Conf:    conf,
-			Metrics: makeMetrics(cmt),
-			Logger:  logger,
-		}
-		err = theOutput.Init(ctx, fbit)
 	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "init: %v\n", err)
 		return input.FLB_ERROR
 	}
 
-	return input.FLB_OK
+	conf = r.configLoader(conf)
+	info := instanceInfo(ptr, r.name, conf)
+	inst, ret := r.initInstance(info, conf, logger, func(prefix metricsPrefix) Metrics {
+		return makeMetrics(cmt, prefix)
+	})
+	if inst != nil {
+		// each [OUTPUT] section gets its own context, handed back by
+		// fluent-bit to FLBPluginFlushCtx and FLBPluginExitCtx.
+		output.FLBPluginSetContext(ptr, inst)
+	}
+
+	return ret
 }
 
-// flbPluginReset is meant to reset the plugin between tests.
-func flbPluginReset() {
-	theInputLock.Lock()
-	defer theInputLock.Unlock()
-	defer func() {
-		if ret := recover(); ret != nil {
-			fmt.Fprintf(os.Stderr, "Channel is already closed")
-			return
-		}
-	}()
+// testExitCtx exits the instance through FLBPluginExitCtx, with the context fluent-bit
+// hands back to it, nil exiting an unknown context. It is a testing utility.
+func testExitCtx(o *outputInstance) int {
+	if o == nil {
+		return FLBPluginExitCtx(nil)
+	}
+
+	var ctx C.struct_flbgo_test_proxy_context
+	plugin := C.struct_flbgo_test_output_plugin{context: &ctx}
+	output.FLBPluginSetContext(unsafe.Pointer(&plugin), o)
 
-	close(theChannel)
-	theInput = nil
+	return FLBPluginExitCtx(ctx.remote_context)
 }
 
 func testFLBPluginInputCallback() ([]byte, error) {
@@ -190,51 +337,132 @@ func testFLBPluginInputCallback() ([]byte, error) {
 		return []byte{}, nil
 	}
 
-	defer C.free(data)
-	return C.GoBytes(data, C.int(csize)), nil
+	defer FLBPluginInputCleanupCallback(data)
+	return cmem.Copy(data, int(csize)), nil
 }
 
-// prepareOutputFlush is a testing utility.
-func prepareOutputFlush(output OutputPlugin) error {
-	theOutput = output
-	FLBPluginOutputPreRun(0)
-	return nil
+// testFlush flushes b to the instance from C memory, overwritten and freed once the
+// callback returns like fluent-bit does, it is a testing utility.
+func testFlush(o *outputInstance, tag string, b []byte) int {
+	data, err := cmem.Own(b)
+	if err != nil {
+		panic(err)
+	}
+	defer cmem.Free(data)
+
+	ctag := C.CString(tag)
+	defer C.free(unsafe.Pointer(ctag))
+
+	ret := o.flush(data, C.int(len(b)), ctag)
+	clear(cmem.Borrow(data, len(b)))
+
+	return ret
+}
+
+// testSetOutBuffer is a testing utility.
+func testSetOutBuffer(b []byte) (unsafe.Pointer, int, error) {
+	var (
+		p    unsafe.Pointer
+		size C.size_t
+	)
+	err := setOutBuffer(&p, &size, b)
+	return p, int(size), err
+}
+
+// testFree frees memory like fluent-bit does, it is a testing utility.
+func testFree(p unsafe.Pointer) {
+	C.free(p)
 }
 
-// Lock used to synchronize access to theInput variable.
-var theInputLock sync.Mutex
+// FLBPluginReload this method gets invoked by the fluent-bit runtime when the configuration of a
+// running plugin instance changes. Plugins implementing Reloader apply the new configuration in place,
+// otherwise a retry is returned so the instance gets recreated instead.
+//
+//export FLBPluginReload
+func FLBPluginReload(ptr unsafe.Pointer) int {
+	if err := startup.awaitInitialized("reload"); err != nil {
+		return input.FLB_RETRY
+	}
 
-// prepareInputCollector is meant to prepare resources for input collectors
-func prepareInputCollector(multiInstance bool) {
-	runCtx, runCancel = context.WithCancel(context.Background())
-	if !multiInstance {
-		theChannel = make(chan Message, maxBufferedMessages)
+	r := lookupRegistration(input.FLBPluginName(ptr))
+	if r == nil {
+		fmt.Fprintf(os.Stderr, "no plugin registered\n")
+		return input.FLB_RETRY
 	}
 
-	theInputLock.Lock()
-	if multiInstance {
-		if theChannel == nil {
-			theChannel = make(chan Message, maxBufferedMessages)
+	var (
+		plug  any
+		conf  ConfigLoader
+		state = &r.runState
+	)
+	switch r.kind {
+	case inputKind:
+		plug, conf = r.input, r.configLoader(&flbInputConfigLoader{ptr: ptr})
+	case filterKind:
+		plug, conf = r.filter, r.configLoader(&flbFilterConfigLoader{ptr: ptr})
+	case processorKind:
+		plug, conf = r.processor, r.configLoader(&flbProcessorConfigLoader{ptr: ptr})
+	default:
+		inst, ok := output.FLBPluginInstanceContext(ptr).(*outputInstance)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "no output instance\n")
+			return output.FLB_RETRY
 		}
-		defer theInputLock.Unlock()
+
+		plug, conf, state = inst.plugin(), r.configLoader(&flbOutputConfigLoader{ptr: ptr}), &inst.runState
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ok, err := state.reloadPlugin(ctx, plug, conf)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reload: %v\n", err)
+		return input.FLB_ERROR
+	}
+
+	if !ok {
+		return input.FLB_RETRY
 	}
 
-	go func(theChannel chan<- Message) {
-		if !multiInstance {
-			defer theInputLock.Unlock()
+	return input.FLB_OK
+}
+
+// FLBPluginHealth this method gets invoked by the fluent-bit runtime to check the health of a plugin
+// instance, plugins not implementing HealthChecker are always reported as healthy.
+//
+//export FLBPluginHealth
+func FLBPluginHealth(ptr unsafe.Pointer) int {
+	if err := startup.awaitInitialized("health"); err != nil {
+		return input.FLB_RETRY
+	}
+
+	r := lookupRegistration(input.FLBPluginName(ptr))
+	if r == nil {
+		fmt.Fprintf(os.Stderr, "no plugin registered\n")
+		return input.FLB_RETRY
+	}
+
+	state := &r.runState
+	if r.kind == outputKind {
+		inst, ok := output.FLBPluginInstanceContext(ptr).(*outputInstance)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "no output instance\n")
+			return output.FLB_RETRY
 		}
+		state = &inst.runState
+	}
 
-		go func(theChannel chan<- Message) {
-			err := theInput.Collect(runCtx, theChannel)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "collect error: %v\n", err)
-			}
-		}(theChannel)
+	if state.health == nil {
+		return input.FLB_OK
+	}
 
-		<-runCtx.Done()
+	if err := state.health.check(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "health: name=%q: %v\n", r.name, err)
+		return input.FLB_ERROR
+	}
 
-		log.Printf("goroutine will be stopping: name=%q\n", theName)
-	}(theChannel)
+	return input.FLB_OK
 }
 
 // FLBPluginInputPreRun this method gets invoked by the fluent-bit runtime, once the plugin has been
@@ -242,9 +470,22 @@ func prepareInputCollector(multiInstance bool) {
 //
 //export FLBPluginInputPreRun
 func FLBPluginInputPreRun(useHotReload C.int) int {
-	registerWG.Wait()
+	if err := startup.awaitRegistered("input pre-run"); err != nil {
+		return input.FLB_RETRY
+	}
+
+	r := registrationOf(inputKind)
+	if r == nil {
+		fmt.Fprintf(os.Stderr, "no input registered\n")
+		return input.FLB_RETRY
+	}
 
-	prepareInputCollector(true)
+	if err := r.onStart(r.input); err != nil {
+		fmt.Fprintf(os.Stderr, "start: %v\n", err)
+		return input.FLB_ERROR
+	}
+
+	r.prepareInputCollector()
 
 	return input.FLB_OK
 }
@@ -254,19 +495,9 @@ func FLBPluginInputPreRun(useHotReload C.int) int {
 //
 //export FLBPluginInputPause
 func FLBPluginInputPause() {
-	if runCancel != nil {
-		runCancel()
-		runCancel = nil
-	}
-
-	if !theInputLock.TryLock() {
-		return
-	}
-	defer theInputLock.Unlock()
-
-	if theChannel != nil {
-		close(theChannel)
-		theChannel = nil
+	if r := registrationOf(inputKind); r != nil {
+		r.pauseInput()
+		r.onPause(r.input)
 	}
 }
 
@@ -275,7 +506,10 @@ func FLBPluginInputPause() {
 //
 //export FLBPluginInputResume
 func FLBPluginInputResume() {
-	prepareInputCollector(true)
+	if r := registrationOf(inputKind); r != nil {
+		r.onResume(r.input)
+		r.resumeInput()
+	}
 }
 
 // FLBPluginOutputPreExit this method gets invoked by the fluent-bit runtime, once the plugin has been
@@ -283,19 +517,52 @@ func FLBPluginInputResume() {
 //
 //export FLBPluginOutputPreExit
 func FLBPluginOutputPreExit() {
-	if runCancel != nil {
-		runCancel()
-		runCancel = nil
+	if r := registrationOf(outputKind); r != nil {
+		for _, inst := range r.outputInstances() {
+			inst.stop()
+		}
 	}
+}
 
-	if !theInputLock.TryLock() {
-		return
+// FLBPluginOutputPause this method gets invoked by the fluent-bit runtime when it pauses the output,
+// e.g. while retrying its chunks. Without an instance context all the instances are paused.
+//
+//export FLBPluginOutputPause
+func FLBPluginOutputPause() {
+	if r := registrationOf(outputKind); r != nil {
+		for _, inst := range r.outputInstances() {
+			inst.pause()
+		}
+	}
+}
+
+// FLBPluginOutputResume this method gets invoked by the fluent-bit runtime when it resumes the
+// paused output.
+//
+//export FLBPluginOutputResume
+func FLBPluginOutputResume() {
+	if r := registrationOf(outputKind); r != nil {
+		for _, inst := range r.outputInstances() {
+			inst.resume()
+		}
 	}
-	defer theInputLock.Unlock()
+}
+
+// FLBPluginOutputPauseCtx is invoked instead of FLBPluginOutputPause to pause a single output instance.
+//
+//export FLBPluginOutputPauseCtx
+func FLBPluginOutputPauseCtx(ctx unsafe.Pointer) {
+	if inst, ok := output.FLBPluginGetContext(ctx).(*outputInstance); ok {
+		inst.pause()
+	}
+}
 
-	if theChannel != nil {
-		close(theChannel)
-		theChannel = nil
+// FLBPluginOutputResumeCtx is invoked instead of FLBPluginOutputResume to resume a single output instance.
+//
+//export FLBPluginOutputResumeCtx
+func FLBPluginOutputResumeCtx(ctx unsafe.Pointer) {
+	if inst, ok := output.FLBPluginGetContext(ctx).(*outputInstance); ok {
+		inst.resume()
 	}
 }
 
@@ -303,24 +570,21 @@ func FLBPluginOutputPreExit() {
 //
 //export FLBPluginOutputPreRun
 func FLBPluginOutputPreRun(useHotReload C.int) int {
-	registerWG.Wait()
-
-	var err error
-	runCtx, runCancel = context.WithCancel(context.Background())
-	theChannel = make(chan Message)
-	go func(runCtx context.Context) {
-		go func(runCtx context.Context) {
-			err = theOutput.Flush(runCtx, theChannel)
-		}(runCtx)
-
-		<-runCtx.Done()
+	if err := startup.awaitRegistered("output pre-run"); err != nil {
+		return output.FLB_RETRY
+	}
 
-		log.Printf("goroutine will be stopping: name=%q\n", theName)
-	}(runCtx)
+	r := registrationOf(outputKind)
+	if r == nil {
+		fmt.Fprintf(os.Stderr, "no output registered\n")
+		return output.FLB_RETRY
+	}
 
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "run: %s\n", err)
-		return output.FLB_ERROR
+	for _, inst := range r.outputInstances() {
+		if err := inst.run(); err != nil {
+			fmt.Fprintf(os.Stderr, "run: %s\n", err)
+			return output.FLB_ERROR
+		}
 	}
 
 	return output.FLB_OK
@@ -336,63 +600,129 @@ func FLBPluginOutputPreRun(useHotReload C.int) int {
 //
 //export FLBPluginInputCallback
 func FLBPluginInputCallback(data *unsafe.Pointer, csize *C.size_t) int {
-	initWG.Wait()
+	if err := startup.awaitInitialized("input callback"); err != nil {
+		return input.FLB_RETRY
+	}
 
-	if theInput == nil {
+	r := registrationOf(inputKind)
+	if r == nil {
 		fmt.Fprintf(os.Stderr, "no input registered\n")
 		return input.FLB_RETRY
 	}
+	b, full, err := r.collectLogs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "collect: %s\n", err)
+		return input.FLB_ERROR
+	}
 
-	buf := bytes.NewBuffer([]byte{})
-
-	for loop := min(len(theChannel), maxBufferedMessages); loop > 0; loop-- {
-		select {
-		case msg, ok := <-theChannel:
-			if !ok {
-				return input.FLB_ERROR
-			}
-
-			b, err := msgpack.Marshal([]any{&EventTime{msg.Time}, msg.Record})
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "msgpack marshal: %s\n", err)
-				return input.FLB_ERROR
-			}
-
-			buf.Grow(len(b))
-			buf.Write(b)
-		case <-runCtx.Done():
-			err := runCtx.Err()
-			if err != nil && !errors.Is(err, context.Canceled) {
-				fmt.Fprintf(os.Stderr, "run: %s\n", err)
-				return input.FLB_ERROR
-			}
-			// enforce a runtime gc, to prevent the thread finalizer on
-			// fluent-bit to kick in before any remaining data has not been GC'ed
-			// causing a sigsegv.
-			defer runtime.GC()
-			loop = 0
-		default:
-			loop = 0
-		}
+	if err := setInputBuffer(r.inputBuf, data, csize, b); err != nil {
+		fmt.Fprintf(os.Stderr, "input: %s\n", err)
+		return input.FLB_ERROR
 	}
 
-	if buf.Len() > 0 {
-		b := buf.Bytes()
-		cdata := C.CBytes(b)
-		*data = cdata
-		if csize != nil {
-			*csize = C.size_t(len(b))
-		}
+	if full {
+		return input.FLB_RETRY
 	}
 
 	return input.FLB_OK
 }
 
-// FLBPluginInputCleanupCallback releases the memory used during the input callback
+// FLBPluginInputCollectInterval is invoked by fluent-bit after initializing the input to
+// get the interval, in nanoseconds, between the input callbacks.
+//
+//export FLBPluginInputCollectInterval
+func FLBPluginInputCollectInterval() C.longlong {
+	r := registrationOf(inputKind)
+	if r == nil || r.inputInterval <= 0 {
+		return C.longlong(defaultCollectInterval)
+	}
+
+	return C.longlong(r.inputInterval)
+}
+
+// FLBPluginInputDone is invoked by fluent-bit after the input callbacks to know whether a
+// one-shot input collected and delivered all its records, so it can be stopped.
+//
+//export FLBPluginInputDone
+func FLBPluginInputDone() C.int {
+	r := registrationOf(inputKind)
+	if r == nil || !r.inputDone() {
+		return 0
+	}
+
+	return 1
+}
+
+// FLBPluginInputMetricsCallback this method gets invoked by the fluent-bit runtime to collect the metrics
+// events of inputs implementing MetricsInput, the buffered contexts are returned as a single cmetrics
+// msgpack payload that gets appended to the pipeline as metrics instead of log records.
+//
+//export FLBPluginInputMetricsCallback
+func FLBPluginInputMetricsCallback(data *unsafe.Pointer, csize *C.size_t) int {
+	if err := startup.awaitInitialized("input metrics callback"); err != nil {
+		return input.FLB_RETRY
+	}
+
+	r := registrationOf(inputKind)
+	if r == nil {
+		fmt.Fprintf(os.Stderr, "no input registered\n")
+		return input.FLB_RETRY
+	}
+
+	b, err := r.collectMetrics()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "collect metrics: %s\n", err)
+		return input.FLB_ERROR
+	}
+
+	if err := setInputBuffer(r.inputBuf, data, csize, b); err != nil {
+		fmt.Fprintf(os.Stderr, "input: %s\n", err)
+		return input.FLB_ERROR
+	}
+
+	return input.FLB_OK
+}
+
+// FLBPluginInputTracesCallback this method gets invoked by the fluent-bit runtime to collect the trace
+// events of inputs implementing TracesInput, the buffered traces are returned as a single ctraces
+// msgpack payload that gets appended to the pipeline as traces instead of log records.
+//
+//export FLBPluginInputTracesCallback
+func FLBPluginInputTracesCallback(data *unsafe.Pointer, csize *C.size_t) int {
+	if err := startup.awaitInitialized("input traces callback"); err != nil {
+		return input.FLB_RETRY
+	}
+
+	r := registrationOf(inputKind)
+	if r == nil {
+		fmt.Fprintf(os.Stderr, "no input registered\n")
+		return input.FLB_RETRY
+	}
+
+	b, err := r.collectTraces()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "collect traces: %s\n", err)
+		return input.FLB_ERROR
+	}
+
+	if err := setInputBuffer(r.inputBuf, data, csize, b); err != nil {
+		fmt.Fprintf(os.Stderr, "input: %s\n", err)
+		return input.FLB_ERROR
+	}
+
+	return input.FLB_OK
+}
+
+// FLBPluginInputCleanupCallback releases the memory lent to fluent-bit by the input callbacks.
 //
 //export FLBPluginInputCleanupCallback
 func FLBPluginInputCleanupCallback(data unsafe.Pointer) int {
-	C.free(data)
+	var buf *cmem.Buffer
+	if r := registrationOf(inputKind); r != nil {
+		buf = r.inputBuf
+	}
+
+	buf.Release(data)
 	return input.FLB_OK
 }
 
@@ -401,110 +731,189 @@ func FLBPluginInputCleanupCallback(data unsafe.Pointer) int {
 //
 //export FLBPluginFlush
 func FLBPluginFlush(data unsafe.Pointer, clength C.int, ctag *C.char) int {
-	initWG.Wait()
+	if err := startup.awaitInitialized("flush"); err != nil {
+		return output.FLB_RETRY
+	}
 
-	if theOutput == nil {
+	r := registrationOf(outputKind)
+	if r == nil {
 		fmt.Fprintf(os.Stderr, "no output registered\n")
 		return output.FLB_RETRY
 	}
 
-	var err error
-	select {
-	case <-runCtx.Done():
-		err = runCtx.Err()
-		if err != nil && !errors.Is(err, context.Canceled) {
-			fmt.Fprintf(os.Stderr, "run: %s\n", err)
-			return output.FLB_ERROR
-		}
+	// without an instance context fluent-bit runs a single instance.
+	inst := r.firstOutputInstance()
+	if inst == nil {
+		fmt.Fprintf(os.Stderr, "no output instance\n")
+		return output.FLB_RETRY
+	}
 
-		return output.FLB_OK
-	default:
+	return inst.flush(data, clength, ctag)
+}
+
+// FLBPluginFlushCtx callback gets invoked by the fluent-bit runtime instead of FLBPluginFlush,
+// the context set at initialisation identifies the output instance the data is flushed to.
+//
+//export FLBPluginFlushCtx
+func FLBPluginFlushCtx(ctx, data unsafe.Pointer, clength C.int, ctag *C.char) int {
+	if err := startup.awaitInitialized("flush"); err != nil {
+		return output.FLB_RETRY
 	}
 
-	in := C.GoBytes(data, clength)
-	tag := C.GoString(ctag)
-	if err := pluginFlush(tag, in); err != nil {
-		fmt.Fprintf(os.Stderr, "flush: %s\n", err)
-		return output.FLB_ERROR
+	inst, ok := output.FLBPluginGetContext(ctx).(*outputInstance)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "no output instance\n")
+		return output.FLB_RETRY
 	}
 
-	return output.FLB_OK
+	return inst.flush(data, clength, ctag)
 }
 
-func pluginFlush(tag string, b []byte) error {
-	dec := msgpack.NewDecoder(bytes.NewReader(b))
-	for {
-		select {
-		case <-runCtx.Done():
-			err := runCtx.Err()
-			if err != nil && !errors.Is(err, context.Canceled) {
-				fmt.Fprintf(os.Stderr, "run: %s\n", err)
-				return fmt.Errorf("run: %w", err)
-			}
+// flush flushes the chunk of a flush callback to the instance. The chunk is decoded
+// straight from the memory of fluent-bit, which is only valid during the callback: the
+// decoders copy the records out of it, and handleFlush must not retain it once returned.
+func (o *outputInstance) flush(data unsafe.Pointer, clength C.int, ctag *C.char) int {
+	// the callback runs on the thread of the worker invoking it.
+	worker := o.workerID(output.FLBPluginThreadID())
 
-			return nil
-		default:
-		}
+	in := cmem.Borrow(data, int(clength))
+	if o.copyChunks {
+		in = cmem.Copy(data, int(clength))
+	}
 
-		msg, err := decodeMsg(dec, tag)
-		if errors.Is(err, io.EOF) {
-			return nil
-		}
+	return o.handleFlush(worker, C.GoString(ctag), in)
+}
 
-		if err != nil {
-			return err
-		}
+// FLBPluginFilter callback gets invoked by the fluent-bit runtime for every chunk going through
+// the filter, the filtered records are written back into outBuf and outSize.
+// An empty result drops all the records from the chunk.
+//
+//export FLBPluginFilter
+func FLBPluginFilter(data unsafe.Pointer, clength C.int, ctag *C.char, outBuf *unsafe.Pointer, outSize *C.size_t) int {
+	if err := startup.awaitInitialized("filter"); err != nil {
+		return filter.FLB_FILTER_NOTOUCH
+	}
 
-		theChannel <- msg
+	r := registrationOf(filterKind)
+	if r == nil {
+		fmt.Fprintf(os.Stderr, "no filter registered\n")
+		return filter.FLB_FILTER_NOTOUCH
 	}
+	in, out := pooledChunk(data, clength), getBuffer()
+	defer putBuffer(in)
+	defer putBuffer(out)
+
+	b, err := r.runFilter(C.GoString(ctag), in.Bytes(), out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "filter: %s\n", err)
+		return filter.FLB_FILTER_NOTOUCH
+	}
+
+	if err := setOutBuffer(outBuf, outSize, b); err != nil {
+		fmt.Fprintf(os.Stderr, "filter: %s\n", err)
+		return filter.FLB_FILTER_NOTOUCH
+	}
+
+	return filter.FLB_FILTER_MODIFIED
 }
 
-// decodeMsg should be called with an already initialized decoder.
-func decodeMsg(dec *msgpack.Decoder, tag string) (Message, error) {
-	var out Message
+// FLBPluginProcessLogs callback gets invoked by the fluent-bit runtime for every chunk of logs
+// going through the processor, the processed records are written back into outBuf and outSize.
+// An empty result drops all the records from the chunk.
+//
+//export FLBPluginProcessLogs
+func FLBPluginProcessLogs(data unsafe.Pointer, clength C.int, ctag *C.char, outBuf *unsafe.Pointer, outSize *C.size_t) int {
+	return process("logs", data, clength, ctag, outBuf, outSize)
+}
+
+// FLBPluginProcessMetrics callback gets invoked by the fluent-bit runtime for every cmetrics payload
+// going through the processor, the processed metrics are written back into outBuf and outSize.
+//
+//export FLBPluginProcessMetrics
+func FLBPluginProcessMetrics(data unsafe.Pointer, clength C.int, ctag *C.char, outBuf *unsafe.Pointer, outSize *C.size_t) int {
+	return process("metrics", data, clength, ctag, outBuf, outSize)
+}
 
-	var entry []msgpack.RawMessage
-	err := dec.Decode(&entry)
-	if errors.Is(err, io.EOF) {
-		return out, err
+// FLBPluginProcessTraces callback gets invoked by the fluent-bit runtime for every ctraces payload
+// going through the processor, the processed traces are written back into outBuf and outSize.
+//
+//export FLBPluginProcessTraces
+func FLBPluginProcessTraces(data unsafe.Pointer, clength C.int, ctag *C.char, outBuf *unsafe.Pointer, outSize *C.size_t) int {
+	return process("traces", data, clength, ctag, outBuf, outSize)
+}
+
+// process runs the processor callback of the given event type.
+func process(event string, data unsafe.Pointer, clength C.int, ctag *C.char, outBuf *unsafe.Pointer, outSize *C.size_t) int {
+	if err := startup.awaitInitialized(event + " processor"); err != nil {
+		return processor.FLB_ERROR
 	}
 
+	r := registrationOf(processorKind)
+	if r == nil {
+		fmt.Fprintf(os.Stderr, "no processor registered\n")
+		return processor.FLB_ERROR
+	}
+
+	in, out := pooledChunk(data, clength), getBuffer()
+	defer putBuffer(in)
+	defer putBuffer(out)
+
+	b, err := r.runProcessor(event, C.GoString(ctag), in.Bytes(), out)
 	if err != nil {
-		return out, fmt.Errorf("msgpack unmarshal: %w", err)
+		fmt.Fprintf(os.Stderr, "process %s: %s\n", event, err)
+		return processor.FLB_ERROR
 	}
 
-	if l := len(entry); l < 2 {
-		return out, fmt.Errorf("msgpack unmarshal: expected 2 elements, got %d", l)
+	if err := setOutBuffer(outBuf, outSize, b); err != nil {
+		fmt.Fprintf(os.Stderr, "process: %s\n", err)
+		return processor.FLB_ERROR
 	}
 
-	eventTime := &EventTime{}
-	if err := msgpack.Unmarshal(entry[0], &eventTime); err != nil {
-		var eventWithMetadata []msgpack.RawMessage // for Fluent Bit V2 metadata type of format
-		if err := msgpack.Unmarshal(entry[0], &eventWithMetadata); err != nil {
-			return out, fmt.Errorf("msgpack unmarshal event with metadata: %w", err)
-		}
+	return processor.FLB_OK
+}
 
-		if len(eventWithMetadata) < 1 {
-			return out, fmt.Errorf("msgpack unmarshal event time with metadata: expected 1 element, got %d", len(eventWithMetadata))
-		}
+// pooledChunk copies the chunk of a callback into a pooled buffer, put back once the
+// callback is done with it.
+func pooledChunk(data unsafe.Pointer, clength C.int) *bytes.Buffer {
+	buf := getBuffer()
+	buf.Write(cmem.Borrow(data, int(clength)))
+	return buf
+}
 
-		if err := msgpack.Unmarshal(eventWithMetadata[0], &eventTime); err != nil {
-			return out, fmt.Errorf("msgpack unmarshal event time with metadata: %w", err)
-		}
+// setOutBuffer copies b into C memory handed over to fluent-bit, which frees it.
+func setOutBuffer(outBuf *unsafe.Pointer, outSize *C.size_t, b []byte) error {
+	*outBuf = nil
+	*outSize = 0
 
-		return out, fmt.Errorf("msgpack unmarshal event time: %w", err)
+	p, err := cmem.Own(b)
+	if err != nil {
+		return err
 	}
 
-	var record map[string]any
-	if err := msgpack.Unmarshal(entry[1], &record); err != nil {
-		return out, fmt.Errorf("msgpack unmarshal event record: %w", err)
+	cmem.HandOver(p)
+	if p != nil {
+		*outBuf = p
+		*outSize = C.size_t(len(b))
 	}
 
-	out.Time = eventTime.Time.UTC()
-	out.Record = record
-	out.tag = &tag
+	return nil
+}
+
+// setInputBuffer lends b to fluent-bit, which gives it back through
+// FLBPluginInputCleanupCallback. b is copied into the input buffer unless it was
+// written in place. data is left untouched when b is empty.
+func setInputBuffer(buf *cmem.Buffer, data *unsafe.Pointer, csize *C.size_t, b []byte) error {
+	p, err := buf.Lend(b)
+	if err != nil || p == nil {
+		return err
+	}
 
-	return out, nil
+	*data = p
+	if csize != nil {
+		*csize = C.size_t(len(b))
+	}
+
+	return nil
 }
 
 // FLBPluginExit method is invoked once the plugin instance is exited from the fluent-bit context.
@@ -514,6 +923,29 @@ func FLBPluginExit() int {
 	return cleanup()
 }
 
+// FLBPluginExitCtx method is invoked instead of FLBPluginExit once an output instance
+// is exited from the fluent-bit context. Each exit counts toward the last one, which
+// releases the plugins like FLBPluginExit.
+//
+//export FLBPluginExitCtx
+func FLBPluginExitCtx(ctx unsafe.Pointer) int {
+	inst, ok := output.FLBPluginGetContext(ctx).(*outputInstance)
+	if !ok {
+		return cleanup()
+	}
+
+	inst.stop()
+	inst.health.stop()
+	inst.runtime.stop()
+	inst.pprof.stop()
+	inst.watchdog.stop()
+	inst.onStop(inst.plugin())
+	inst.reg.removeOutputInstance(inst)
+	output.FLBPluginDeleteContext(ctx)
+
+	return cleanup()
+}
+
 type flbInputConfigLoader struct {
 	ptr unsafe.Pointer
 }
@@ -537,6 +969,34 @@ func unquote(s string) string {
 	return s
 }
 
+func (f *flbInputConfigLoader) Keys() []string {
+	return declaredConfigKeys(f, f.ptr)
+}
+
+func (f *flbInputConfigLoader) All() map[string]string {
+	return configAll(f)
+}
+
+// declaredConfigKeys returns the keys declared with WithConfigMap which are set,
+// as the proxy API only supports lookups by key.
+func declaredConfigKeys(conf ConfigLoader, ptr unsafe.Pointer) []string {
+	// all the go proxy plugin structures start with the plugin name.
+	r := lookupRegistration(input.FLBPluginName(ptr))
+	if r == nil {
+		return nil
+	}
+
+	var keys []string
+	for _, opt := range r.configMap {
+		if opt.Type != ConfigStringPrefix && conf.String(opt.Name) != "" {
+			keys = append(keys, opt.Name)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
 type flbOutputConfigLoader struct {
 	ptr unsafe.Pointer
 }
@@ -545,58 +1005,195 @@ func (f *flbOutputConfigLoader) String(key string) string {
 	return unquote(output.FLBPluginConfigKey(f.ptr, key))
 }
 
+func (f *flbOutputConfigLoader) Keys() []string {
+	return declaredConfigKeys(f, f.ptr)
+}
+
+func (f *flbOutputConfigLoader) All() map[string]string {
+	return configAll(f)
+}
+
+type flbFilterConfigLoader struct {
+	ptr unsafe.Pointer
+}
+
+func (f *flbFilterConfigLoader) String(key string) string {
+	return unquote(filter.FLBPluginConfigKey(f.ptr, key))
+}
+
+func (f *flbFilterConfigLoader) Keys() []string {
+	return declaredConfigKeys(f, f.ptr)
+}
+
+func (f *flbFilterConfigLoader) All() map[string]string {
+	return configAll(f)
+}
+
+type flbProcessorConfigLoader struct {
+	ptr unsafe.Pointer
+}
+
+func (f *flbProcessorConfigLoader) String(key string) string {
+	return unquote(processor.FLBPluginConfigKey(f.ptr, key))
+}
+
+func (f *flbProcessorConfigLoader) Keys() []string {
+	return declaredConfigKeys(f, f.ptr)
+}
+
+func (f *flbProcessorConfigLoader) All() map[string]string {
+	return configAll(f)
+}
+
+// logMessage formats a message for the fluent-bit logger. The lines already formatted by
+// the instance loggers are passed through, not copied once more.
+func logMessage(format string, a []any) string {
+	if format == "%s" && len(a) == 1 {
+		if s, ok := a[0].(string); ok {
+			return s
+		}
+	}
+
+	return fmt.Sprintf(format, a...)
+}
+
 type flbInputLogger struct {
 	ptr unsafe.Pointer
 }
 
+func (f *flbInputLogger) logLevel() LogLevel {
+	return logLevelOf(func(level int) bool { return input.FLBPluginLogCheck(f.ptr, level) })
+}
+
 func (f *flbInputLogger) Error(format string, a ...any) {
-	message := fmt.Sprintf(format, a...)
+	message := logMessage(format, a)
 	input.FLBPluginLogPrint(f.ptr, input.FLB_LOG_ERROR, message)
 }
 
 func (f *flbInputLogger) Warn(format string, a ...any) {
-	message := fmt.Sprintf(format, a...)
+	message := logMessage(format, a)
 	input.FLBPluginLogPrint(f.ptr, input.FLB_LOG_WARN, message)
 }
 
 func (f *flbInputLogger) Info(format string, a ...any) {
-	message := fmt.Sprintf(format, a...)
+	message := logMessage(format, a)
 	input.FLBPluginLogPrint(f.ptr, input.FLB_LOG_INFO, message)
 }
 
 func (f *flbInputLogger) Debug(format string, a ...any) {
-	message := fmt.Sprintf(format, a...)
+	message := logMessage(format, a)
 	input.FLBPluginLogPrint(f.ptr, input.FLB_LOG_DEBUG, message)
 }
 
+func (f *flbInputLogger) Trace(format string, a ...any) {
+	message := logMessage(format, a)
+	input.FLBPluginLogPrint(f.ptr, input.FLB_LOG_TRACE, message)
+}
+
 type flbOutputLogger struct {
 	ptr unsafe.Pointer
 }
 
+func (f *flbOutputLogger) logLevel() LogLevel {
+	return logLevelOf(func(level int) bool { return output.FLBPluginLogCheck(f.ptr, level) })
+}
+
 func (f *flbOutputLogger) Error(format string, a ...any) {
-	message := fmt.Sprintf(format, a...)
+	message := logMessage(format, a)
 	output.FLBPluginLogPrint(f.ptr, output.FLB_LOG_ERROR, message)
 }
 
 func (f *flbOutputLogger) Warn(format string, a ...any) {
-	message := fmt.Sprintf(format, a...)
+	message := logMessage(format, a)
 	output.FLBPluginLogPrint(f.ptr, output.FLB_LOG_WARN, message)
 }
 
 func (f *flbOutputLogger) Info(format string, a ...any) {
-	message := fmt.Sprintf(format, a...)
+	message := logMessage(format, a)
 	output.FLBPluginLogPrint(f.ptr, output.FLB_LOG_INFO, message)
 }
 
 func (f *flbOutputLogger) Debug(format string, a ...any) {
-	message := fmt.Sprintf(format, a...)
+	message := logMessage(format, a)
 	output.FLBPluginLogPrint(f.ptr, output.FLB_LOG_DEBUG, message)
 }
 
-func makeMetrics(cmp *cmetrics.Context) Metrics {
+func (f *flbOutputLogger) Trace(format string, a ...any) {
+	message := logMessage(format, a)
+	output.FLBPluginLogPrint(f.ptr, output.FLB_LOG_TRACE, message)
+}
+
+type flbFilterLogger struct {
+	ptr unsafe.Pointer
+}
+
+func (f *flbFilterLogger) logLevel() LogLevel {
+	return logLevelOf(func(level int) bool { return filter.FLBPluginLogCheck(f.ptr, level) })
+}
+
+func (f *flbFilterLogger) Error(format string, a ...any) {
+	message := logMessage(format, a)
+	filter.FLBPluginLogPrint(f.ptr, filter.FLB_LOG_ERROR, message)
+}
+
+func (f *flbFilterLogger) Warn(format string, a ...any) {
+	message := logMessage(format, a)
+	filter.FLBPluginLogPrint(f.ptr, filter.FLB_LOG_WARN, message)
+}
+
+func (f *flbFilterLogger) Info(format string, a ...any) {
+	message := logMessage(format, a)
+	filter.FLBPluginLogPrint(f.ptr, filter.FLB_LOG_INFO, message)
+}
+
+func (f *flbFilterLogger) Debug(format string, a ...any) {
+	message := logMessage(format, a)
+	filter.FLBPluginLogPrint(f.ptr, filter.FLB_LOG_DEBUG, message)
+}
+
+func (f *flbFilterLogger) Trace(format string, a ...any) {
+	message := logMessage(format, a)
+	filter.FLBPluginLogPrint(f.ptr, filter.FLB_LOG_TRACE, message)
+}
+
+type flbProcessorLogger struct {
+	ptr unsafe.Pointer
+}
+
+func (f *flbProcessorLogger) logLevel() LogLevel {
+	return logLevelOf(func(level int) bool { return processor.FLBPluginLogCheck(f.ptr, level) })
+}
+
+func (f *flbProcessorLogger) Error(format string, a ...any) {
+	message := logMessage(format, a)
+	processor.FLBPluginLogPrint(f.ptr, processor.FLB_LOG_ERROR, message)
+}
+
+func (f *flbProcessorLogger) Warn(format string, a ...any) {
+	message := logMessage(format, a)
+	processor.FLBPluginLogPrint(f.ptr, processor.FLB_LOG_WARN, message)
+}
+
+func (f *flbProcessorLogger) Info(format string, a ...any) {
+	message := logMessage(format, a)
+	processor.FLBPluginLogPrint(f.ptr, processor.FLB_LOG_INFO, message)
+}
+
+func (f *flbProcessorLogger) Debug(format string, a ...any) {
+	message := logMessage(format, a)
+	processor.FLBPluginLogPrint(f.ptr, processor.FLB_LOG_DEBUG, message)
+}
+
+func (f *flbProcessorLogger) Trace(format string, a ...any) {
+	message := logMessage(format, a)
+	processor.FLBPluginLogPrint(f.ptr, processor.FLB_LOG_TRACE, message)
+}
+
+// makeMetrics returns the metrics of an instance, prefixed with the given prefix.
+func makeMetrics(cmp *cmetrics.Context, prefix metricsPrefix) Metrics {
 	return &metricbuilder.Builder{
-		Namespace: "fluentbit",
-		SubSystem: "plugin",
+		Namespace: prefix.namespace,
+		SubSystem: prefix.subsystem,
 		Context:   cmp,
 		OnError: func(err error) {
 			fmt.Fprintf(os.Stderr, "metrics: %s\n", err)
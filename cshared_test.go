@@ -19,6 +19,10 @@ import (
 	"github.com/calyptia/plugin/metric"
 )
 
+// collectInterval is the interval between the input callbacks of the fluent-bit versions
+// not negotiating it, the callbacks being invoked continuously.
+const collectInterval = 1000 * time.Nanosecond
+
 type testPluginInputCallbackCtrlC struct{}
 
 func (t testPluginInputCallbackCtrlC) Init(ctx context.Context, fbit *Fluentbit) error {
@@ -30,7 +34,7 @@ func (t testPluginInputCallbackCtrlC) Collect(ctx context.Context, ch chan<- Mes
 }
 
 func init() {
-	registerWG.Done()
+	startup.registered()
 }
 
 func TestMain(m *testing.M) {
@@ -39,9 +43,7 @@ func TestMain(m *testing.M) {
 }
 
 func TestInputCallbackCtrlC(t *testing.T) {
-	theInputLock.Lock()
-	theInput = testPluginInputCallbackCtrlC{}
-	theInputLock.Unlock()
+	r := prepareInput(testPluginInputCallbackCtrlC{})
 
 	cdone := make(chan bool)
 	timeout := time.NewTimer(1 * time.Second)
@@ -50,7 +52,7 @@ func TestInputCallbackCtrlC(t *testing.T) {
 	ptr := unsafe.Pointer(nil)
 
 	// prepare channel for input explicitly.
-	prepareInputCollector(false)
+	r.prepareInputCollector()
 
 	go func() {
 		FLBPluginInputCallback(&ptr, nil)
@@ -60,7 +62,7 @@ func TestInputCallbackCtrlC(t *testing.T) {
 	select {
 	case <-cdone:
 		timeout.Stop()
-		runCancel()
+		r.runCancel()
 	case <-timeout.C:
 		t.Fatalf("timed out ...")
 	}
@@ -89,15 +91,13 @@ func (t testPluginInputCallbackDangle) Collect(ctx context.Context, ch chan<- Me
 // Collect multiple times. This is inline with backward-compatible
 // behavior.
 func TestInputCallbackDangle(t *testing.T) {
-	theInputLock.Lock()
-	theInput = testPluginInputCallbackDangle{}
-	theInputLock.Unlock()
+	r := prepareInput(testPluginInputCallbackDangle{})
 
 	cdone := make(chan bool)
 	ptr := unsafe.Pointer(nil)
 
 	// prepare channel for input explicitly.
-	prepareInputCollector(false)
+	r.prepareInputCollector()
 
 	go func() {
 		t := time.NewTicker(collectInterval)
@@ -118,7 +118,7 @@ func TestInputCallbackDangle(t *testing.T) {
 
 	<-timeout.C
 	timeout.Stop()
-	runCancel()
+	r.runCancel()
 	cdone <- true
 
 	// Test the assumption that only a single goroutine is
@@ -159,16 +159,14 @@ func (t testPluginInputCallbackInfinite) Collect(ctx context.Context, ch chan<-
 // TestInputCallbackInfinite is a test for the main method most plugins
 // use where they do not return from the first invocation of collect.
 func TestInputCallbackInfinite(t *testing.T) {
-	theInputLock.Lock()
-	theInput = testPluginInputCallbackInfinite{}
-	theInputLock.Unlock()
+	r := prepareInput(testPluginInputCallbackInfinite{})
 
 	cdone := make(chan bool)
 	cshutdown := make(chan bool)
 	ptr := unsafe.Pointer(nil)
 
 	// prepare channel for input explicitly.
-	prepareInputCollector(false)
+	r.prepareInputCollector()
 
 	go func() {
 		t := time.NewTicker(collectInterval)
@@ -193,7 +191,7 @@ func TestInputCallbackInfinite(t *testing.T) {
 
 	select {
 	case <-cdone:
-		runCancel()
+		r.runCancel()
 		// make sure Collect is not being invoked after Done().
 		time.Sleep(collectInterval * 10)
 		// Test the assumption that only a single goroutine is
@@ -204,7 +202,7 @@ func TestInputCallbackInfinite(t *testing.T) {
 		}
 		return
 	case <-timeout.C:
-		runCancel()
+		r.runCancel()
 		cshutdown <- true
 		// This test seems to fail some what frequently because the Collect goroutine
 		// inside cshared is never being scheduled.
@@ -241,16 +239,14 @@ func (t testPluginInputCallbackLatency) Collect(ctx context.Context, ch chan<- M
 // TestInputCallbackInfiniteLatency is a test of the latency between
 // messages.
 func TestInputCallbackLatency(t *testing.T) {
-	theInputLock.Lock()
-	theInput = testPluginInputCallbackLatency{}
-	theInputLock.Unlock()
+	r := prepareInput(testPluginInputCallbackLatency{})
 
 	cdone := make(chan bool)
 	cstarted := make(chan bool)
 	cmsg := make(chan []byte)
 
 	// prepare channel for input explicitly.
-	prepareInputCollector(false)
+	r.prepareInputCollector()
 
 	go func() {
 		t := time.NewTicker(collectInterval)
@@ -305,7 +301,7 @@ func TestInputCallbackLatency(t *testing.T) {
 				}
 			}
 		case <-timeout.C:
-			runCancel()
+			r.runCancel()
 			cdone <- true
 
 			if msgs < 128 {
@@ -316,6 +312,60 @@ func TestInputCallbackLatency(t *testing.T) {
 	}
 }
 
+// TestInputCallbackThreaded makes sure threaded inputs deliver records as soon
+// as they are collected, instead of waiting for the next callback.
+func TestInputCallbackThreaded(t *testing.T) {
+	r := prepareInput(testPluginInputCallbackCtrlC{})
+	r.inputThreaded, r.inputInterval = true, time.Minute
+
+	r.prepareInputCollector()
+	defer r.runCancel()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		r.channel <- Message{Time: time.Now(), Record: map[string]string{"Foo": "BAR"}}
+	}()
+
+	start := time.Now()
+	buf, err := testFLBPluginInputCallback()
+	assert.NoError(t, err)
+	assert.NotZero(t, len(buf))
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+// BenchmarkInputCallbackLatency measures the time between a record being
+// collected and its delivery by the input callback.
+func BenchmarkInputCallbackLatency(b *testing.B) {
+	for _, threaded := range []bool{false, true} {
+		b.Run(fmt.Sprintf("threaded=%t", threaded), func(b *testing.B) {
+			r := prepareInput(testPluginInputCallbackCtrlC{})
+			r.inputThreaded, r.inputInterval = threaded, time.Second
+
+			r.prepareInputCollector()
+			defer r.runCancel()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				go func() {
+					r.channel <- Message{Time: time.Now(), Record: map[string]string{"Foo": "BAR"}}
+				}()
+
+				for {
+					buf, err := testFLBPluginInputCallback()
+					if err != nil {
+						b.Fatal(err)
+					}
+					if len(buf) > 0 {
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
 type testInputCallbackInfiniteConcurrent struct{}
 
 var (
@@ -357,9 +407,7 @@ func (t testInputCallbackInfiniteConcurrent) Collect(ctx context.Context, ch cha
 // TestInputCallbackInfiniteConcurrent is meant to make sure we do not
 // break anythin with respect to concurrent ingest.
 func TestInputCallbackInfiniteConcurrent(t *testing.T) {
-	theInputLock.Lock()
-	theInput = testInputCallbackInfiniteConcurrent{}
-	theInputLock.Unlock()
+	r := prepareInput(testInputCallbackInfiniteConcurrent{})
 
 	cdone := make(chan bool)
 	cstarted := make(chan bool)
@@ -368,7 +416,7 @@ func TestInputCallbackInfiniteConcurrent(t *testing.T) {
 	concurrentWait.Add(64)
 
 	// prepare channel for input explicitly.
-	prepareInputCollector(false)
+	r.prepareInputCollector()
 
 	go func(cstarted chan bool) {
 		ticker := time.NewTicker(time.Second * 1)
@@ -381,7 +429,7 @@ func TestInputCallbackInfiniteConcurrent(t *testing.T) {
 			select {
 			case <-ticker.C:
 				FLBPluginInputCallback(&ptr, nil)
-			case <-runCtx.Done():
+			case <-r.runCtx.Done():
 				return
 			}
 		}
@@ -397,9 +445,9 @@ func TestInputCallbackInfiniteConcurrent(t *testing.T) {
 
 	select {
 	case <-cdone:
-		runCancel()
+		r.runCancel()
 	case <-timeout.C:
-		runCancel()
+		r.runCancel()
 		// this test seems to timeout semi-frequently... need to get to
 		// the bottom of it...
 		t.Fatalf("---- timed out: %d/%d ...",
@@ -581,7 +629,7 @@ func TestOutputFlush(t *testing.T) {
 			return nil
 		},
 	}
-	_ = prepareOutputFlush(&out)
+	r := prepareOutputFlush(&out)
 
 	msg := Message{
 		Time: now,
@@ -599,7 +647,7 @@ func TestOutputFlush(t *testing.T) {
 	assert.NoError(t, err)
 
 	wg.Add(1)
-	assert.NoError(t, pluginFlush("foobar", b))
+	assert.NoError(t, r.pluginFlush(0, "foobar", b))
 	wg.Wait()
 }
 
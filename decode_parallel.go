@@ -0,0 +1,166 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// minParallelChunk is the size of the smallest chunk decoded in parallel, smaller ones
+// are decoded faster than the workers start.
+const minParallelChunk = 1 << 20
+
+// decodeWorkersConfig reads the go.DecodeWorkers config key, the number of goroutines
+// decoding the large chunks flushed to an output instance. It defaults to 1, decoding
+// the chunks in the flush callback.
+func decodeWorkersConfig(conf ConfigLoader) int {
+	s := conf.String("go.DecodeWorkers")
+	if s == "" {
+		return 1
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		fmt.Fprintf(os.Stderr, "invalid go.DecodeWorkers %q, using 1\n", s)
+		return 1
+	}
+
+	return n
+}
+
+// chunkDecoder returns the decoder of a chunk flushed to the instance, and a func waiting
+// for its workers. The chunk may be memory of fluent-bit, so it is not read past wait.
+func (o *outputInstance) chunkDecoder(b []byte) (RecordDecoder, func()) {
+	// the records of loaned messages are reused once done.
+	c, pooled := o.reg.recordCodec(), o.loaning()
+	if o.lazyDecoding() {
+		dec := newRecordDecoder(c, o.keys, b, pooled)
+		if rd, ok := dec.(*recordReader); ok {
+			rd.lazy = true
+		}
+
+		return dec, func() {}
+	}
+
+	if o.decodeWorkers <= 1 || len(b) < minParallelChunk {
+		return newRecordDecoder(c, o.keys, b, pooled), func() {}
+	}
+
+	dec := newParallelDecoder(c, o.keys, b, o.decodeWorkers, pooled)
+	return dec, dec.stop
+}
+
+// parallelDecoder decodes the parts of a chunk concurrently, each one by its own worker,
+// and returns their entries in order: the ones of a part once its worker is done.
+type parallelDecoder struct {
+	parts   []*decodedPart
+	part    int
+	next    int
+	stopped atomic.Bool
+	wg      sync.WaitGroup
+}
+
+// decodedPart holds the entries decoded from a part of a chunk, up to the error of the
+// part if any, once done is closed.
+type decodedPart struct {
+	done    chan struct{}
+	entries []decodedEntry
+	err     error
+}
+
+type decodedEntry struct {
+	time   time.Time
+	record map[string]any
+}
+
+func newParallelDecoder(c Codec, keys *keyCache, b []byte, workers int, pooled bool) *parallelDecoder {
+	d := &parallelDecoder{}
+	for _, part := range splitEntries(b, workers) {
+		p := &decodedPart{done: make(chan struct{})}
+		d.parts = append(d.parts, p)
+
+		d.wg.Add(1)
+		go func(b []byte) {
+			defer d.wg.Done()
+			defer close(p.done)
+
+			dec := newRecordDecoder(c, keys, b, pooled)
+			for !d.stopped.Load() {
+				t, record, err := dec.Next()
+				if errors.Is(err, io.EOF) {
+					return
+				}
+
+				if err != nil {
+					p.err = err
+					return
+				}
+
+				p.entries = append(p.entries, decodedEntry{time: t, record: record})
+			}
+		}(part)
+	}
+
+	return d
+}
+
+// Next returns the next entry of the chunk, waiting for the worker of its part.
+func (d *parallelDecoder) Next() (time.Time, map[string]any, error) {
+	for d.part < len(d.parts) {
+		p := d.parts[d.part]
+		<-p.done
+
+		if d.next < len(p.entries) {
+			e := p.entries[d.next]
+			p.entries[d.next] = decodedEntry{}
+			d.next++
+			return e.time, e.record, nil
+		}
+
+		if p.err != nil {
+			return time.Time{}, nil, p.err
+		}
+
+		d.part, d.next = d.part+1, 0
+	}
+
+	return time.Time{}, nil, io.EOF
+}
+
+// stop stops the workers and waits for them to return.
+func (d *parallelDecoder) stop() {
+	d.stopped.Store(true)
+	d.wg.Wait()
+}
+
+// splitEntries splits a chunk into up to n parts of about the same size, on the boundaries
+// of its entries. Past an invalid entry, the rest of the chunk is left in the last part, to
+// fail decoding there.
+func splitEntries(b []byte, n int) [][]byte {
+	size := len(b) / n
+	parts := make([][]byte, 0, n)
+
+	r := &recordReader{b: b}
+	start := 0
+	for len(parts) < n-1 && r.off < len(b) {
+		if err := r.skip(); err != nil {
+			break
+		}
+
+		if r.off-start >= size {
+			parts = append(parts, b[start:r.off])
+			start = r.off
+		}
+	}
+
+	if start < len(b) || len(parts) == 0 {
+		parts = append(parts, b[start:])
+	}
+
+	return parts
+}
@@ -0,0 +1,152 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestDecodeWorkersConfig(t *testing.T) {
+	assert.Equal(t, 1, decodeWorkersConfig(testConfigLoader{}))
+	assert.Equal(t, 4, decodeWorkersConfig(testConfigLoader{"go.DecodeWorkers": "4"}))
+	assert.Equal(t, 1, decodeWorkersConfig(testConfigLoader{"go.DecodeWorkers": "0"}))
+	assert.Equal(t, 1, decodeWorkersConfig(testConfigLoader{"go.DecodeWorkers": "many"}))
+}
+
+func TestSplitEntries(t *testing.T) {
+	b, err := encodeMessages(defaultCodec, nil, benchMessages(benchShapes[1], 100))
+	assert.NoError(t, err)
+
+	for _, n := range []int{1, 3, 8, 200} {
+		parts := splitEntries(b, n)
+		assert.True(t, len(parts) >= 1 && len(parts) <= n)
+		assert.Equal(t, b, bytes.Join(parts, nil))
+
+		// every part holds whole entries.
+		var records int
+		for _, part := range parts {
+			records += len(testDecodeCodec(t, defaultCodec, part))
+		}
+		assert.Equal(t, 100, records)
+	}
+
+	// the entries past an invalid one are left in the last part.
+	invalid := append(append([]byte{}, b[:len(b)/2]...), 0xc1)
+	parts := splitEntries(invalid, 4)
+	assert.Equal(t, invalid, bytes.Join(parts, nil))
+	assert.Equal(t, byte(0xc1), parts[len(parts)-1][len(parts[len(parts)-1])-1])
+
+	assert.Equal(t, [][]byte{nil}, splitEntries(nil, 4))
+}
+
+func TestParallelDecoder(t *testing.T) {
+	msgs := benchMessages(benchShapes[1], 1000)
+	b, err := encodeMessages(defaultCodec, nil, msgs)
+	assert.NoError(t, err)
+
+	want := testDecodeCodec(t, defaultCodec, b)
+
+	dec := newParallelDecoder(defaultCodec, nil, b, 4, false)
+	defer dec.stop()
+	assert.Equal(t, 4, len(dec.parts))
+
+	// the entries are returned in the order of the chunk.
+	tag := "tag"
+	for i := range want {
+		msg, err := decodeMessage(dec, &tag)
+		assert.NoError(t, err)
+		assert.Equal(t, want[i].Time, msg.Time)
+		assert.Equal(t, want[i].Record, msg.Record)
+	}
+
+	_, _, err = dec.Next()
+	assert.IsError(t, err, io.EOF)
+}
+
+func TestParallelDecoderError(t *testing.T) {
+	b, err := encodeMessages(defaultCodec, nil, benchMessages(benchShapes[0], 100))
+	assert.NoError(t, err)
+
+	// the entries before a truncated one are decoded, as sequentially.
+	truncated := b[:len(b)-1]
+	want := 0
+	seq := newRecordDecoder(defaultCodec, nil, truncated, false)
+	for {
+		if _, _, err := seq.Next(); err != nil {
+			break
+		}
+		want++
+	}
+
+	dec := newParallelDecoder(defaultCodec, nil, truncated, 4, false)
+	defer dec.stop()
+
+	got := 0
+	for {
+		_, _, err := dec.Next()
+		if err != nil {
+			assert.IsError(t, err, io.ErrUnexpectedEOF)
+			break
+		}
+		got++
+	}
+	assert.Equal(t, 99, want)
+	assert.Equal(t, want, got)
+}
+
+func TestChunkDecoder(t *testing.T) {
+	o := &outputInstance{decodeWorkers: 4}
+
+	small, err := encodeMessages(defaultCodec, nil, benchMessages(benchShapes[0], 10))
+	assert.NoError(t, err)
+
+	dec, wait := o.chunkDecoder(small)
+	wait()
+	_, ok := dec.(*recordReader)
+	assert.True(t, ok)
+
+	// large chunks are decoded in parallel.
+	large, err := encodeMessages(defaultCodec, nil, benchMessages(benchShapes[2], minParallelChunk/8000))
+	assert.NoError(t, err)
+	assert.True(t, len(large) >= minParallelChunk)
+
+	dec, wait = o.chunkDecoder(large)
+	_, ok = dec.(*parallelDecoder)
+	assert.True(t, ok)
+
+	// the workers are stopped before the whole chunk is read.
+	_, _, err = dec.Next()
+	assert.NoError(t, err)
+	wait()
+
+	chunk, err := o.decodeChunk("tag", large)
+	assert.NoError(t, err)
+	assert.Equal(t, minParallelChunk/8000, chunk.Records)
+}
+
+// BenchmarkParallelDecode decodes a large chunk with more and more workers, the gains
+// depending on the cores available, e.g. -cpu 1,4,8.
+func BenchmarkParallelDecode(b *testing.B) {
+	chunk, err := encodeMessages(defaultCodec, nil, benchMessages(benchShapes[1], 10000))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			o := &outputInstance{decodeWorkers: workers}
+
+			b.ReportAllocs()
+			b.SetBytes(int64(len(chunk)))
+
+			for i := 0; i < b.N; i++ {
+				if _, err := o.decodeChunk("tag", chunk); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package plugin
+
+import (
+	"sort"
+)
+
+// WithDefaults sets default config values, the config loader falls back to them
+// for the keys not set on the plugin instance.
+func WithDefaults(defaults map[string]string) RegisterOption {
+	return func(r *registration) {
+		if r.defaults == nil {
+			r.defaults = map[string]string{}
+		}
+
+		for k, v := range defaults {
+			r.defaults[k] = v
+		}
+	}
+}
+
+// defaultsConfigLoader falls back to the registered default values.
+type defaultsConfigLoader struct {
+	ConfigLoader
+	defaults map[string]string
+}
+
+func (c defaultsConfigLoader) String(key string) string {
+	if v := c.ConfigLoader.String(key); v != "" {
+		return v
+	}
+
+	return c.defaults[key]
+}
+
+// Keys returns the keys of the wrapped loader, if it implements ConfigIterator,
+// along with the keys having a default value.
+func (c defaultsConfigLoader) Keys() []string {
+	seen := map[string]bool{}
+	if it, ok := c.ConfigLoader.(ConfigIterator); ok {
+		for _, k := range it.Keys() {
+			seen[k] = true
+		}
+	}
+	for k := range c.defaults {
+		seen[k] = true
+	}
+
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (c defaultsConfigLoader) All() map[string]string {
+	return configAll(c)
+}
+
+// configLoader wraps the fluent-bit config loader of an instance with
+// the behaviors requested at registration.
+func (r *registration) configLoader(conf ConfigLoader) ConfigLoader {
+	// deprecated keys take precedence over the defaults of their replacement.
+	if len(r.deprecated) != 0 {
+		conf = newDeprecatedConfigLoader(conf, r.deprecated)
+	}
+
+	if len(r.defaults) != 0 {
+		conf = defaultsConfigLoader{ConfigLoader: conf, defaults: r.defaults}
+	}
+
+	// defaults are interpolated too.
+	if r.envInterpolation {
+		conf = envConfigLoader{conf}
+	}
+
+	return conf
+}
@@ -0,0 +1,29 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestWithDefaults(t *testing.T) {
+	t.Setenv("TEST_REGION", "eu-west-1")
+
+	r := &registration{}
+	WithDefaults(map[string]string{"timeout": "5s", "region": "${TEST_REGION}"})(r)
+	WithDefaults(map[string]string{"workers": "2"})(r)
+	WithEnvInterpolation()(r)
+
+	conf := r.configLoader(testConfigLoader{"timeout": "10s", "endpoint": "https://example.com"})
+	assert.Equal(t, "10s", conf.String("timeout"))
+	assert.Equal(t, "2", conf.String("workers"))
+	assert.Equal(t, "eu-west-1", conf.String("region"))
+	assert.Equal(t, "", conf.String("unknown"))
+
+	assert.Equal(t, map[string]string{
+		"endpoint": "https://example.com",
+		"region":   "eu-west-1",
+		"timeout":  "10s",
+		"workers":  "2",
+	}, conf.(ConfigIterator).All())
+}
@@ -0,0 +1,103 @@
+package plugin
+
+import (
+	"sort"
+	"strings"
+)
+
+// deprecatedOption is a config option renamed by the plugin.
+type deprecatedOption struct {
+	old         string
+	replacement string
+}
+
+// WithDeprecatedOption declares a renamed config option: reading the replacement
+// key falls back to the deprecated one, and instances still using it get a warning
+// logged and counted by the fluentbit_plugin_deprecated_options_total counter.
+func WithDeprecatedOption(old, replacement string) RegisterOption {
+	return func(r *registration) {
+		r.deprecated = append(r.deprecated, deprecatedOption{old: old, replacement: replacement})
+	}
+}
+
+// deprecatedConfigLoader falls back to the deprecated keys.
+type deprecatedConfigLoader struct {
+	ConfigLoader
+	// renames maps the lower cased replacement keys to the deprecated ones.
+	renames map[string]string
+}
+
+func newDeprecatedConfigLoader(conf ConfigLoader, opts []deprecatedOption) deprecatedConfigLoader {
+	renames := make(map[string]string, len(opts))
+	for _, opt := range opts {
+		renames[strings.ToLower(opt.replacement)] = opt.old
+	}
+
+	return deprecatedConfigLoader{ConfigLoader: conf, renames: renames}
+}
+
+func (c deprecatedConfigLoader) String(key string) string {
+	v := c.ConfigLoader.String(key)
+	if v != "" {
+		return v
+	}
+
+	if old, ok := c.renames[strings.ToLower(key)]; ok {
+		return c.ConfigLoader.String(old)
+	}
+
+	return ""
+}
+
+// Keys returns the keys of the wrapped loader, if it implements ConfigIterator,
+// the deprecated keys being listed under their replacement.
+func (c deprecatedConfigLoader) Keys() []string {
+	it, ok := c.ConfigLoader.(ConfigIterator)
+	if !ok {
+		return nil
+	}
+
+	replacements := make(map[string]string, len(c.renames))
+	for replacement, old := range c.renames {
+		replacements[strings.ToLower(old)] = replacement
+	}
+
+	seen := map[string]bool{}
+	for _, k := range it.Keys() {
+		if replacement, ok := replacements[strings.ToLower(k)]; ok {
+			k = replacement
+		}
+		seen[k] = true
+	}
+
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (c deprecatedConfigLoader) All() map[string]string {
+	return configAll(c)
+}
+
+// reportDeprecations warns about the deprecated options set on an instance.
+func (r *registration) reportDeprecations(fbit *Fluentbit) {
+	if len(r.deprecated) == 0 {
+		return
+	}
+
+	counter := fbit.Metrics.NewCounter("deprecated_options_total",
+		"Number of deprecated config options used by the plugin instances", "name", "option")
+
+	for _, opt := range r.deprecated {
+		if fbit.Conf.String(opt.old) == "" {
+			continue
+		}
+
+		fbit.Logger.Warn("deprecated config option: option=%q replacement=%q", opt.old, opt.replacement)
+		counter.Add(1, fbit.Instance.Label(), opt.old)
+	}
+}
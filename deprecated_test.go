@@ -0,0 +1,51 @@
+package plugin
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+type testLogger struct {
+	warnings []string
+}
+
+func (l *testLogger) Error(format string, a ...any) {}
+
+func (l *testLogger) Warn(format string, a ...any) {
+	l.warnings = append(l.warnings, fmt.Sprintf(format, a...))
+}
+
+func (l *testLogger) Info(format string, a ...any) {}
+
+func (l *testLogger) Debug(format string, a ...any) {}
+
+func TestWithDeprecatedOption(t *testing.T) {
+	r := &registration{}
+	WithDeprecatedOption("host", "endpoint")(r)
+	WithDeprecatedOption("passwd", "password")(r)
+	WithDefaults(map[string]string{"endpoint": "http://localhost"})(r)
+
+	conf := r.configLoader(testConfigLoader{"host": "https://example.com", "password": "hunter2", "passwd": "old"})
+	assert.Equal(t, "https://example.com", conf.String("endpoint"))
+	assert.Equal(t, "hunter2", conf.String("password"))
+	assert.Equal(t, []string{"endpoint", "password"}, conf.(ConfigIterator).Keys())
+
+	logger := &testLogger{}
+	gauge := &testGauge{values: map[string]float64{}}
+	r.reportDeprecations(&Fluentbit{
+		Conf:     conf,
+		Logger:   logger,
+		Metrics:  testMetrics{gauge: gauge},
+		Instance: InstanceInfo{Name: "dummy.0"},
+	})
+	assert.Equal(t, []string{
+		`deprecated config option: option="host" replacement="endpoint"`,
+		`deprecated config option: option="passwd" replacement="password"`,
+	}, logger.warnings)
+	assert.Equal(t, 2.0, gauge.get("dummy.0"))
+
+	conf = r.configLoader(testConfigLoader{})
+	assert.Equal(t, "http://localhost", conf.String("endpoint"))
+}
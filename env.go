@@ -0,0 +1,65 @@
+package plugin
+
+import (
+	"os"
+	"strings"
+)
+
+// WithEnvInterpolation expands the ${NAME} placeholders found in the config values
+// with the environment variables, like fluent-bit does for its own configuration
+// files. Undefined variables expand to an empty string.
+func WithEnvInterpolation() RegisterOption {
+	return func(r *registration) {
+		r.envInterpolation = true
+	}
+}
+
+// envConfigLoader expands the environment variables of the config values.
+type envConfigLoader struct {
+	ConfigLoader
+}
+
+func (c envConfigLoader) String(key string) string {
+	return interpolateEnv(c.ConfigLoader.String(key))
+}
+
+// Keys returns the keys of the wrapped loader if it implements ConfigIterator.
+func (c envConfigLoader) Keys() []string {
+	if it, ok := c.ConfigLoader.(ConfigIterator); ok {
+		return it.Keys()
+	}
+
+	return nil
+}
+
+func (c envConfigLoader) All() map[string]string {
+	return configAll(c)
+}
+
+// interpolateEnv replaces the ${NAME} placeholders of s, unterminated
+// placeholders are kept as is.
+func interpolateEnv(s string) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+
+	var sb strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start == -1 {
+			break
+		}
+
+		end := strings.IndexByte(s[start+2:], '}')
+		if end == -1 {
+			break
+		}
+
+		sb.WriteString(s[:start])
+		sb.WriteString(os.Getenv(s[start+2 : start+2+end]))
+		s = s[start+2+end+1:]
+	}
+	sb.WriteString(s)
+
+	return sb.String()
+}
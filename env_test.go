@@ -0,0 +1,31 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestInterpolateEnv(t *testing.T) {
+	t.Setenv("TEST_HOST", "example.com")
+	t.Setenv("TEST_PORT", "443")
+
+	assert.Equal(t, "https://example.com:443/api", interpolateEnv("https://${TEST_HOST}:${TEST_PORT}/api"))
+	assert.Equal(t, "token=", interpolateEnv("token=${TEST_UNDEFINED}"))
+	assert.Equal(t, "$TEST_HOST ${TEST_HOST", interpolateEnv("$TEST_HOST ${TEST_HOST"))
+	assert.Equal(t, "plain", interpolateEnv("plain"))
+}
+
+func TestWithEnvInterpolation(t *testing.T) {
+	t.Setenv("TEST_TOKEN", "secret")
+
+	conf := testConfigLoader{"token": "${TEST_TOKEN}"}
+
+	r := &registration{}
+	assert.Equal(t, "${TEST_TOKEN}", r.configLoader(conf).String("token"))
+
+	WithEnvInterpolation()(r)
+	loader := r.configLoader(conf)
+	assert.Equal(t, "secret", loader.String("token"))
+	assert.Equal(t, map[string]string{"token": "secret"}, loader.(ConfigIterator).All())
+}
@@ -0,0 +1,31 @@
+package plugin
+
+import (
+	"errors"
+
+	"github.com/calyptia/plugin/output"
+)
+
+var (
+	// ErrRetry is returned, or wrapped, by output plugins to ask fluent-bit
+	// to retry the flushed chunk later. Returned by Init, fluent-bit is told
+	// to retry the initialization instead of failing it.
+	ErrRetry = errors.New("retry")
+	// ErrError is returned, or wrapped, by output plugins to report an
+	// unrecoverable error, fluent-bit drops the flushed chunk.
+	// Any other error is handled the same way.
+	ErrError = errors.New("error")
+)
+
+// flushResult maps the error returned by an output plugin to the
+// fluent-bit flush return value.
+func flushResult(err error) int {
+	switch {
+	case err == nil:
+		return output.FLB_OK
+	case errors.Is(err, ErrRetry):
+		return output.FLB_RETRY
+	}
+
+	return output.FLB_ERROR
+}
@@ -0,0 +1,79 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/calyptia/plugin/output"
+)
+
+func TestFlushResult(t *testing.T) {
+	assert.Equal(t, output.FLB_OK, flushResult(nil))
+	assert.Equal(t, output.FLB_RETRY, flushResult(ErrRetry))
+	assert.Equal(t, output.FLB_RETRY, flushResult(fmt.Errorf("send: %w", ErrRetry)))
+	assert.Equal(t, output.FLB_ERROR, flushResult(ErrError))
+	assert.Equal(t, output.FLB_ERROR, flushResult(errors.New("bad request")))
+}
+
+// testFailingOutput returns err from Flush after taking a message, the first fails times.
+type testFailingOutput struct {
+	err   error
+	fails int
+}
+
+func (plug *testFailingOutput) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (plug *testFailingOutput) Flush(ctx context.Context, ch <-chan Message) error {
+	for {
+		select {
+		case <-ch:
+			if plug.fails > 0 {
+				plug.fails--
+				return plug.err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func TestFlushError(t *testing.T) {
+	defer resetRegistry()
+
+	var b []byte
+	for i := 0; i < 2; i++ {
+		rec, err := msgpack.Marshal([]any{&EventTime{time.Now()}, map[string]any{"idx": i}})
+		assert.NoError(t, err)
+		b = append(b, rec...)
+	}
+
+	for _, tc := range []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "retry", err: fmt.Errorf("send: %w", ErrRetry), want: output.FLB_RETRY},
+		{name: "error", err: ErrError, want: output.FLB_ERROR},
+		{name: "failed", err: errors.New("bad request"), want: output.FLB_ERROR},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resetRegistry()
+			RegisterOutput("test-output", "", &testFailingOutput{err: tc.err, fails: 1})
+			o := registrationOf(outputKind).newOutputInstance()
+			assert.Equal(t, output.FLB_OK, FLBPluginOutputPreRun(0))
+			defer o.stop()
+
+			// the chunk fails with the error returned by Flush, invoked again for the next one.
+			assert.Equal(t, tc.want, testFlush(o, "foobar", b))
+			assert.Equal(t, output.FLB_OK, testFlush(o, "foobar", b))
+		})
+	}
+}
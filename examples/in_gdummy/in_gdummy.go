@@ -20,8 +20,8 @@ type gdummyPlugin struct {
 }
 
 func (plug *gdummyPlugin) Init(ctx context.Context, fbit *plugin.Fluentbit) error {
-	plug.counterSuccess = fbit.Metrics.NewCounter("operation_succeeded_total", "Total number of succeeded operations", "gdummy")
-	plug.counterFailure = fbit.Metrics.NewCounter("operation_failed_total", "Total number of failed operations", "gdummy")
+	plug.counterSuccess = fbit.Metrics.NewCounter("operation_succeeded_total", "Total number of succeeded operations")
+	plug.counterFailure = fbit.Metrics.NewCounter("operation_failed_total", "Total number of failed operations")
 	plug.log = fbit.Logger
 
 	return nil
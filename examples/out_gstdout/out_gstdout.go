@@ -20,7 +20,7 @@ type gstdoutPlugin struct {
 }
 
 func (plug *gstdoutPlugin) Init(ctx context.Context, fbit *plugin.Fluentbit) error {
-	plug.flushCounter = fbit.Metrics.NewCounter("flush_total", "Total number of flushes", "gstdout")
+	plug.flushCounter = fbit.Metrics.NewCounter("flush_total", "Total number of flushes")
 	plug.param = fbit.Conf.String("param")
 	plug.log = fbit.Logger
 
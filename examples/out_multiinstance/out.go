@@ -60,7 +60,7 @@ func FLBPluginFlushCtx(ctx, data unsafe.Pointer, length C.int, tag *C.char) int
 		// Print record keys and values
 		fmt.Printf("[%d] %s: [%s, {", count, C.GoString(tag), timestamp.String())
 
-		for k, v := range record {
+		for k, v := range output.RecordMap(record) {
 			fmt.Printf("\"%s\": %v, ", k, v)
 		}
 		fmt.Printf("}\n")
@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// FilterPlugin interface to represent a filter fluent-bit plugin.
+// Filter receives all the messages of a chunk and returns the messages that
+// should continue through the pipeline, messages can be modified, dropped or added.
+// The messages and their records are reused once Filter returns, they must be copied
+// to be retained.
+type FilterPlugin interface {
+	Init(ctx context.Context, fbit *Fluentbit) error
+	Filter(ctx context.Context, in []Message) ([]Message, error)
+}
+
+// RegisterFilter plugin.
+// A single filter plugin can be registered per shared library.
+func RegisterFilter(name, desc string, filter FilterPlugin, opts ...RegisterOption) {
+	register(&registration{
+		kind:   filterKind,
+		name:   name,
+		desc:   desc,
+		filter: filter,
+	}, opts...)
+}
+
+// encodeMessages encodes messages into the msgpack format expected by fluent-bit,
+// appending them to buf, a new buffer when nil.
+func encodeMessages(c Codec, buf *bytes.Buffer, msgs []Message) ([]byte, error) {
+	if buf == nil {
+		buf = new(bytes.Buffer)
+	}
+
+	for _, msg := range msgs {
+		if err := encodeRecordTo(c, buf, msg); err != nil {
+			return nil, fmt.Errorf("msgpack marshal: %w", err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// pluginFilter decodes the chunk, runs it through the registered filter and returns
+// the msgpack encoded result, written to out. The decoded records are reused once
+// encoded, see pool.go.
+func (r *registration) pluginFilter(ctx context.Context, tag string, b []byte, out *bytes.Buffer) ([]byte, error) {
+	batch, err := decodeMessages(r.recordCodec(), r.keys, tag, b)
+	if err != nil {
+		return nil, err
+	}
+	defer batch.release()
+
+	msgs, err := r.filter.Filter(ctx, batch.msgs)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeMessages(r.recordCodec(), out, msgs)
+}
@@ -0,0 +1,84 @@
+/* -*- Mode: C; tab-width: 4; indent-tabs-mode: nil; c-basic-offset: 4 -*- */
+
+/*  Fluent Bit Go!
+ *  ==============
+ *  Copyright (C) 2022 The Fluent Bit Go Authors
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+#ifndef FLBGO_FILTER_H
+#define FLBGO_FILTER_H
+
+struct flb_api {
+    char *_;
+    char *__;
+    void *___;
+    void *____;
+    void (*log_print) (int, const char*, int, const char*, ...);
+    /* input_log_check and output_log_check */
+    void *_____;
+    void *______;
+    char *(*filter_get_property) (char *, void *);
+    void *(*filter_get_cmt_instance) (void *);
+    int (*filter_log_check) (void *, int);
+};
+
+struct flb_plugin_proxy_context {
+    void *remote_context;
+};
+
+/* This structure is used for initialization.
+ * It matches the one in proxy/go/go.c in fluent-bit source code.
+ */
+struct flbgo_filter_plugin {
+    char *name;
+    struct flb_api *api;
+    struct flb_filter_instance *f_ins;
+    struct flb_plugin_proxy_context *context;
+};
+
+char *filter_get_plugin_name(void *plugin)
+{
+    struct flbgo_filter_plugin *p = plugin;
+    return p->name;
+}
+
+char *filter_get_property(char *key, void *plugin)
+{
+    struct flbgo_filter_plugin *p = plugin;
+    return p->api->filter_get_property(key, p->f_ins);
+}
+
+void *filter_get_cmt_instance(void *plugin)
+{
+    struct flbgo_filter_plugin *p = plugin;
+    return p->api->filter_get_cmt_instance(p->f_ins);
+}
+
+int filter_log_check(void *plugin, int log_level)
+{
+    struct flbgo_filter_plugin *p = plugin;
+    return p->api->filter_log_check(p->f_ins, log_level);
+}
+
+void filter_log_print_novar(void *plugin, int log_level, const char* message)
+{
+    struct flbgo_filter_plugin *p = plugin;
+    if (p->api->filter_log_check(p->f_ins, log_level)) {
+        /* all formating is done in golang, avoid fmt string bugs. */
+        p->api->log_print(log_level, NULL, 0, "%s", message);
+    }
+}
+
+#endif
@@ -0,0 +1,54 @@
+/* -*- Mode: C; tab-width: 4; indent-tabs-mode: nil; c-basic-offset: 4 -*- */
+
+/*  Fluent Bit Go!
+ *  ==============
+ *  Copyright (C) 2022 The Fluent Bit Go Authors
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+#ifndef FLBGO_PLUGIN_H
+#define FLBGO_PLUGIN_H
+
+/* Return values */
+#define FLB_ERROR   0
+#define FLB_OK      1
+#define FLB_RETRY   2
+
+/* Filter return values */
+#define FLB_FILTER_MODIFIED 1
+#define FLB_FILTER_NOTOUCH  2
+
+/* Proxy definition */
+#define FLB_PROXY_FILTER_PLUGIN   4
+#define FLB_PROXY_GOLANG          11
+
+/* Message Types */
+#define FLB_LOG_ERROR   1
+#define FLB_LOG_WARN    2
+#define FLB_LOG_INFO    3  /* default */
+#define FLB_LOG_DEBUG   4
+#define FLB_LOG_TRACE   5
+
+/* This structure is used for registration.
+ * It matches the one in flb_plugin_proxy.h in fluent-bit source code.
+ */
+struct flb_plugin_proxy_def {
+    int type;
+    int proxy;
+    int flags;
+    char *name;
+    char *description;
+};
+
+#endif
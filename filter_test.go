@@ -0,0 +1,80 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type testFilterDropOdd struct{}
+
+func (t testFilterDropOdd) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (t testFilterDropOdd) Filter(ctx context.Context, in []Message) ([]Message, error) {
+	var out []Message
+	for _, msg := range in {
+		record, ok := msg.Record.(map[string]any)
+		if !ok {
+			return nil, errors.New("unexpected record type")
+		}
+
+		idx, ok := record["idx"].(int8)
+		if !ok {
+			return nil, errors.New("unexpected idx type")
+		}
+
+		if idx%2 != 0 {
+			continue
+		}
+
+		record["filtered"] = true
+		out = append(out, msg)
+	}
+
+	return out, nil
+}
+
+func TestFilter(t *testing.T) {
+	now := time.Now().UTC()
+
+	in, err := encodeMessages(defaultCodec, nil, []Message{
+		{Time: now, Record: map[string]any{"idx": 0}},
+		{Time: now, Record: map[string]any{"idx": 1}},
+		{Time: now, Record: map[string]any{"idx": 2}},
+	})
+	assert.NoError(t, err)
+
+	r := &registration{filter: testFilterDropOdd{}}
+
+	b, err := r.pluginFilter(context.Background(), "foobar", in, nil)
+	assert.NoError(t, err)
+
+	var got []Message
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	for {
+		msg, err := decodeMsg(dec, "foobar")
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		assert.NoError(t, err)
+		got = append(got, msg)
+	}
+
+	assert.Equal(t, 2, len(got))
+	for i, msg := range got {
+		assert.Equal(t, now, msg.Time)
+
+		record := assertType[map[string]any](t, msg.Record)
+		assert.Equal(t, int8(i*2), assertType[int8](t, record["idx"]))
+		assert.Equal(t, true, assertType[bool](t, record["filtered"]))
+	}
+}
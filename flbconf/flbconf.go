@@ -0,0 +1,128 @@
+// Package flbconf provides parsers for the fluent-bit configuration values,
+// like sizes ("512k", "10M"), times ("30s", "2h") and booleans, matching the way
+// fluent-bit reads them, so they can be reused outside of the plugins.
+package flbconf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Size units, fluent-bit sizes are powers of 1024.
+const (
+	KB = 1024
+	MB = 1024 * KB
+	GB = 1024 * MB
+)
+
+// ErrInvalid is wrapped by the errors of the parsers.
+var ErrInvalid = errors.New("invalid value")
+
+// ParseSize parses a size in bytes, a number optionally followed by the K, KB, M, MB,
+// G or GB unit, case insensitive. Like fluent-bit, "false" is parsed as zero.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "false") {
+		return 0, nil
+	}
+
+	num := strings.TrimRightFunc(s, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	unit := strings.ToUpper(s[len(num):])
+
+	var mult float64
+	switch unit {
+	case "":
+		mult = 1
+	case "K", "KB":
+		mult = KB
+	case "M", "MB":
+		mult = MB
+	case "G", "GB":
+		mult = GB
+	default:
+		return 0, fmt.Errorf("%w: size %q: unknown unit %q", ErrInvalid, s, unit)
+	}
+
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("%w: size %q", ErrInvalid, s)
+	}
+
+	v *= mult
+	if v >= math.MaxInt64 {
+		return 0, fmt.Errorf("%w: size %q out of range", ErrInvalid, s)
+	}
+
+	return int64(v), nil
+}
+
+// ParseTime parses a time, a number of seconds optionally followed by the s, m, h or d
+// unit as fluent-bit does. Go durations like "500ms" or "1h30m" are accepted too.
+func ParseTime(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("%w: empty time", ErrInvalid)
+	}
+
+	unit := time.Second
+	num := s
+	switch strings.ToLower(s[len(s)-1:]) {
+	case "s":
+		num = s[:len(s)-1]
+	case "m":
+		num, unit = s[:len(s)-1], time.Minute
+	case "h":
+		num, unit = s[:len(s)-1], time.Hour
+	case "d":
+		num, unit = s[:len(s)-1], 24*time.Hour
+	}
+
+	if n, err := strconv.ParseUint(num, 10, 63); err == nil {
+		if n > uint64(math.MaxInt64/unit) {
+			return 0, fmt.Errorf("%w: time %q out of range", ErrInvalid, s)
+		}
+		return time.Duration(n) * unit, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%w: time %q", ErrInvalid, s)
+	}
+
+	return d, nil
+}
+
+// ParseBool parses a boolean the way fluent-bit does, accepting on/off, true/false,
+// yes/no and 1/0, case insensitive. Other values are reported as invalid rather
+// than being read as false.
+func ParseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "on", "true", "yes", "1":
+		return true, nil
+	case "off", "false", "no", "0":
+		return false, nil
+	}
+
+	return false, fmt.Errorf("%w: boolean %q", ErrInvalid, s)
+}
+
+// Size is a size in bytes, parsed with ParseSize when used with encoding.TextUnmarshaler
+// based decoders like plugin.Unmarshal.
+type Size int64
+
+// UnmarshalText parses the size.
+func (s *Size) UnmarshalText(b []byte) error {
+	v, err := ParseSize(string(b))
+	if err != nil {
+		return err
+	}
+
+	*s = Size(v)
+	return nil
+}
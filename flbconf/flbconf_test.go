@@ -0,0 +1,120 @@
+package flbconf
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestParseSize(t *testing.T) {
+	for s, want := range map[string]int64{
+		"0":     0,
+		"false": 0,
+		"512":   512,
+		"512k":  512 * KB,
+		"512KB": 512 * KB,
+		"10M":   10 * MB,
+		"1.5m":  MB + MB/2,
+		"2G":    2 * GB,
+		" 2gb ": 2 * GB,
+	} {
+		got, err := ParseSize(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, want, got, s)
+	}
+
+	for _, s := range []string{"", "k", "10T", "10KiB", "-1", "ten", "1e30G"} {
+		_, err := ParseSize(s)
+		assert.True(t, errors.Is(err, ErrInvalid), s)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	for s, want := range map[string]time.Duration{
+		"0":      0,
+		"30":     30 * time.Second,
+		"30s":    30 * time.Second,
+		"5m":     5 * time.Minute,
+		"2h":     2 * time.Hour,
+		"1D":     24 * time.Hour,
+		"500ms":  500 * time.Millisecond,
+		"1h30m":  90 * time.Minute,
+		"1.5s":   1500 * time.Millisecond,
+		" 10s  ": 10 * time.Second,
+	} {
+		got, err := ParseTime(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, want, got, s)
+	}
+
+	for _, s := range []string{"", "s", "soon", "-5s", "10y", "99999999999999999d"} {
+		_, err := ParseTime(s)
+		assert.True(t, errors.Is(err, ErrInvalid), s)
+	}
+}
+
+func TestParseBool(t *testing.T) {
+	for _, s := range []string{"on", "On", "true", "yes", "1"} {
+		v, err := ParseBool(s)
+		assert.NoError(t, err)
+		assert.True(t, v)
+	}
+
+	for _, s := range []string{"off", "FALSE", "no", "0"} {
+		v, err := ParseBool(s)
+		assert.NoError(t, err)
+		assert.False(t, v)
+	}
+
+	_, err := ParseBool("maybe")
+	assert.True(t, errors.Is(err, ErrInvalid))
+}
+
+func TestSize(t *testing.T) {
+	var s Size
+	assert.NoError(t, s.UnmarshalText([]byte("4k")))
+	assert.Equal(t, Size(4*KB), s)
+	assert.Error(t, s.UnmarshalText([]byte("4x")))
+}
+
+func FuzzParseSize(f *testing.F) {
+	for _, s := range []string{"512", "512k", "10MB", "1.5G", "false", "-1", "k"} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		v, err := ParseSize(s)
+		if err != nil {
+			if !errors.Is(err, ErrInvalid) {
+				t.Fatalf("ParseSize(%q) error does not wrap ErrInvalid: %v", s, err)
+			}
+			return
+		}
+
+		if v < 0 {
+			t.Fatalf("ParseSize(%q) = %d, want a positive size", s, v)
+		}
+	})
+}
+
+func FuzzParseTime(f *testing.F) {
+	for _, s := range []string{"30", "30s", "5m", "2h", "1d", "500ms", "1h30m", "-5s"} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		d, err := ParseTime(s)
+		if err != nil {
+			if !errors.Is(err, ErrInvalid) {
+				t.Fatalf("ParseTime(%q) error does not wrap ErrInvalid: %v", s, err)
+			}
+			return
+		}
+
+		if d < 0 {
+			t.Fatalf("ParseTime(%q) = %s, want a positive time", s, d)
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/calyptia/plugin/flbconf"
+)
+
+// defaultSyncFlushTimeout is the time a chunk flushed in sync mode waits for
+// its messages to be acknowledged, it can be changed with go.SyncFlushTimeout.
+const defaultSyncFlushTimeout = 30 * time.Second
+
+// chunkAck tracks the acknowledgment of the messages of a chunk flushed in sync mode.
+// The messages are counted once sent, and acked closes once the chunk is decoded and
+// the messages sent are all acknowledged.
+type chunkAck struct {
+	mu      sync.Mutex
+	pending int
+	sealed  bool
+	acked   chan struct{}
+	err     error
+}
+
+// messageAck acknowledges a single message of a chunk, only once.
+type messageAck struct {
+	once  sync.Once
+	chunk *chunkAck
+}
+
+func newChunkAck() *chunkAck {
+	return &chunkAck{acked: make(chan struct{})}
+}
+
+// message returns the acknowledgment of a message, counted with add once it is sent.
+func (c *chunkAck) message() *messageAck {
+	return &messageAck{chunk: c}
+}
+
+// add counts a message sent, to be acknowledged. Messages acknowledged before being
+// counted leave the count negative until then.
+func (c *chunkAck) add() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending++
+}
+
+func (m *messageAck) done(err error) {
+	m.once.Do(func() {
+		c := m.chunk
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if err != nil && c.err == nil {
+			c.err = err
+		}
+		c.pending--
+		c.complete()
+	})
+}
+
+// complete closes acked once the chunk is sealed and its messages acknowledged, the
+// caller holding the lock.
+func (c *chunkAck) complete() {
+	if !c.sealed || c.pending > 0 {
+		return
+	}
+
+	select {
+	case <-c.acked:
+	default:
+		close(c.acked)
+	}
+}
+
+// wait seals the chunk, all its messages being sent, and blocks until they are all
+// acknowledged. It returns the first error reported. Timeouts are reported as ErrRetry
+// so the chunk is flushed again.
+func (c *chunkAck) wait(ctx context.Context, timeout time.Duration, errs <-chan error) error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	c.sealed = true
+	c.complete()
+	c.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-c.acked:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.err
+	case err := <-errs:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("flush not acknowledged after %s: %w", timeout, ErrRetry)
+	case <-ctx.Done():
+		return fmt.Errorf("flush interrupted: %w", ErrRetry)
+	}
+}
+
+// syncFlushConfig reads the go.SyncFlush and go.SyncFlushTimeout config keys.
+func syncFlushConfig(conf ConfigLoader) (bool, time.Duration) {
+	timeout := defaultSyncFlushTimeout
+	if s := conf.String("go.SyncFlushTimeout"); s != "" {
+		d, err := flbconf.ParseTime(s)
+		if err != nil || d <= 0 {
+			fmt.Fprintf(os.Stderr, "invalid go.SyncFlushTimeout %q, using %s\n", s, defaultSyncFlushTimeout)
+		} else {
+			timeout = d
+		}
+	}
+
+	enabled, err := BoolFromConf(conf, "go.SyncFlush", false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, sync flush disabled\n", err)
+	}
+
+	return enabled, timeout
+}
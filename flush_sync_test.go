@@ -0,0 +1,150 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/calyptia/plugin/output"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type testAckOutput struct {
+	err error
+	ack bool
+}
+
+func (plug *testAckOutput) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (plug *testAckOutput) Flush(ctx context.Context, ch <-chan Message) error {
+	for {
+		select {
+		case msg := <-ch:
+			if plug.ack {
+				msg.Ack(plug.err)
+				// acknowledging twice is a no-op.
+				msg.Ack(nil)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func TestSyncFlush(t *testing.T) {
+	var b []byte
+	for i := 0; i < 3; i++ {
+		rec, err := msgpack.Marshal([]any{&EventTime{time.Now()}, map[string]any{"idx": i}})
+		assert.NoError(t, err)
+		b = append(b, rec...)
+	}
+
+	for _, tc := range []struct {
+		name string
+		plug *testAckOutput
+		err  error
+	}{
+		{name: "acknowledged", plug: &testAckOutput{ack: true}},
+		{name: "retry", plug: &testAckOutput{ack: true, err: ErrRetry}, err: ErrRetry},
+		{name: "failed", plug: &testAckOutput{ack: true, err: errors.New("bad request")}, err: ErrError},
+		{name: "timeout", plug: &testAckOutput{}, err: ErrRetry},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			o := (&registration{name: "test-output", output: tc.plug}).newOutputInstance()
+			o.syncFlush, o.syncFlushTimeout = true, 100*time.Millisecond
+			assert.NoError(t, o.run())
+			defer o.stop()
+
+			err := o.pluginFlush(0, "foobar", b)
+			assert.Equal(t, flushResult(tc.err), flushResult(err))
+		})
+	}
+}
+
+func TestChunkAck(t *testing.T) {
+	ack := newChunkAck()
+
+	// messages acknowledged before being counted.
+	first, second := ack.message(), ack.message()
+	first.done(nil)
+	ack.add()
+	ack.add()
+	second.done(ErrRetry)
+	second.done(nil)
+	assert.True(t, errors.Is(ack.wait(context.Background(), time.Second, nil), ErrRetry))
+
+	// the messages not acknowledged time out, or get interrupted.
+	ack = newChunkAck()
+	ack.message()
+	ack.add()
+	assert.True(t, errors.Is(ack.wait(context.Background(), time.Millisecond, nil), ErrRetry))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.True(t, errors.Is(ack.wait(ctx, time.Minute, nil), ErrRetry))
+
+	// the errors returned by Flush fail the chunk.
+	errs := make(chan error, 1)
+	errs <- ErrError
+	ack = newChunkAck()
+	ack.message()
+	ack.add()
+	assert.True(t, errors.Is(ack.wait(context.Background(), time.Minute, errs), ErrError))
+
+	// chunks without messages are acknowledged.
+	assert.NoError(t, newChunkAck().wait(context.Background(), time.Millisecond, nil))
+}
+
+func TestSyncFlushInterrupted(t *testing.T) {
+	rec, err := msgpack.Marshal([]any{&EventTime{time.Now()}, map[string]any{"idx": 0}})
+	assert.NoError(t, err)
+
+	// the output never reads the records, the flush is interrupted once cancelled.
+	o := (&registration{name: "test-output", output: &testBlockedOutput{}}).newOutputInstance()
+	o.syncFlush, o.syncFlushTimeout = true, time.Minute
+	assert.NoError(t, o.run())
+	defer o.stop()
+
+	done := make(chan int, 1)
+	go func() { done <- o.handleFlush(0, "foobar", append(rec, rec...)) }()
+	time.Sleep(10 * time.Millisecond)
+	_, _, cancel := o.running()
+	cancel()
+
+	select {
+	case ret := <-done:
+		assert.Equal(t, output.FLB_RETRY, ret)
+	case <-time.After(time.Second):
+		t.Fatal("flush not interrupted")
+	}
+}
+
+// testBlockedOutput never reads the flushed records.
+type testBlockedOutput struct{}
+
+func (plug *testBlockedOutput) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (plug *testBlockedOutput) Flush(ctx context.Context, ch <-chan Message) error {
+	<-ctx.Done()
+	return nil
+}
+
+func TestSyncFlushConfig(t *testing.T) {
+	enabled, timeout := syncFlushConfig(testConfigLoader{})
+	assert.False(t, enabled)
+	assert.Equal(t, defaultSyncFlushTimeout, timeout)
+
+	enabled, timeout = syncFlushConfig(testConfigLoader{"go.SyncFlush": "on", "go.SyncFlushTimeout": "5s"})
+	assert.True(t, enabled)
+	assert.Equal(t, 5*time.Second, timeout)
+
+	// the times of fluent-bit are seconds without a unit.
+	_, timeout = syncFlushConfig(testConfigLoader{"go.SyncFlushTimeout": "90"})
+	assert.Equal(t, 90*time.Second, timeout)
+}
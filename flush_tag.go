@@ -0,0 +1,174 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+const (
+	// defaultMaxFlushTags is the number of Flush goroutines of the tags when WithFlushPerTag
+	// is given no limit.
+	defaultMaxFlushTags = 64
+	// flushTagIdle is the time after which the tags not flushed are forgotten, and the
+	// Flush goroutines left without tags return.
+	flushTagIdle = 5 * time.Minute
+)
+
+// WithFlushPerTag makes the output run a Flush goroutine per tag, each one receiving the
+// records of its tag in order on its own channel, so a slow tag does not hold back the
+// others. Flush must then be safe to invoke concurrently. The goroutines are started as
+// tags are flushed, up to maxTags of them, 64 with a zero maxTags, the tags past the limit
+// sharing the goroutines of the others, see WithGoroutineBudget. The tags not flushed for
+// 5 minutes are forgotten, their channel being closed once no other tag uses it, so Flush
+// returns. It panics when used to register other kinds of plugins or with a negative
+// maxTags.
+func WithFlushPerTag(maxTags int) RegisterOption {
+	return func(r *registration) {
+		if r.kind != outputKind {
+			panic(fmt.Sprintf("flush per tag set on %s plugin: %q", r.kind, r.name))
+		}
+
+		if maxTags < 0 {
+			panic(fmt.Sprintf("invalid max tags %d: %q", maxTags, r.name))
+		}
+
+		r.flushPerTag, r.maxFlushTags = true, maxTags
+	}
+}
+
+// tagLane is the channel of a Flush goroutine, shared by the tags past the limit, and
+// the channel of the errors it returns.
+type tagLane struct {
+	ch   chan Message
+	errs chan error
+	// tags is the number of tags using the lane, and busy the number of flushes
+	// sending to it.
+	tags, busy int
+}
+
+// tagEntry is a tag flushed recently, with the lane of its records.
+type tagEntry struct {
+	lane *tagLane
+	busy int
+	last time.Time
+}
+
+// tagChannel returns the channel of the Flush goroutine of the tag and the one of its
+// errors, starting it for new tags, and the func releasing it once the records of the
+// flush are sent. It returns nil channels once the instance is stopped.
+func (o *outputInstance) tagChannel(tag string) (chan Message, chan error, func()) {
+	o.channelLock.Lock()
+	defer o.channelLock.Unlock()
+
+	if o.tagChannels == nil {
+		return nil, nil, func() {}
+	}
+
+	now := sdkClock.Now()
+	if now.Sub(o.tagSweep) >= flushTagIdle {
+		o.retireTags(now)
+	}
+
+	e, ok := o.tagChannels[tag]
+	if !ok {
+		e = &tagEntry{lane: o.tagLane(tag)}
+		e.lane.tags++
+		o.tagChannels[tag] = e
+	}
+	e.busy++
+	e.lane.busy++
+
+	return e.lane.ch, e.lane.errs, func() {
+		o.channelLock.Lock()
+		defer o.channelLock.Unlock()
+
+		e.busy--
+		e.lane.busy--
+		e.last = sdkClock.Now()
+	}
+}
+
+// tagLane returns the lane of a new tag, a new one within the limit, else the one of
+// its hash. It is invoked with the channel lock held.
+func (o *outputInstance) tagLane(tag string) *tagLane {
+	maxTags := o.reg.maxFlushTags
+	if maxTags == 0 {
+		maxTags = defaultMaxFlushTags
+	}
+
+	// tags past the limit share the goroutines of the others.
+	if maxTags = withinBudget(maxTags, o.goroutineBudget); len(o.tagLanes) >= maxTags {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(tag))
+		return o.tagLanes[h.Sum32()%uint32(len(o.tagLanes))]
+	}
+
+	l := &tagLane{ch: make(chan Message), errs: make(chan error)}
+	o.tagLanes = append(o.tagLanes, l)
+	o.spawnFlush("flush "+o.reg.name+" "+tag, o.runCtx, o.runCancel, l.ch, l.errs)
+
+	return l
+}
+
+// retireTags forgets the tags not flushed since flushTagIdle, and closes the lanes left
+// without tags, their Flush goroutine returning. The tags keep their lane while flushed,
+// so their records stay in order. It is invoked with the channel lock held.
+func (o *outputInstance) retireTags(now time.Time) {
+	o.tagSweep = now
+	for tag, e := range o.tagChannels {
+		if e.busy == 0 && now.Sub(e.last) >= flushTagIdle {
+			delete(o.tagChannels, tag)
+			e.lane.tags--
+		}
+	}
+
+	lanes := o.tagLanes[:0]
+	for _, l := range o.tagLanes {
+		if l.tags == 0 && l.busy == 0 {
+			close(l.ch)
+			continue
+		}
+		lanes = append(lanes, l)
+	}
+	clear(o.tagLanes[len(lanes):])
+	o.tagLanes = lanes
+}
+
+// closeTagChannels closes the channels of the tags, once their Flush goroutines returned.
+// It is invoked with the channel lock held.
+func (o *outputInstance) closeTagChannels() {
+	for _, l := range o.tagLanes {
+		close(l.ch)
+	}
+	o.tagChannels, o.tagLanes = nil, nil
+}
+
+// spawnFlush starts a Flush goroutine reading the given channel. The errors returned by
+// Flush are handed to the next flush callback sending to the channel, or waiting for its
+// records to be acknowledged, which returns it to fluent-bit, before Flush is invoked again.
+func (o *outputInstance) spawnFlush(name string, runCtx context.Context, runCancel context.CancelFunc, ch chan Message, errs chan error) {
+	o.spawn(name, func() {
+		for {
+			err := o.runProtected(runCtx, "flush", false, func(ctx context.Context) error {
+				return o.output.Flush(ctx, ch)
+			})
+			if err != nil && runCtx.Err() == nil && !o.panicked.Load() {
+				select {
+				case errs <- err:
+					continue
+				case <-runCtx.Done():
+				}
+			}
+
+			if err != nil {
+				o.logf(LogError, "flush error: %v", err)
+			}
+			if o.panicked.Load() {
+				runCancel()
+			}
+			return
+		}
+	})
+}
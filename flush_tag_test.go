@@ -0,0 +1,206 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/calyptia/plugin/output"
+)
+
+func TestWithFlushPerTag(t *testing.T) {
+	r := &registration{kind: outputKind, name: "dummy"}
+	WithFlushPerTag(4)(r)
+	assert.True(t, r.flushPerTag)
+	assert.Equal(t, 4, r.maxFlushTags)
+
+	assert.Panics(t, func() {
+		WithFlushPerTag(4)(&registration{kind: inputKind, name: "dummy"})
+	})
+	assert.Panics(t, func() {
+		WithFlushPerTag(-1)(&registration{kind: outputKind, name: "dummy"})
+	})
+}
+
+// testTagOutput holds back the records of the slow tag until released.
+type testTagOutput struct {
+	release chan struct{}
+	ch      chan Message
+}
+
+func (plug *testTagOutput) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (plug *testTagOutput) Flush(ctx context.Context, ch <-chan Message) error {
+	for {
+		select {
+		case msg := <-ch:
+			if msg.Tag() == "slow" {
+				select {
+				case <-plug.release:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+			plug.ch <- msg
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func TestOutputFlushPerTag(t *testing.T) {
+	plug := &testTagOutput{release: make(chan struct{}), ch: make(chan Message, 10)}
+	o := &outputInstance{reg: &registration{name: "test-output", flushPerTag: true}, output: plug}
+	assert.NoError(t, o.run())
+	defer o.stop()
+
+	var b []byte
+	for i := range 3 {
+		rec, err := msgpack.Marshal([]any{&EventTime{time.Now()}, map[string]any{"idx": i}})
+		assert.NoError(t, err)
+		b = append(b, rec...)
+	}
+
+	slow := make(chan int)
+	go func() {
+		slow <- o.handleFlush(0, "slow", b)
+	}()
+
+	// the fast tag is not held back by the slow one.
+	assert.Equal(t, output.FLB_OK, o.handleFlush(0, "fast", b))
+	for i := range 3 {
+		msg := <-plug.ch
+		assert.Equal(t, "fast", msg.Tag())
+		assert.Equal(t, any(int8(i)), assertType[map[string]any](t, msg.Record)["idx"])
+	}
+
+	close(plug.release)
+	assert.Equal(t, output.FLB_OK, <-slow)
+	for i := range 3 {
+		msg := <-plug.ch
+		assert.Equal(t, "slow", msg.Tag())
+		assert.Equal(t, any(int8(i)), assertType[map[string]any](t, msg.Record)["idx"])
+	}
+}
+
+func TestOutputFlushPerTagLimit(t *testing.T) {
+	o := &outputInstance{
+		reg:    &registration{name: "test-output", flushPerTag: true, maxFlushTags: 2},
+		output: &testOutputCounter{ch: make(chan Message)},
+	}
+	assert.NoError(t, o.run())
+
+	for _, tag := range []string{"a", "b", "c", "d", "a"} {
+		ch, _, release := o.tagChannel(tag)
+		assert.NotZero(t, ch)
+		release()
+	}
+	assert.Equal(t, 2, len(o.tagLanes))
+	assert.Equal(t, 4, len(o.tagChannels))
+
+	o.stop()
+	ch, _, _ := o.tagChannel("a")
+	assert.Zero(t, ch)
+}
+
+func TestOutputFlushPerTagDefaultLimit(t *testing.T) {
+	o := &outputInstance{
+		reg:    &registration{name: "test-output", flushPerTag: true},
+		output: &testOutputCounter{ch: make(chan Message)},
+	}
+	assert.NoError(t, o.run())
+	defer o.stop()
+
+	for i := range defaultMaxFlushTags + 10 {
+		_, _, release := o.tagChannel(fmt.Sprintf("tag-%d", i))
+		release()
+	}
+	assert.Equal(t, defaultMaxFlushTags, len(o.tagLanes))
+}
+
+// testLaneOutput reports the Flush goroutines returning once their channel is closed.
+type testLaneOutput struct {
+	returned chan struct{}
+}
+
+func (plug *testLaneOutput) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (plug *testLaneOutput) Flush(ctx context.Context, ch <-chan Message) error {
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				plug.returned <- struct{}{}
+				return nil
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func TestOutputFlushPerTagRetire(t *testing.T) {
+	clk := useFakeClock(t)
+	plug := &testLaneOutput{returned: make(chan struct{}, 10)}
+	o := &outputInstance{
+		reg:    &registration{name: "test-output", flushPerTag: true, maxFlushTags: 2},
+		output: plug,
+	}
+	assert.NoError(t, o.run())
+	defer o.stop()
+
+	var lanes []chan Message
+	for _, tag := range []string{"a", "b", "c"} {
+		ch, _, release := o.tagChannel(tag)
+		lanes = append(lanes, ch)
+		release()
+	}
+
+	// b is still being flushed, a and c are idle.
+	clk.Advance(time.Minute)
+	_, _, releaseB := o.tagChannel("b")
+	clk.Advance(flushTagIdle)
+
+	ch, _, release := o.tagChannel("d")
+	release()
+	assert.Equal(t, []string{"b", "d"}, testTags(o))
+	assert.Equal(t, 2, len(o.tagLanes))
+	assert.True(t, ch != lanes[0] && ch != lanes[1])
+
+	// the lane of a is closed once unused, its Flush goroutine returning.
+	<-plug.returned
+	assert.Equal(t, 0, len(plug.returned))
+
+	// b keeps its lane while flushed, then is retired with d.
+	releaseB()
+	clk.Advance(flushTagIdle)
+	_, _, release = o.tagChannel("e")
+	release()
+	assert.Equal(t, []string{"e"}, testTags(o))
+	assert.Equal(t, 1, len(o.tagLanes))
+	<-plug.returned
+	<-plug.returned
+}
+
+// testTags returns the sorted tags of the instance.
+func testTags(o *outputInstance) []string {
+	o.channelLock.Lock()
+	defer o.channelLock.Unlock()
+
+	var tags []string
+	for tag := range o.tagChannels {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	return tags
+}
@@ -0,0 +1,120 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/calyptia/plugin/flbconf"
+)
+
+// debugEnv enables the debug mode of the SDK when set to a true value, e.g. FLB_GO_DEBUG=on.
+// Checks too expensive for production, like reporting leaked goroutines, are enabled.
+const debugEnv = "FLB_GO_DEBUG"
+
+// debugEnabled reports whether the debug mode is enabled.
+func debugEnabled() bool {
+	v, err := flbconf.ParseBool(os.Getenv(debugEnv))
+	return err == nil && v
+}
+
+// spawned tracks the goroutines started by the SDK, FLBPluginExit waits for
+// them so none of them outlives the plugin.
+var spawned struct {
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	next  uint64
+	alive map[uint64]string
+}
+
+// spawn runs fn in a goroutine tracked under the given name.
+func spawn(name string, fn func()) {
+	spawned.mu.Lock()
+	if spawned.alive == nil {
+		spawned.alive = map[uint64]string{}
+	}
+	id := spawned.next
+	spawned.next++
+	spawned.alive[id] = name
+	spawned.mu.Unlock()
+
+	spawned.wg.Add(1)
+	go func() {
+		defer func() {
+			spawned.mu.Lock()
+			delete(spawned.alive, id)
+			spawned.mu.Unlock()
+
+			spawned.wg.Done()
+		}()
+		defer func() {
+			// panics of the SDK itself crash fluent-bit, report them first.
+			if v := recover(); v != nil {
+				reportCrash(crashInfo{}, name, v, debug.Stack())
+				panic(v)
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// spawn runs fn in a goroutine of the plugin, tracked under the given name. The goroutine
+// is labeled with the plugin and instance names, for the profiles.
+func (s *runState) spawn(name string, fn func()) {
+	s.goroutines.Add(1)
+	spawn(name, func() {
+		defer s.goroutines.Done()
+
+		labels := pprof.Labels("plugin", s.crash.plugin, "instance", s.crash.instance)
+		pprof.Do(context.Background(), labels, func(context.Context) {
+			fn()
+		})
+	})
+}
+
+// aliveGoroutines returns the names of the goroutines started by the SDK still running.
+func aliveGoroutines() []string {
+	spawned.mu.Lock()
+	defer spawned.mu.Unlock()
+
+	names := make([]string, 0, len(spawned.alive))
+	for _, name := range spawned.alive {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// waitSpawned waits up to timeout for the goroutines started by the SDK to return. The ones
+// still running are reported, along with the stacks of all goroutines in debug mode.
+func waitSpawned(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		spawned.wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(max(timeout, 0))
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+	}
+
+	alive := aliveGoroutines()
+	fmt.Fprintf(os.Stderr, "exit: %d goroutines still running after %s: %q\n", len(alive), timeout, alive)
+	if debugEnabled() {
+		_ = pprof.Lookup("goroutine").WriteTo(os.Stderr, 1)
+	}
+
+	return false
+}
@@ -0,0 +1,32 @@
+package plugin
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestSpawn(t *testing.T) {
+	var s runState
+	release := make(chan struct{})
+	s.spawn("test-spawn", func() {
+		<-release
+	})
+
+	assert.True(t, slices.Contains(aliveGoroutines(), "test-spawn"))
+	assert.False(t, waitSpawned(10*time.Millisecond))
+
+	close(release)
+	assert.True(t, s.waitGoroutines(time.Second))
+	assert.False(t, slices.Contains(aliveGoroutines(), "test-spawn"))
+}
+
+func TestDebugEnabled(t *testing.T) {
+	t.Setenv(debugEnv, "")
+	assert.False(t, debugEnabled())
+
+	t.Setenv(debugEnv, "on")
+	assert.True(t, debugEnabled())
+}
@@ -0,0 +1,130 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/calyptia/plugin/flbconf"
+	"github.com/calyptia/plugin/metric"
+)
+
+const (
+	// defaultHealthCheckInterval is the interval between health checks,
+	// it can be changed with the go.HealthCheckInterval config key.
+	defaultHealthCheckInterval = 10 * time.Second
+	// defaultHealthCheckTimeout is the time a health check is given, it can be
+	// changed with the go.HealthCheckTimeout config key.
+	defaultHealthCheckTimeout = 5 * time.Second
+)
+
+// HealthChecker is an optional interface plugins implement to report their health.
+// Health is checked periodically and on demand by the FLBPluginHealth callback, the
+// result is exposed by the fluentbit_plugin_healthy gauge of the plugin instance
+// so the fluent-bit HTTP server and its scrapers can report it. The context of Health
+// expires after the go.HealthCheckTimeout, 5 seconds by default, Health must return
+// then as it blocks the callback.
+type HealthChecker interface {
+	Health(ctx context.Context) error
+}
+
+// healthReporter runs the health checks of a plugin instance.
+type healthReporter struct {
+	name    string
+	checker HealthChecker
+	timeout time.Duration
+	gauge   metric.Gauge
+	cancel  context.CancelFunc
+}
+
+func newHealthReporter(name string, checker HealthChecker, metrics Metrics, timeout time.Duration) *healthReporter {
+	return &healthReporter{
+		name:    name,
+		checker: checker,
+		timeout: timeout,
+		gauge:   metrics.NewGauge("healthy", "Whether the plugin reports itself as healthy", "name"),
+	}
+}
+
+// check runs the health check within the timeout and updates the healthy gauge.
+func (h *healthReporter) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	err := h.checker.Health(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("health check timed out after %s: %w", h.timeout, err)
+	}
+	if err != nil {
+		h.gauge.Set(0, h.name)
+		return err
+	}
+
+	h.gauge.Set(1, h.name)
+	return nil
+}
+
+// start checks the health of the plugin every interval until stopped.
+func (h *healthReporter) start(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+
+	spawn("health "+h.name, func() {
+		ticker := sdkClock.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := h.check(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "health: name=%q: %v\n", h.name, err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+			}
+		}
+	})
+}
+
+func (h *healthReporter) stop() {
+	if h == nil || h.cancel == nil {
+		return
+	}
+
+	h.cancel()
+}
+
+// healthCheckInterval reads the go.HealthCheckInterval config key.
+func healthCheckInterval(conf ConfigLoader) time.Duration {
+	s := conf.String("go.HealthCheckInterval")
+	if s == "" {
+		return defaultHealthCheckInterval
+	}
+
+	d, err := flbconf.ParseTime(s)
+	if err != nil || d <= 0 {
+		fmt.Fprintf(os.Stderr, "invalid go.HealthCheckInterval %q, using %s\n", s, defaultHealthCheckInterval)
+		return defaultHealthCheckInterval
+	}
+
+	return d
+}
+
+// healthCheckTimeout reads the go.HealthCheckTimeout config key.
+func healthCheckTimeout(conf ConfigLoader) time.Duration {
+	s := conf.String("go.HealthCheckTimeout")
+	if s == "" {
+		return defaultHealthCheckTimeout
+	}
+
+	d, err := flbconf.ParseTime(s)
+	if err != nil || d <= 0 {
+		fmt.Fprintf(os.Stderr, "invalid go.HealthCheckTimeout %q, using %s\n", s, defaultHealthCheckTimeout)
+		return defaultHealthCheckTimeout
+	}
+
+	return d
+}
@@ -0,0 +1,120 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/calyptia/plugin/metric"
+)
+
+type testGauge struct {
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func (g *testGauge) Add(delta float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values[labelValues[0]] += delta
+}
+
+func (g *testGauge) Sub(delta float64, labelValues ...string) {
+	g.Add(-delta, labelValues...)
+}
+
+func (g *testGauge) Set(value float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values[labelValues[0]] = value
+}
+
+func (g *testGauge) get(label string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.values[label]
+}
+
+// testCounter is a counter adding to a test gauge.
+type testCounter struct {
+	*testGauge
+}
+
+type testMetrics struct {
+	gauge *testGauge
+}
+
+func (m testMetrics) NewCounter(name, desc string, labelValues ...string) metric.Counter {
+	return testCounter{m.gauge}
+}
+
+func (m testMetrics) NewGauge(name, desc string, labelValues ...string) metric.Gauge {
+	return m.gauge
+}
+
+type testHealthChecker struct {
+	err  error
+	hang bool
+}
+
+func (c *testHealthChecker) Health(ctx context.Context) error {
+	if c.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	return c.err
+}
+
+func TestHealthReporter(t *testing.T) {
+	gauge := &testGauge{values: map[string]float64{}}
+	checker := &testHealthChecker{}
+	h := newHealthReporter("dummy", checker, testMetrics{gauge: gauge}, time.Second)
+
+	assert.NoError(t, h.check(context.Background()))
+	assert.Equal(t, 1.0, gauge.get("dummy"))
+
+	checker.err = errors.New("connection refused")
+	assert.EqualError(t, h.check(context.Background()), "connection refused")
+	assert.Equal(t, 0.0, gauge.get("dummy"))
+
+	// checks not returning are given up on after the timeout.
+	checker.err, checker.hang = nil, true
+	h.timeout = 10 * time.Millisecond
+	err := h.check(context.Background())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Contains(t, err.Error(), "timed out after 10ms")
+	assert.Equal(t, 0.0, gauge.get("dummy"))
+
+	checker.hang = false
+	h.start(time.Millisecond)
+	defer h.stop()
+
+	deadline := time.Now().Add(time.Second)
+	for gauge.get("dummy") != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, 1.0, gauge.get("dummy"))
+
+	// stopping a reporter never started is a no-op.
+	var none *healthReporter
+	none.stop()
+}
+
+func TestHealthCheckInterval(t *testing.T) {
+	assert.Equal(t, defaultHealthCheckInterval, healthCheckInterval(testConfigLoader{}))
+	assert.Equal(t, time.Minute, healthCheckInterval(testConfigLoader{"go.HealthCheckInterval": "1m"}))
+	assert.Equal(t, 30*time.Second, healthCheckInterval(testConfigLoader{"go.HealthCheckInterval": "30"}))
+	assert.Equal(t, defaultHealthCheckInterval, healthCheckInterval(testConfigLoader{"go.HealthCheckInterval": "soon"}))
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	assert.Equal(t, defaultHealthCheckTimeout, healthCheckTimeout(testConfigLoader{}))
+	assert.Equal(t, 2*time.Second, healthCheckTimeout(testConfigLoader{"go.HealthCheckTimeout": "2"}))
+	assert.Equal(t, defaultHealthCheckTimeout, healthCheckTimeout(testConfigLoader{"go.HealthCheckTimeout": "0"}))
+	assert.Equal(t, defaultHealthCheckTimeout, healthCheckTimeout(testConfigLoader{"go.HealthCheckTimeout": "soon"}))
+}
@@ -0,0 +1,53 @@
+package plugin
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// tracing reports whether the logger logs the trace messages, to skip building the ones
+// of the hex dumps.
+func tracing(logger Logger) bool {
+	l, ok := logger.(FieldLogger)
+	return ok && l.Enabled(LogTrace)
+}
+
+// TraceHex logs b at the trace level as a hex dump, e.g. a msgpack chunk, to debug the
+// encoding of the records. The message and its fields are logged first, with the size
+// of b, then a line for every 16 bytes: their offset, their hex and their ASCII. It is
+// a no-op unless the go.Trace config key is set.
+//
+//	plugin.TraceHex(fbit.Logger, "request body", body, "endpoint", plug.endpoint)
+func TraceHex(logger Logger, msg string, b []byte, keyvals ...any) {
+	if !tracing(logger) {
+		return
+	}
+
+	l := logger.(FieldLogger)
+	l.Tracew(msg, append(keyvals[:len(keyvals):len(keyvals)], "bytes", len(b))...)
+	if len(b) == 0 {
+		return
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(hex.Dump(b), "\n"), "\n") {
+		l.Trace("%s", line)
+	}
+}
+
+// TraceRecord logs the record encoded as msgpack at the trace level as a hex dump, see
+// TraceHex. Lazy records are dumped as they were received.
+func TraceRecord(logger Logger, msg string, record any, keyvals ...any) {
+	if !tracing(logger) {
+		return
+	}
+
+	b, err := msgpack.Marshal(record)
+	if err != nil {
+		logger.(FieldLogger).Tracew(msg, append(keyvals[:len(keyvals):len(keyvals)], "err", err)...)
+		return
+	}
+
+	TraceHex(logger, msg, b, keyvals...)
+}
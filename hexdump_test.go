@@ -0,0 +1,85 @@
+package plugin
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+// testTraceLogger records the debug and trace messages, at the given log level.
+type testTraceLogger struct {
+	testLeveledLogger
+	debug  []string
+	traces []string
+}
+
+func (l *testTraceLogger) Debug(format string, a ...any) {
+	l.debug = append(l.debug, fmt.Sprintf(format, a...))
+}
+
+func (l *testTraceLogger) Trace(format string, a ...any) {
+	l.traces = append(l.traces, fmt.Sprintf(format, a...))
+}
+
+func TestTraceLevel(t *testing.T) {
+	logger := &testTraceLogger{testLeveledLogger: testLeveledLogger{level: LogTrace}}
+
+	// the trace messages are enabled by go.Trace only.
+	log := Fields(newInstanceLogger(logger, "dummy", InstanceInfo{Name: "dummy"}))
+	assert.False(t, log.Enabled(LogTrace))
+	log.Trace("dropped")
+
+	log = Fields(newInstanceLogger(logger, "dummy", InstanceInfo{Name: "dummy"}).withTrace(true))
+	assert.True(t, log.Enabled(LogTrace))
+	log.With("key", "value").Tracew("traced", "n", 1)
+	assert.Equal(t, []string{"[dummy] traced key=value n=1"}, logger.traces)
+
+	// instances below the trace level log them at the debug level.
+	logger.level = LogDebug
+	log = Fields(newInstanceLogger(logger, "dummy", InstanceInfo{Name: "dummy"}).withTrace(true))
+	log.Trace("as debug")
+	assert.Equal(t, []string{"[dummy] as debug"}, logger.debug)
+
+	logger.level = LogInfo
+	log = Fields(newInstanceLogger(logger, "dummy", InstanceInfo{Name: "dummy"}).withTrace(true))
+	assert.False(t, log.Enabled(LogTrace))
+}
+
+func TestTraceConfig(t *testing.T) {
+	assert.False(t, traceConfig(testConfigLoader{}))
+	assert.True(t, traceConfig(testConfigLoader{"go.Trace": "on"}))
+	assert.False(t, traceConfig(testConfigLoader{"go.Trace": "off"}))
+	assert.False(t, traceConfig(testConfigLoader{"go.Trace": "maybe"}))
+}
+
+func TestTraceHex(t *testing.T) {
+	logger := &testTraceLogger{testLeveledLogger: testLeveledLogger{level: LogTrace}}
+	log := newInstanceLogger(logger, "dummy", InstanceInfo{Name: "dummy"})
+
+	TraceHex(log, "dropped", []byte("chunk"))
+	assert.Equal(t, 0, len(logger.traces))
+
+	log.withTrace(true)
+	TraceHex(log, "chunk", []byte("\x92\xd7\x00fluent-bit, record"), "tag", "app")
+	assert.Equal(t, []string{
+		"[dummy] chunk tag=app bytes=21",
+		"[dummy] 00000000  92 d7 00 66 6c 75 65 6e  74 2d 62 69 74 2c 20 72  |...fluent-bit, r|",
+		"[dummy] 00000010  65 63 6f 72 64                                    |ecord|",
+	}, logger.traces)
+
+	logger.traces = nil
+	TraceRecord(log, "record", map[string]any{"a": 1})
+	assert.Equal(t, []string{
+		"[dummy] record bytes=4",
+		"[dummy] 00000000  81 a1 61 01                                       |..a.|",
+	}, logger.traces)
+
+	logger.traces = nil
+	TraceRecord(log, "record", func() {})
+	assert.Equal(t, 1, len(logger.traces))
+	assert.Contains(t, logger.traces[0], "[dummy] record err=")
+
+	// other loggers are not traced.
+	TraceHex(&testLogger{}, "chunk", []byte("chunk"))
+}
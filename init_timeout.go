@@ -0,0 +1,64 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/calyptia/plugin/flbconf"
+)
+
+// defaultInitTimeout is the time given to Init to return, it can be changed with
+// WithInitTimeout or the go.InitTimeout config key.
+const defaultInitTimeout = time.Minute
+
+// WithInitTimeout sets the time given to Init to return before fluent-bit is told the
+// plugin failed to start, instances can override it with the go.InitTimeout config key.
+// It panics with a non positive timeout.
+func WithInitTimeout(d time.Duration) RegisterOption {
+	return func(r *registration) {
+		if d <= 0 {
+			panic(fmt.Sprintf("invalid init timeout %s: %q", d, r.name))
+		}
+
+		r.initTimeout = d
+	}
+}
+
+// initTimeout reads the go.InitTimeout config key.
+func initTimeout(conf ConfigLoader, def time.Duration) time.Duration {
+	s := conf.String("go.InitTimeout")
+	if s == "" {
+		return def
+	}
+
+	d, err := flbconf.ParseTime(s)
+	if err != nil || d <= 0 {
+		fmt.Fprintf(os.Stderr, "invalid go.InitTimeout %q, using %s\n", s, def)
+		return def
+	}
+
+	return d
+}
+
+// runInit initializes the plugin, giving up once the timeout expires. The context passed
+// to Init is cancelled then, so plugins blocked dialing an endpoint can return.
+func (s *runState) runInit(ctx context.Context, timeout time.Duration, plug initer, fbit *Fluentbit) error {
+	ctx, cancel := context.WithTimeout(withInstance(ctx, fbit.Instance), timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	spawn("init "+fbit.Instance.Label(), func() {
+		done <- s.protect("init", func() error {
+			return initPlugin(ctx, plug, fbit)
+		})
+	})
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%q did not initialize within %s: %w", fbit.Instance.Label(), timeout, ctx.Err())
+	}
+}
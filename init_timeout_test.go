@@ -0,0 +1,43 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+type testBlockingInit struct{}
+
+func (plug testBlockingInit) Init(ctx context.Context, fbit *Fluentbit) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRunInit(t *testing.T) {
+	var s runState
+	fbit := &Fluentbit{Conf: testConfigLoader{}, Instance: InstanceInfo{Name: "dummy.0"}}
+
+	err := s.runInit(context.Background(), 10*time.Millisecond, testBlockingInit{}, fbit)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Contains(t, err.Error(), `"dummy.0" did not initialize within 10ms`)
+
+	err = s.runInit(context.Background(), time.Second, testPluginInputCallbackCtrlC{}, fbit)
+	assert.NoError(t, err)
+}
+
+func TestInitTimeout(t *testing.T) {
+	assert.Equal(t, time.Minute, initTimeout(testConfigLoader{}, time.Minute))
+	assert.Equal(t, 5*time.Second, initTimeout(testConfigLoader{"go.InitTimeout": "5s"}, time.Minute))
+	assert.Equal(t, 90*time.Second, initTimeout(testConfigLoader{"go.InitTimeout": "90"}, time.Minute))
+	assert.Equal(t, time.Minute, initTimeout(testConfigLoader{"go.InitTimeout": "soon"}, time.Minute))
+
+	r := &registration{}
+	WithInitTimeout(time.Second)(r)
+	assert.Equal(t, time.Second, r.initTimeout)
+	assert.Panics(t, func() {
+		WithInitTimeout(0)(r)
+	})
+}
@@ -40,12 +40,18 @@ struct flb_plugin_proxy_context {
  * It matches the one in proxy/go/go.c in fluent-bit source code.
  */
 struct flbgo_input_plugin {
-    void *_;
+    char *name;
     struct flb_api *api;
     struct flb_input_instance *i_ins;
     struct flb_plugin_proxy_context *context;
 };
 
+char *input_get_plugin_name(void *plugin)
+{
+    struct flbgo_input_plugin *p = plugin;
+    return p->name;
+}
+
 char *input_get_property(char *key, void *plugin)
 {
     struct flbgo_input_plugin *p = plugin;
@@ -58,6 +64,12 @@ void *input_get_cmt_instance(void *plugin)
     return p->api->input_get_cmt_instance(p->i_ins);
 }
 
+int input_log_check(void *plugin, int log_level)
+{
+    struct flbgo_input_plugin *p = plugin;
+    return p->api->input_log_check(p->i_ins, log_level);
+}
+
 void input_log_print_novar(void *plugin, int log_level, const char* message)
 {
     struct flbgo_input_plugin *p = plugin;
@@ -29,11 +29,16 @@
 #define FLB_PROXY_INPUT_PLUGIN    1
 #define FLB_PROXY_GOLANG          11
 
+/* Input flags */
+#define FLB_INPUT_CORO      128
+#define FLB_INPUT_THREADED 1024
+
 /* Message Types */
 #define FLB_LOG_ERROR   1
 #define FLB_LOG_WARN    2
 #define FLB_LOG_INFO    3  /* default */
 #define FLB_LOG_DEBUG   4
+#define FLB_LOG_TRACE   5
 
 /* This structure is used for registration.
  * It matches the one in flb_plugin_proxy.h in fluent-bit source code.
@@ -39,10 +39,14 @@ const (
 	FLB_PROXY_INPUT_PLUGIN = C.FLB_PROXY_INPUT_PLUGIN
 	FLB_PROXY_GOLANG       = C.FLB_PROXY_GOLANG
 
+	FLB_INPUT_CORO     = C.FLB_INPUT_CORO
+	FLB_INPUT_THREADED = C.FLB_INPUT_THREADED
+
 	FLB_LOG_ERROR = C.FLB_LOG_ERROR
 	FLB_LOG_WARN  = C.FLB_LOG_WARN
 	FLB_LOG_INFO  = C.FLB_LOG_INFO
 	FLB_LOG_DEBUG = C.FLB_LOG_DEBUG
+	FLB_LOG_TRACE = C.FLB_LOG_TRACE
 )
 
 type (
@@ -65,6 +69,12 @@ func FLBPluginRegister(def unsafe.Pointer, name, desc string) int {
 	return 0
 }
 
+// FLBPluginSetFlags sets the flags of the plugin, like its execution mode.
+func FLBPluginSetFlags(def unsafe.Pointer, flags int) {
+	p := (*FLBPluginProxyDef)(def)
+	p.flags = C.int(flags)
+}
+
 // FLBPluginUnregister release resources allocated by the plugin initialization
 func FLBPluginUnregister(def unsafe.Pointer) {
 	p := (*FLBPluginProxyDef)(def)
@@ -72,6 +82,11 @@ func FLBPluginUnregister(def unsafe.Pointer) {
 	C.free(unsafe.Pointer(p.description))
 }
 
+// FLBPluginName returns the name of the plugin the instance was created from.
+func FLBPluginName(plugin unsafe.Pointer) string {
+	return C.GoString(C.input_get_plugin_name(plugin))
+}
+
 func FLBPluginConfigKey(plugin unsafe.Pointer, key string) string {
 	_key := C.CString(key)
 	value := C.GoString(C.input_get_property(_key, plugin))
@@ -84,6 +99,12 @@ func FLBPluginGetCMetricsContext(plugin unsafe.Pointer) (*cmetrics.Context, erro
 	return cmetrics.NewContextFromCMTPointer(cmt)
 }
 
+// FLBPluginLogCheck reports whether the messages of the given level are logged by the
+// instance, according to its log_level.
+func FLBPluginLogCheck(plugin unsafe.Pointer, logLevel int) bool {
+	return C.input_log_check(plugin, C.int(logLevel)) != 0
+}
+
 func FLBPluginLogPrint(plugin unsafe.Pointer, log_level C.int, message string) {
 	_message := C.CString(message)
 	C.input_log_print_novar(plugin, log_level, _message)
@@ -0,0 +1,158 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/calyptia/plugin/flbconf"
+)
+
+// defaultChunkSize is the size of the chunks handed to fluent-bit by the input callbacks,
+// the size fluent-bit caps its own input chunks at: larger chunks slow down the storage and
+// the outputs downstream.
+const defaultChunkSize = 2048000
+
+// WithBatchLimits caps the records handed to fluent-bit by each input callback, to
+// at most maxRecords records and maxBytes bytes of encoded records, zero meaning no
+// limit. The records over the limits stay buffered for the next callbacks. A single
+// record larger than maxBytes is still handed alone. Instances can override the limits
+// with the go.MaxBatchRecords and go.MaxBatchBytes config keys. It panics when used to
+// register other kinds of plugins or with negative limits.
+func WithBatchLimits(maxRecords, maxBytes int) RegisterOption {
+	return func(r *registration) {
+		if r.kind != inputKind {
+			panic(fmt.Sprintf("batch limits set on %s plugin: %q", r.kind, r.name))
+		}
+
+		if maxRecords < 0 || maxBytes < 0 {
+			panic(fmt.Sprintf("invalid batch limits %d records, %d bytes: %q", maxRecords, maxBytes, r.name))
+		}
+
+		r.maxBatchRecords, r.maxBatchBytes = maxRecords, maxBytes
+	}
+}
+
+// batchLimitsFromConf reads the go.MaxBatchRecords and go.MaxBatchBytes config keys,
+// defaulting to the limits set on registration.
+func batchLimitsFromConf(conf ConfigLoader, maxRecords, maxBytes int) (int, int, error) {
+	for _, limit := range []struct {
+		key string
+		v   *int
+	}{
+		{"go.MaxBatchRecords", &maxRecords},
+		{"go.MaxBatchBytes", &maxBytes},
+	} {
+		s := conf.String(limit.key)
+		if s == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("%s: invalid value %q", limit.key, s)
+		}
+		*limit.v = n
+	}
+
+	return maxRecords, maxBytes, nil
+}
+
+// WithChunkSize sets the size of the chunks handed to fluent-bit by the input callbacks,
+// 2048000 bytes by default like the chunks of fluent-bit: the records buffered past it are
+// split into chunks of that size, handed by the next callbacks. A single record larger than
+// the chunk size is still handed alone. Instances can override it with the go.ChunkSize config
+// key. A zero size disables the splitting. It panics when used to register other kinds of
+// plugins or with a negative size.
+func WithChunkSize(size int) RegisterOption {
+	return func(r *registration) {
+		if r.kind != inputKind {
+			panic(fmt.Sprintf("chunk size set on %s plugin: %q", r.kind, r.name))
+		}
+
+		if size < 0 {
+			panic(fmt.Sprintf("invalid chunk size %d: %q", size, r.name))
+		}
+
+		// zero is the default size, disabled chunk sizes are negative.
+		r.chunkSize = size
+		if size == 0 {
+			r.chunkSize = -1
+		}
+	}
+}
+
+// chunkSizeFromConf reads the go.ChunkSize config key, a size like 2M or 0 to disable the
+// splitting, defaulting to the size set on registration.
+func chunkSizeFromConf(conf ConfigLoader, size int) int {
+	if size == 0 {
+		size = defaultChunkSize
+	}
+
+	s := conf.String("go.ChunkSize")
+	if s == "" {
+		return max(size, 0)
+	}
+
+	n, err := flbconf.ParseSize(s)
+	if err != nil || n < 0 {
+		fmt.Fprintf(os.Stderr, "invalid go.ChunkSize %q, using %d\n", s, max(size, 0))
+		return max(size, 0)
+	}
+
+	return int(n)
+}
+
+// batchBytes returns the byte limit of the chunk of an input callback, the smallest of the
+// batch byte limit and the chunk size, zero meaning no limit.
+func (r *registration) batchBytes() int {
+	if r.maxBatchBytes == 0 || r.inputChunkSize > 0 && r.inputChunkSize < r.maxBatchBytes {
+		return r.inputChunkSize
+	}
+
+	return r.maxBatchBytes
+}
+
+// batchFull reports whether the chunk of an input callback reached the batch limits.
+func (r *registration) batchFull(buf *bytes.Buffer, records int) bool {
+	maxBytes := r.batchBytes()
+	return r.batchCarry != nil ||
+		r.maxBatchRecords > 0 && records >= r.maxBatchRecords ||
+		maxBytes > 0 && buf.Len() >= maxBytes
+}
+
+// addToBatch appends an encoded record to the chunk of an input callback. The record is
+// carried over to the next callback instead when it would make the chunk exceed the
+// byte limit, false is returned then.
+func (r *registration) addToBatch(buf *bytes.Buffer, record []byte) bool {
+	if maxBytes := r.batchBytes(); maxBytes > 0 && buf.Len() > 0 && buf.Len()+len(record) > maxBytes {
+		r.batchCarry = bytes.Clone(record)
+		return false
+	}
+
+	buf.Write(record)
+	return true
+}
+
+// encodeToBatch encodes a message into scratch and adds it to the chunk of an input
+// callback, see addToBatch.
+func (r *registration) encodeToBatch(buf, scratch *bytes.Buffer, msg Message) (bool, error) {
+	scratch.Reset()
+	if err := encodeRecordTo(r.recordCodec(), scratch, msg); err != nil {
+		return false, fmt.Errorf("msgpack marshal: %w", err)
+	}
+
+	return r.addToBatch(buf, scratch.Bytes()), nil
+}
+
+// takeCarry appends the record carried over by the previous callback to the chunk.
+func (r *registration) takeCarry(buf *bytes.Buffer) bool {
+	if r.batchCarry == nil {
+		return false
+	}
+
+	buf.Write(r.batchCarry)
+	r.batchCarry = nil
+	return true
+}
@@ -0,0 +1,162 @@
+package plugin
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestWithBatchLimits(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	RegisterInput("dummy-input", "", testPluginInputCallbackCtrlC{}, WithBatchLimits(10, 1024))
+	assert.Equal(t, 10, registrationOf(inputKind).maxBatchRecords)
+	assert.Equal(t, 1024, registrationOf(inputKind).maxBatchBytes)
+
+	assert.Panics(t, func() {
+		RegisterOutput("dummy-output", "", &testOutputCounter{}, WithBatchLimits(10, 0))
+	})
+	assert.Panics(t, func() {
+		resetRegistry()
+		RegisterInput("dummy-input", "", testPluginInputCallbackCtrlC{}, WithBatchLimits(-1, 0))
+	})
+}
+
+func TestBatchLimitsFromConf(t *testing.T) {
+	records, size, err := batchLimitsFromConf(testConfigLoader{}, 10, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, records)
+	assert.Equal(t, 0, size)
+
+	records, size, err = batchLimitsFromConf(testConfigLoader{"go.MaxBatchRecords": "0", "go.MaxBatchBytes": "4096"}, 10, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, records)
+	assert.Equal(t, 4096, size)
+
+	_, _, err = batchLimitsFromConf(testConfigLoader{"go.MaxBatchBytes": "-1"}, 0, 0)
+	assert.Error(t, err)
+}
+
+func TestCollectLogsBatchLimits(t *testing.T) {
+	defer resetRegistry()
+
+	r := prepareInput(testPluginInputCallbackCtrlC{})
+	r.prepareInputCollector()
+	defer r.runCancel()
+
+	for range 5 {
+		r.channel <- Message{Time: time.Now(), Record: map[string]string{"foo": "bar"}}
+	}
+
+	record, err := encodeRecord(defaultCodec, Message{Time: time.Now(), Record: map[string]string{"foo": "bar"}})
+	assert.NoError(t, err)
+
+	collect := func() (records int, size int) {
+		b, _, err := r.collectLogs()
+		assert.NoError(t, err)
+
+		dec := msgpack.NewDecoder(bytes.NewReader(b))
+		for {
+			_, err := decodeMsg(dec, "")
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			assert.NoError(t, err)
+			records++
+		}
+
+		return records, len(b)
+	}
+
+	r.maxBatchRecords = 2
+	records, _ := collect()
+	assert.Equal(t, 2, records)
+	assert.Equal(t, 3, len(r.channel))
+
+	// the record over the byte limit is carried to the next callback.
+	r.maxBatchRecords, r.maxBatchBytes = 0, 2*len(record)-1
+	records, size := collect()
+	assert.Equal(t, 1, records)
+	assert.Equal(t, len(record), size)
+	assert.NotZero(t, r.batchCarry)
+	assert.Equal(t, 1, len(r.channel))
+
+	r.maxBatchBytes = 0
+	records, _ = collect()
+	assert.Equal(t, 2, records)
+	assert.Zero(t, r.batchCarry)
+	assert.Equal(t, 0, len(r.channel))
+}
+
+func TestWithChunkSize(t *testing.T) {
+	r := &registration{kind: inputKind, name: "dummy"}
+	WithChunkSize(1 << 20)(r)
+	assert.Equal(t, 1<<20, r.chunkSize)
+	WithChunkSize(0)(r)
+	assert.Equal(t, -1, r.chunkSize)
+
+	assert.Panics(t, func() {
+		WithChunkSize(1 << 20)(&registration{kind: outputKind, name: "dummy"})
+	})
+	assert.Panics(t, func() {
+		WithChunkSize(-1)(&registration{kind: inputKind, name: "dummy"})
+	})
+}
+
+func TestChunkSizeFromConf(t *testing.T) {
+	assert.Equal(t, defaultChunkSize, chunkSizeFromConf(testConfigLoader{}, 0))
+	assert.Equal(t, 1024, chunkSizeFromConf(testConfigLoader{}, 1024))
+	assert.Equal(t, 0, chunkSizeFromConf(testConfigLoader{}, -1))
+	assert.Equal(t, 4<<20, chunkSizeFromConf(testConfigLoader{"go.ChunkSize": "4M"}, 1024))
+	assert.Equal(t, 0, chunkSizeFromConf(testConfigLoader{"go.ChunkSize": "0"}, 0))
+	assert.Equal(t, 1024, chunkSizeFromConf(testConfigLoader{"go.ChunkSize": "large"}, 1024))
+	assert.Equal(t, defaultChunkSize, chunkSizeFromConf(testConfigLoader{"go.ChunkSize": "-1"}, 0))
+
+	// the smallest of the batch limit and of the chunk size applies.
+	r := &registration{}
+	assert.Equal(t, 0, r.batchBytes())
+	r.inputChunkSize = 4096
+	assert.Equal(t, 4096, r.batchBytes())
+	r.maxBatchBytes = 1024
+	assert.Equal(t, 1024, r.batchBytes())
+	r.maxBatchBytes = 8192
+	assert.Equal(t, 4096, r.batchBytes())
+	r.inputChunkSize = 0
+	assert.Equal(t, 8192, r.batchBytes())
+}
+
+func TestCollectLogsChunkSize(t *testing.T) {
+	defer resetRegistry()
+
+	r := prepareInput(testPluginInputCallbackCtrlC{})
+	r.prepareInputCollector()
+	defer r.runCancel()
+
+	const records = 50
+	for i := range records {
+		r.channel <- Message{Time: time.Now(), Record: map[string]any{"idx": i, "log": strings.Repeat("x", 200)}}
+	}
+	r.inputChunkSize = 2048
+
+	// the records are split into chunks of up to the chunk size, in order.
+	next := 0
+	for next < records {
+		b, _, err := r.collectLogs()
+		assert.NoError(t, err)
+		assert.True(t, len(b) > 0 && len(b) <= r.inputChunkSize)
+
+		for _, msg := range testDecodeCodec(t, defaultCodec, b) {
+			assert.Equal(t, any(int8(next)), msg.Record.(map[string]any)["idx"])
+			next++
+		}
+	}
+	assert.Equal(t, 0, len(r.channel))
+	assert.Zero(t, r.batchCarry)
+}
@@ -0,0 +1,176 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/calyptia/plugin/flbconf"
+	"github.com/calyptia/plugin/internal/cmem"
+)
+
+// defaultInputBufferSize is the initial size of the C memory the input callbacks hand
+// the records to fluent-bit in, it can be changed with the go.InputBufferSize config key.
+const defaultInputBufferSize = 256 << 10
+
+// OverflowPolicy decides what happens to the records sent by an input while its buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the collector until the buffer has room.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest buffered record to make room for the new one.
+	OverflowDropOldest
+	// OverflowDropNewest drops the records sent while the buffer is full.
+	OverflowDropNewest
+	// OverflowRetry blocks the collector like OverflowBlock, and makes the input callback
+	// return FLB_RETRY while the buffer is full, so fluent-bit knows the input is behind.
+	OverflowRetry
+	// OverflowSpill writes the records sent while the buffer is full to a temp file, see
+	// the go.SpillDir and go.SpillMaxSize config keys, replayed in order by the next
+	// callbacks. Records sent while the file is full are dropped. The file is deleted
+	// on exit, it only protects against running out of memory.
+	OverflowSpill
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "block"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowDropNewest:
+		return "drop-newest"
+	case OverflowRetry:
+		return "retry"
+	case OverflowSpill:
+		return "spill"
+	}
+
+	return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+}
+
+// drops reports whether the policy drops records.
+func (p OverflowPolicy) drops() bool {
+	return p == OverflowDropOldest || p == OverflowDropNewest || p == OverflowSpill
+}
+
+// WithBufferSize sets the number of records buffered by the input between the collect
+// callbacks, instances can override it with the go.MaxBufferedMessages config key.
+// It panics when used to register other kinds of plugins or with a non positive size.
+func WithBufferSize(n int) RegisterOption {
+	return func(r *registration) {
+		if r.kind != inputKind {
+			panic(fmt.Sprintf("buffer size set on %s plugin: %q", r.kind, r.name))
+		}
+
+		if n <= 0 {
+			panic(fmt.Sprintf("invalid buffer size %d: %q", n, r.name))
+		}
+
+		r.maxBufferedMessages = n
+	}
+}
+
+// WithOverflowPolicy sets what happens to the records sent while the buffer of the input
+// is full, the default is OverflowBlock. Dropped records are counted by the
+// input_dropped_records_total metric. It panics when used to register other kinds of plugins.
+func WithOverflowPolicy(p OverflowPolicy) RegisterOption {
+	return func(r *registration) {
+		if r.kind != inputKind {
+			panic(fmt.Sprintf("overflow policy set on %s plugin: %q", r.kind, r.name))
+		}
+
+		r.overflowPolicy = p
+	}
+}
+
+// inputBuffer reads the go.InputBufferSize config key. The records are encoded in place
+// into a buffer of that size reused across the callbacks, growing to hold larger chunks,
+// instead of being copied into new C memory every callback. Zero disables it.
+func inputBuffer(conf ConfigLoader) *cmem.Buffer {
+	size := int64(defaultInputBufferSize)
+	if s := conf.String("go.InputBufferSize"); s != "" {
+		n, err := flbconf.ParseSize(s)
+		if err != nil || n < 0 {
+			fmt.Fprintf(os.Stderr, "invalid go.InputBufferSize %q, using %d\n", s, size)
+		} else {
+			size = n
+		}
+	}
+
+	if size == 0 {
+		return nil
+	}
+
+	return cmem.NewBuffer(int(size))
+}
+
+// relayed reports whether the records of the input go through relayInput.
+func (r *registration) relayed() bool {
+	return r.overflowPolicy.drops() || r.highWater > 0
+}
+
+// relayInput moves the records sent by the collector into the buffer, applying the
+// overflow policy and backpressure, until the context is cancelled.
+func (r *registration) relayInput(ctx context.Context, in <-chan Message, out chan Message, finished <-chan struct{}) {
+	for {
+		if !r.holdInput(ctx, len(out)) {
+			return
+		}
+
+		var msg Message
+		select {
+		case <-ctx.Done():
+			return
+		case <-finished:
+			// one-shot inputs are complete once their records are relayed.
+			r.completeInput()
+			return
+		case msg = <-in:
+		}
+
+		if !r.overflowPolicy.drops() {
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		if r.overflowPolicy == OverflowSpill {
+			if n := r.spillInput(out, msg); n > 0 && r.onDrop != nil {
+				r.onDrop(n)
+			}
+			continue
+		}
+
+		if n := enqueue(out, msg, r.overflowPolicy); n > 0 && r.onDrop != nil {
+			r.onDrop(n)
+		}
+	}
+}
+
+// enqueue sends msg to ch without blocking, it returns the number of records
+// dropped by the policy to do so.
+func enqueue(ch chan Message, msg Message, policy OverflowPolicy) int {
+	var dropped int
+	for {
+		select {
+		case ch <- msg:
+			return dropped
+		default:
+		}
+
+		if policy != OverflowDropOldest {
+			return dropped + 1
+		}
+
+		select {
+		case <-ch:
+			dropped++
+		default:
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"unsafe"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/calyptia/plugin/input"
+)
+
+func TestWithBufferSize(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	RegisterInput("dummy-input", "", testPluginInputCallbackCtrlC{})
+	assert.Equal(t, defaultMaxBufferedMessages, registrationOf(inputKind).maxBufferedMessages)
+
+	resetRegistry()
+	RegisterInput("dummy-input", "", testPluginInputCallbackCtrlC{}, WithBufferSize(10), WithOverflowPolicy(OverflowDropOldest))
+	assert.Equal(t, 10, registrationOf(inputKind).maxBufferedMessages)
+	assert.Equal(t, OverflowDropOldest, registrationOf(inputKind).overflowPolicy)
+
+	assert.Panics(t, func() {
+		RegisterOutput("dummy-output", "", &testOutputCounter{}, WithBufferSize(10))
+	})
+	assert.Panics(t, func() {
+		RegisterOutput("dummy-output", "", &testOutputCounter{}, WithOverflowPolicy(OverflowDropNewest))
+	})
+	assert.Panics(t, func() {
+		resetRegistry()
+		RegisterInput("dummy-input", "", testPluginInputCallbackCtrlC{}, WithBufferSize(0))
+	})
+}
+
+func TestEnqueue(t *testing.T) {
+	ch := make(chan Message, 2)
+	assert.Equal(t, 0, enqueue(ch, Message{Record: 1}, OverflowDropNewest))
+	assert.Equal(t, 0, enqueue(ch, Message{Record: 2}, OverflowDropNewest))
+	assert.Equal(t, 1, enqueue(ch, Message{Record: 3}, OverflowDropNewest))
+	assert.Equal(t, 1, enqueue(ch, Message{Record: 4}, OverflowDropOldest))
+
+	assert.Equal(t, any(2), (<-ch).Record)
+	assert.Equal(t, any(4), (<-ch).Record)
+}
+
+func TestRelayInput(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dropped := make(chan int, 2)
+	r := &registration{overflowPolicy: OverflowDropNewest, onDrop: func(n int) { dropped <- n }}
+	in, out := make(chan Message), make(chan Message, 1)
+	go r.relayInput(ctx, in, out, nil)
+
+	in <- Message{Record: 1}
+	in <- Message{Record: 2}
+	in <- Message{Record: 3}
+
+	assert.Equal(t, 1, <-dropped)
+	assert.Equal(t, 1, <-dropped)
+	assert.Equal(t, any(1), (<-out).Record)
+}
+
+func TestInputCallbackOverflowRetry(t *testing.T) {
+	r := prepareInput(testPluginInputCallbackCtrlC{})
+	r.overflowPolicy, r.maxBufferedMessages = OverflowRetry, 1
+
+	r.prepareInputCollector()
+	defer r.runCancel()
+
+	r.channel <- Message{Record: map[string]string{"Foo": "BAR"}}
+
+	ptr := unsafe.Pointer(nil)
+	assert.Equal(t, input.FLB_RETRY, FLBPluginInputCallback(&ptr, nil))
+	assert.NotZero(t, ptr)
+	assert.Equal(t, input.FLB_OK, FLBPluginInputCallback(&ptr, nil))
+}
+
+func TestOverflowPolicyString(t *testing.T) {
+	assert.Equal(t, "drop-oldest", OverflowDropOldest.String())
+	assert.Equal(t, "OverflowPolicy(9)", OverflowPolicy(9).String())
+}
@@ -0,0 +1,186 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/calyptia/plugin/flbconf"
+)
+
+// Idler is an optional interface inputs registered with WithIdleTimeout implement to
+// release their resources, e.g. close their connections, while idle. The hooks run in a
+// goroutine of their own, one at a time, the callbacks of fluent-bit not waiting for them.
+type Idler interface {
+	// OnIdle is invoked once the input produced no records for the idle timeout,
+	// after the context of Collect got cancelled.
+	OnIdle(ctx context.Context) error
+	// OnWake is invoked before Collect is invoked again, to re-acquire the resources
+	// released by OnIdle. The input stays idle for another timeout when it fails.
+	OnWake(ctx context.Context) error
+}
+
+// WithIdleTimeout makes the input go idle once it produced no records for the given
+// duration: Collect gets cancelled, and OnIdle is invoked when the plugin implements
+// Idler. The idle input wakes up after the same duration, or when fluent-bit resumes it,
+// and Collect is invoked again. It suits polling inputs: inputs pushed records, e.g. by
+// a listener of Collect, must not use it, as nothing receives the records sent while
+// idle. Instances can override the timeout with the go.IdleTimeout config key. It panics
+// when used to register other kinds of plugins or with a non positive timeout.
+func WithIdleTimeout(d time.Duration) RegisterOption {
+	return func(r *registration) {
+		if r.kind != inputKind {
+			panic(fmt.Sprintf("idle timeout set on %s plugin: %q", r.kind, r.name))
+		}
+
+		if d <= 0 {
+			panic(fmt.Sprintf("invalid idle timeout %s: %q", d, r.name))
+		}
+
+		r.idleTimeout = d
+	}
+}
+
+// idleTimeout reads the go.IdleTimeout config key, inputs don't go idle when
+// neither the key nor a default timeout are set.
+func idleTimeout(conf ConfigLoader, def time.Duration) time.Duration {
+	s := conf.String("go.IdleTimeout")
+	if s == "" {
+		return def
+	}
+
+	d, err := flbconf.ParseTime(s)
+	if err != nil || d < 0 {
+		fmt.Fprintf(os.Stderr, "invalid go.IdleTimeout %q, using %s\n", s, def)
+		return def
+	}
+
+	return d
+}
+
+// checkIdle is invoked by every input callback, with active set when records were
+// collected. It puts the input to sleep once idle for the timeout, and wakes it up
+// once it slept for as long. OnIdle and OnWake run in their own goroutine, so the
+// callbacks of fluent-bit never wait for them.
+func (r *registration) checkIdle(active bool, now time.Time) {
+	if r.inputIdleTimeout <= 0 {
+		return
+	}
+
+	r.idleMu.Lock()
+	defer r.idleMu.Unlock()
+
+	if r.idle {
+		if !r.waking && now.Sub(r.idleSince) >= r.inputIdleTimeout {
+			r.wakeInput(now)
+		}
+		return
+	}
+
+	if active || r.lastActive.IsZero() {
+		r.lastActive = now
+		return
+	}
+
+	if now.Sub(r.lastActive) < r.inputIdleTimeout {
+		return
+	}
+
+	r.idle, r.idleSince = true, now
+	r.stop()
+
+	if r.logger != nil {
+		r.logger.Info("no records for %s, going idle", r.inputIdleTimeout)
+	}
+
+	if p, ok := r.input.(Idler); ok {
+		r.runIdleHook("idle", p.OnIdle, func(err error) {
+			if err != nil {
+				r.logf(LogError, "idle: %v", err)
+			}
+		})
+	}
+}
+
+// wakeInput restarts the collect goroutines of an idle input, once OnWake succeeded.
+// It is invoked with the idle lock held.
+func (r *registration) wakeInput(now time.Time) {
+	p, ok := r.input.(Idler)
+	if !ok {
+		r.awake()
+		return
+	}
+
+	r.waking = true
+	r.runIdleHook("wake", p.OnWake, func(err error) {
+		r.waking = false
+		if err != nil {
+			r.logf(LogError, "wake: %v", err)
+			r.idleSince = now
+			return
+		}
+
+		r.awake()
+	})
+}
+
+// awake marks the input awake and restarts its collect goroutines, unless fluent-bit
+// paused it meanwhile, its resume restarting them. It is invoked with the idle lock held.
+func (r *registration) awake() {
+	r.idle, r.lastActive = false, time.Time{}
+	if !r.inputPaused {
+		r.prepareInputCollector()
+	}
+}
+
+// runIdleHook runs the OnIdle or OnWake hook in a goroutine, once the previous one
+// returned, then done with its error and the idle lock held. It is invoked with the idle
+// lock held.
+func (r *registration) runIdleHook(name string, hook func(ctx context.Context) error, done func(err error)) {
+	prev, next := r.idleHook, make(chan struct{})
+	r.idleHook = next
+
+	r.spawn(name+" "+r.name, func() {
+		defer close(next)
+
+		if prev != nil {
+			<-prev
+		}
+
+		err := r.protect(name, func() error { return hook(r.baseContext()) })
+
+		r.idleMu.Lock()
+		defer r.idleMu.Unlock()
+
+		done(err)
+	})
+}
+
+// pauseInput stops the collect goroutines of the input paused by fluent-bit, or exiting.
+func (r *registration) pauseInput() {
+	r.idleMu.Lock()
+	r.inputPaused = true
+	r.idleMu.Unlock()
+
+	r.stop()
+}
+
+// resumeInput restarts the collect goroutines of the input resumed by fluent-bit, idle
+// inputs waking up.
+func (r *registration) resumeInput() {
+	r.idleMu.Lock()
+	defer r.idleMu.Unlock()
+
+	r.inputPaused = false
+	if r.idle {
+		if !r.waking {
+			r.wakeInput(sdkClock.Now())
+		}
+		return
+	}
+
+	// the paused time does not count as idle.
+	r.lastActive = time.Time{}
+	r.prepareInputCollector()
+}
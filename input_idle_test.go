@@ -0,0 +1,244 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestWithIdleTimeout(t *testing.T) {
+	r := &registration{kind: inputKind, name: "dummy"}
+	WithIdleTimeout(time.Minute)(r)
+	assert.Equal(t, time.Minute, r.idleTimeout)
+
+	assert.Panics(t, func() {
+		WithIdleTimeout(time.Minute)(&registration{kind: outputKind, name: "dummy"})
+	})
+	assert.Panics(t, func() {
+		WithIdleTimeout(0)(&registration{kind: inputKind, name: "dummy"})
+	})
+
+	assert.Equal(t, time.Duration(0), idleTimeout(testConfigLoader{}, 0))
+	assert.Equal(t, 5*time.Second, idleTimeout(testConfigLoader{"go.IdleTimeout": "5s"}, time.Minute))
+	assert.Equal(t, 24*time.Hour, idleTimeout(testConfigLoader{"go.IdleTimeout": "1d"}, time.Minute))
+	assert.Equal(t, time.Minute, idleTimeout(testConfigLoader{"go.IdleTimeout": "soon"}, time.Minute))
+}
+
+type testIdleInput struct {
+	collects chan context.Context
+	mu       sync.Mutex
+	idle     int
+	wakes    int
+	wakeErr  error
+	// hold blocks the hooks until closed.
+	hold chan struct{}
+}
+
+func (plug *testIdleInput) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (plug *testIdleInput) Collect(ctx context.Context, ch chan<- Message) error {
+	plug.collects <- ctx
+	<-ctx.Done()
+	return nil
+}
+
+func (plug *testIdleInput) OnIdle(ctx context.Context) error {
+	if plug.hold != nil {
+		<-plug.hold
+	}
+
+	plug.mu.Lock()
+	defer plug.mu.Unlock()
+
+	plug.idle++
+	return nil
+}
+
+func (plug *testIdleInput) OnWake(ctx context.Context) error {
+	if plug.hold != nil {
+		<-plug.hold
+	}
+
+	plug.mu.Lock()
+	defer plug.mu.Unlock()
+
+	plug.wakes++
+	return plug.wakeErr
+}
+
+func (plug *testIdleInput) counts() (int, int) {
+	plug.mu.Lock()
+	defer plug.mu.Unlock()
+
+	return plug.idle, plug.wakes
+}
+
+// awaitIdleHooks waits for the OnIdle and OnWake hooks of the input to return.
+func awaitIdleHooks(r *registration) {
+	r.idleMu.Lock()
+	hook := r.idleHook
+	r.idleMu.Unlock()
+
+	if hook != nil {
+		<-hook
+	}
+}
+
+// isIdle reports whether the input is idle.
+func isIdle(r *registration) bool {
+	r.idleMu.Lock()
+	defer r.idleMu.Unlock()
+
+	return r.idle
+}
+
+func TestCheckIdle(t *testing.T) {
+	defer resetRegistry()
+
+	plug := &testIdleInput{collects: make(chan context.Context, 2)}
+	r := prepareInput(plug)
+	r.inputIdleTimeout = time.Minute
+	r.prepareInputCollector()
+	defer r.stop()
+
+	ctx := <-plug.collects
+	now := time.Now()
+
+	r.checkIdle(false, now)
+	r.checkIdle(true, now.Add(30*time.Second))
+	r.checkIdle(false, now.Add(time.Minute))
+	assert.False(t, isIdle(r))
+	assert.NoError(t, ctx.Err())
+
+	r.checkIdle(false, now.Add(90*time.Second))
+	awaitIdleHooks(r)
+	assert.True(t, isIdle(r))
+	idle, _ := plug.counts()
+	assert.Equal(t, 1, idle)
+	assert.Error(t, ctx.Err())
+
+	// failing to wake up keeps the input idle for another timeout.
+	plug.mu.Lock()
+	plug.wakeErr = errors.New("unreachable")
+	plug.mu.Unlock()
+	r.checkIdle(false, now.Add(150*time.Second))
+	awaitIdleHooks(r)
+	assert.True(t, isIdle(r))
+	_, wakes := plug.counts()
+	assert.Equal(t, 1, wakes)
+
+	plug.mu.Lock()
+	plug.wakeErr = nil
+	plug.mu.Unlock()
+	r.checkIdle(false, now.Add(180*time.Second))
+	awaitIdleHooks(r)
+	assert.True(t, isIdle(r))
+	_, wakes = plug.counts()
+	assert.Equal(t, 1, wakes)
+
+	r.checkIdle(false, now.Add(210*time.Second))
+	awaitIdleHooks(r)
+	assert.False(t, isIdle(r))
+	_, wakes = plug.counts()
+	assert.Equal(t, 2, wakes)
+	assert.NoError(t, (<-plug.collects).Err())
+}
+
+func TestCheckIdleHooksOffCallback(t *testing.T) {
+	defer resetRegistry()
+
+	plug := &testIdleInput{collects: make(chan context.Context, 2), hold: make(chan struct{})}
+	r := prepareInput(plug)
+	r.inputIdleTimeout = time.Minute
+	r.prepareInputCollector()
+	defer r.stop()
+
+	<-plug.collects
+	now := time.Now()
+
+	// the callbacks return while OnIdle and OnWake are blocked.
+	r.checkIdle(false, now)
+	r.checkIdle(false, now.Add(time.Minute))
+	assert.True(t, isIdle(r))
+	r.checkIdle(false, now.Add(2*time.Minute))
+	r.checkIdle(false, now.Add(3*time.Minute))
+	assert.True(t, isIdle(r))
+
+	// the input paused meanwhile wakes up on resume, its collectors started once.
+	r.pauseInput()
+	close(plug.hold)
+	awaitIdleHooks(r)
+	assert.False(t, isIdle(r))
+	idle, wakes := plug.counts()
+	assert.Equal(t, 1, idle)
+	assert.Equal(t, 1, wakes)
+	assert.Equal(t, 0, len(plug.collects))
+
+	r.resumeInput()
+	assert.NoError(t, (<-plug.collects).Err())
+}
+
+// cpuTime returns the CPU time used by the process.
+func cpuTime(t *testing.T) time.Duration {
+	t.Helper()
+
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		t.Fatal(err)
+	}
+
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
+
+func TestIdleCPU(t *testing.T) {
+	r := prepareInput(testPluginInputCallbackCtrlC{})
+	r.inputInterval, r.inputThreaded = defaultCollectInterval, true
+	r.prepareInputCollector()
+	defer r.runCancel()
+
+	// fluent-bit invokes the callbacks of paused threaded inputs continuously.
+	FLBPluginInputPause()
+
+	w := &watchdog{name: "dummy", callback: "flush", timeout: 10 * time.Millisecond, onStall: func() {}}
+	w.start()
+	defer w.stop()
+
+	const idle = 500 * time.Millisecond
+	var calls atomic.Int64
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ptr := unsafe.Pointer(nil)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			FLBPluginInputCallback(&ptr, nil)
+			calls.Add(1)
+		}
+	}()
+
+	start := cpuTime(t)
+	time.Sleep(idle)
+	used, n := cpuTime(t)-start, calls.Load()
+	close(stop)
+	<-done
+
+	// the callbacks wait for the collect interval.
+	assert.True(t, n <= 1, "%d callbacks while idle", n)
+	assert.True(t, used < idle/10, "%s of CPU while idle for %s", used, idle)
+}
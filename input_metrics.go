@@ -0,0 +1,43 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/calyptia/plugin/metric/cmt"
+)
+
+// MetricsInput is an optional interface input plugins implement to emit
+// metrics events into the pipeline, in addition to the log records sent by
+// Collect. Contexts are built with cmt.NewContext and consumed by the metrics
+// outputs, like prometheus_exporter.
+type MetricsInput interface {
+	CollectMetrics(ctx context.Context, ch chan<- *cmt.Context) error
+}
+
+// drain receives up to max values from ch without blocking.
+func drain[T any](ch <-chan T, max int) []T {
+	var out []T
+	for loop := min(len(ch), max); loop > 0; loop-- {
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				return out
+			}
+			out = append(out, v)
+		default:
+			return out
+		}
+	}
+
+	return out
+}
+
+// collectMetrics encodes the buffered metrics contexts into a cmetrics msgpack payload.
+func (r *registration) collectMetrics() ([]byte, error) {
+	contexts := drain(r.metricsChannel, r.maxBufferedMessages)
+	if len(contexts) == 0 {
+		return nil, nil
+	}
+
+	return cmt.Encode(contexts...)
+}
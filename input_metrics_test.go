@@ -0,0 +1,52 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/calyptia/plugin/metric/cmt"
+)
+
+func TestDrain(t *testing.T) {
+	ch := make(chan int, 5)
+	assert.Equal(t, nil, drain(ch, 10))
+
+	for i := 0; i < 5; i++ {
+		ch <- i
+	}
+	assert.Equal(t, []int{0, 1, 2}, drain(ch, 3))
+	assert.Equal(t, []int{3, 4}, drain(ch, 3))
+
+	ch <- 5
+	close(ch)
+	assert.Equal(t, []int{5}, drain(ch, 3))
+}
+
+func TestCollectMetrics(t *testing.T) {
+	r := &registration{
+		metricsChannel:      make(chan *cmt.Context, 2),
+		maxBufferedMessages: defaultMaxBufferedMessages,
+	}
+
+	b, err := r.collectMetrics()
+	assert.NoError(t, err)
+	assert.Zero(t, b)
+
+	counter := cmt.NewCounter(cmt.Opts{Name: "records_total"})
+	counter.Add(time.Now(), 1)
+
+	ctx := cmt.NewContext()
+	ctx.Add(counter)
+	r.metricsChannel <- ctx
+	r.metricsChannel <- ctx
+
+	b, err = r.collectMetrics()
+	assert.NoError(t, err)
+
+	got, err := cmt.Decode(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(got))
+	assert.Equal(t, "records_total", got[1].Metrics[0].FullName())
+}
@@ -0,0 +1,43 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/calyptia/plugin/input"
+)
+
+// InputMode is the execution mode of an input plugin in fluent-bit.
+type InputMode int
+
+const (
+	// InputModeDefault lets fluent-bit decide, following the threaded config key.
+	InputModeDefault InputMode = iota
+	// InputModeCoroutine runs the input collect callback in a coroutine of the engine.
+	InputModeCoroutine
+	// InputModeThreaded runs the input in a dedicated thread.
+	InputModeThreaded
+)
+
+// flags returns the proxy definition flags matching the mode.
+func (m InputMode) flags() int {
+	switch m {
+	case InputModeCoroutine:
+		return input.FLB_INPUT_CORO
+	case InputModeThreaded:
+		return input.FLB_INPUT_THREADED
+	}
+
+	return 0
+}
+
+// WithInputMode declares whether the input runs threaded or in a coroutine,
+// it panics when used to register other kinds of plugins.
+func WithInputMode(mode InputMode) RegisterOption {
+	return func(r *registration) {
+		if r.kind != inputKind {
+			panic(fmt.Sprintf("input mode set on %s plugin: %q", r.kind, r.name))
+		}
+
+		r.inputMode = mode
+	}
+}
@@ -0,0 +1,27 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/calyptia/plugin/input"
+)
+
+func TestWithInputMode(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	RegisterInput("dummy-input", "", testPluginInputCallbackCtrlC{}, WithInputMode(InputModeThreaded))
+	assert.Equal(t, input.FLB_INPUT_THREADED, registrationOf(inputKind).inputMode.flags())
+
+	assert.Panics(t, func() {
+		RegisterOutput("dummy-output", "", &testOutputCounter{}, WithInputMode(InputModeThreaded))
+	})
+}
+
+func TestInputModeFlags(t *testing.T) {
+	assert.Equal(t, 0, InputModeDefault.flags())
+	assert.Equal(t, input.FLB_INPUT_CORO, InputModeCoroutine.flags())
+	assert.Equal(t, input.FLB_INPUT_THREADED, InputModeThreaded.flags())
+}
@@ -0,0 +1,37 @@
+package plugin
+
+import (
+	"fmt"
+)
+
+// WithOneShot makes the input collect a single time, e.g. to read a file or run a
+// migration: Collect is expected to return once all its records are sent. The input is
+// then reported done to fluent-bit, through the FLBPluginInputDone callback, once its
+// buffered records were delivered. A Collect returning an error is restarted, and one
+// interrupted by a pause runs again on resume. It panics when used to register other
+// kinds of plugins.
+func WithOneShot() RegisterOption {
+	return func(r *registration) {
+		if r.kind != inputKind {
+			panic(fmt.Sprintf("one-shot set on %s plugin: %q", r.kind, r.name))
+		}
+
+		r.oneShot = true
+	}
+}
+
+// completeInput marks a one-shot input as collected, Collect is not invoked again.
+func (r *registration) completeInput() {
+	if r.collected.Swap(true) {
+		return
+	}
+
+	if r.logger != nil {
+		r.logger.Info("one-shot input collected, waiting for its records to be delivered")
+	}
+}
+
+// inputDone reports whether a one-shot input collected and delivered all its records.
+func (r *registration) inputDone() bool {
+	return r.oneShot && r.collected.Load() && r.bufferedInput() == 0
+}
@@ -0,0 +1,73 @@
+package plugin
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+type testOneShotInput struct {
+	calls atomic.Int32
+}
+
+func (plug *testOneShotInput) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (plug *testOneShotInput) Collect(ctx context.Context, ch chan<- Message) error {
+	plug.calls.Add(1)
+	for _, v := range []string{"foo", "bar"} {
+		select {
+		case ch <- Message{Time: time.Now(), Record: map[string]string{"value": v}}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func TestWithOneShot(t *testing.T) {
+	assert.Panics(t, func() {
+		WithOneShot()(&registration{kind: outputKind, name: "dummy"})
+	})
+}
+
+func TestInputOneShot(t *testing.T) {
+	for name, opts := range map[string][]RegisterOption{
+		"buffered": nil,
+		"relayed":  {WithOverflowPolicy(OverflowDropNewest)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer resetRegistry()
+			resetRegistry()
+
+			plug := &testOneShotInput{}
+			RegisterInput("test-input", "", plug, append(opts, WithOneShot())...)
+			r := registrationOf(inputKind)
+			r.prepareInputCollector()
+			defer r.stop()
+
+			deadline := time.Now().Add(time.Second)
+			for !r.collected.Load() && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			assert.True(t, r.collected.Load())
+			assert.False(t, r.inputDone())
+
+			b, err := testFLBPluginInputCallback()
+			assert.NoError(t, err)
+			assert.NotZero(t, len(b))
+			assert.True(t, r.inputDone())
+
+			// the input is not collected again when resumed.
+			r.stop()
+			r.prepareInputCollector()
+			assert.Equal(t, int32(1), plug.calls.Load())
+			assert.True(t, r.inputDone())
+		})
+	}
+}
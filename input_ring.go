@@ -0,0 +1,182 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// WithRingBuffer buffers the records of the input in a lock-free ring between the collect
+// callbacks, instead of the buffered channel, so the callbacks draining it do not contend
+// with the collector. The ring holds the buffer size rounded up to a power of two. The
+// channel given to Collect is then unbuffered, relayed to the ring. It does not apply with
+// the overflow policies dropping records, which keep the channel. It panics when used to
+// register other kinds of plugins.
+func WithRingBuffer() RegisterOption {
+	return func(r *registration) {
+		if r.kind != inputKind {
+			panic(fmt.Sprintf("ring buffer set on %s plugin: %q", r.kind, r.name))
+		}
+
+		r.ringBuffer = true
+	}
+}
+
+// ringSlot is a slot of the ring, its sequence tells the producers and the consumer
+// whose turn it is.
+type ringSlot struct {
+	seq atomic.Uint64
+	msg Message
+}
+
+// inputRing is a bounded multi-producer single-consumer queue, each slot holding the
+// position it expects next: producers claim the tail of the ring then publish the slot,
+// the consumer takes the slot once published then frees it for the next lap.
+type inputRing struct {
+	mask  uint64
+	slots []ringSlot
+	// head is the position of the next record to pop, only moved by the consumer, and
+	// tail the one of the next record to push, claimed by the producers.
+	head, tail atomic.Uint64
+	// ready is signalled on push and space on pop, for the sides waiting on the other.
+	ready, space chan struct{}
+}
+
+func newInputRing(size int) *inputRing {
+	n := 1
+	for n < size {
+		n <<= 1
+	}
+
+	q := &inputRing{
+		mask:  uint64(n - 1),
+		slots: make([]ringSlot, n),
+		ready: make(chan struct{}, 1),
+		space: make(chan struct{}, 1),
+	}
+	for i := range q.slots {
+		q.slots[i].seq.Store(uint64(i))
+	}
+
+	return q
+}
+
+// tryPush adds msg to the ring, it returns false when the ring is full.
+func (q *inputRing) tryPush(msg Message) bool {
+	for {
+		tail := q.tail.Load()
+		slot := &q.slots[tail&q.mask]
+		seq := slot.seq.Load()
+		if seq < tail {
+			return false
+		}
+
+		// the slot is free and no other producer claimed it first.
+		if seq == tail && q.tail.CompareAndSwap(tail, tail+1) {
+			slot.msg = msg
+			slot.seq.Store(tail + 1)
+			signal(q.ready)
+			return true
+		}
+	}
+}
+
+// push adds msg to the ring, waiting for room while full. It returns false when the
+// context is cancelled while waiting.
+func (q *inputRing) push(ctx context.Context, msg Message) bool {
+	waited := false
+	for !q.tryPush(msg) {
+		waited = true
+		select {
+		case <-q.space:
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	// pass the room on to the other producers waiting.
+	if waited && q.len() < q.cap() {
+		signal(q.space)
+	}
+
+	return true
+}
+
+// pop takes the oldest record of the ring, it returns false when the ring is empty. It
+// must not be called concurrently.
+func (q *inputRing) pop() (Message, bool) {
+	head := q.head.Load()
+	slot := &q.slots[head&q.mask]
+	if slot.seq.Load() != head+1 {
+		return Message{}, false
+	}
+
+	msg := slot.msg
+	slot.msg = Message{}
+	slot.seq.Store(head + q.mask + 1)
+	q.head.Store(head + 1)
+	signal(q.space)
+
+	return msg, true
+}
+
+// len returns the number of records in the ring, including the ones being pushed.
+func (q *inputRing) len() int {
+	head := q.head.Load()
+	return min(int(q.tail.Load()-head), q.cap())
+}
+
+func (q *inputRing) cap() int {
+	return len(q.slots)
+}
+
+// signal wakes up the receiver of ch without blocking.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// ringInput moves the records sent by the collector into the ring, applying
+// backpressure, until the context is cancelled.
+func (r *registration) ringInput(ctx context.Context, in <-chan Message, finished <-chan struct{}) {
+	for {
+		if !r.holdInput(ctx, r.ring.len()) {
+			return
+		}
+
+		var msg Message
+		select {
+		case <-ctx.Done():
+			return
+		case <-finished:
+			// one-shot inputs are complete once their records are relayed.
+			r.completeInput()
+			return
+		case msg = <-in:
+		}
+
+		if !r.ring.push(ctx, msg) {
+			return
+		}
+	}
+}
+
+// bufferedInput returns the number of records buffered by the input.
+func (r *registration) bufferedInput() int {
+	if r.ring != nil {
+		return r.ring.len()
+	}
+
+	return len(r.channel)
+}
+
+// inputFull reports whether the buffer of the input is full.
+func (r *registration) inputFull() bool {
+	if r.ring != nil {
+		return r.ring.len() == r.ring.cap()
+	}
+
+	return len(r.channel) == cap(r.channel) && cap(r.channel) > 0
+}
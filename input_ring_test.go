@@ -0,0 +1,113 @@
+package plugin
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestWithRingBuffer(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	RegisterInput("dummy-input", "", testPluginInputCallbackCtrlC{}, WithRingBuffer())
+	assert.True(t, registrationOf(inputKind).ringBuffer)
+
+	assert.Panics(t, func() {
+		RegisterOutput("dummy-output", "", &testOutputCounter{}, WithRingBuffer())
+	})
+}
+
+func TestInputRing(t *testing.T) {
+	// the size is rounded up to a power of two.
+	q := newInputRing(3)
+	assert.Equal(t, 4, q.cap())
+
+	_, ok := q.pop()
+	assert.False(t, ok)
+
+	// the records wrap around the ring, in order.
+	for lap := 0; lap < 3; lap++ {
+		for i := 0; i < 4; i++ {
+			assert.True(t, q.tryPush(Message{Record: i}))
+		}
+		assert.False(t, q.tryPush(Message{Record: 4}))
+		assert.Equal(t, 4, q.len())
+
+		for i := 0; i < 4; i++ {
+			msg, ok := q.pop()
+			assert.True(t, ok)
+			assert.Equal(t, any(i), msg.Record)
+		}
+		assert.Equal(t, 0, q.len())
+	}
+
+	// push waits for room, until cancelled.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	for i := 0; i < 4; i++ {
+		assert.True(t, q.push(ctx, Message{Record: i}))
+	}
+	assert.False(t, q.push(ctx, Message{Record: 4}))
+}
+
+func TestInputRingProducers(t *testing.T) {
+	const producers, records = 8, 1000
+
+	q := newInputRing(16)
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < records; i++ {
+				q.push(context.Background(), Message{Record: [2]int{p, i}})
+			}
+		}()
+	}
+
+	// the records of each producer are popped in the order they were pushed.
+	next := make([]int, producers)
+	for n := 0; n < producers*records; {
+		msg, ok := q.pop()
+		if !ok {
+			<-q.ready
+			continue
+		}
+
+		rec := msg.Record.([2]int)
+		assert.Equal(t, next[rec[0]], rec[1])
+		next[rec[0]]++
+		n++
+	}
+	wg.Wait()
+
+	_, ok := q.pop()
+	assert.False(t, ok)
+}
+
+func TestCollectLogsRing(t *testing.T) {
+	defer resetRegistry()
+
+	r := prepareInput(testPluginInputCallbackCtrlC{})
+	r.ringBuffer, r.maxBufferedMessages = true, 2
+	r.prepareInputCollector()
+	defer r.runCancel()
+
+	// the collect channel is relayed to the ring.
+	assert.Equal(t, 0, cap(r.channel))
+	r.channel <- Message{Time: time.Now(), Record: map[string]string{"foo": "bar"}}
+	r.channel <- Message{Time: time.Now(), Record: map[string]string{"foo": "baz"}}
+	for r.bufferedInput() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, r.inputFull())
+
+	b, _, err := r.collectLogs()
+	assert.NoError(t, err)
+	assert.NotZero(t, len(b))
+	assert.Equal(t, 0, r.bufferedInput())
+}
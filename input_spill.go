@@ -0,0 +1,219 @@
+package plugin
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/calyptia/plugin/flbconf"
+)
+
+// defaultSpillMaxSize is the size of the records an input spills to disk, it can be
+// changed with the go.SpillMaxSize config key.
+const defaultSpillMaxSize = 1 << 30
+
+// errSpillFull is returned when the spill file reached its max size.
+var errSpillFull = errors.New("spill file full")
+
+// spillConfig reads the go.SpillDir and go.SpillMaxSize config keys, the temp
+// directory and 1G by default.
+func spillConfig(conf ConfigLoader) (string, int64) {
+	dir := conf.String("go.SpillDir")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	size := int64(defaultSpillMaxSize)
+	if s := conf.String("go.SpillMaxSize"); s != "" {
+		n, err := flbconf.ParseSize(s)
+		if err != nil || n <= 0 {
+			fmt.Fprintf(os.Stderr, "invalid go.SpillMaxSize %q, using %d\n", s, size)
+		} else {
+			size = n
+		}
+	}
+
+	return dir, size
+}
+
+// spill holds the encoded records of an input overflowing its buffer in a temp file,
+// until the input callbacks replay them. The records are prefixed by their length. The
+// file is a ring of maxSize bytes: the records wrap around to its start, over the ones
+// already replayed, so it never grows past maxSize while the input keeps spilling.
+type spill struct {
+	dir     string
+	maxSize int64
+
+	mu sync.Mutex
+	f  *os.File
+	// written and read are the offsets the records are written to and read from, from
+	// the start of the ring, their position in the file wrapping around maxSize.
+	written, read int64
+	pending       int
+}
+
+func newSpill(dir string, maxSize int64) *spill {
+	return &spill{dir: dir, maxSize: maxSize}
+}
+
+// len returns the number of records spilled and not yet replayed.
+func (s *spill) len() int {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pending
+}
+
+// write appends an encoded record to the spill file, created on the first write.
+func (s *spill) write(record []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.written-s.read+int64(len(record))+4 > s.maxSize {
+		return errSpillFull
+	}
+
+	if s.f == nil {
+		if err := os.MkdirAll(s.dir, 0o755); err != nil {
+			return err
+		}
+
+		f, err := os.CreateTemp(s.dir, "fluent-bit-go-spill-*")
+		if err != nil {
+			return err
+		}
+		// the file is only reachable through its descriptor, so it goes away with the process.
+		_ = os.Remove(f.Name())
+		s.f = f
+	}
+
+	b := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(record)), uint32(len(record)))
+	b = append(b, record...)
+	if err := s.writeAt(b, s.written); err != nil {
+		return err
+	}
+
+	s.written += int64(len(b))
+	s.pending++
+
+	return nil
+}
+
+// next reads the oldest record spilled, the file is truncated once all its records are read.
+func (s *spill) next() ([]byte, error) {
+	if s == nil {
+		return nil, io.EOF
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending == 0 {
+		return nil, io.EOF
+	}
+
+	var size [4]byte
+	if err := s.readAt(size[:], s.read); err != nil {
+		return nil, err
+	}
+
+	record := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if err := s.readAt(record, s.read+4); err != nil {
+		return nil, err
+	}
+
+	s.read += int64(4 + len(record))
+	s.pending--
+
+	if s.pending == 0 {
+		s.written, s.read = 0, 0
+		if err := s.f.Truncate(0); err != nil {
+			return nil, err
+		}
+	}
+
+	return record, nil
+}
+
+// writeAt writes b at the offset off of the ring, wrapping around to the start of the file.
+func (s *spill) writeAt(b []byte, off int64) error {
+	pos := off % s.maxSize
+	n := min(int64(len(b)), s.maxSize-pos)
+	if _, err := s.f.WriteAt(b[:n], pos); err != nil {
+		return err
+	}
+
+	if n < int64(len(b)) {
+		if _, err := s.f.WriteAt(b[n:], 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readAt reads b from the offset off of the ring, wrapping around to the start of the file.
+func (s *spill) readAt(b []byte, off int64) error {
+	pos := off % s.maxSize
+	n := min(int64(len(b)), s.maxSize-pos)
+	if _, err := s.f.ReadAt(b[:n], pos); err != nil {
+		return err
+	}
+
+	if n < int64(len(b)) {
+		if _, err := s.f.ReadAt(b[n:], 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// close removes the spill file, the records not yet replayed are lost.
+func (s *spill) close() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f != nil {
+		_ = s.f.Close()
+		s.f = nil
+	}
+	s.written, s.read, s.pending = 0, 0, 0
+}
+
+// spillInput sends msg to the buffer, or spills it to disk while the buffer is full or
+// records are still spilled, so they are replayed in order. It returns the number of
+// records dropped as the spill file is full.
+func (r *registration) spillInput(out chan Message, msg Message) int {
+	if r.spill.len() == 0 {
+		select {
+		case out <- msg:
+			return 0
+		default:
+		}
+	}
+
+	record, err := encodeRecord(r.recordCodec(), msg)
+	if err == nil {
+		err = r.spill.write(record)
+	}
+	if err != nil {
+		if !errors.Is(err, errSpillFull) {
+			r.logf(LogError, "spill: %v", err)
+		}
+		return 1
+	}
+
+	return 0
+}
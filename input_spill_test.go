@@ -0,0 +1,125 @@
+package plugin
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestSpillConfig(t *testing.T) {
+	dir, size := spillConfig(testConfigLoader{})
+	assert.Equal(t, os.TempDir(), dir)
+	assert.Equal(t, int64(defaultSpillMaxSize), size)
+
+	dir, size = spillConfig(testConfigLoader{"go.SpillDir": "/var/spill", "go.SpillMaxSize": "10M"})
+	assert.Equal(t, "/var/spill", dir)
+	assert.Equal(t, int64(10<<20), size)
+
+	_, size = spillConfig(testConfigLoader{"go.SpillMaxSize": "lots"})
+	assert.Equal(t, int64(defaultSpillMaxSize), size)
+}
+
+func TestSpill(t *testing.T) {
+	s := newSpill(t.TempDir(), 20)
+	defer s.close()
+
+	_, err := s.next()
+	assert.IsError(t, err, io.EOF)
+
+	assert.NoError(t, s.write([]byte("foo")))
+	assert.NoError(t, s.write([]byte("barbaz")))
+	assert.Equal(t, 2, s.len())
+	assert.IsError(t, s.write([]byte("too long")), errSpillFull)
+
+	b, err := s.next()
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(b))
+
+	b, err = s.next()
+	assert.NoError(t, err)
+	assert.Equal(t, "barbaz", string(b))
+	assert.Equal(t, 0, s.len())
+
+	// the file is truncated once replayed.
+	assert.NoError(t, s.write([]byte("too long")))
+	b, err = s.next()
+	assert.NoError(t, err)
+	assert.Equal(t, "too long", string(b))
+}
+
+func TestSpillRing(t *testing.T) {
+	s := newSpill(t.TempDir(), 32)
+	defer s.close()
+
+	// the records wrap around the ring, the file never growing past its max size, while
+	// some remain spilled.
+	assert.NoError(t, s.write([]byte("first")))
+	for i := range 50 {
+		record := bytes.Repeat([]byte{'a' + byte(i%26)}, 1+i%9)
+		assert.NoError(t, s.write(record))
+
+		b, err := s.next()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, s.len())
+		if i == 0 {
+			assert.Equal(t, "first", string(b))
+		}
+
+		fi, err := s.f.Stat()
+		assert.NoError(t, err)
+		assert.True(t, fi.Size() <= 32, "spill file of %d bytes", fi.Size())
+	}
+
+	b, err := s.next()
+	assert.NoError(t, err)
+	assert.Equal(t, "xxxxx", string(b))
+	assert.Equal(t, 0, s.len())
+
+	// the records not yet replayed are never written over.
+	assert.NoError(t, s.write(bytes.Repeat([]byte("y"), 25)))
+	assert.IsError(t, s.write([]byte("z")), errSpillFull)
+}
+
+func TestCollectLogsSpill(t *testing.T) {
+	defer resetRegistry()
+
+	r := prepareInput(testPluginInputCallbackCtrlC{})
+	r.overflowPolicy, r.maxBufferedMessages = OverflowSpill, 2
+	r.spill = newSpill(t.TempDir(), defaultSpillMaxSize)
+	defer r.spill.close()
+	r.prepareInputCollector()
+	defer r.runCancel()
+
+	for i := range 5 {
+		assert.Equal(t, 0, r.spillInput(r.channel, Message{Time: time.Now(), Record: map[string]int{"idx": i}}))
+	}
+	assert.Equal(t, 2, len(r.channel))
+	assert.Equal(t, 3, r.spill.len())
+
+	var idx []int64
+	for len(idx) < 5 {
+		b, _, err := r.collectLogs()
+		assert.NoError(t, err)
+
+		dec := msgpack.NewDecoder(bytes.NewReader(b))
+		for {
+			msg, err := decodeMsg(dec, "")
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			assert.NoError(t, err)
+
+			v, ok := msg.Record.(map[string]any)["idx"]
+			assert.True(t, ok)
+			idx = append(idx, int64(assertType[int8](t, v)))
+		}
+	}
+	assert.Equal(t, []int64{0, 1, 2, 3, 4}, idx)
+	assert.Equal(t, 0, r.spill.len())
+}
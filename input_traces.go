@@ -0,0 +1,25 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/calyptia/plugin/trace/ctr"
+)
+
+// TracesInput is an optional interface input plugins implement to emit
+// trace events into the pipeline, in addition to the log records sent by
+// Collect. Spans are built with the ctr package and consumed by the traces
+// outputs, like opentelemetry.
+type TracesInput interface {
+	CollectTraces(ctx context.Context, ch chan<- *ctr.Traces) error
+}
+
+// collectTraces encodes the buffered traces into a ctraces msgpack payload.
+func (r *registration) collectTraces() ([]byte, error) {
+	traces := drain(r.tracesChannel, r.maxBufferedMessages)
+	if len(traces) == 0 {
+		return nil, nil
+	}
+
+	return ctr.Encode(traces...)
+}
@@ -0,0 +1,36 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/calyptia/plugin/trace/ctr"
+)
+
+func TestCollectTraces(t *testing.T) {
+	r := &registration{
+		tracesChannel:       make(chan *ctr.Traces, 1),
+		maxBufferedMessages: defaultMaxBufferedMessages,
+	}
+
+	b, err := r.collectTraces()
+	assert.NoError(t, err)
+	assert.Zero(t, b)
+
+	span := ctr.NewSpan("collect", ctr.SpanKindInternal, time.Now())
+	span.End(time.Now())
+
+	traces := ctr.NewTraces()
+	traces.Add(ctr.Resource{}, ctr.Scope{Name: "go"}, span)
+	r.tracesChannel <- traces
+
+	b, err = r.collectTraces()
+	assert.NoError(t, err)
+
+	got, err := ctr.Decode(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(got))
+	assert.Equal(t, "collect", got[0].ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+}
@@ -0,0 +1,394 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/calyptia/plugin/output"
+)
+
+// outputInstance is a configured output plugin. fluent-bit creates one instance
+// per [OUTPUT] section using the plugin, each one with its own config, logger,
+// metrics and flush goroutine.
+type outputInstance struct {
+	runState
+
+	reg         *registration
+	output      OutputPlugin
+	chunkOutput ChunkOutputPlugin
+	// metricsOutput receives the metrics events, it is set when the plugin
+	// implements MetricsOutputPlugin.
+	metricsOutput MetricsOutputPlugin
+	// tracesOutput receives the trace events, it is set when the plugin
+	// implements TracesOutputPlugin.
+	tracesOutput TracesOutputPlugin
+	// batchOutput receives whole chunks, it is set when the output plugin
+	// implements BatchFlusher.
+	batchOutput BatchFlusher
+	started     bool
+	// paused is set while fluent-bit pauses the instance.
+	paused atomic.Bool
+
+	// syncFlush makes flushes wait for the messages to be acknowledged.
+	syncFlush        bool
+	syncFlushTimeout time.Duration
+	// copyChunks makes flushes copy the chunks to Go memory before decoding them.
+	copyChunks bool
+	// decodeWorkers is the number of goroutines decoding the large chunks.
+	decodeWorkers int
+	// debugLoans reports the misuses of the loaned messages.
+	debugLoans bool
+	// goroutineBudget caps the Flush goroutines per tag and the decode workers.
+	goroutineBudget int
+
+	// flushLock is held for reading by the in-flight flushes, and for writing
+	// while the instance is started or stopped.
+	flushLock sync.RWMutex
+	// flushErrs receives the errors returned by the Flush goroutine, it is
+	// replaced along with the channel.
+	flushErrs chan error
+
+	// tagChannels are the tags flushed recently, with the channels of their Flush
+	// goroutine, for plugins registered with WithFlushPerTag. tagLanes lists the
+	// channels, several tags can share one past the limit. tagSweep is the last time
+	// the idle tags were retired.
+	tagChannels map[string]*tagEntry
+	tagLanes    []*tagLane
+	tagSweep    time.Time
+
+	workersMu sync.Mutex
+	// workers maps the fluent-bit worker threads to worker ids.
+	workers map[uint64]int
+
+	// keys interns the keys of the records flushed to the instance.
+	keys *keyCache
+}
+
+// newOutputInstance creates an instance of the registered output.
+// Plugins registered with RegisterOutputFactory get a new plugin value per instance.
+func (r *registration) newOutputInstance() *outputInstance {
+	inst := &outputInstance{
+		reg:           r,
+		output:        r.output,
+		chunkOutput:   r.chunkOutput,
+		metricsOutput: r.metricsOutput,
+		tracesOutput:  r.tracesOutput,
+		keys:          newKeyCache(r.keyCacheSize),
+	}
+	if r.newOutput != nil {
+		inst.output = r.newOutput()
+	}
+	inst.metricsOutput, _ = inst.plugin().(MetricsOutputPlugin)
+	inst.tracesOutput, _ = inst.plugin().(TracesOutputPlugin)
+	inst.batchOutput, _ = inst.output.(BatchFlusher)
+	if r.newOutput != nil {
+		inst.checkOutputEvents()
+	}
+
+	r.instancesMu.Lock()
+	defer r.instancesMu.Unlock()
+
+	r.instances = append(r.instances, inst)
+
+	return inst
+}
+
+func (r *registration) removeOutputInstance(inst *outputInstance) {
+	r.instancesMu.Lock()
+	defer r.instancesMu.Unlock()
+
+	for i, v := range r.instances {
+		if v == inst {
+			r.instances = append(r.instances[:i], r.instances[i+1:]...)
+			return
+		}
+	}
+}
+
+// initer is implemented by all the plugin interfaces.
+type initer interface {
+	Init(ctx context.Context, fbit *Fluentbit) error
+}
+
+// plugin returns the plugin value of the instance.
+func (o *outputInstance) plugin() initer {
+	switch {
+	case o.output != nil:
+		return o.output
+	case o.chunkOutput != nil:
+		return o.chunkOutput
+	case o.metricsOutput != nil:
+		return o.metricsOutput
+	}
+
+	return o.tracesOutput
+}
+
+// outputEventTypes returns the fluent-bit event types handled by the registered output,
+// the ones declared with WithOutputEvents for factories.
+func (r *registration) outputEventTypes() int {
+	if r.newOutput != nil {
+		types := output.FLB_OUTPUT_LOGS
+		if r.outputEvents&MetricsEvents != 0 {
+			types |= output.FLB_OUTPUT_METRICS
+		}
+		if r.outputEvents&TracesEvents != 0 {
+			types |= output.FLB_OUTPUT_TRACES
+		}
+		return types
+	}
+
+	var plug initer = r.metricsOutput
+	switch {
+	case r.tracesOutput != nil:
+		plug = r.tracesOutput
+	case r.output != nil:
+		plug = r.output
+	case r.chunkOutput != nil:
+		plug = r.chunkOutput
+	}
+
+	var types int
+	if r.output != nil || r.chunkOutput != nil {
+		types |= output.FLB_OUTPUT_LOGS
+	}
+	if _, ok := plug.(MetricsOutputPlugin); ok {
+		types |= output.FLB_OUTPUT_METRICS
+	}
+	if _, ok := plug.(TracesOutputPlugin); ok {
+		types |= output.FLB_OUTPUT_TRACES
+	}
+
+	return types
+}
+
+// registerEventTypes returns the event types the output is registered with. Only the
+// fluent-bit versions announcing FeatureEventType have the event_type field in the proxy
+// definition, so without the announcement, the handshake included, the outputs of metrics
+// or traces only are refused, and the others only get the logs.
+func (r *registration) registerEventTypes() (int, error) {
+	types := r.outputEventTypes()
+	if types == output.FLB_OUTPUT_LOGS || HostSupports(FeatureEventType) {
+		return types, nil
+	}
+
+	if types&output.FLB_OUTPUT_LOGS == 0 {
+		return 0, fmt.Errorf("name=%q: fluent-bit does not support metrics and traces outputs", r.name)
+	}
+
+	fmt.Fprintf(os.Stderr, "register: name=%q: fluent-bit does not support metrics and traces outputs, only logs will be flushed\n", r.name)
+	return output.FLB_OUTPUT_LOGS, nil
+}
+
+// checkOutputEvents warns when the value created by an output factory does not handle
+// the events declared with WithOutputEvents, or handles undeclared ones, never routed
+// to it.
+func (o *outputInstance) checkOutputEvents() {
+	r := o.reg
+	for _, e := range []struct {
+		events  OutputEvents
+		name    string
+		handled bool
+		option  string
+	}{
+		{MetricsEvents, "MetricsOutputPlugin", o.metricsOutput != nil, "plugin.MetricsEvents"},
+		{TracesEvents, "TracesOutputPlugin", o.tracesOutput != nil, "plugin.TracesEvents"},
+	} {
+		switch declared := r.outputEvents&e.events != 0; {
+		case declared && !e.handled:
+			fmt.Fprintf(os.Stderr, "output: name=%q: %s declared but %s not implemented\n", r.name, e.option, e.name)
+		case !declared && e.handled:
+			fmt.Fprintf(os.Stderr, "output: name=%q: %s implemented but not declared with plugin.WithOutputEvents(%s)\n", r.name, e.name, e.option)
+		}
+	}
+}
+
+// outputInstances returns a copy of the output instances.
+func (r *registration) outputInstances() []*outputInstance {
+	r.instancesMu.Lock()
+	defer r.instancesMu.Unlock()
+
+	return append([]*outputInstance(nil), r.instances...)
+}
+
+// firstOutputInstance returns the first instance created, it is used by the
+// callbacks fluent-bit invokes without an instance context.
+func (r *registration) firstOutputInstance() *outputInstance {
+	r.instancesMu.Lock()
+	defer r.instancesMu.Unlock()
+
+	if len(r.instances) == 0 {
+		return nil
+	}
+
+	return r.instances[0]
+}
+
+// run starts the flush goroutine of the instance, it is a no-op when the
+// instance is already running. Flushes invoked meanwhile wait for it to return.
+func (o *outputInstance) run() error {
+	o.flushLock.Lock()
+	defer o.flushLock.Unlock()
+
+	if o.started {
+		return nil
+	}
+	if err := o.onStart(o.plugin()); err != nil {
+		return err
+	}
+	o.started = true
+	o.startFlush()
+
+	return nil
+}
+
+// startFlush creates the run context and channel of the instance, and starts its
+// flush goroutine. It is invoked with the flush lock held.
+func (o *outputInstance) startFlush() {
+	runCtx, runCancel := o.newRunContext()
+	var (
+		ch   chan Message
+		errs chan error
+	)
+	if o.output != nil && o.batchOutput == nil {
+		// chunk, batch, metrics and traces outputs are invoked synchronously from the flush callback.
+		ch, errs = make(chan Message), make(chan error)
+	}
+
+	o.channelLock.Lock()
+	o.runCtx, o.runCancel, o.channel, o.flushErrs = runCtx, runCancel, ch, errs
+	if ch != nil && o.reg.flushPerTag {
+		// the goroutines of the tags are started as they are flushed.
+		o.tagChannels, o.tagLanes, o.tagSweep = map[string]*tagEntry{}, nil, sdkClock.Now()
+	}
+	o.channelLock.Unlock()
+
+	if ch == nil {
+		return
+	}
+
+	if !o.reg.flushPerTag {
+		o.spawnFlush("flush "+o.reg.name, runCtx, runCancel, ch, errs)
+	}
+
+	context.AfterFunc(runCtx, func() {
+		o.logf(LogInfo, "goroutine will be stopping")
+	})
+}
+
+// restartFlush cancels the flushes of a running instance and starts a new flush
+// goroutine. The stalled goroutine is left behind along with its channel, which is
+// not closed as it may still be reading from it.
+func (o *outputInstance) restartFlush() {
+	_, _, cancel := o.running()
+	if cancel == nil {
+		return
+	}
+	cancel()
+
+	o.flushLock.Lock()
+	defer o.flushLock.Unlock()
+
+	// the instance was stopped meanwhile.
+	if _, _, cancel := o.running(); cancel == nil {
+		return
+	}
+
+	o.startFlush()
+}
+
+// stop drains the instance within the grace period of the service: the in-flight
+// flushes hand their records to the flush goroutine, which is then cancelled and
+// waited for, before closing the channel. Flushes still in flight after the grace
+// period are cancelled.
+func (o *outputInstance) stop() {
+	grace := currentServiceConfig().Grace
+	start := time.Now()
+	_, _, cancel := o.running()
+	if cancel != nil {
+		force := time.AfterFunc(grace, cancel)
+		defer force.Stop()
+	}
+
+	o.flushLock.Lock()
+	defer o.flushLock.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+
+	if !o.waitGoroutines(grace - time.Since(start)) {
+		o.logf(LogError, "exit: flush still running after the grace period")
+	}
+
+	o.channelLock.Lock()
+	defer o.channelLock.Unlock()
+
+	// the instance is started again by the next run, e.g. after a reload.
+	o.started = false
+	o.runCancel = nil
+	if o.channel != nil {
+		close(o.channel)
+		o.channel = nil
+	}
+	o.closeTagChannels()
+}
+
+// running returns the run context, the channel and the cancel function of the
+// instance. The context is nil until the instance is started, and the cancel
+// function once it is stopped.
+func (o *outputInstance) running() (context.Context, chan Message, context.CancelFunc) {
+	o.channelLock.Lock()
+	defer o.channelLock.Unlock()
+
+	return o.runCtx, o.channel, o.runCancel
+}
+
+// flushErrors returns the channel of the errors returned by the Flush goroutine.
+func (o *outputInstance) flushErrors() chan error {
+	o.channelLock.Lock()
+	defer o.channelLock.Unlock()
+
+	return o.flushErrs
+}
+
+// pause notifies the plugin that fluent-bit paused the instance, e.g. while its
+// chunks are retried, so it can pause its own workers. The flushes are not affected.
+func (o *outputInstance) pause() {
+	if o.paused.Swap(true) {
+		return
+	}
+
+	o.onPause(o.plugin())
+}
+
+// resume notifies the plugin that fluent-bit resumed the paused instance.
+func (o *outputInstance) resume() {
+	if !o.paused.Swap(false) {
+		return
+	}
+
+	o.onResume(o.plugin())
+}
+
+// workerID returns the id of the worker running on the given thread,
+// assigning the next available id the first time a thread is seen.
+func (o *outputInstance) workerID(thread uint64) int {
+	o.workersMu.Lock()
+	defer o.workersMu.Unlock()
+
+	id, ok := o.workers[thread]
+	if !ok {
+		if o.workers == nil {
+			o.workers = map[uint64]int{}
+		}
+		id = len(o.workers)
+		o.workers[thread] = id
+	}
+
+	return id
+}
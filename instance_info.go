@@ -0,0 +1,54 @@
+package plugin
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// InstanceInfo identifies the fluent-bit instance of a plugin, it can be used
+// for metric labels and log prefixes.
+type InstanceInfo struct {
+	// Name is the instance name, e.g. "my_output.0".
+	Name string
+	// Alias is the alias set on the instance configuration, if any.
+	Alias string
+}
+
+// Label returns the alias when set, else the name, like fluent-bit does
+// for the labels of its instance metrics.
+func (i InstanceInfo) Label() string {
+	if i.Alias != "" {
+		return i.Alias
+	}
+
+	return i.Name
+}
+
+var (
+	instanceInfosMu sync.Mutex
+	// instanceInfos are the instance names and aliases shared by fluent-bit
+	// with FLBPluginInstanceInfo, keyed by plugin instance.
+	instanceInfos = map[unsafe.Pointer]InstanceInfo{}
+)
+
+func setInstanceInfo(ptr unsafe.Pointer, info InstanceInfo) {
+	instanceInfosMu.Lock()
+	defer instanceInfosMu.Unlock()
+
+	instanceInfos[ptr] = info
+}
+
+// instanceInfo returns the info shared by fluent-bit for the instance. Older fluent-bit
+// versions not sharing it get the plugin name and the alias config key instead.
+func instanceInfo(ptr unsafe.Pointer, name string, conf ConfigLoader) InstanceInfo {
+	instanceInfosMu.Lock()
+	info, ok := instanceInfos[ptr]
+	delete(instanceInfos, ptr)
+	instanceInfosMu.Unlock()
+
+	if ok {
+		return info
+	}
+
+	return InstanceInfo{Name: name, Alias: conf.String("alias")}
+}
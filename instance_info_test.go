@@ -0,0 +1,26 @@
+package plugin
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestInstanceInfo(t *testing.T) {
+	var first, second int
+	conf := testConfigLoader{"alias": "primary"}
+
+	setInstanceInfo(unsafe.Pointer(&first), InstanceInfo{Name: "go-test-output.1", Alias: "shipper"})
+
+	info := instanceInfo(unsafe.Pointer(&first), "go-test-output", conf)
+	assert.Equal(t, InstanceInfo{Name: "go-test-output.1", Alias: "shipper"}, info)
+	assert.Equal(t, "shipper", info.Label())
+
+	// older fluent-bit versions do not share the instance info.
+	info = instanceInfo(unsafe.Pointer(&second), "go-test-output", conf)
+	assert.Equal(t, InstanceInfo{Name: "go-test-output", Alias: "primary"}, info)
+
+	info = instanceInfo(unsafe.Pointer(&first), "go-test-output", testConfigLoader{})
+	assert.Equal(t, "go-test-output", info.Label())
+}
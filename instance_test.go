@@ -0,0 +1,215 @@
+package plugin
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/calyptia/plugin/output"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type testOutputCounter struct {
+	ch chan Message
+}
+
+func (plug *testOutputCounter) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (plug *testOutputCounter) Flush(ctx context.Context, ch <-chan Message) error {
+	for {
+		select {
+		case msg := <-ch:
+			plug.ch <- msg
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func TestOutputMultiInstance(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	var plugins []*testOutputCounter
+	RegisterOutputFactory("test-output", "", func() OutputPlugin {
+		plug := &testOutputCounter{ch: make(chan Message, 1)}
+		plugins = append(plugins, plug)
+		return plug
+	})
+
+	r := registrationOf(outputKind)
+	first := r.newOutputInstance()
+	second := r.newOutputInstance()
+	assert.Equal(t, 2, len(plugins))
+	assert.Equal(t, []*outputInstance{first, second}, r.outputInstances())
+	assert.True(t, first == r.firstOutputInstance())
+
+	assert.NoError(t, first.run())
+	assert.NoError(t, second.run())
+	defer first.stop()
+	defer second.stop()
+
+	b, err := msgpack.Marshal([]any{&EventTime{time.Now()}, map[string]any{"foo": "bar"}})
+	assert.NoError(t, err)
+
+	assert.NoError(t, first.pluginFlush(0, "first", b))
+	assert.NoError(t, second.pluginFlush(0, "second", b))
+
+	assert.Equal(t, "first", (<-plugins[0].ch).Tag())
+	assert.Equal(t, "second", (<-plugins[1].ch).Tag())
+
+	r.removeOutputInstance(first)
+	assert.True(t, second == r.firstOutputInstance())
+}
+
+func TestOutputWorkerID(t *testing.T) {
+	o := &outputInstance{}
+	assert.Equal(t, 0, o.workerID(100))
+	assert.Equal(t, 1, o.workerID(200))
+	assert.Equal(t, 0, o.workerID(100))
+	assert.Equal(t, 2, o.workerID(300))
+}
+
+func TestOutputConcurrentFlush(t *testing.T) {
+	plug := &testOutputCounter{ch: make(chan Message)}
+	o := &outputInstance{reg: &registration{name: "test-output"}, output: plug}
+	assert.NoError(t, o.run())
+
+	b, err := msgpack.Marshal([]any{&EventTime{time.Now()}, map[string]any{"foo": "bar"}})
+	assert.NoError(t, err)
+
+	const workers = 4
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		go func(worker int) {
+			errs <- o.pluginFlush(worker, "foobar", b)
+		}(i)
+	}
+
+	seen := map[int]bool{}
+	for i := 0; i < workers; i++ {
+		msg := <-plug.ch
+		assert.Equal(t, "foobar", msg.Tag())
+		seen[msg.Worker()] = true
+	}
+	assert.Equal(t, map[int]bool{0: true, 1: true, 2: true, 3: true}, seen)
+	for i := 0; i < workers; i++ {
+		assert.NoError(t, <-errs)
+	}
+
+	o.stop()
+}
+
+func TestOutputRunAfterStop(t *testing.T) {
+	plug := &testOutputCounter{ch: make(chan Message, 1)}
+	o := &outputInstance{reg: &registration{name: "test-output"}, output: plug}
+
+	b, err := msgpack.Marshal([]any{&EventTime{time.Now()}, map[string]any{"foo": "bar"}})
+	assert.NoError(t, err)
+
+	for _, tag := range []string{"first", "second"} {
+		assert.NoError(t, o.run())
+		assert.Equal(t, output.FLB_OK, o.handleFlush(0, tag, b))
+		assert.Equal(t, tag, (<-plug.ch).Tag())
+		o.stop()
+		assert.False(t, o.started)
+	}
+}
+
+func TestOutputFlushNotRunning(t *testing.T) {
+	o := &outputInstance{reg: &registration{name: "test-output"}, output: &testOutputCounter{}}
+	assert.Equal(t, output.FLB_RETRY, o.handleFlush(0, "foobar", nil))
+	assert.IsError(t, o.pluginFlush(0, "foobar", nil), ErrRetry)
+}
+
+func TestOutputConcurrentFlushInstances(t *testing.T) {
+	b, err := msgpack.Marshal([]any{&EventTime{time.Now()}, map[string]any{"foo": "bar"}})
+	assert.NoError(t, err)
+
+	const (
+		instances = 3
+		workers   = 4
+		flushes   = 50
+	)
+
+	var (
+		outputs []*outputInstance
+		plugins []*testOutputCounter
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < instances; i++ {
+		plug := &testOutputCounter{ch: make(chan Message, workers*flushes)}
+		o := &outputInstance{reg: &registration{name: "test-output"}, output: plug}
+		outputs = append(outputs, o)
+		plugins = append(plugins, plug)
+
+		// flushes may be invoked while the instance is being started.
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, o.run())
+		}()
+	}
+
+	results := make(chan int, instances*workers*flushes)
+	for _, o := range outputs {
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < flushes; i++ {
+					results <- o.handleFlush(0, "foobar", b)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	close(results)
+
+	var ok int
+	for res := range results {
+		if res == output.FLB_OK {
+			ok++
+			continue
+		}
+		assert.Equal(t, output.FLB_RETRY, res)
+	}
+
+	for _, o := range outputs {
+		o.stop()
+	}
+
+	var delivered int
+	for _, plug := range plugins {
+		delivered += len(plug.ch)
+	}
+	assert.Equal(t, ok, delivered)
+}
+
+func TestOutputFlushWhileStopping(t *testing.T) {
+	b, err := msgpack.Marshal([]any{&EventTime{time.Now()}, map[string]any{"foo": "bar"}})
+	assert.NoError(t, err)
+
+	plug := &testOutputCounter{ch: make(chan Message, 1000)}
+	o := &outputInstance{reg: &registration{name: "test-output"}, output: plug}
+	assert.NoError(t, o.run())
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				assert.Equal(t, output.FLB_OK, o.handleFlush(0, "foobar", b))
+			}
+		}()
+	}
+
+	o.stop()
+	wg.Wait()
+	assert.Zero(t, o.channel)
+}
@@ -0,0 +1,127 @@
+// Package bridge copies the metrics of the instrumentation libraries, like prometheus
+// client_golang or OpenTelemetry, to the metrics of a plugin. The metrics of the plugin are
+// created the first time their name is seen, with the label keys of their first series.
+package bridge
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/calyptia/plugin/metric"
+)
+
+// Metrics creates the metrics of a plugin, implemented by the Metrics of
+// plugin.Fluentbit.
+type Metrics interface {
+	NewCounter(name, desc string, labelKeys ...string) metric.Counter
+	NewGauge(name, desc string, labelKeys ...string) metric.Gauge
+}
+
+// Set is the set of the metrics of a plugin the series are copied to. It is not safe for
+// concurrent use.
+type Set struct {
+	metrics Metrics
+	series  map[string]*series
+	// counted holds the last values of the cumulative counters, by name and label values,
+	// the counters adding the difference.
+	counted map[string]float64
+}
+
+// series is a metric of the plugin.
+type series struct {
+	labelKeys []string
+	counter   metric.Counter
+	gauge     metric.Gauge
+}
+
+// New returns an empty Set of the metrics created with metrics.
+func New(metrics Metrics) *Set {
+	return &Set{
+		metrics: metrics,
+		series:  map[string]*series{},
+		counted: map[string]float64{},
+	}
+}
+
+// Count adds the difference between the cumulative value v of a counter and its previous
+// value. A value lower than the previous one is a reset of the counter, the whole value
+// being added.
+func (s *Set) Count(name, help string, labelKeys, labelValues []string, v float64) {
+	m := s.lookup(name, help, labelKeys, true)
+	values := m.labelValues(labelKeys, labelValues)
+
+	key := name + "\xff" + strings.Join(values, "\xff")
+	delta := v - s.counted[key]
+	if delta < 0 {
+		delta = v
+	}
+	s.counted[key] = v
+
+	if delta > 0 {
+		m.counter.Add(delta, values...)
+	}
+}
+
+// Add adds delta to a counter.
+func (s *Set) Add(name, help string, labelKeys, labelValues []string, delta float64) {
+	if delta <= 0 {
+		return
+	}
+
+	m := s.lookup(name, help, labelKeys, true)
+	m.counter.Add(delta, m.labelValues(labelKeys, labelValues)...)
+}
+
+// Set sets a gauge to v.
+func (s *Set) Set(name, help string, labelKeys, labelValues []string, v float64) {
+	m := s.lookup(name, help, labelKeys, false)
+	m.gauge.Set(v, m.labelValues(labelKeys, labelValues)...)
+}
+
+// AddGauge adds delta to a gauge.
+func (s *Set) AddGauge(name, help string, labelKeys, labelValues []string, delta float64) {
+	m := s.lookup(name, help, labelKeys, false)
+	m.gauge.Add(delta, m.labelValues(labelKeys, labelValues)...)
+}
+
+// lookup returns the metric named name, creating it with labelKeys the first time the
+// name is seen.
+func (s *Set) lookup(name, help string, labelKeys []string, counter bool) *series {
+	if m, ok := s.series[name]; ok {
+		return m
+	}
+
+	if help == "" {
+		help = name
+	}
+
+	m := &series{labelKeys: append([]string(nil), labelKeys...)}
+	if counter {
+		m.counter = s.metrics.NewCounter(name, help, m.labelKeys...)
+	} else {
+		m.gauge = s.metrics.NewGauge(name, help, m.labelKeys...)
+	}
+	s.series[name] = m
+
+	return m
+}
+
+// labelValues returns the values of the label keys of the metric, given the keys and the
+// values of a series. The labels the series lacks are empty.
+func (m *series) labelValues(labelKeys, labelValues []string) []string {
+	if slices.Equal(m.labelKeys, labelKeys) {
+		return labelValues
+	}
+
+	values := make([]string, len(m.labelKeys))
+	for i, k := range m.labelKeys {
+		for j, key := range labelKeys {
+			if key == k {
+				values[i] = labelValues[j]
+				break
+			}
+		}
+	}
+
+	return values
+}
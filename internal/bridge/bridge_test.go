@@ -0,0 +1,32 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/calyptia/plugin/internal/metrictest"
+	"github.com/calyptia/plugin/metric/cmt"
+)
+
+func TestSet(t *testing.T) {
+	m := cmt.NewMetrics("fluentbit", "plugin")
+	s := New(m)
+
+	s.Count("requests_total", "", []string{"code"}, []string{"200"}, 3)
+	s.Count("requests_total", "", []string{"code"}, []string{"200"}, 5)
+	s.Add("requests_total", "", []string{"code"}, []string{"500"}, 1)
+	s.Set("connections", "", nil, nil, 4)
+	s.AddGauge("connections", "", nil, nil, -1)
+
+	// the labels are matched by key, the missing ones being empty.
+	s.Count("requests_total", "", []string{"method", "code"}, []string{"GET", "404"}, 2)
+	s.Set("connections", "", []string{"host"}, []string{"localhost"}, 2)
+
+	assert.Equal(t, map[string]float64{
+		"requests_total{code=200}": 5,
+		"requests_total{code=500}": 1,
+		"requests_total{code=404}": 2,
+		"connections{}":            2,
+	}, metrictest.Values(t, m))
+	assert.Equal(t, []string{"code"}, metrictest.LabelKeys(t, m, "requests_total"))
+}
@@ -0,0 +1,227 @@
+// Package cmem defines the ownership of the memory crossing the cgo boundary.
+//
+// Memory passed by fluent-bit to a callback is borrowed: it stays owned by fluent-bit
+// and is only valid during the callback, so it is read with Borrow and copied with Copy
+// to be retained.
+//
+// Memory returned to fluent-bit is allocated with Own, which reports allocation failures
+// instead of aborting. Once handed to fluent-bit, either fluent-bit frees it, which is
+// recorded with HandOver, or fluent-bit gives it back through a cleanup callback calling
+// Free. Memory allocated with Own and not handed over is released with Free as well.
+//
+// Outstanding counts the allocations not handed over nor freed, tests use it to check the
+// callbacks don't leak.
+//
+// A Buffer is C memory kept across callbacks, lent to fluent-bit and given back through
+// a cleanup callback, so the callbacks returning data don't allocate every time.
+package cmem
+
+/*
+#include <stdlib.h>
+#include <string.h>
+
+// cmem_alloc returns NULL on failure, unlike the malloc wrapper of cgo which aborts.
+static void *cmem_alloc(size_t size) {
+    return malloc(size);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// ErrNoMemory is returned when the C allocation fails.
+var ErrNoMemory = errors.New("cmem: out of memory")
+
+// outstanding counts the allocations not handed over nor freed.
+var outstanding atomic.Int64
+
+// Borrow returns a view of n bytes of C memory owned by fluent-bit, without copying it.
+// The slice must not be retained after the callback returns.
+func Borrow(p unsafe.Pointer, n int) []byte {
+	if p == nil || n <= 0 {
+		return nil
+	}
+
+	return unsafe.Slice((*byte)(p), n)
+}
+
+// Copy returns a copy of n bytes of C memory owned by fluent-bit.
+func Copy(p unsafe.Pointer, n int) []byte {
+	b := Borrow(p, n)
+	if b == nil {
+		return nil
+	}
+
+	return append([]byte(nil), b...)
+}
+
+// Own allocates C memory holding a copy of b, it returns nil for an empty b.
+func Own(b []byte) (unsafe.Pointer, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	p := C.cmem_alloc(C.size_t(len(b)))
+	if p == nil {
+		return nil, ErrNoMemory
+	}
+
+	C.memcpy(p, unsafe.Pointer(&b[0]), C.size_t(len(b)))
+	outstanding.Add(1)
+
+	return p, nil
+}
+
+// OwnString allocates a NUL terminated C string holding a copy of s.
+func OwnString(s string) (unsafe.Pointer, error) {
+	return Own(append([]byte(s), 0))
+}
+
+// HandOver records the memory allocated with Own as owned by fluent-bit, which frees it.
+func HandOver(p unsafe.Pointer) {
+	if p != nil {
+		outstanding.Add(-1)
+	}
+}
+
+// Free releases memory allocated with Own.
+func Free(p unsafe.Pointer) {
+	if p == nil {
+		return
+	}
+
+	C.free(p)
+	outstanding.Add(-1)
+}
+
+// Outstanding returns the number of allocations made with Own not handed over nor freed.
+func Outstanding() int64 {
+	return outstanding.Load()
+}
+
+// Buffer is C memory reused across the callbacks returning data to fluent-bit. Data is
+// written in place into the slice returned by Available, then lent to fluent-bit with
+// Lend until it gives it back with Release. Its memory is not counted by Outstanding.
+type Buffer struct {
+	mu     sync.Mutex
+	p      unsafe.Pointer
+	size   int
+	lent   bool
+	closed bool
+}
+
+// NewBuffer returns a buffer of the given size, allocated on first use.
+func NewBuffer(size int) *Buffer {
+	return &Buffer{size: size}
+}
+
+// Available returns an empty slice backed by the buffer, to append data to in place.
+// It returns nil while the buffer is lent, or when it can't be allocated.
+func (buf *Buffer) Available() []byte {
+	if buf == nil {
+		return nil
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	if buf.lent || buf.closed || buf.size <= 0 || !buf.alloc(buf.size) {
+		return nil
+	}
+
+	return unsafe.Slice((*byte)(buf.p), buf.size)[:0]
+}
+
+// alloc makes the buffer hold at least size bytes.
+func (buf *Buffer) alloc(size int) bool {
+	if buf.p != nil && size <= buf.size {
+		return true
+	}
+
+	p := C.cmem_alloc(C.size_t(size))
+	if p == nil {
+		return false
+	}
+
+	if buf.p != nil {
+		C.free(buf.p)
+	}
+	buf.p, buf.size = p, size
+
+	return true
+}
+
+// Lend returns C memory holding b for fluent-bit: the buffer itself when b was written
+// in place, or when it can be copied into it, growing it as needed. Memory is allocated
+// with Own instead while the buffer is lent. It returns nil for an empty b.
+func (buf *Buffer) Lend(b []byte) (unsafe.Pointer, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	if buf == nil {
+		return Own(b)
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	if buf.lent || buf.closed {
+		return Own(b)
+	}
+
+	if unsafe.Pointer(&b[0]) != buf.p {
+		if !buf.alloc(len(b)) {
+			return Own(b)
+		}
+		C.memcpy(buf.p, unsafe.Pointer(&b[0]), C.size_t(len(b)))
+	}
+	buf.lent = true
+
+	return buf.p, nil
+}
+
+// Release takes back the memory lent to fluent-bit, freeing it when it was allocated with Own.
+func (buf *Buffer) Release(p unsafe.Pointer) {
+	if buf == nil {
+		Free(p)
+		return
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	if p != nil && p == buf.p {
+		buf.lent = false
+		if buf.closed {
+			C.free(buf.p)
+			buf.p = nil
+		}
+		return
+	}
+
+	Free(p)
+}
+
+// Close frees the buffer, or once given back when it is still lent.
+func (buf *Buffer) Close() {
+	if buf == nil {
+		return
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	buf.closed = true
+	if buf.p == nil || buf.lent {
+		return
+	}
+
+	C.free(buf.p)
+	buf.p = nil
+}
@@ -0,0 +1,86 @@
+package cmem
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestOwn(t *testing.T) {
+	before := Outstanding()
+
+	p, err := Own([]byte("foobar"))
+	assert.NoError(t, err)
+	assert.Equal(t, before+1, Outstanding())
+
+	assert.Equal(t, []byte("foo"), Borrow(p, 3))
+	b := Copy(p, 6)
+	Free(p)
+	assert.Equal(t, "foobar", string(b))
+	assert.Equal(t, before, Outstanding())
+
+	p, err = OwnString("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("foo\x00"), Copy(p, 4))
+	HandOver(p)
+	assert.Equal(t, before, Outstanding())
+
+	// fluent-bit would free the memory handed over.
+	outstanding.Add(1)
+	Free(p)
+
+	p, err = Own(nil)
+	assert.NoError(t, err)
+	assert.Zero(t, p)
+	assert.Zero(t, Borrow(nil, 10))
+	assert.Zero(t, Copy(nil, 10))
+	assert.Equal(t, before, Outstanding())
+}
+
+func TestBuffer(t *testing.T) {
+	before := Outstanding()
+
+	buf := NewBuffer(8)
+	b := append(buf.Available(), "foo"...)
+
+	// data written in place is lent without a copy.
+	p, err := buf.Lend(b)
+	assert.NoError(t, err)
+	assert.Equal(t, unsafe.Pointer(&b[0]), p)
+	assert.Equal(t, "foo", string(Copy(p, 3)))
+	assert.Zero(t, buf.Available())
+
+	// the buffer being lent, memory is allocated.
+	other, err := buf.Lend([]byte("bar"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, p, other)
+	assert.Equal(t, before+1, Outstanding())
+	buf.Release(other)
+	assert.Equal(t, before, Outstanding())
+
+	buf.Release(p)
+	assert.Equal(t, 8, cap(buf.Available()))
+
+	// data written elsewhere is copied, growing the buffer.
+	p, err = buf.Lend([]byte("foobarbaz"))
+	assert.NoError(t, err)
+	assert.Equal(t, "foobarbaz", string(Copy(p, 9)))
+	buf.Release(p)
+	assert.Equal(t, 9, cap(buf.Available()))
+
+	p, err = buf.Lend(nil)
+	assert.NoError(t, err)
+	assert.Zero(t, p)
+
+	buf.Close()
+	assert.Zero(t, buf.Available())
+	assert.Equal(t, before, Outstanding())
+
+	var none *Buffer
+	assert.Zero(t, none.Available())
+	p, err = none.Lend([]byte("foo"))
+	assert.NoError(t, err)
+	none.Release(p)
+	assert.Equal(t, before, Outstanding())
+}
@@ -0,0 +1,54 @@
+// Package metrictest reads back the metrics kept in memory by cmt.Metrics, for the tests
+// of the packages bridging other metrics libraries to the metrics of the plugins.
+package metrictest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/calyptia/plugin/metric/cmt"
+)
+
+// Values returns the values of the series of m by metric name and label values, e.g.
+// `requests_total{code=200}`, the names left without their namespace and subsystem.
+func Values(t testing.TB, m *cmt.Metrics) map[string]float64 {
+	t.Helper()
+
+	out := map[string]float64{}
+	for _, f := range snapshot(t, m) {
+		for _, v := range f.Values {
+			labels := make([]string, len(v.Labels))
+			for i, value := range v.Labels {
+				labels[i] = f.Meta.Labels[i] + "=" + value
+			}
+
+			out[f.Meta.Opts.Name+"{"+strings.Join(labels, ",")+"}"] = v.Value
+		}
+	}
+
+	return out
+}
+
+// LabelKeys returns the label keys of the metric of the given name, nil when missing.
+func LabelKeys(t testing.TB, m *cmt.Metrics, name string) []string {
+	t.Helper()
+
+	for _, f := range snapshot(t, m) {
+		if f.Meta.Opts.Name == name {
+			return f.Meta.Labels
+		}
+	}
+
+	return nil
+}
+
+func snapshot(t testing.TB, m *cmt.Metrics) []cmt.Metric {
+	t.Helper()
+
+	metrics, err := m.Snapshot()
+	if err != nil {
+		t.Fatalf("metrics snapshot: %v", err)
+	}
+
+	return metrics
+}
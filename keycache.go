@@ -0,0 +1,88 @@
+package plugin
+
+import (
+	"fmt"
+	"hash/maphash"
+	"math/bits"
+	"sync/atomic"
+)
+
+const (
+	// defaultKeyCacheSize is the number of keys interned per instance by default.
+	defaultKeyCacheSize = 1024
+	// maxCachedKeyLen is the length of the longest key interned, longer keys are unlikely
+	// to repeat and would pin their memory.
+	maxCachedKeyLen = 64
+)
+
+// WithKeyCache sets the number of record keys interned by the decoder of each instance of
+// the plugin, 1024 by default. Log streams repeat the same keys over and over ("log",
+// "stream", "kubernetes"...), the records decoded for outputs, filters and processors
+// share a single string per key instead of allocating one per record. The size is rounded
+// up to a power of two, a zero size disables the cache. It panics when used to register
+// input plugins or with a negative size.
+func WithKeyCache(size int) RegisterOption {
+	return func(r *registration) {
+		if r.kind == inputKind {
+			panic(fmt.Sprintf("key cache set on %s plugin: %q", r.kind, r.name))
+		}
+
+		if size < 0 {
+			panic(fmt.Sprintf("invalid key cache size %d: %q", size, r.name))
+		}
+
+		if size == 0 {
+			// a negative size tells the cache apart from the default.
+			size = -1
+		}
+
+		r.keyCacheSize = size
+	}
+}
+
+// keyCache interns the keys of the decoded records. It is a direct-mapped table: a key
+// replaces the one of its slot on collisions, so the cache stays bounded and hot keys
+// win their slot back. The slots are swapped atomically, so the concurrent flushes of
+// an instance share its cache without locking.
+type keyCache struct {
+	seed  maphash.Seed
+	mask  uint64
+	slots []atomic.Pointer[string]
+}
+
+// newKeyCache returns a cache of size keys, rounded up to a power of two. It returns nil,
+// a cache interning nothing, for negative sizes.
+func newKeyCache(size int) *keyCache {
+	if size < 0 {
+		return nil
+	}
+
+	if size == 0 {
+		size = defaultKeyCacheSize
+	}
+
+	size = 1 << bits.Len(uint(size-1))
+
+	return &keyCache{
+		seed:  maphash.MakeSeed(),
+		mask:  uint64(size - 1),
+		slots: make([]atomic.Pointer[string], size),
+	}
+}
+
+// intern returns the string of b, the cached one when the key was seen before.
+func (c *keyCache) intern(b []byte) string {
+	if c == nil || len(b) > maxCachedKeyLen {
+		return string(b)
+	}
+
+	slot := &c.slots[maphash.Bytes(c.seed, b)&c.mask]
+	if s := slot.Load(); s != nil && *s == string(b) {
+		return *s
+	}
+
+	s := string(b)
+	slot.Store(&s)
+
+	return s
+}
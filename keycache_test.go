@@ -0,0 +1,167 @@
+package plugin
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestWithKeyCache(t *testing.T) {
+	r := &registration{kind: outputKind, name: "dummy"}
+	WithKeyCache(100)(r)
+	assert.Equal(t, 100, r.keyCacheSize)
+	assert.Equal(t, 128, len(newKeyCache(r.keyCacheSize).slots))
+
+	WithKeyCache(0)(r)
+	assert.Zero(t, newKeyCache(r.keyCacheSize))
+
+	// the default size applies unless set.
+	assert.Equal(t, defaultKeyCacheSize, len(newKeyCache(0).slots))
+
+	assert.Panics(t, func() {
+		WithKeyCache(100)(&registration{kind: inputKind, name: "dummy"})
+	})
+	assert.Panics(t, func() {
+		WithKeyCache(-1)(&registration{kind: filterKind, name: "dummy"})
+	})
+}
+
+func TestKeyCache(t *testing.T) {
+	c := newKeyCache(16)
+
+	first := c.intern([]byte("kubernetes"))
+	second := c.intern([]byte("kubernetes"))
+	assert.Equal(t, "kubernetes", second)
+	assert.Equal(t, unsafe.StringData(first), unsafe.StringData(second))
+
+	// long keys are not interned.
+	long := strings.Repeat("k", maxCachedKeyLen+1)
+	assert.Equal(t, long, c.intern([]byte(long)))
+	for i := range c.slots {
+		if s := c.slots[i].Load(); s != nil {
+			assert.NotEqual(t, long, *s)
+		}
+	}
+
+	// the cache stays bounded, evicted keys are interned again.
+	for i := 0; i < 1000; i++ {
+		k := fmt.Sprintf("key%d", i%100)
+		assert.Equal(t, k, c.intern([]byte(k)))
+	}
+	assert.Equal(t, 16, len(c.slots))
+
+	var disabled *keyCache
+	assert.Equal(t, "log", disabled.intern([]byte("log")))
+}
+
+func TestKeyCacheConcurrent(t *testing.T) {
+	c := newKeyCache(4)
+	keys := []string{"log", "stream", "time", "kubernetes", "pod_name", "namespace_name"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				k := keys[j%len(keys)]
+				if got := c.intern([]byte(k)); got != k {
+					t.Errorf("got %q, want %q", got, k)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRecordReaderKeyCache(t *testing.T) {
+	now := time.Now()
+	b := testEncodeEntries(t, [][]any{
+		{&EventTime{now}, map[string]any{"log": "first", "kubernetes": map[string]any{"pod_name": "a"}}},
+		{&EventTime{now}, map[string]any{"log": "second", "kubernetes": map[string]any{"pod_name": "b"}}},
+	})
+
+	dec := newRecordDecoder(MsgpackCodec{}, newKeyCache(16), b, false)
+	_, first, err := dec.Next()
+	assert.NoError(t, err)
+	_, second, err := dec.Next()
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]any{"log": "second", "kubernetes": map[string]any{"pod_name": "b"}}, second)
+
+	// the keys of both records, nested ones included, share their strings.
+	keyData := func(m map[string]any) map[string]*byte {
+		data := map[string]*byte{}
+		for k, v := range m {
+			data[k] = unsafe.StringData(k)
+			if nested, ok := v.(map[string]any); ok {
+				for nk := range nested {
+					data[k+"."+nk] = unsafe.StringData(nk)
+				}
+			}
+		}
+		return data
+	}
+	assert.Equal(t, keyData(first), keyData(second))
+}
+
+// BenchmarkKeyCache reports the memory retained per record by the decoded records, with
+// and without the key cache.
+func BenchmarkKeyCache(b *testing.B) {
+	const records = 1000
+
+	for _, shape := range benchShapes {
+		chunk, err := encodeMessages(MsgpackCodec{}, nil, benchMessages(shape, records))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for _, size := range []int{-1, defaultKeyCacheSize} {
+			name := shape.name + "/uncached"
+			if size > 0 {
+				name = shape.name + "/cached"
+			}
+
+			b.Run(name, func(b *testing.B) {
+				keys := newKeyCache(size)
+				retained := make([]map[string]any, records)
+
+				var retainedBytes uint64
+
+				b.ReportAllocs()
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					var before, after runtime.MemStats
+					b.StopTimer()
+					clear(retained)
+					runtime.GC()
+					runtime.ReadMemStats(&before)
+					b.StartTimer()
+
+					dec := newRecordDecoder(MsgpackCodec{}, keys, chunk, false)
+					for j := range retained {
+						if _, retained[j], err = dec.Next(); err != nil {
+							b.Fatal(err)
+						}
+					}
+
+					b.StopTimer()
+					runtime.GC()
+					runtime.ReadMemStats(&after)
+					retainedBytes += after.HeapAlloc - before.HeapAlloc
+					b.StartTimer()
+				}
+
+				b.ReportMetric(float64(retainedBytes)/float64(b.N*records), "retained-B/record")
+			})
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// WithLazyRecords makes the output flush lazy records: the records of its messages are
+// *LazyRecord, decoding only the fields the plugin reads, so outputs reading a few fields
+// of wide records skip decoding the rest. The records are encoded back as they were
+// received, forwarding the untouched fields as is. It applies to the msgpack codec, the
+// records decoded by the other codecs being maps, and disables the parallel decoding of
+// the chunks. It panics when used to register other kinds of plugins.
+func WithLazyRecords() RegisterOption {
+	return func(r *registration) {
+		if r.kind != outputKind {
+			panic(fmt.Sprintf("lazy records set on %s plugin: %q", r.kind, r.name))
+		}
+
+		r.lazyRecords = true
+	}
+}
+
+// lazyDecoding reports whether the instance flushes lazy records.
+func (o *outputInstance) lazyDecoding() bool {
+	return o.reg != nil && o.reg.lazyRecords
+}
+
+// LazyRecord is a record decoded on access. Get decodes the value of a single field,
+// while iterating the record decodes all of them, once. It is safe for concurrent use.
+type LazyRecord struct {
+	// raw is the encoded map, copied out of the chunk.
+	raw []byte
+
+	once   sync.Once
+	record map[string]any
+	err    error
+}
+
+// Get decodes the value of the field with the given key, reporting whether the record
+// holds it.
+func (r *LazyRecord) Get(key string) (any, bool) {
+	rd, n := r.fields()
+	for i := 0; i < n; i++ {
+		c, err := rd.code()
+		if err != nil {
+			return nil, false
+		}
+
+		k, err := rd.rawStr(c)
+		if err != nil {
+			return nil, false
+		}
+
+		if string(k) == key {
+			v, err := rd.value()
+			return v, err == nil
+		}
+
+		if err := rd.skip(); err != nil {
+			return nil, false
+		}
+	}
+
+	return nil, false
+}
+
+// Len returns the number of fields of the record, without decoding them.
+func (r *LazyRecord) Len() int {
+	_, n := r.fields()
+	return n
+}
+
+// Map decodes all the fields of the record, once.
+func (r *LazyRecord) Map() (map[string]any, error) {
+	r.once.Do(func() {
+		rd := &recordReader{b: r.raw}
+		record, err := rd.record()
+		if err != nil {
+			r.err = fmt.Errorf("msgpack unmarshal event record: %w", err)
+			return
+		}
+
+		r.record = record
+	})
+
+	return r.record, r.err
+}
+
+// Range invokes fn for each field of the record, decoding all of them, until fn returns
+// false.
+func (r *LazyRecord) Range(fn func(key string, value any) bool) error {
+	record, err := r.Map()
+	if err != nil {
+		return err
+	}
+
+	for k, v := range record {
+		if !fn(k, v) {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Raw returns the record encoded as msgpack, which must not be modified.
+func (r *LazyRecord) Raw() []byte {
+	return r.raw
+}
+
+// EncodeMsgpack writes the record as it was received.
+func (r *LazyRecord) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if len(r.raw) == 0 {
+		return enc.EncodeNil()
+	}
+
+	_, err := enc.Writer().Write(r.raw)
+	return err
+}
+
+// fields returns a reader positioned at the first field of the record, and the number of
+// its fields.
+func (r *LazyRecord) fields() (*recordReader, int) {
+	rd := &recordReader{b: r.raw}
+	if len(r.raw) == 0 {
+		return rd, 0
+	}
+
+	c, err := rd.code()
+	if err != nil || c == msgpcode.Nil {
+		return rd, 0
+	}
+
+	n, err := rd.mapLen(c)
+	if err != nil {
+		return rd, 0
+	}
+
+	return rd, n
+}
+
+// nextLazy decodes the next entry of the chunk with its record left encoded, it returns
+// io.EOF after the last one.
+func (r *recordReader) nextLazy() (time.Time, *LazyRecord, error) {
+	n, ts, err := r.entry()
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	start := r.off
+	if start < len(r.b) && !isMapCode(r.b[start]) {
+		return time.Time{}, nil, fmt.Errorf("msgpack unmarshal event record: msgpack: invalid code=%x decoding map length", r.b[start])
+	}
+
+	if err := r.skip(); err != nil {
+		return time.Time{}, nil, fmt.Errorf("msgpack unmarshal event record: %w", err)
+	}
+
+	// the chunk may be memory of fluent-bit, released once flushed.
+	record := &LazyRecord{raw: bytes.Clone(r.b[start:r.off])}
+
+	if err := r.skipExtra(n); err != nil {
+		return time.Time{}, nil, err
+	}
+
+	return ts, record, nil
+}
+
+// isMapCode reports whether c starts a map, or a nil record.
+func isMapCode(c byte) bool {
+	return c == msgpcode.Nil || msgpcode.IsFixedMap(c) || c == msgpcode.Map16 || c == msgpcode.Map32
+}
@@ -0,0 +1,175 @@
+package plugin
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestWithLazyRecords(t *testing.T) {
+	r := &registration{kind: outputKind, name: "dummy"}
+	WithLazyRecords()(r)
+	assert.True(t, r.lazyRecords)
+
+	assert.Panics(t, func() {
+		WithLazyRecords()(&registration{kind: filterKind, name: "dummy"})
+	})
+}
+
+// wideRecord returns a record of n fields.
+func wideRecord(n int) map[string]any {
+	record := make(map[string]any, n)
+	for i := 0; i < n; i++ {
+		record[fmt.Sprintf("field_%02d", i)] = fmt.Sprintf("value of the field %d", i)
+	}
+	record["status"] = 200
+	record["kubernetes"] = map[string]any{"pod": "web", "labels": map[string]any{"app": "web"}}
+
+	return record
+}
+
+func TestLazyRecord(t *testing.T) {
+	o := &outputInstance{reg: &registration{kind: outputKind, name: "dummy", lazyRecords: true}}
+
+	now := time.Unix(1700000000, 1).UTC()
+	b, err := encodeMessages(defaultCodec, nil, []Message{
+		{Time: now, Record: wideRecord(50)},
+		{Time: now, Record: nil},
+	})
+	assert.NoError(t, err)
+	want := testDecodeCodec(t, defaultCodec, b)
+
+	chunk, err := o.decodeChunk("tag", b)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, chunk.Records)
+	assert.Equal(t, now, chunk.Messages[0].Time)
+
+	record := chunk.Messages[0].Record.(*LazyRecord)
+	assert.Equal(t, 52, record.Len())
+
+	v, ok := record.Get("field_42")
+	assert.True(t, ok)
+	assert.Equal(t, any("value of the field 42"), v)
+	v, ok = record.Get("kubernetes")
+	assert.True(t, ok)
+	assert.Equal(t, want[0].Record.(map[string]any)["kubernetes"], v)
+	_, ok = record.Get("missing")
+	assert.False(t, ok)
+
+	// iterating decodes the whole record.
+	m, err := record.Map()
+	assert.NoError(t, err)
+	assert.Equal(t, want[0].Record, any(m))
+
+	fields := map[string]any{}
+	assert.NoError(t, record.Range(func(k string, v any) bool {
+		fields[k] = v
+		return true
+	}))
+	assert.Equal(t, m, fields)
+
+	// the records are encoded back as received.
+	got, err := encodeMessages(defaultCodec, nil, chunk.Messages)
+	assert.NoError(t, err)
+	assert.Equal(t, b, got)
+	got, err = encodeMessages(UgorjiCodec{}, nil, chunk.Messages)
+	assert.NoError(t, err)
+	assert.Equal(t, want, testDecodeCodec(t, defaultCodec, got))
+
+	empty := chunk.Messages[1].Record.(*LazyRecord)
+	assert.Zero(t, empty.Len())
+	m, err = empty.Map()
+	assert.NoError(t, err)
+	assert.Zero(t, m)
+}
+
+func TestLazyRecordChunkMemory(t *testing.T) {
+	o := &outputInstance{reg: &registration{kind: outputKind, name: "dummy", lazyRecords: true}}
+
+	b, err := encodeMessages(defaultCodec, nil, []Message{{Time: time.Now(), Record: map[string]any{"log": "kept"}}})
+	assert.NoError(t, err)
+
+	chunk, err := o.decodeChunk("tag", b)
+	assert.NoError(t, err)
+
+	// the records outlive the chunk, released by fluent-bit once flushed.
+	clear(b)
+	v, ok := chunk.Messages[0].Record.(*LazyRecord).Get("log")
+	assert.True(t, ok)
+	assert.Equal(t, any("kept"), v)
+}
+
+func TestLazyRecordErrors(t *testing.T) {
+	o := &outputInstance{reg: &registration{kind: outputKind, name: "dummy", lazyRecords: true}}
+
+	b, err := encodeMessages(defaultCodec, nil, []Message{{Time: time.Now(), Record: []any{"not a map"}}})
+	assert.NoError(t, err)
+	_, err = o.decodeChunk("tag", b)
+	assert.EqualError(t, err, "msgpack unmarshal event record: msgpack: invalid code=91 decoding map length")
+
+	b, err = encodeMessages(defaultCodec, nil, []Message{{Time: time.Now(), Record: map[string]any{"log": "truncated"}}})
+	assert.NoError(t, err)
+	_, err = o.decodeChunk("tag", b[:len(b)-1])
+	assert.Error(t, err)
+
+	// the other codecs decode maps.
+	o.reg.codec = UgorjiCodec{}
+	chunk, err := o.decodeChunk("tag", b)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"log": "truncated"}, chunk.Messages[0].Record.(map[string]any))
+}
+
+// BenchmarkLazyRecord flushes chunks of wide records to outputs reading three fields of
+// them, from decoded or lazy records.
+func BenchmarkLazyRecord(b *testing.B) {
+	const records = 100
+
+	msgs := make([]Message, records)
+	for i := range msgs {
+		msgs[i] = Message{Time: time.Now(), Record: wideRecord(50)}
+	}
+
+	chunk, err := encodeMessages(defaultCodec, nil, msgs)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	keys := []string{"status", "field_07", "field_42"}
+	for _, lazy := range []bool{false, true} {
+		name := "decoded"
+		if lazy {
+			name = "lazy"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			o := &outputInstance{reg: &registration{kind: outputKind, name: "dummy", lazyRecords: lazy}}
+
+			b.ReportAllocs()
+			b.SetBytes(int64(len(chunk)))
+
+			for i := 0; i < b.N; i++ {
+				c, err := o.decodeChunk("tag", chunk)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				for _, msg := range c.Messages {
+					for _, k := range keys {
+						var ok bool
+						switch record := msg.Record.(type) {
+						case *LazyRecord:
+							_, ok = record.Get(k)
+						case map[string]any:
+							_, ok = record[k]
+						}
+						if !ok {
+							b.Fatalf("missing %q", k)
+						}
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package plugin
+
+import "context"
+
+// Starter is an optional interface plugins implement to start background work, like
+// connection pools, once fluent-bit runs them. OnStart is invoked after Init, before the
+// first collect or flush. An error fails the start of the plugin.
+type Starter interface {
+	OnStart(ctx context.Context) error
+}
+
+// Stopper is an optional interface plugins implement to release their resources on
+// exit, once Collect or Flush returned. The context expires with the grace period.
+type Stopper interface {
+	OnStop(ctx context.Context) error
+}
+
+// Pauser is an optional interface plugins implement to be notified when fluent-bit pauses
+// them. Inputs are paused e.g. when the memory buffer limit is reached, once Collect got
+// cancelled. Outputs are paused e.g. while their chunks are retried, so they can pause their
+// internal workers instead of queueing records.
+type Pauser interface {
+	OnPause(ctx context.Context) error
+}
+
+// Resumer is an optional interface plugins implement to be notified when fluent-bit
+// resumes them, for inputs before Collect is invoked again.
+type Resumer interface {
+	OnResume(ctx context.Context) error
+}
+
+// onStart invokes the OnStart hook of the plugin, when implemented.
+func (s *runState) onStart(plug any) error {
+	p, ok := plug.(Starter)
+	if !ok {
+		return nil
+	}
+
+	return s.protect("start", func() error {
+		return p.OnStart(s.baseContext())
+	})
+}
+
+// onStop invokes the OnStop hook of the plugin, when implemented.
+func (s *runState) onStop(plug any) {
+	p, ok := plug.(Stopper)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.baseContext(), currentServiceConfig().Grace)
+	defer cancel()
+
+	if err := s.protect("stop", func() error { return p.OnStop(ctx) }); err != nil {
+		s.logf(LogError, "stop: %v", err)
+	}
+}
+
+// onPause invokes the OnPause hook of the plugin, when implemented.
+func (s *runState) onPause(plug any) {
+	p, ok := plug.(Pauser)
+	if !ok {
+		return
+	}
+
+	if err := s.protect("pause", func() error { return p.OnPause(s.baseContext()) }); err != nil {
+		s.logf(LogError, "pause: %v", err)
+	}
+}
+
+// onResume invokes the OnResume hook of the plugin, when implemented.
+func (s *runState) onResume(plug any) {
+	p, ok := plug.(Resumer)
+	if !ok {
+		return
+	}
+
+	if err := s.protect("resume", func() error { return p.OnResume(s.baseContext()) }); err != nil {
+		s.logf(LogError, "resume: %v", err)
+	}
+}
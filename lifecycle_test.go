@@ -0,0 +1,113 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/calyptia/plugin/input"
+	"github.com/calyptia/plugin/output"
+)
+
+type testLifecycle struct {
+	mu       sync.Mutex
+	events   []string
+	startErr error
+}
+
+func (plug *testLifecycle) record(event string) {
+	plug.mu.Lock()
+	defer plug.mu.Unlock()
+	plug.events = append(plug.events, event)
+}
+
+func (plug *testLifecycle) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (plug *testLifecycle) Collect(ctx context.Context, ch chan<- Message) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (plug *testLifecycle) Flush(ctx context.Context, ch <-chan Message) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (plug *testLifecycle) OnStart(ctx context.Context) error {
+	plug.record("start")
+	return plug.startErr
+}
+
+func (plug *testLifecycle) OnStop(ctx context.Context) error {
+	plug.record("stop")
+	return nil
+}
+
+func (plug *testLifecycle) OnPause(ctx context.Context) error {
+	plug.record("pause")
+	return nil
+}
+
+func (plug *testLifecycle) OnResume(ctx context.Context) error {
+	plug.record("resume")
+	return nil
+}
+
+func TestInputLifecycle(t *testing.T) {
+	defer resetRegistry()
+
+	plug := &testLifecycle{}
+	r := prepareInput(plug)
+
+	assert.Equal(t, input.FLB_OK, FLBPluginInputPreRun(0))
+	FLBPluginInputPause()
+	FLBPluginInputResume()
+	r.cleanup()
+
+	assert.Equal(t, []string{"start", "pause", "resume", "stop"}, plug.events)
+}
+
+func TestOutputLifecycle(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	plug := &testLifecycle{startErr: errors.New("connection refused")}
+	RegisterOutput("test-output", "", plug)
+	r := registrationOf(outputKind)
+	r.newOutputInstance()
+
+	assert.Equal(t, output.FLB_ERROR, FLBPluginOutputPreRun(0))
+
+	plug.startErr = nil
+	assert.Equal(t, output.FLB_OK, FLBPluginOutputPreRun(0))
+	r.cleanup()
+
+	assert.Equal(t, []string{"start", "start", "stop"}, plug.events)
+}
+
+func TestOutputPause(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	plug := &testLifecycle{}
+	RegisterOutput("test-output", "", plug)
+	r := registrationOf(outputKind)
+	inst := r.newOutputInstance()
+	assert.Equal(t, output.FLB_OK, FLBPluginOutputPreRun(0))
+
+	FLBPluginOutputPause()
+	// pausing twice notifies the plugin once.
+	inst.pause()
+	assert.True(t, inst.paused.Load())
+	FLBPluginOutputResume()
+	inst.resume()
+	assert.False(t, inst.paused.Load())
+	r.cleanup()
+
+	assert.Equal(t, []string{"start", "pause", "resume", "stop"}, plug.events)
+}
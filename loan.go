@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// releasedKey is the field of the records of the messages done in debug mode, so plugins
+// using them past Done see it.
+const releasedKey = "go.released"
+
+// WithMessageLoans makes the output loan the messages it flushes to the plugin, which calls
+// Message.Done once done with each message: its record is then reused to decode the next
+// ones, cutting the allocations and the GC work of the flushes. The record must not be used
+// once done, the values read from it can be kept. Instances configured with the go.DebugLoans
+// key report the misuses: Done invoked twice panics, and the records of the messages done
+// are poisoned with a go.released field instead of being reused. It panics when used to
+// register other kinds of plugins.
+func WithMessageLoans() RegisterOption {
+	return func(r *registration) {
+		if r.kind != outputKind {
+			panic(fmt.Sprintf("message loans set on %s plugin: %q", r.kind, r.name))
+		}
+
+		r.messageLoans = true
+	}
+}
+
+// messageLoan is the loan of the record of a message.
+type messageLoan struct {
+	record   map[string]any
+	debug    bool
+	returned atomic.Bool
+}
+
+// loaning reports whether the instance loans its messages.
+func (o *outputInstance) loaning() bool {
+	return o.reg != nil && o.reg.messageLoans
+}
+
+// loan loans the message, when the instance loans its messages.
+func (o *outputInstance) loan(msg *Message) {
+	if !o.loaning() {
+		return
+	}
+
+	record, _ := msg.Record.(map[string]any)
+	msg.loan = &messageLoan{record: record, debug: o.debugLoans}
+}
+
+// done gives the record back to the pool, or poisons it in debug mode.
+func (l *messageLoan) done() {
+	if !l.returned.CompareAndSwap(false, true) {
+		if l.debug {
+			panic("message loan: Done invoked twice")
+		}
+		return
+	}
+
+	if l.record == nil {
+		return
+	}
+
+	clear(l.record)
+	if l.debug {
+		l.record[releasedKey] = true
+		return
+	}
+
+	if pooling {
+		recordPool.Put(l.record)
+	}
+}
+
+// debugLoansConfig reads the go.DebugLoans config key.
+func debugLoansConfig(conf ConfigLoader) bool {
+	enabled, err := BoolFromConf(conf, "go.DebugLoans", false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, loans debug disabled\n", err)
+	}
+
+	return enabled
+}
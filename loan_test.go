@@ -0,0 +1,151 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestWithMessageLoans(t *testing.T) {
+	r := &registration{kind: outputKind, name: "dummy"}
+	WithMessageLoans()(r)
+	assert.True(t, r.messageLoans)
+
+	assert.Panics(t, func() {
+		WithMessageLoans()(&registration{kind: filterKind, name: "dummy"})
+	})
+}
+
+func TestDebugLoansConfig(t *testing.T) {
+	assert.False(t, debugLoansConfig(testConfigLoader{}))
+	assert.True(t, debugLoansConfig(testConfigLoader{"go.DebugLoans": "on"}))
+}
+
+// testLoanOutput hands the messages it is flushed to the test.
+type testLoanOutput struct {
+	ch chan Message
+}
+
+func (plug *testLoanOutput) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (plug *testLoanOutput) Flush(ctx context.Context, ch <-chan Message) error {
+	for {
+		select {
+		case msg := <-ch:
+			plug.ch <- msg
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// prepareLoanOutput runs an output loaning its messages.
+func prepareLoanOutput(t *testing.T, debug bool) (*outputInstance, chan Message) {
+	t.Helper()
+
+	out := &testLoanOutput{ch: make(chan Message, 10)}
+	resetRegistry()
+	t.Cleanup(func() { resetRegistry() })
+	RegisterOutput("test-loan-output", "", out, WithMessageLoans())
+	o := registrationOf(outputKind).newOutputInstance()
+	o.debugLoans = debug
+	FLBPluginOutputPreRun(0)
+
+	return o, out.ch
+}
+
+func TestMessageLoans(t *testing.T) {
+	o, ch := prepareLoanOutput(t, false)
+
+	b, err := encodeMessages(defaultCodec, nil, []Message{{Time: time.Now(), Record: map[string]any{"log": "first"}}})
+	assert.NoError(t, err)
+	assert.NoError(t, o.pluginFlush(0, "tag", b))
+
+	msg := <-ch
+	record := msg.Record.(map[string]any)
+	assert.Equal(t, map[string]any{"log": "first"}, record)
+
+	// the record is given back once done, the message can be done again.
+	msg.Done()
+	assert.Zero(t, len(record))
+	msg.Done()
+
+	// messages not loaned can be done as well.
+	Message{Record: map[string]any{"log": "kept"}}.Done()
+}
+
+func TestMessageLoansDebug(t *testing.T) {
+	o, ch := prepareLoanOutput(t, true)
+
+	b, err := encodeMessages(defaultCodec, nil, []Message{{Time: time.Now(), Record: map[string]any{"log": "first"}}})
+	assert.NoError(t, err)
+	assert.NoError(t, o.pluginFlush(0, "tag", b))
+
+	msg := <-ch
+	msg.Done()
+
+	// the records used past Done are poisoned, and Done panics when invoked twice.
+	assert.Equal(t, map[string]any{releasedKey: true}, msg.Record.(map[string]any))
+	assert.Panics(t, msg.Done)
+}
+
+func TestChunkMessageLoans(t *testing.T) {
+	o := &outputInstance{reg: &registration{kind: outputKind, name: "dummy", messageLoans: true}}
+
+	b, err := encodeMessages(defaultCodec, nil, []Message{
+		{Time: time.Now(), Record: map[string]any{"idx": 0}},
+		{Time: time.Now(), Record: map[string]any{"idx": 1}},
+	})
+	assert.NoError(t, err)
+
+	chunk, err := o.decodeChunk("tag", b)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(chunk.Messages))
+
+	for _, msg := range chunk.Messages {
+		assert.NotZero(t, msg.loan)
+		msg.Done()
+		assert.Zero(t, len(msg.Record.(map[string]any)))
+	}
+}
+
+// BenchmarkFlushLoans flushes chunks to outputs loaning their messages or not, the loaned
+// messages being done once received.
+func BenchmarkFlushLoans(b *testing.B) {
+	const records = 1000
+
+	chunk, err := encodeMessages(MsgpackCodec{}, nil, benchMessages(benchShapes[1], records))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, loans := range []bool{false, true} {
+		name := "copied"
+		if loans {
+			name = "loaned"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			defer resetRegistry()
+
+			out := &benchOutput{records: records, done: make(chan struct{})}
+			o := prepareOutputFlush(out)
+			o.reg.messageLoans = loans
+
+			b.ReportAllocs()
+			b.SetBytes(int64(len(chunk)))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if err := o.pluginFlush(0, "tag", chunk); err != nil {
+					b.Fatal(err)
+				}
+				<-out.done
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/calyptia/plugin/flbconf"
+)
+
+// LogLevel is the level of the messages, the ones of fluent-bit.
+type LogLevel int
+
+const (
+	LogError LogLevel = iota + 1
+	LogWarn
+	LogInfo
+	LogDebug
+	// LogTrace is only enabled by the go.Trace config key, its messages being logged at
+	// the debug level unless the instance runs at the trace level.
+	LogTrace
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogError:
+		return "error"
+	case LogWarn:
+		return "warn"
+	case LogInfo:
+		return "info"
+	case LogDebug:
+		return "debug"
+	case LogTrace:
+		return "trace"
+	}
+	return "off"
+}
+
+// tracer is implemented by the loggers of the fluent-bit instances, logging at the trace
+// level.
+type tracer interface {
+	Trace(format string, a ...any)
+}
+
+// leveledLogger is implemented by the loggers of the fluent-bit instances, logLevel
+// returning the most verbose level enabled by the log_level of the instance.
+type leveledLogger interface {
+	logLevel() LogLevel
+}
+
+// logLevelOf returns the most verbose level check reports enabled, zero when none is.
+func logLevelOf(check func(level int) bool) LogLevel {
+	for l := LogTrace; l >= LogError; l-- {
+		if check(int(l)) {
+			return l
+		}
+	}
+
+	return 0
+}
+
+// levelOf returns the log level of the logger, all the levels but trace being enabled for
+// the loggers not telling theirs.
+func levelOf(logger Logger) LogLevel {
+	if l, ok := logger.(leveledLogger); ok {
+		return l.logLevel()
+	}
+
+	return LogDebug
+}
+
+// logf reports a diagnostic of the SDK about the running instance through its logger,
+// prefixing it with the plugin name and alias, or to stderr with the instance label
+// until the instance has a logger.
+func (s *runState) logf(level LogLevel, format string, a ...any) {
+	logger := s.logger
+	if logger == nil {
+		if label := s.instance.Label(); label != "" {
+			format = "[" + label + "] " + format
+		}
+		fmt.Fprintf(os.Stderr, format+"\n", a...)
+		return
+	}
+
+	switch level {
+	case LogError:
+		logger.Error(format, a...)
+	case LogWarn:
+		logger.Warn(format, a...)
+	case LogInfo:
+		logger.Info(format, a...)
+	default:
+		logger.Debug(format, a...)
+	}
+}
+
+// traceConfig reads the go.Trace config key, enabling the trace messages.
+func traceConfig(conf ConfigLoader) bool {
+	s := conf.String("go.Trace")
+	if s == "" {
+		return false
+	}
+
+	v, err := flbconf.ParseBool(s)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid go.Trace %q, using false\n", s)
+		return false
+	}
+
+	return v
+}
+
+// instanceLogger prefixes the messages of a plugin instance with the plugin name and
+// the instance alias, or name, so the messages of several instances can be told apart.
+// Fields added with With, or LoggerWith, are appended to the messages. The messages
+// above the log level of the instance are dropped before being formatted, the level
+// being read once, not to cross cgo for every message.
+type instanceLogger struct {
+	logger Logger
+	prefix string
+	fields string
+	level  LogLevel
+	// tracing enables the trace messages.
+	tracing bool
+}
+
+func newInstanceLogger(logger Logger, name string, info InstanceInfo) *instanceLogger {
+	prefix := name
+	if label := info.Label(); label != "" && label != name {
+		prefix += ":" + label
+	}
+
+	return &instanceLogger{logger: logger, prefix: "[" + prefix + "] ", level: levelOf(logger)}
+}
+
+// withTrace enables the trace messages of the logger, or not.
+func (l *instanceLogger) withTrace(enabled bool) *instanceLogger {
+	l.tracing = enabled
+	return l
+}
+
+// LoggerWith returns a logger appending the given key/value pairs to the messages,
+// formatted as key=value. Values are quoted when holding spaces or quotes.
+//
+//	log := plugin.LoggerWith(fbit.Logger, "endpoint", plug.endpoint)
+//	log.Info("connected") // [my-output:my_alias] connected endpoint=http://localhost
+func LoggerWith(logger Logger, keyvals ...any) Logger {
+	return Fields(logger).With(keyvals...)
+}
+
+// Fields returns the logger as a FieldLogger, the loggers given to the plugins being
+// returned as is, others being wrapped to render the fields the same way.
+//
+//	log := plugin.Fields(fbit.Logger).With("endpoint", plug.endpoint)
+//	log.Infow("flushed", "records", n) // [my-output:my_alias] flushed endpoint=http://localhost records=3
+func Fields(logger Logger) FieldLogger {
+	if l, ok := logger.(FieldLogger); ok {
+		return l
+	}
+
+	return &instanceLogger{logger: logger, level: levelOf(logger)}
+}
+
+// appendFields appends the key/value pairs to b, formatted as key=value.
+func appendFields(b []byte, keyvals []any) []byte {
+	for i := 0; i < len(keyvals); i += 2 {
+		var v any = "(missing)"
+		if i+1 < len(keyvals) {
+			v = keyvals[i+1]
+		}
+
+		b = fmt.Appendf(b, " %v=", keyvals[i])
+		s := fmt.Sprint(v)
+		if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+			b = strconv.AppendQuote(b, s)
+		} else {
+			b = append(b, s...)
+		}
+	}
+
+	return b
+}
+
+func (l *instanceLogger) With(keyvals ...any) FieldLogger {
+	return &instanceLogger{
+		logger:  l.logger,
+		prefix:  l.prefix,
+		fields:  l.fields + string(appendFields(nil, keyvals)),
+		level:   l.level,
+		tracing: l.tracing,
+	}
+}
+
+func (l *instanceLogger) Enabled(level LogLevel) bool {
+	if level == LogTrace {
+		return l.tracing && l.level >= LogDebug
+	}
+
+	return level <= l.level
+}
+
+// format formats the log line followed by the fields, recording it for the crash
+// reports. The line is built in a pooled buffer, so its string is its only allocation
+// besides the arguments.
+func (l *instanceLogger) format(level, format string, a, keyvals []any) string {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	buf.WriteString(l.prefix)
+	fmt.Fprintf(buf, format, a...)
+	buf.WriteString(l.fields)
+	buf.Write(appendFields(buf.AvailableBuffer(), keyvals))
+
+	line := buf.String()
+	recordLog(level, line)
+	return line
+}
+
+func (l *instanceLogger) Error(format string, a ...any) {
+	if !l.Enabled(LogError) {
+		return
+	}
+	l.logger.Error("%s", l.format("error", format, a, nil))
+}
+
+func (l *instanceLogger) Warn(format string, a ...any) {
+	if !l.Enabled(LogWarn) {
+		return
+	}
+	l.logger.Warn("%s", l.format("warn", format, a, nil))
+}
+
+func (l *instanceLogger) Info(format string, a ...any) {
+	if !l.Enabled(LogInfo) {
+		return
+	}
+	l.logger.Info("%s", l.format("info", format, a, nil))
+}
+
+func (l *instanceLogger) Debug(format string, a ...any) {
+	if !l.Enabled(LogDebug) {
+		return
+	}
+	l.logger.Debug("%s", l.format("debug", format, a, nil))
+}
+
+func (l *instanceLogger) Errorw(msg string, keyvals ...any) {
+	if !l.Enabled(LogError) {
+		return
+	}
+	l.logger.Error("%s", l.format("error", "%s", []any{msg}, keyvals))
+}
+
+func (l *instanceLogger) Warnw(msg string, keyvals ...any) {
+	if !l.Enabled(LogWarn) {
+		return
+	}
+	l.logger.Warn("%s", l.format("warn", "%s", []any{msg}, keyvals))
+}
+
+func (l *instanceLogger) Infow(msg string, keyvals ...any) {
+	if !l.Enabled(LogInfo) {
+		return
+	}
+	l.logger.Info("%s", l.format("info", "%s", []any{msg}, keyvals))
+}
+
+func (l *instanceLogger) Debugw(msg string, keyvals ...any) {
+	if !l.Enabled(LogDebug) {
+		return
+	}
+	l.logger.Debug("%s", l.format("debug", "%s", []any{msg}, keyvals))
+}
+
+func (l *instanceLogger) Trace(format string, a ...any) {
+	if !l.Enabled(LogTrace) {
+		return
+	}
+	l.trace(l.format("trace", format, a, nil))
+}
+
+func (l *instanceLogger) Tracew(msg string, keyvals ...any) {
+	if !l.Enabled(LogTrace) {
+		return
+	}
+	l.trace(l.format("trace", "%s", []any{msg}, keyvals))
+}
+
+// trace logs the line at the trace level when the instance runs at it, at the debug
+// level otherwise.
+func (l *instanceLogger) trace(line string) {
+	if t, ok := l.logger.(tracer); ok && l.level >= LogTrace {
+		t.Trace("%s", line)
+		return
+	}
+
+	l.logger.Debug("%s", line)
+}
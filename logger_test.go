@@ -0,0 +1,115 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestInstanceLogger(t *testing.T) {
+	logger := &testLogger{}
+
+	newInstanceLogger(logger, "dummy", InstanceInfo{Name: "dummy.0"}).Warn("flush failed: %s", "timeout")
+	newInstanceLogger(logger, "dummy", InstanceInfo{Name: "dummy.1", Alias: "backup"}).Warn("flush failed")
+	newInstanceLogger(logger, "dummy", InstanceInfo{Name: "dummy"}).Warn("100%% done")
+
+	assert.Equal(t, []string{
+		"[dummy:dummy.0] flush failed: timeout",
+		"[dummy:backup] flush failed",
+		"[dummy] 100% done",
+	}, logger.warnings)
+}
+
+func TestRunStateLogf(t *testing.T) {
+	logger := &testLogger{}
+
+	// the diagnostics of the SDK tell the instances and workers apart.
+	s := &runState{logger: newInstanceLogger(logger, "dummy", InstanceInfo{Name: "dummy.1", Alias: "backup"})}
+	s.logf(LogWarn, "flush: %s worker=%d", "timeout", 2)
+	s.logf(LogInfo, "dropped")
+
+	assert.Equal(t, []string{"[dummy:backup] flush: timeout worker=2"}, logger.warnings)
+}
+
+func TestLoggerWith(t *testing.T) {
+	logger := &testLogger{}
+
+	log := LoggerWith(newInstanceLogger(logger, "dummy", InstanceInfo{Name: "dummy.0"}), "endpoint", "http://localhost")
+	log.Warn("connected")
+	LoggerWith(log, "reason", "connection refused", "retries").Warn("disconnected")
+	LoggerWith(logger, "empty", "").Warn("plain")
+
+	assert.Equal(t, []string{
+		"[dummy:dummy.0] connected endpoint=http://localhost",
+		`[dummy:dummy.0] disconnected endpoint=http://localhost reason="connection refused" retries=(missing)`,
+		`plain empty=""`,
+	}, logger.warnings)
+}
+
+func TestFieldLogger(t *testing.T) {
+	logger := &testLogger{}
+
+	log := Fields(newInstanceLogger(logger, "dummy", InstanceInfo{Name: "dummy.0"})).With("endpoint", "http://localhost")
+	log.Warnw("flush failed", "records", 3, "err", "connection refused")
+	log.With("worker", 1).Warnw("100% done")
+	log.Warn("retrying in %s", "1s")
+
+	// other loggers are wrapped, rendering the fields the same way.
+	Fields(logger).Warnw("plain", "key")
+
+	assert.Equal(t, []string{
+		`[dummy:dummy.0] flush failed endpoint=http://localhost records=3 err="connection refused"`,
+		"[dummy:dummy.0] 100% done endpoint=http://localhost worker=1",
+		"[dummy:dummy.0] retrying in 1s endpoint=http://localhost",
+		"plain key=(missing)",
+	}, logger.warnings)
+}
+
+// testLeveledLogger is a test logger with the log level of a fluent-bit instance.
+type testLeveledLogger struct {
+	testLogger
+	level LogLevel
+}
+
+func (l *testLeveledLogger) logLevel() LogLevel {
+	return l.level
+}
+
+// testStringer counts the times it is formatted.
+type testStringer struct {
+	n *int
+}
+
+func (s testStringer) String() string {
+	*s.n++
+	return "formatted"
+}
+
+func TestLoggerLevel(t *testing.T) {
+	logger := &testLeveledLogger{level: LogError}
+	log := Fields(newInstanceLogger(logger, "dummy", InstanceInfo{Name: "dummy"}))
+	assert.True(t, log.Enabled(LogError))
+	assert.False(t, log.Enabled(LogWarn))
+
+	// the messages above the level are not formatted.
+	var formatted int
+	log.Warn("%s", testStringer{&formatted})
+	log.With("key", "value").Warnw("dropped", "value", testStringer{&formatted})
+	assert.Equal(t, 0, formatted)
+	assert.Equal(t, 0, len(logger.warnings))
+
+	logger.level = LogWarn
+	log = Fields(newInstanceLogger(logger, "dummy", InstanceInfo{Name: "dummy"}))
+	log.Warn("%s", testStringer{&formatted})
+	assert.Equal(t, []string{"[dummy] formatted"}, logger.warnings)
+
+	// the loggers not telling their level log every message.
+	assert.True(t, Fields(&testLogger{}).Enabled(LogDebug))
+}
+
+func TestLogLevelOf(t *testing.T) {
+	for _, level := range []LogLevel{0, LogError, LogWarn, LogInfo, LogDebug, LogTrace} {
+		got := logLevelOf(func(l int) bool { return LogLevel(l) <= level })
+		assert.Equal(t, level, got, level.String())
+	}
+}
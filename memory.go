@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/calyptia/plugin/flbconf"
+)
+
+// ballast is the heap ballast of the process, grown to the largest go.HeapBallast of the
+// instances. It is never written, so its pages are never resident.
+var ballast struct {
+	mu sync.Mutex
+	b  []byte
+}
+
+// gcTuning is the GC tuning of the go.GOGC, go.MemoryLimit and go.HeapBallast config keys,
+// the limit and the ballast being zero when unset.
+type gcTuning struct {
+	// percent is the GOGC, negative to turn the GC off, when setPercent.
+	percent    int
+	setPercent bool
+	limit      int64
+	ballast    int64
+}
+
+// gcTuningConfig reads the go.GOGC, go.MemoryLimit and go.HeapBallast config keys. GOGC is
+// a percent or off.
+func gcTuningConfig(conf ConfigLoader) gcTuning {
+	var t gcTuning
+
+	if s := conf.String("go.GOGC"); s != "" {
+		if strings.EqualFold(s, "off") {
+			t.percent, t.setPercent = -1, true
+		} else if n, err := strconv.Atoi(s); err != nil || n < 0 {
+			fmt.Fprintf(os.Stderr, "invalid go.GOGC %q, ignored\n", s)
+		} else {
+			t.percent, t.setPercent = n, true
+		}
+	}
+
+	if s := conf.String("go.MemoryLimit"); s != "" {
+		if n, err := flbconf.ParseSize(s); err != nil || n <= 0 {
+			fmt.Fprintf(os.Stderr, "invalid go.MemoryLimit %q, ignored\n", s)
+		} else {
+			t.limit = n
+		}
+	}
+
+	if s := conf.String("go.HeapBallast"); s != "" {
+		if n, err := flbconf.ParseSize(s); err != nil || n <= 0 {
+			fmt.Fprintf(os.Stderr, "invalid go.HeapBallast %q, ignored\n", s)
+		} else {
+			t.ballast = n
+		}
+	}
+
+	return t
+}
+
+// applyGCTuning applies the GC tuning of the config keys to the Go runtime. Like
+// GOMAXPROCS, the tuning is shared by all the plugins of the process: the last instance
+// setting GOGC or the memory limit wins, and the ballast is the largest one.
+func applyGCTuning(logger Logger, conf ConfigLoader) {
+	t := gcTuningConfig(conf)
+
+	if t.setPercent {
+		if prev := debug.SetGCPercent(t.percent); prev != t.percent && logger != nil {
+			logger.Info("GOGC set to %d, was %d", t.percent, prev)
+		}
+	}
+
+	if t.limit > 0 {
+		if prev := debug.SetMemoryLimit(t.limit); prev != t.limit && logger != nil {
+			logger.Info("Go memory limit set to %d bytes, was %d", t.limit, prev)
+		}
+	}
+
+	if t.ballast > 0 {
+		ballast.mu.Lock()
+		if int64(len(ballast.b)) < t.ballast {
+			ballast.b = make([]byte, t.ballast)
+		}
+		ballast.mu.Unlock()
+	}
+}
@@ -0,0 +1,46 @@
+package plugin
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestGCTuningConfig(t *testing.T) {
+	assert.Equal(t, gcTuning{}, gcTuningConfig(testConfigLoader{}))
+	assert.Equal(t, gcTuning{percent: 50, setPercent: true, limit: 512 << 20, ballast: 64 << 20}, gcTuningConfig(testConfigLoader{
+		"go.GOGC":        "50",
+		"go.MemoryLimit": "512M",
+		"go.HeapBallast": "64M",
+	}))
+	assert.Equal(t, gcTuning{percent: -1, setPercent: true}, gcTuningConfig(testConfigLoader{"go.GOGC": "off"}))
+	assert.Equal(t, gcTuning{}, gcTuningConfig(testConfigLoader{
+		"go.GOGC":        "-5",
+		"go.MemoryLimit": "lots",
+		"go.HeapBallast": "0",
+	}))
+}
+
+func TestApplyGCTuning(t *testing.T) {
+	percent := debug.SetGCPercent(100)
+	limit := debug.SetMemoryLimit(-1)
+	t.Cleanup(func() {
+		debug.SetGCPercent(percent)
+		debug.SetMemoryLimit(limit)
+
+		ballast.mu.Lock()
+		ballast.b = nil
+		ballast.mu.Unlock()
+	})
+
+	applyGCTuning(nil, testConfigLoader{"go.GOGC": "200", "go.MemoryLimit": "1G", "go.HeapBallast": "1M"})
+	assert.Equal(t, 200, debug.SetGCPercent(200))
+	assert.Equal(t, int64(1<<30), debug.SetMemoryLimit(-1))
+	assert.Equal(t, 1<<20, len(ballast.b))
+
+	// the ballast is the largest one.
+	applyGCTuning(nil, testConfigLoader{"go.HeapBallast": "512k"})
+	assert.Equal(t, 1<<20, len(ballast.b))
+	assert.Equal(t, 200, debug.SetGCPercent(200))
+}
@@ -0,0 +1,75 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// semverRegexp matches semantic versions, with an optional v prefix.
+var semverRegexp = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+	`(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+	`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+// Metadata describes a plugin build, it is exported to fluent-bit by the
+// FLBPluginMetadata callback so operators can inventory the loaded Go plugins.
+type Metadata struct {
+	Version string `json:"version,omitempty"`
+	License string `json:"license,omitempty"`
+	Author  string `json:"author,omitempty"`
+}
+
+// RegisterOption configures the registration of a plugin.
+type RegisterOption func(*registration)
+
+// WithVersion sets the semantic version of the plugin, it panics if the version is invalid.
+func WithVersion(version string) RegisterOption {
+	if !semverRegexp.MatchString(version) {
+		panic(fmt.Sprintf("invalid plugin version %q", version))
+	}
+
+	return func(r *registration) {
+		r.meta.Version = version
+	}
+}
+
+// WithLicense sets the license of the plugin, e.g. Apache-2.0.
+func WithLicense(license string) RegisterOption {
+	return func(r *registration) {
+		r.meta.License = license
+	}
+}
+
+// WithAuthor sets the author of the plugin.
+func WithAuthor(author string) RegisterOption {
+	return func(r *registration) {
+		r.meta.Author = author
+	}
+}
+
+// pluginInfo is the description of a registered plugin exported to fluent-bit.
+type pluginInfo struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Metadata
+	Config []ConfigOption `json:"config,omitempty"`
+}
+
+// registryMetadata returns the JSON description of all the registered plugins.
+func registryMetadata() ([]byte, error) {
+	regs := registrations()
+
+	infos := make([]pluginInfo, 0, len(regs))
+	for _, r := range regs {
+		infos = append(infos, pluginInfo{
+			Name:        r.name,
+			Type:        r.kind.String(),
+			Description: r.desc,
+			Metadata:    r.meta,
+			Config:      r.configMap,
+		})
+	}
+
+	return json.Marshal(infos)
+}
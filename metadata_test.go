@@ -0,0 +1,33 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestRegistryMetadata(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	RegisterInput("dummy-input", "dummy input", testPluginInputCallbackCtrlC{},
+		WithVersion("v1.2.3-rc.1"), WithLicense("Apache-2.0"), WithAuthor("Calyptia"))
+	RegisterOutput("dummy-output", "dummy output", &testOutputCounter{})
+
+	b, err := registryMetadata()
+	assert.NoError(t, err)
+	assert.Equal(t, `[`+
+		`{"name":"dummy-input","type":"input","description":"dummy input","version":"v1.2.3-rc.1","license":"Apache-2.0","author":"Calyptia"},`+
+		`{"name":"dummy-output","type":"output","description":"dummy output"}`+
+		`]`, string(b))
+}
+
+func TestWithVersion(t *testing.T) {
+	for _, v := range []string{"1.0.0", "v0.1.7", "2.0.0+build.5"} {
+		assert.NotPanics(t, func() { WithVersion(v) })
+	}
+
+	for _, v := range []string{"", "1.0", "latest", "01.0.0"} {
+		assert.Panics(t, func() { WithVersion(v) })
+	}
+}
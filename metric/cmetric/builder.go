@@ -5,6 +5,7 @@ import (
 
 	cmetrics "github.com/calyptia/cmetrics-go"
 	"github.com/calyptia/plugin/metric"
+	"github.com/calyptia/plugin/metric/cmt"
 )
 
 type Builder struct {
@@ -14,8 +15,8 @@ type Builder struct {
 	OnError   func(err error)
 }
 
-func (b *Builder) NewCounter(name, desc string, labelValues ...string) metric.Counter {
-	base, err := b.Context.CounterCreate(b.Namespace, b.SubSystem, name, desc, labelValues)
+func (b *Builder) NewCounter(name, desc string, labelKeys ...string) metric.Counter {
+	base, err := b.Context.CounterCreate(b.Namespace, b.SubSystem, name, desc, labelKeys)
 	if err != nil {
 		if b.OnError != nil {
 			b.OnError(fmt.Errorf("new counter: %w", err))
@@ -26,11 +27,12 @@ func (b *Builder) NewCounter(name, desc string, labelValues ...string) metric.Co
 	return &Counter{
 		Base:    base,
 		OnError: b.OnError,
+		series:  newSeries(b, name, desc, labelKeys),
 	}
 }
 
-func (b *Builder) NewGauge(name, desc string, labelValues ...string) metric.Gauge {
-	base, err := b.Context.GaugeCreate(b.Namespace, b.SubSystem, name, desc, labelValues)
+func (b *Builder) NewGauge(name, desc string, labelKeys ...string) metric.Gauge {
+	base, err := b.Context.GaugeCreate(b.Namespace, b.SubSystem, name, desc, labelKeys)
 	if err != nil {
 		if b.OnError != nil {
 			b.OnError(fmt.Errorf("new gauge: %w", err))
@@ -41,5 +43,26 @@ func (b *Builder) NewGauge(name, desc string, labelValues ...string) metric.Gaug
 	return &Gauge{
 		Base:    base,
 		OnError: b.OnError,
+		series:  newSeries(b, name, desc, labelKeys),
 	}
 }
+
+// Snapshot returns the metric families of the context, decoded from its msgpack encoding.
+func (b *Builder) Snapshot() ([]cmt.Metric, error) {
+	buf, err := b.Context.EncodeMsgPack()
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+
+	contexts, err := cmt.Decode(buf)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+
+	var out []cmt.Metric
+	for _, c := range contexts {
+		out = append(out, c.Metrics...)
+	}
+
+	return out, nil
+}
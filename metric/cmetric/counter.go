@@ -5,20 +5,94 @@ import (
 	"time"
 
 	cmetrics "github.com/calyptia/cmetrics-go"
+	"github.com/calyptia/plugin/metric"
+)
+
+var (
+	_ metric.Counter       = (*Counter)(nil)
+	_ metric.SeriesRemover = (*Counter)(nil)
+	_ metric.Counter       = noopCounter{}
 )
 
 type Counter struct {
 	Base    *cmetrics.Counter
 	OnError func(err error)
+	// series tracks the label values of the counters created by a Builder, nil for the
+	// others, whose series cannot be removed.
+	series *series
 }
 
 func (c *Counter) Add(delta float64, labelValues ...string) {
+	if c.series != nil {
+		c.series.mu.Lock()
+		defer c.series.mu.Unlock()
+	}
+
 	err := c.Base.Add(time.Now(), delta, labelValues)
+	if err != nil {
+		if c.OnError != nil {
+			c.OnError(fmt.Errorf("counter add: %w", err))
+		}
+		return
+	}
+
+	if c.series != nil {
+		c.series.track(labelValues)
+	}
+}
+
+// Remove removes the series of the given label values, the counter being recreated with
+// the other series.
+func (c *Counter) Remove(labelValues ...string) {
+	c.rebuild("counter remove", labelValues, false)
+}
+
+// Reset removes all the series, the counter being recreated empty.
+func (c *Counter) Reset() {
+	c.rebuild("counter reset", nil, true)
+}
+
+func (c *Counter) rebuild(op string, labelValues []string, reset bool) {
+	err := c.recreate(labelValues, reset)
 	if err != nil && c.OnError != nil {
-		c.OnError(fmt.Errorf("counter add: %w", err))
+		c.OnError(fmt.Errorf("%s: %w", op, err))
 	}
 }
 
+// recreate replaces the counter in its context by a new one holding the series kept,
+// leaving it as is on failure.
+func (c *Counter) recreate(labelValues []string, reset bool) error {
+	s := c.series
+	if s == nil {
+		return errNoSeries
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept, ok := s.without(labelValues, reset)
+	if !ok {
+		return nil
+	}
+
+	base, err := s.ctx.CounterCreate(s.namespace, s.subsystem, s.name, s.desc, s.keys)
+	if err != nil {
+		return err
+	}
+
+	if err := replay(c.Base, base, kept); err != nil {
+		destroyCounter(base)
+		return err
+	}
+
+	destroyCounter(c.Base)
+	c.Base, s.values = base, kept
+
+	return nil
+}
+
 type noopCounter struct{}
 
 func (n noopCounter) Add(float64, ...string) {}
+func (n noopCounter) Remove(...string)       {}
+func (n noopCounter) Reset()                 {}
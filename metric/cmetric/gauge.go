@@ -5,28 +5,116 @@ import (
 	"time"
 
 	cmetrics "github.com/calyptia/cmetrics-go"
+	"github.com/calyptia/plugin/metric"
+)
+
+var (
+	_ metric.Gauge           = (*Gauge)(nil)
+	_ metric.GaugeSubtracter = (*Gauge)(nil)
+	_ metric.SeriesRemover   = (*Gauge)(nil)
+	_ metric.Gauge           = noopGauge{}
 )
 
 type Gauge struct {
 	Base    *cmetrics.Gauge
 	OnError func(err error)
+	// series tracks the label values of the gauges created by a Builder, nil for the
+	// others, whose series cannot be removed.
+	series *series
 }
 
 func (c *Gauge) Add(delta float64, labelValues ...string) {
-	err := c.Base.Add(time.Now(), delta, labelValues)
-	if err != nil && c.OnError != nil {
-		c.OnError(fmt.Errorf("gauge add: %w", err))
-	}
+	c.update("gauge add", labelValues, func(ts time.Time) error {
+		return c.Base.Add(ts, delta, labelValues)
+	})
+}
+
+func (c *Gauge) Sub(delta float64, labelValues ...string) {
+	c.update("gauge sub", labelValues, func(ts time.Time) error {
+		return c.Base.Sub(ts, delta, labelValues)
+	})
 }
 
 func (c *Gauge) Set(value float64, labelValues ...string) {
-	err := c.Base.Set(time.Now(), value, labelValues)
+	c.update("gauge set", labelValues, func(ts time.Time) error {
+		return c.Base.Set(ts, value, labelValues)
+	})
+}
+
+// update applies fn to the base of the gauge, tracking the series of the label values.
+func (c *Gauge) update(op string, labelValues []string, fn func(ts time.Time) error) {
+	if c.series != nil {
+		c.series.mu.Lock()
+		defer c.series.mu.Unlock()
+	}
+
+	err := fn(time.Now())
+	if err != nil {
+		if c.OnError != nil {
+			c.OnError(fmt.Errorf("%s: %w", op, err))
+		}
+		return
+	}
+
+	if c.series != nil {
+		c.series.track(labelValues)
+	}
+}
+
+// Remove removes the series of the given label values, the gauge being recreated with
+// the other series.
+func (c *Gauge) Remove(labelValues ...string) {
+	c.rebuild("gauge remove", labelValues, false)
+}
+
+// Reset removes all the series, the gauge being recreated empty.
+func (c *Gauge) Reset() {
+	c.rebuild("gauge reset", nil, true)
+}
+
+func (c *Gauge) rebuild(op string, labelValues []string, reset bool) {
+	err := c.recreate(labelValues, reset)
 	if err != nil && c.OnError != nil {
-		c.OnError(fmt.Errorf("gauge set: %w", err))
+		c.OnError(fmt.Errorf("%s: %w", op, err))
+	}
+}
+
+// recreate replaces the gauge in its context by a new one holding the series kept,
+// leaving it as is on failure.
+func (c *Gauge) recreate(labelValues []string, reset bool) error {
+	s := c.series
+	if s == nil {
+		return errNoSeries
 	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept, ok := s.without(labelValues, reset)
+	if !ok {
+		return nil
+	}
+
+	base, err := s.ctx.GaugeCreate(s.namespace, s.subsystem, s.name, s.desc, s.keys)
+	if err != nil {
+		return err
+	}
+
+	if err := replay(c.Base, base, kept); err != nil {
+		destroyGauge(base)
+		return err
+	}
+
+	destroyGauge(c.Base)
+	c.Base, s.values = base, kept
+
+	return nil
 }
 
 type noopGauge struct{}
 
 func (n noopGauge) Add(float64, ...string) {}
+func (n noopGauge) Sub(float64, ...string) {}
 func (n noopGauge) Set(float64, ...string) {}
+func (n noopGauge) Remove(...string)       {}
+func (n noopGauge) Reset()                 {}
@@ -0,0 +1,116 @@
+package cmetric
+
+/*
+#cgo CFLAGS: -I/usr/local/include/ -w
+
+#include <cmetrics/cmt_counter.h>
+#include <cmetrics/cmt_gauge.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	cmetrics "github.com/calyptia/cmetrics-go"
+)
+
+// errNoSeries is reported when removing the series of a metric not created by a Builder,
+// whose series are not tracked.
+var errNoSeries = errors.New("metric not created by a builder")
+
+// series tracks the label values of a metric created by a Builder. cmetrics only removing
+// whole metrics, Remove and Reset recreate the metric in the context with the values of
+// the series kept, then destroy the former one.
+type series struct {
+	ctx       *cmetrics.Context
+	namespace string
+	subsystem string
+	name      string
+	desc      string
+	keys      []string
+
+	// mu guards the values and the base of the metric, swapped when recreated.
+	mu     sync.Mutex
+	values map[string][]string
+}
+
+func newSeries(b *Builder, name, desc string, labelKeys []string) *series {
+	return &series{
+		ctx:       b.Context,
+		namespace: b.Namespace,
+		subsystem: b.SubSystem,
+		name:      name,
+		desc:      desc,
+		keys:      append([]string{}, labelKeys...),
+		values:    map[string][]string{},
+	}
+}
+
+// track records the label values of an update.
+func (s *series) track(labelValues []string) {
+	key := seriesKey(labelValues)
+	if _, ok := s.values[key]; !ok {
+		s.values[key] = append([]string{}, labelValues...)
+	}
+}
+
+// without returns the series tracked but the ones of the given label values, or none when
+// reset. It returns false when there is no series to remove.
+func (s *series) without(labelValues []string, reset bool) (map[string][]string, bool) {
+	if reset {
+		return map[string][]string{}, len(s.values) > 0
+	}
+
+	key := seriesKey(labelValues)
+	if _, ok := s.values[key]; !ok {
+		return nil, false
+	}
+
+	kept := maps.Clone(s.values)
+	delete(kept, key)
+
+	return kept, true
+}
+
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\x00")
+}
+
+// seriesBase is implemented by the counters and the gauges of cmetrics-go alike.
+type seriesBase interface {
+	GetVal(labels []string) (float64, error)
+	Set(ts time.Time, value float64, labels []string) error
+}
+
+// replay sets the values of the kept series of a metric on the metric recreated.
+func replay(from, to seriesBase, kept map[string][]string) error {
+	now := time.Now()
+	for _, labels := range kept {
+		v, err := from.GetVal(labels)
+		if err != nil {
+			return err
+		}
+
+		if err := to.Set(now, v, labels); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The counters and the gauges of cmetrics-go hold the pointer to their cmetrics struct as
+// their only field, they are destroyed through it, cmetrics-go lacking the API.
+
+func destroyCounter(c *cmetrics.Counter) {
+	C.cmt_counter_destroy(*(**C.struct_cmt_counter)(unsafe.Pointer(c)))
+}
+
+func destroyGauge(g *cmetrics.Gauge) {
+	C.cmt_gauge_destroy(*(**C.struct_cmt_gauge)(unsafe.Pointer(g)))
+}
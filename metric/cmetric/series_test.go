@@ -0,0 +1,70 @@
+package cmetric
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	cmetrics "github.com/calyptia/cmetrics-go"
+	"github.com/calyptia/plugin/metric"
+	"github.com/calyptia/plugin/metric/cmt"
+)
+
+func TestBuilderRemoveAndReset(t *testing.T) {
+	ctx, err := cmetrics.NewContext()
+	assert.NoError(t, err)
+	defer ctx.Destroy()
+
+	b := &Builder{Namespace: "fluentbit", SubSystem: "plugin", Context: ctx, OnError: func(err error) {
+		t.Error(err)
+	}}
+
+	records := b.NewCounter("records_total", "Number of records", "tag")
+	records.Add(1, "app")
+	records.Add(2, "stale")
+	assert.True(t, metric.Remove(records, "stale"))
+	// the series not seen are left as is.
+	assert.True(t, metric.Remove(records, "missing"))
+
+	conns := b.NewGauge("connections", "Number of open connections", "upstream")
+	conns.Set(3, "http://localhost")
+	conns.Set(4, "http://example.com")
+	assert.True(t, metric.Reset(conns))
+	conns.Set(5, "http://example.com")
+
+	got, err := b.Snapshot()
+	assert.NoError(t, err)
+
+	c, ok := cmt.Find(got, "fluentbit_plugin_records_total")
+	assert.True(t, ok)
+	v, ok := c.Value("app")
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, v)
+	_, ok = c.Value("stale")
+	assert.False(t, ok)
+
+	// the recreated metrics keep counting.
+	records.Add(1, "app")
+	got, err = b.Snapshot()
+	assert.NoError(t, err)
+	c, ok = cmt.Find(got, "fluentbit_plugin_records_total")
+	assert.True(t, ok)
+	v, _ = c.Value("app")
+	assert.Equal(t, 2.0, v)
+
+	g, ok := cmt.Find(got, "fluentbit_plugin_connections")
+	assert.True(t, ok)
+	_, ok = g.Value("http://localhost")
+	assert.False(t, ok)
+	v, _ = g.Value("http://example.com")
+	assert.Equal(t, 5.0, v)
+}
+
+func TestRemoveNotBuilt(t *testing.T) {
+	var errs []error
+	c := &Counter{OnError: func(err error) { errs = append(errs, err) }}
+	c.Remove("app")
+	c.Reset()
+
+	assert.Equal(t, 2, len(errs))
+	assert.IsError(t, errs[0], errNoSeries)
+}
@@ -0,0 +1,156 @@
+package cmt
+
+import (
+	"hash/fnv"
+	"slices"
+	"sort"
+	"time"
+)
+
+// aggregationCumulative is the cmetrics aggregation type of counters and histograms.
+const aggregationCumulative = 2
+
+// NewContext returns an empty context, ready to be filled with metrics
+// built with NewCounter, NewGauge, NewUntyped and NewHistogram.
+func NewContext() *Context {
+	return &Context{
+		Meta: Meta{
+			Cmetrics: map[string]any{},
+			External: map[string]any{},
+			Processing: Processing{
+				StaticLabels: [][]string{},
+			},
+		},
+		Metrics: []Metric{},
+	}
+}
+
+// AddStaticLabel adds a label applied to every metric of the context.
+func (c *Context) AddStaticLabel(key, value string) {
+	c.Meta.Processing.StaticLabels = append(c.Meta.Processing.StaticLabels, []string{key, value})
+}
+
+// Add appends a copy of the metrics to the context.
+func (c *Context) Add(metrics ...*Metric) {
+	for _, m := range metrics {
+		c.Metrics = append(c.Metrics, *m)
+	}
+}
+
+// NewCounter returns a counter metric family with the given label keys.
+func NewCounter(opts Opts, labels ...string) *Metric {
+	m := newMetric(TypeCounter, opts, labels)
+	m.Meta.AggregationType = aggregationCumulative
+	return m
+}
+
+// NewGauge returns a gauge metric family with the given label keys.
+func NewGauge(opts Opts, labels ...string) *Metric {
+	return newMetric(TypeGauge, opts, labels)
+}
+
+// NewUntyped returns an untyped metric family with the given label keys.
+func NewUntyped(opts Opts, labels ...string) *Metric {
+	return newMetric(TypeUntyped, opts, labels)
+}
+
+// NewHistogram returns a histogram metric family with the given bucket
+// upper bounds and label keys.
+func NewHistogram(opts Opts, buckets []float64, labels ...string) *Metric {
+	m := newMetric(TypeHistogram, opts, labels)
+	m.Meta.AggregationType = aggregationCumulative
+	m.Meta.Buckets = append([]float64{}, buckets...)
+	sort.Float64s(m.Meta.Buckets)
+	return m
+}
+
+func newMetric(typ Type, opts Opts, labels []string) *Metric {
+	return &Metric{
+		Meta: MetricMeta{
+			Ver:    2,
+			Type:   typ,
+			Opts:   opts,
+			Labels: append([]string{}, labels...),
+		},
+		Values: []Value{},
+	}
+}
+
+// Set sets the value of the sample matching the label values.
+func (m *Metric) Set(ts time.Time, value float64, labelValues ...string) {
+	v := m.sample(labelValues)
+	v.Timestamp = uint64(ts.UnixNano())
+	v.Value = value
+}
+
+// Add adds delta to the value of the sample matching the label values.
+func (m *Metric) Add(ts time.Time, delta float64, labelValues ...string) {
+	v := m.sample(labelValues)
+	v.Timestamp = uint64(ts.UnixNano())
+	v.Value += delta
+}
+
+// Observe records an observation in the histogram sample matching the label values.
+// It is a no-op for metrics other than histograms.
+func (m *Metric) Observe(ts time.Time, value float64, labelValues ...string) {
+	if m.Meta.Type != TypeHistogram {
+		return
+	}
+
+	v := m.sample(labelValues)
+	v.Timestamp = uint64(ts.UnixNano())
+	if v.Histogram == nil {
+		// the last bucket counts the observations above all the bounds (+Inf).
+		v.Histogram = &Histogram{Buckets: make([]uint64, len(m.Meta.Buckets)+1)}
+	}
+
+	idx := sort.SearchFloat64s(m.Meta.Buckets, value)
+	v.Histogram.Buckets[idx]++
+	v.Histogram.Sum += value
+	v.Histogram.Count++
+}
+
+// Remove removes the sample matching the label values, reporting whether it existed.
+func (m *Metric) Remove(labelValues ...string) bool {
+	hash := labelsHash(labelValues)
+	for i := range m.Values {
+		if m.Values[i].Hash == hash {
+			m.Values = slices.Delete(m.Values, i, i+1)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reset removes all the samples.
+func (m *Metric) Reset() {
+	m.Values = []Value{}
+}
+
+// sample returns the sample matching the label values, creating it when missing.
+func (m *Metric) sample(labelValues []string) *Value {
+	hash := labelsHash(labelValues)
+	for i := range m.Values {
+		if m.Values[i].Hash == hash {
+			return &m.Values[i]
+		}
+	}
+
+	m.Values = append(m.Values, Value{
+		Labels: append([]string{}, labelValues...),
+		Hash:   hash,
+	})
+
+	return &m.Values[len(m.Values)-1]
+}
+
+// labelsHash identifies a set of label values within a metric family.
+func labelsHash(labelValues []string) uint64 {
+	h := fnv.New64a()
+	for _, v := range labelValues {
+		_, _ = h.Write([]byte(v))
+		_, _ = h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
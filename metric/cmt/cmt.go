@@ -0,0 +1,186 @@
+// Package cmt provides a Go representation of the cmetrics msgpack payloads
+// exchanged with fluent-bit, so metrics can be built, inspected and modified
+// without going through the cmetrics C library.
+package cmt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Type of a metric, matching the cmetrics metric types.
+type Type int
+
+const (
+	TypeCounter Type = iota
+	TypeGauge
+	TypeHistogram
+	TypeSummary
+	TypeUntyped
+)
+
+// Context is a decoded cmetrics context, a set of metrics sharing static labels.
+type Context struct {
+	Meta    Meta     `msgpack:"meta"`
+	Metrics []Metric `msgpack:"metrics"`
+}
+
+// Meta contains the context level metadata.
+type Meta struct {
+	Cmetrics   map[string]any `msgpack:"cmetrics"`
+	External   map[string]any `msgpack:"external"`
+	Processing Processing     `msgpack:"processing"`
+}
+
+// Processing contains the labels applied to every metric of the context.
+type Processing struct {
+	StaticLabels [][]string `msgpack:"static_labels"`
+}
+
+// Metric is a metric family, its options and label keys plus all its samples.
+type Metric struct {
+	Meta   MetricMeta `msgpack:"meta"`
+	Values []Value    `msgpack:"values"`
+}
+
+// MetricMeta describes a metric family.
+type MetricMeta struct {
+	Ver             int       `msgpack:"ver"`
+	Type            Type      `msgpack:"type"`
+	Opts            Opts      `msgpack:"opts"`
+	Labels          []string  `msgpack:"labels"`
+	AggregationType int       `msgpack:"aggregation_type"`
+	Buckets         []float64 `msgpack:"buckets,omitempty"`
+	Quantiles       []float64 `msgpack:"quantiles,omitempty"`
+}
+
+// Opts are the naming options of a metric family.
+type Opts struct {
+	Namespace   string `msgpack:"ns"`
+	Subsystem   string `msgpack:"ss"`
+	Name        string `msgpack:"name"`
+	Description string `msgpack:"desc"`
+}
+
+// Value is a single sample of a metric family, Labels holds the values
+// for each of the label keys declared in MetricMeta.Labels.
+type Value struct {
+	Timestamp uint64     `msgpack:"ts"`
+	Value     float64    `msgpack:"value"`
+	Labels    []string   `msgpack:"labels"`
+	Hash      uint64     `msgpack:"hash"`
+	Histogram *Histogram `msgpack:"histogram,omitempty"`
+	Summary   *Summary   `msgpack:"summary,omitempty"`
+}
+
+// Histogram sample values.
+type Histogram struct {
+	Buckets []uint64 `msgpack:"buckets"`
+	Sum     float64  `msgpack:"sum"`
+	Count   uint64   `msgpack:"count"`
+}
+
+// Summary sample values.
+type Summary struct {
+	QuantilesSet uint64    `msgpack:"quantiles_set"`
+	Quantiles    []float64 `msgpack:"quantiles"`
+	Sum          float64   `msgpack:"sum"`
+	Count        uint64    `msgpack:"count"`
+}
+
+// FullName returns the metric name as exposed by prometheus: namespace_subsystem_name.
+func (m *Metric) FullName() string {
+	name := m.Meta.Opts.Name
+	if m.Meta.Opts.Subsystem != "" {
+		name = m.Meta.Opts.Subsystem + "_" + name
+	}
+	if m.Meta.Opts.Namespace != "" {
+		name = m.Meta.Opts.Namespace + "_" + name
+	}
+	return name
+}
+
+// LabelValue returns the value for the given label key of a sample.
+func (m *Metric) LabelValue(v Value, key string) (string, bool) {
+	for i, k := range m.Meta.Labels {
+		if k == key && i < len(v.Labels) {
+			return v.Labels[i], true
+		}
+	}
+	return "", false
+}
+
+// RenameLabel renames a label key, it reports whether the key was found.
+func (m *Metric) RenameLabel(from, to string) bool {
+	for i, k := range m.Meta.Labels {
+		if k == from {
+			m.Meta.Labels[i] = to
+			return true
+		}
+	}
+	return false
+}
+
+// SetLabel sets a label to the same value on every sample, adding the
+// label key to the metric when not present yet.
+func (m *Metric) SetLabel(key, value string) {
+	idx := -1
+	for i, k := range m.Meta.Labels {
+		if k == key {
+			idx = i
+			break
+		}
+	}
+
+	if idx == -1 {
+		m.Meta.Labels = append(m.Meta.Labels, key)
+		idx = len(m.Meta.Labels) - 1
+	}
+
+	for i := range m.Values {
+		for len(m.Values[i].Labels) <= idx {
+			m.Values[i].Labels = append(m.Values[i].Labels, "")
+		}
+		m.Values[i].Labels[idx] = value
+	}
+}
+
+// Decode all the contexts contained in a cmetrics msgpack payload.
+func Decode(b []byte) ([]*Context, error) {
+	var out []*Context
+
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	for {
+		var ctx Context
+		err := dec.Decode(&ctx)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("msgpack unmarshal metrics: %w", err)
+		}
+
+		out = append(out, &ctx)
+	}
+
+	return out, nil
+}
+
+// Encode contexts back into a cmetrics msgpack payload.
+func Encode(contexts ...*Context) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := msgpack.NewEncoder(&buf)
+	for _, ctx := range contexts {
+		if err := enc.Encode(ctx); err != nil {
+			return nil, fmt.Errorf("msgpack marshal metrics: %w", err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,108 @@
+package cmt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	in := &Context{
+		Meta: Meta{
+			Processing: Processing{
+				StaticLabels: [][]string{{"host", "localhost"}},
+			},
+		},
+		Metrics: []Metric{
+			{
+				Meta: MetricMeta{
+					Ver:    2,
+					Type:   TypeCounter,
+					Opts:   Opts{Namespace: "fluentbit", Subsystem: "plugin", Name: "records_total", Description: "Total records"},
+					Labels: []string{"name"},
+				},
+				Values: []Value{
+					{Timestamp: 1, Value: 42, Labels: []string{"gdummy"}},
+				},
+			},
+		},
+	}
+
+	b, err := Encode(in, in)
+	assert.NoError(t, err)
+
+	got, err := Decode(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(got))
+	assert.Equal(t, in.Metrics, got[0].Metrics)
+	assert.Equal(t, in.Meta.Processing, got[1].Meta.Processing)
+	assert.Equal(t, "fluentbit_plugin_records_total", got[0].Metrics[0].FullName())
+}
+
+func TestLabels(t *testing.T) {
+	m := Metric{
+		Meta: MetricMeta{Labels: []string{"name"}},
+		Values: []Value{
+			{Labels: []string{"a"}},
+			{Labels: []string{"b"}},
+		},
+	}
+
+	assert.True(t, m.RenameLabel("name", "plugin"))
+	assert.False(t, m.RenameLabel("name", "plugin"))
+
+	m.SetLabel("env", "prod")
+	assert.Equal(t, []string{"plugin", "env"}, m.Meta.Labels)
+
+	for _, v := range m.Values {
+		env, ok := m.LabelValue(v, "env")
+		assert.True(t, ok)
+		assert.Equal(t, "prod", env)
+	}
+
+	plugin, ok := m.LabelValue(m.Values[1], "plugin")
+	assert.True(t, ok)
+	assert.Equal(t, "b", plugin)
+}
+
+func TestBuilder(t *testing.T) {
+	now := time.Unix(0, 42)
+
+	counter := NewCounter(Opts{Namespace: "go", Name: "requests_total"}, "method")
+	counter.Add(now, 1, "GET")
+	counter.Add(now, 2, "GET")
+	counter.Set(now, 5, "POST")
+
+	histogram := NewHistogram(Opts{Name: "latency_seconds"}, []float64{1, 0.1})
+	histogram.Observe(now, 0.05)
+	histogram.Observe(now, 0.5)
+	histogram.Observe(now, 3)
+
+	ctx := NewContext()
+	ctx.AddStaticLabel("host", "localhost")
+	ctx.Add(counter, histogram)
+
+	b, err := Encode(ctx)
+	assert.NoError(t, err)
+
+	got, err := Decode(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(got))
+	assert.Equal(t, [][]string{{"host", "localhost"}}, got[0].Meta.Processing.StaticLabels)
+	assert.Equal(t, 2, len(got[0].Metrics))
+
+	c := got[0].Metrics[0]
+	assert.Equal(t, TypeCounter, c.Meta.Type)
+	assert.Equal(t, "go_requests_total", c.FullName())
+	assert.Equal(t, 2, len(c.Values))
+	assert.Equal(t, 3.0, c.Values[0].Value)
+	assert.Equal(t, []string{"GET"}, c.Values[0].Labels)
+	assert.Equal(t, 5.0, c.Values[1].Value)
+	assert.Equal(t, uint64(42), c.Values[1].Timestamp)
+
+	h := got[0].Metrics[1]
+	assert.Equal(t, TypeHistogram, h.Meta.Type)
+	assert.Equal(t, []float64{0.1, 1}, h.Meta.Buckets)
+	assert.Equal(t, &Histogram{Buckets: []uint64{1, 1, 1}, Sum: 3.55, Count: 3}, h.Values[0].Histogram)
+}
@@ -0,0 +1,157 @@
+package cmt
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/calyptia/plugin/metric"
+)
+
+// Metrics builds counters and gauges kept in memory, e.g. to give to the plugins in their
+// unit tests, the values being read back with Snapshot. It is safe for concurrent use.
+type Metrics struct {
+	namespace string
+	subsystem string
+
+	mu       sync.Mutex
+	families []*Metric
+}
+
+// NewMetrics returns empty metrics, whose names are prefixed with the given namespace and
+// subsystem like the metrics of the plugins.
+func NewMetrics(namespace, subsystem string) *Metrics {
+	return &Metrics{namespace: namespace, subsystem: subsystem}
+}
+
+// NewCounter returns the counter of the given name, created with the label keys the
+// first time.
+func (m *Metrics) NewCounter(name, desc string, labelKeys ...string) metric.Counter {
+	return counter{family{m: m, metric: m.family(TypeCounter, name, desc, labelKeys)}}
+}
+
+// NewGauge returns the gauge of the given name, created with the label keys the first
+// time.
+func (m *Metrics) NewGauge(name, desc string, labelKeys ...string) metric.Gauge {
+	return family{m: m, metric: m.family(TypeGauge, name, desc, labelKeys)}
+}
+
+// Snapshot returns a copy of the metric families, in the order of their creation.
+func (m *Metrics) Snapshot() ([]Metric, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]Metric, len(m.families))
+	for i, f := range m.families {
+		out[i] = f.clone()
+	}
+
+	return out, nil
+}
+
+func (m *Metrics) family(typ Type, name, desc string, labelKeys []string) *Metric {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, f := range m.families {
+		if f.Meta.Opts.Name == name {
+			return f
+		}
+	}
+
+	opts := Opts{Namespace: m.namespace, Subsystem: m.subsystem, Name: name, Description: desc}
+	f := newMetric(typ, opts, labelKeys)
+	if typ == TypeCounter {
+		f.Meta.AggregationType = aggregationCumulative
+	}
+	m.families = append(m.families, f)
+
+	return f
+}
+
+// family is a gauge of Metrics, and the counters through counter.
+type family struct {
+	m      *Metrics
+	metric *Metric
+}
+
+func (f family) Add(delta float64, labelValues ...string) {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+	f.metric.Add(time.Now(), delta, labelValues...)
+}
+
+func (f family) Sub(delta float64, labelValues ...string) {
+	f.Add(-delta, labelValues...)
+}
+
+func (f family) Set(value float64, labelValues ...string) {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+	f.metric.Set(time.Now(), value, labelValues...)
+}
+
+func (f family) Remove(labelValues ...string) {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+	f.metric.Remove(labelValues...)
+}
+
+func (f family) Reset() {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+	f.metric.Reset()
+}
+
+type counter struct {
+	family
+}
+
+// Find returns the metric family of the given full name, e.g.
+// fluentbit_plugin_requests_total.
+func Find(metrics []Metric, fullName string) (*Metric, bool) {
+	for i := range metrics {
+		if metrics[i].FullName() == fullName {
+			return &metrics[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// Value returns the value of the sample matching the label values.
+func (m *Metric) Value(labelValues ...string) (float64, bool) {
+	for _, v := range m.Values {
+		if slices.Equal(v.Labels, labelValues) {
+			return v.Value, true
+		}
+	}
+
+	return 0, false
+}
+
+// clone returns a deep copy of the metric family.
+func (m *Metric) clone() Metric {
+	out := *m
+	out.Meta.Labels = append([]string(nil), m.Meta.Labels...)
+	out.Meta.Buckets = append([]float64(nil), m.Meta.Buckets...)
+	out.Meta.Quantiles = append([]float64(nil), m.Meta.Quantiles...)
+
+	out.Values = make([]Value, len(m.Values))
+	for i, v := range m.Values {
+		v.Labels = append([]string(nil), v.Labels...)
+		if v.Histogram != nil {
+			h := *v.Histogram
+			h.Buckets = append([]uint64(nil), h.Buckets...)
+			v.Histogram = &h
+		}
+		if v.Summary != nil {
+			s := *v.Summary
+			s.Quantiles = append([]float64(nil), s.Quantiles...)
+			v.Summary = &s
+		}
+		out.Values[i] = v
+	}
+
+	return out
+}
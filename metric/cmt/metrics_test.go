@@ -0,0 +1,92 @@
+package cmt
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/calyptia/plugin/metric"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics("fluentbit", "plugin")
+
+	requests := m.NewCounter("requests_total", "Number of requests", "tag", "status")
+	requests.Add(1, "app", "200")
+	metric.BindCounter(requests, "app").Add(2, "200")
+	metric.BindCounter(requests, "app", "500").Add(1)
+
+	conns := m.NewGauge("connections", "Number of open connections")
+	conns.Set(4)
+	metric.Sub(conns, 1)
+
+	// the metrics of the same name are shared.
+	m.NewGauge("connections", "Number of open connections").Add(2)
+
+	got, err := m.Snapshot()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(got))
+
+	c, ok := Find(got, "fluentbit_plugin_requests_total")
+	assert.True(t, ok)
+	assert.Equal(t, TypeCounter, c.Meta.Type)
+	assert.Equal(t, []string{"tag", "status"}, c.Meta.Labels)
+	v, ok := c.Value("app", "200")
+	assert.True(t, ok)
+	assert.Equal(t, 3.0, v)
+	v, ok = c.Value("app", "500")
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, v)
+	_, ok = c.Value("app", "404")
+	assert.False(t, ok)
+
+	g, ok := Find(got, "fluentbit_plugin_connections")
+	assert.True(t, ok)
+	assert.Equal(t, TypeGauge, g.Meta.Type)
+	v, ok = g.Value()
+	assert.True(t, ok)
+	assert.Equal(t, 5.0, v)
+
+	_, ok = Find(got, "fluentbit_plugin_missing")
+	assert.False(t, ok)
+
+	// the snapshots are copies.
+	conns.Set(0)
+	v, _ = g.Value()
+	assert.Equal(t, 5.0, v)
+
+	b, err := Encode(&Context{Metrics: got})
+	assert.NoError(t, err)
+	decoded, err := Decode(b)
+	assert.NoError(t, err)
+	assert.Equal(t, got, decoded[0].Metrics)
+}
+
+func TestMetricsRemoveAndReset(t *testing.T) {
+	m := NewMetrics("fluentbit", "plugin")
+
+	records := m.NewCounter("records_total", "Number of records", "tag")
+	records.Add(1, "app")
+	records.Add(2, "stale")
+	assert.True(t, metric.Remove(records, "stale"))
+	assert.True(t, metric.Remove(records, "missing"))
+
+	conns := m.NewGauge("connections", "Number of open connections", "upstream")
+	conns.Set(3, "http://localhost")
+	assert.True(t, metric.Reset(conns))
+
+	got, err := m.Snapshot()
+	assert.NoError(t, err)
+
+	c, ok := Find(got, "fluentbit_plugin_records_total")
+	assert.True(t, ok)
+	v, ok := c.Value("app")
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, v)
+	_, ok = c.Value("stale")
+	assert.False(t, ok)
+
+	g, ok := Find(got, "fluentbit_plugin_connections")
+	assert.True(t, ok)
+	assert.Equal(t, 0, len(g.Values))
+}
@@ -1,5 +1,20 @@
 // Package metric provides with Counter and Gauge interfaces.
 // See /cmetric for an implementation using shared memory to cmetrics library.
+//
+// The metrics are created with label keys, and their values take the label values in
+// the same order, e.g. to break a metric down by tag and status code:
+//
+//	requests := fbit.Metrics.NewCounter("requests_total", "Number of requests", "tag", "status")
+//	requests.Add(1, tag, "200")
+//
+// BindCounter and BindGauge bind the first label values, so the values known up front
+// are given once:
+//
+//	metric.BindCounter(requests, tag).Add(1, "200")
+//
+// Every label values add a series to the metric. Plugins with dynamic label values, e.g. the
+// tags of the records, remove the series of the values no longer seen with Remove, or all
+// the series with Reset, so the metrics do not grow unbounded.
 package metric
 
 // Counter describes a metric that accumulates values monotonically.
@@ -7,8 +22,128 @@ type Counter interface {
 	Add(delta float64, labelValues ...string)
 }
 
-// Gauge describes a metric that takes specific values over time.
+// Gauge describes a metric that takes specific values over time, e.g. the depth of a
+// queue or the number of open connections.
 type Gauge interface {
 	Add(delta float64, labelValues ...string)
 	Set(value float64, labelValues ...string)
 }
+
+// GaugeSubtracter is implemented by the gauges subtracting from their value, see Sub.
+type GaugeSubtracter interface {
+	Sub(delta float64, labelValues ...string)
+}
+
+// Sub subtracts delta from the value of g, it adds -delta to the gauges not implementing
+// GaugeSubtracter.
+func Sub(g Gauge, delta float64, labelValues ...string) {
+	if s, ok := g.(GaugeSubtracter); ok {
+		s.Sub(delta, labelValues...)
+		return
+	}
+
+	g.Add(-delta, labelValues...)
+}
+
+// SeriesRemover is implemented by the counters and the gauges removing their series, see
+// Remove and Reset.
+type SeriesRemover interface {
+	// Remove removes the series of the given label values.
+	Remove(labelValues ...string)
+	// Reset removes all the series of the metric.
+	Reset()
+}
+
+// Remove removes the series of the given label values from m, a Counter or a Gauge, the
+// label values bound to m preceding them. It reports whether m implements SeriesRemover.
+func Remove(m any, labelValues ...string) bool {
+	switch b := m.(type) {
+	case boundCounter:
+		m, labelValues = b.c, bind(b.values, labelValues)
+	case boundGauge:
+		m, labelValues = b.g, bind(b.values, labelValues)
+	}
+
+	r, ok := m.(SeriesRemover)
+	if ok {
+		r.Remove(labelValues...)
+	}
+
+	return ok
+}
+
+// Reset removes all the series of m, a Counter or a Gauge, the ones of other label values
+// than the ones bound to m too. It reports whether m implements SeriesRemover.
+func Reset(m any) bool {
+	switch b := m.(type) {
+	case boundCounter:
+		m = b.c
+	case boundGauge:
+		m = b.g
+	}
+
+	r, ok := m.(SeriesRemover)
+	if ok {
+		r.Reset()
+	}
+
+	return ok
+}
+
+// BindCounter returns c with the given label values bound, the values given to Add
+// following them.
+func BindCounter(c Counter, labelValues ...string) Counter {
+	if b, ok := c.(boundCounter); ok {
+		return boundCounter{c: b.c, values: bind(b.values, labelValues)}
+	}
+
+	return boundCounter{c: c, values: bind(nil, labelValues)}
+}
+
+// BindGauge returns g with the given label values bound, the values given to Add, Set
+// and Sub following them.
+func BindGauge(g Gauge, labelValues ...string) Gauge {
+	if b, ok := g.(boundGauge); ok {
+		return boundGauge{g: b.g, values: bind(b.values, labelValues)}
+	}
+
+	return boundGauge{g: g, values: bind(nil, labelValues)}
+}
+
+type boundCounter struct {
+	c      Counter
+	values []string
+}
+
+func (b boundCounter) Add(delta float64, labelValues ...string) {
+	b.c.Add(delta, bind(b.values, labelValues)...)
+}
+
+type boundGauge struct {
+	g      Gauge
+	values []string
+}
+
+func (b boundGauge) Add(delta float64, labelValues ...string) {
+	b.g.Add(delta, bind(b.values, labelValues)...)
+}
+
+func (b boundGauge) Sub(delta float64, labelValues ...string) {
+	Sub(b.g, delta, bind(b.values, labelValues)...)
+}
+
+func (b boundGauge) Set(value float64, labelValues ...string) {
+	b.g.Set(value, bind(b.values, labelValues)...)
+}
+
+// bind returns the bound label values followed by the given ones. The bound values are
+// shared as is when none are given, so the handles of fully bound metrics do not
+// allocate.
+func bind(bound, labelValues []string) []string {
+	if len(labelValues) == 0 {
+		return bound
+	}
+
+	values := make([]string, 0, len(bound)+len(labelValues))
+	return append(append(values, bound...), labelValues...)
+}
@@ -0,0 +1,137 @@
+package metric
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+// testMetric records the values of a metric by their label values.
+type testMetric struct {
+	values map[string]float64
+}
+
+func (m *testMetric) Add(delta float64, labelValues ...string) {
+	m.values[strings.Join(labelValues, ",")] += delta
+}
+
+func (m *testMetric) Sub(delta float64, labelValues ...string) {
+	m.values[strings.Join(labelValues, ",")] -= delta
+}
+
+func (m *testMetric) Set(value float64, labelValues ...string) {
+	m.values[strings.Join(labelValues, ",")] = value
+}
+
+func (m *testMetric) Remove(labelValues ...string) {
+	delete(m.values, strings.Join(labelValues, ","))
+}
+
+func (m *testMetric) Reset() {
+	clear(m.values)
+}
+
+// testCounter is the counter of a test metric.
+type testCounter struct {
+	*testMetric
+}
+
+func TestBindCounter(t *testing.T) {
+	m := &testMetric{values: map[string]float64{}}
+	var c Counter = testCounter{m}
+
+	c.Add(1, "tag", "200")
+	BindCounter(c, "tag", "200").Add(2)
+	BindCounter(c, "tag").Add(4, "500")
+
+	// the bound values of a handle are kept when bound further.
+	byTag := BindCounter(c, "other")
+	BindCounter(byTag, "200").Add(8)
+	BindCounter(byTag, "404").Add(16)
+	byTag.Add(32, "200")
+
+	assert.Equal(t, map[string]float64{
+		"tag,200":   3,
+		"tag,500":   4,
+		"other,200": 40,
+		"other,404": 16,
+	}, m.values)
+}
+
+func TestBindGauge(t *testing.T) {
+	m := &testMetric{values: map[string]float64{}}
+	var g Gauge = m
+
+	conns := BindGauge(g, "http://localhost")
+	conns.Set(4)
+	conns.Add(2)
+	Sub(conns, 1)
+	BindGauge(g, "http://example.com").Add(1)
+
+	assert.Equal(t, map[string]float64{
+		"http://localhost":   5,
+		"http://example.com": 1,
+	}, m.values)
+}
+
+func TestRemoveAndReset(t *testing.T) {
+	m := &testMetric{values: map[string]float64{}}
+	var c Counter = testCounter{m}
+
+	c.Add(1, "app", "200")
+	c.Add(2, "app", "500")
+	c.Add(4, "other", "200")
+
+	// the bound values prefix the removed ones.
+	assert.True(t, Remove(BindCounter(c, "app"), "500"))
+	assert.True(t, Remove(BindCounter(c, "other", "200")))
+	assert.Equal(t, map[string]float64{"app,200": 1}, m.values)
+
+	// the reset of a bound handle removes all the series.
+	c.Add(8, "other", "200")
+	assert.True(t, Reset(BindCounter(c, "app")))
+	assert.Equal(t, map[string]float64{}, m.values)
+
+	// the metrics without Remove keep their series.
+	g := testGauge{m}
+	g.Set(1, "app")
+	assert.False(t, Remove(g, "app"))
+	assert.False(t, Reset(BindGauge(g, "app")))
+	assert.Equal(t, map[string]float64{"app": 1}, m.values)
+}
+
+func TestBindLabelValues(t *testing.T) {
+	values := []string{"tag"}
+	c := BindCounter(testCounter{&testMetric{values: map[string]float64{}}}, values...)
+
+	// the values are copied when bound.
+	values[0] = "changed"
+	assert.Equal(t, []string{"tag"}, c.(boundCounter).values)
+
+	// fully bound handles do not allocate.
+	assert.Zero(t, testing.AllocsPerRun(100, func() { c.Add(1) }))
+}
+
+// testGauge is a gauge without Sub.
+type testGauge struct {
+	m *testMetric
+}
+
+func (g testGauge) Add(delta float64, labelValues ...string) { g.m.Add(delta, labelValues...) }
+
+func (g testGauge) Set(value float64, labelValues ...string) { g.m.Set(value, labelValues...) }
+
+func TestSub(t *testing.T) {
+	m := &testMetric{values: map[string]float64{}}
+	m.Set(4, "a")
+	Sub(m, 1, "a")
+
+	// the gauges without Sub are added the opposite.
+	g := testGauge{m}
+	g.Set(4, "b")
+	Sub(g, 1, "b")
+	Sub(BindGauge(g, "b"), 1)
+
+	assert.Equal(t, map[string]float64{"a": 3, "b": 2}, m.values)
+}
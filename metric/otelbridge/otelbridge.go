@@ -0,0 +1,238 @@
+// Package otelbridge bridges the metrics of the OpenTelemetry Go SDK to the metrics of a
+// plugin, so plugins instrumented with otel-go report their metrics through fluent-bit.
+//
+// The Exporter is a metric exporter of the SDK, read periodically by a reader:
+//
+//	provider := sdkmetric.NewMeterProvider(
+//		sdkmetric.WithReader(otelbridge.NewReader(fbit.Metrics, 10*time.Second)),
+//	)
+//	defer provider.Shutdown(ctx)
+//
+// The names of the instruments and the keys of their attributes are converted to the
+// names and the label keys of cmetrics, the characters other than letters, digits and
+// underscores becoming underscores, e.g. http.server.request.duration becomes
+// http_server_request_duration. The monotonic sums become counters, the other sums and
+// the gauges becoming gauges. The histograms are copied as their name_sum and name_count
+// counters, plus the name_bucket counter with a le label, like the prometheus histograms.
+package otelbridge
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/calyptia/plugin/internal/bridge"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// Metrics creates the metrics the instruments are copied to, implemented by the Metrics
+// of plugin.Fluentbit.
+type Metrics = bridge.Metrics
+
+// Exporter is an sdkmetric.Exporter copying the instruments to the metrics of a plugin.
+type Exporter struct {
+	mu  sync.Mutex
+	set *bridge.Set
+}
+
+var _ sdkmetric.Exporter = (*Exporter)(nil)
+
+// New returns an Exporter copying the instruments to metrics.
+func New(metrics Metrics) *Exporter {
+	return &Exporter{set: bridge.New(metrics)}
+}
+
+// NewReader returns a reader exporting the instruments to metrics every interval, to be
+// given to sdkmetric.WithReader.
+func NewReader(metrics Metrics, interval time.Duration) sdkmetric.Reader {
+	return sdkmetric.NewPeriodicReader(New(metrics), sdkmetric.WithInterval(interval))
+}
+
+// Temporality returns the delta temporality for the counters and the histograms, their
+// points being added to the counters, and the cumulative temporality for the others.
+func (e *Exporter) Temporality(k sdkmetric.InstrumentKind) metricdata.Temporality {
+	switch k {
+	case sdkmetric.InstrumentKindCounter, sdkmetric.InstrumentKindObservableCounter,
+		sdkmetric.InstrumentKindHistogram:
+		return metricdata.DeltaTemporality
+	}
+
+	return metricdata.CumulativeTemporality
+}
+
+// Aggregation returns the default aggregation of the instruments.
+func (e *Exporter) Aggregation(k sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(k)
+}
+
+// Export copies the metrics.
+func (e *Exporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			name := sanitize(m.Name)
+
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				copySum(e.set, name, m.Description, data)
+			case metricdata.Sum[float64]:
+				copySum(e.set, name, m.Description, data)
+			case metricdata.Gauge[int64]:
+				copyGauge(e.set, name, m.Description, data)
+			case metricdata.Gauge[float64]:
+				copyGauge(e.set, name, m.Description, data)
+			case metricdata.Histogram[int64]:
+				copyHistogram(e.set, name, m.Description, data)
+			case metricdata.Histogram[float64]:
+				copyHistogram(e.set, name, m.Description, data)
+			case metricdata.ExponentialHistogram[int64]:
+				copyExponentialHistogram(e.set, name, m.Description, data)
+			case metricdata.ExponentialHistogram[float64]:
+				copyExponentialHistogram(e.set, name, m.Description, data)
+			case metricdata.Summary:
+				copySummary(e.set, name, m.Description, data)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ForceFlush does nothing, the metrics being copied on export.
+func (e *Exporter) ForceFlush(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Shutdown does nothing, the metrics belonging to the plugin.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func copySum[N int64 | float64](set *bridge.Set, name, help string, sum metricdata.Sum[N]) {
+	for _, dp := range sum.DataPoints {
+		keys, values := labels(dp.Attributes, 0)
+		v := float64(dp.Value)
+
+		switch {
+		case sum.IsMonotonic && sum.Temporality == metricdata.DeltaTemporality:
+			set.Add(name, help, keys, values, v)
+		case sum.IsMonotonic:
+			set.Count(name, help, keys, values, v)
+		case sum.Temporality == metricdata.DeltaTemporality:
+			set.AddGauge(name, help, keys, values, v)
+		default:
+			set.Set(name, help, keys, values, v)
+		}
+	}
+}
+
+func copyGauge[N int64 | float64](set *bridge.Set, name, help string, gauge metricdata.Gauge[N]) {
+	for _, dp := range gauge.DataPoints {
+		keys, values := labels(dp.Attributes, 0)
+		set.Set(name, help, keys, values, float64(dp.Value))
+	}
+}
+
+func copyHistogram[N int64 | float64](set *bridge.Set, name, help string, h metricdata.Histogram[N]) {
+	count := set.Count
+	if h.Temporality == metricdata.DeltaTemporality {
+		count = set.Add
+	}
+
+	for _, dp := range h.DataPoints {
+		keys, values := labels(dp.Attributes, 1)
+		count(name+"_sum", help, keys, values, float64(dp.Sum))
+		count(name+"_count", help, keys, values, float64(dp.Count))
+
+		// the counts of the buckets are not cumulative, unlike the prometheus ones, the
+		// last one being the count of the values above the last bound.
+		keys = append(keys, "le")
+		var cumulative uint64
+		for i, n := range dp.BucketCounts {
+			le := math.Inf(1)
+			if i < len(dp.Bounds) {
+				le = dp.Bounds[i]
+			}
+
+			cumulative += n
+			count(name+"_bucket", help, keys, append(values, formatFloat(le)), float64(cumulative))
+		}
+	}
+}
+
+func copyExponentialHistogram[N int64 | float64](set *bridge.Set, name, help string, h metricdata.ExponentialHistogram[N]) {
+	count := set.Count
+	if h.Temporality == metricdata.DeltaTemporality {
+		count = set.Add
+	}
+
+	for _, dp := range h.DataPoints {
+		keys, values := labels(dp.Attributes, 0)
+		count(name+"_sum", help, keys, values, float64(dp.Sum))
+		count(name+"_count", help, keys, values, float64(dp.Count))
+	}
+}
+
+func copySummary(set *bridge.Set, name, help string, s metricdata.Summary) {
+	for _, dp := range s.DataPoints {
+		keys, values := labels(dp.Attributes, 1)
+		set.Count(name+"_sum", help, keys, values, dp.Sum)
+		set.Count(name+"_count", help, keys, values, float64(dp.Count))
+
+		keys = append(keys, "quantile")
+		for _, q := range dp.QuantileValues {
+			set.Set(name, help, keys, append(values, formatFloat(q.Quantile)), q.Value)
+		}
+	}
+}
+
+// labels returns the label keys and values of the attributes, with room for extra labels.
+func labels(attrs attribute.Set, extra int) (keys, values []string) {
+	keys = make([]string, 0, attrs.Len()+extra)
+	values = make([]string, 0, attrs.Len()+extra)
+
+	for iter := attrs.Iter(); iter.Next(); {
+		kv := iter.Attribute()
+		keys = append(keys, sanitize(string(kv.Key)))
+		values = append(values, kv.Value.Emit())
+	}
+
+	return keys, values
+}
+
+// sanitize converts an instrument name or an attribute key to a cmetrics name, replacing
+// the characters other than letters, digits and underscores, and a leading digit, with
+// underscores.
+func sanitize(s string) string {
+	valid := func(i int, r rune) bool {
+		return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9'
+	}
+
+	var b strings.Builder
+	for i, r := range s {
+		if valid(i, r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+
+	return b.String()
+}
+
+// formatFloat formats the quantiles and the bucket bounds like the text exposition format
+// of prometheus.
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,70 @@
+package otelbridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/calyptia/plugin/internal/metrictest"
+	"github.com/calyptia/plugin/metric/cmt"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+func TestExporter(t *testing.T) {
+	ctx := context.Background()
+	m := cmt.NewMetrics("fluentbit", "plugin")
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(NewReader(m, time.Hour)))
+	meter := provider.Meter("test")
+
+	requests, err := meter.Int64Counter("http.requests", otelmetric.WithDescription("Number of requests"))
+	assert.NoError(t, err)
+	inflight, err := meter.Int64UpDownCounter("http.inflight")
+	assert.NoError(t, err)
+	temperature, err := meter.Float64Gauge("temperature")
+	assert.NoError(t, err)
+	latency, err := meter.Float64Histogram("latency", otelmetric.WithExplicitBucketBoundaries(0.1, 1))
+	assert.NoError(t, err)
+
+	get := otelmetric.WithAttributes(attribute.String("http.method", "GET"))
+	requests.Add(ctx, 3, get)
+	requests.Add(ctx, 1, otelmetric.WithAttributes(attribute.String("http.method", "POST")))
+	inflight.Add(ctx, 4)
+	temperature.Record(ctx, 21.5)
+	latency.Record(ctx, 0.05)
+	latency.Record(ctx, 0.5)
+	assert.NoError(t, provider.ForceFlush(ctx))
+
+	// the deltas of the counters and the histograms are added.
+	requests.Add(ctx, 2, get)
+	inflight.Add(ctx, -1)
+	latency.Record(ctx, 2)
+	assert.NoError(t, provider.ForceFlush(ctx))
+	assert.NoError(t, provider.Shutdown(ctx))
+
+	assert.Equal(t, map[string]float64{
+		"http_requests{http_method=GET}":  5,
+		"http_requests{http_method=POST}": 1,
+		"http_inflight{}":                 3,
+		"temperature{}":                   21.5,
+		"latency_bucket{le=0.1}":          1,
+		"latency_bucket{le=1}":            2,
+		"latency_bucket{le=+Inf}":         3,
+		"latency_sum{}":                   2.55,
+		"latency_count{}":                 3,
+	}, metrictest.Values(t, m))
+	assert.Equal(t, []string{"http_method"}, metrictest.LabelKeys(t, m, "http_requests"))
+}
+
+func TestSanitize(t *testing.T) {
+	for name, want := range map[string]string{
+		"http.server.request.duration": "http_server_request_duration",
+		"queue_depth":                  "queue_depth",
+		"k8s.pod-name":                 "k8s_pod_name",
+		"2xx":                          "_xx",
+	} {
+		assert.Equal(t, want, sanitize(name), name)
+	}
+}
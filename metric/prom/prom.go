@@ -0,0 +1,177 @@
+// Package prom bridges the metrics of the prometheus client_golang library to the metrics
+// of a plugin, so plugins already instrumented with client_golang report their metrics
+// through fluent-bit without rewriting their instrumentation.
+//
+// A Bridge gathers the metrics of a prometheus.Gatherer, e.g. prometheus.DefaultGatherer,
+// and copies them to the metrics of the plugin every time it scrapes them:
+//
+//	reg := prom.NewRegistry(fbit.Metrics)
+//	reg.MustRegister(requests, latency)
+//	reg.Start(10 * time.Second)
+//	defer reg.Stop()
+//
+// Counters and gauges keep their names, labels and values, the untyped metrics becoming
+// gauges. Summaries and histograms are copied as their series in the text exposition
+// format: the name_sum and name_count counters, plus the name gauge with a quantile label
+// for the summaries, and the name_bucket counter with a le label for the histograms.
+package prom
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/calyptia/plugin/internal/bridge"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Metrics creates the metrics the prometheus metrics are copied to, implemented by the
+// Metrics of plugin.Fluentbit.
+type Metrics = bridge.Metrics
+
+// Bridge copies the metrics of a prometheus.Gatherer to the metrics of a plugin.
+type Bridge struct {
+	// OnError is invoked with the errors of the scrapes started by Start.
+	OnError func(err error)
+
+	gatherer prometheus.Gatherer
+
+	mu     sync.Mutex
+	set    *bridge.Set
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New returns a Bridge copying the metrics gathered by g to metrics.
+func New(metrics Metrics, g prometheus.Gatherer) *Bridge {
+	return &Bridge{gatherer: g, set: bridge.New(metrics)}
+}
+
+// Registry is a prometheus registry whose metrics are copied to the metrics of a plugin,
+// a prometheus.Registerer to register the collectors of the plugin with.
+type Registry struct {
+	*prometheus.Registry
+	*Bridge
+}
+
+// NewRegistry returns an empty Registry copying its metrics to metrics. The SDK reporting
+// the Go runtime metrics of every instance, the collectors of the Go runtime and of the
+// process are left out.
+func NewRegistry(metrics Metrics) *Registry {
+	reg := prometheus.NewRegistry()
+	return &Registry{Registry: reg, Bridge: New(metrics, reg)}
+}
+
+// Scrape gathers the prometheus metrics and copies them. The metrics gathered despite an
+// error are copied too.
+func (b *Bridge) Scrape() error {
+	families, err := b.gatherer.Gather()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, f := range families {
+		b.copyFamily(f)
+	}
+
+	return err
+}
+
+// Start scrapes the prometheus metrics every interval until stopped.
+func (b *Bridge) Start(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel, b.done = cancel, make(chan struct{})
+
+	go func() {
+		defer close(b.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := b.Scrape(); err != nil && b.OnError != nil {
+				b.OnError(err)
+			}
+		}
+	}()
+}
+
+// Stop stops the scrapes started by Start, then scrapes the metrics a last time so their
+// final values are reported.
+func (b *Bridge) Stop() error {
+	if b.cancel == nil {
+		return nil
+	}
+
+	b.cancel()
+	<-b.done
+	b.cancel = nil
+
+	return b.Scrape()
+}
+
+func (b *Bridge) copyFamily(f *dto.MetricFamily) {
+	name, help := f.GetName(), f.GetHelp()
+
+	for _, m := range f.GetMetric() {
+		keys := make([]string, 0, len(m.GetLabel())+1)
+		values := make([]string, 0, len(m.GetLabel())+1)
+		for _, l := range m.GetLabel() {
+			keys, values = append(keys, l.GetName()), append(values, l.GetValue())
+		}
+
+		switch f.GetType() {
+		case dto.MetricType_COUNTER:
+			b.set.Count(name, help, keys, values, m.GetCounter().GetValue())
+		case dto.MetricType_GAUGE:
+			b.set.Set(name, help, keys, values, m.GetGauge().GetValue())
+		case dto.MetricType_UNTYPED:
+			b.set.Set(name, help, keys, values, m.GetUntyped().GetValue())
+		case dto.MetricType_SUMMARY:
+			s := m.GetSummary()
+			b.set.Count(name+"_sum", help, keys, values, s.GetSampleSum())
+			b.set.Count(name+"_count", help, keys, values, float64(s.GetSampleCount()))
+
+			keys = append(keys, "quantile")
+			for _, q := range s.GetQuantile() {
+				b.set.Set(name, help, keys, append(values, formatFloat(q.GetQuantile())), q.GetValue())
+			}
+		case dto.MetricType_HISTOGRAM:
+			h := m.GetHistogram()
+			count := float64(h.GetSampleCount())
+			if h.SampleCountFloat != nil {
+				count = h.GetSampleCountFloat()
+			}
+			b.set.Count(name+"_sum", help, keys, values, h.GetSampleSum())
+			b.set.Count(name+"_count", help, keys, values, count)
+
+			keys = append(keys, "le")
+			for _, bucket := range h.GetBucket() {
+				v := float64(bucket.GetCumulativeCount())
+				if bucket.CumulativeCountFloat != nil {
+					v = bucket.GetCumulativeCountFloat()
+				}
+				b.set.Count(name+"_bucket", help, keys, append(values, formatFloat(bucket.GetUpperBound())), v)
+			}
+			b.set.Count(name+"_bucket", help, keys, append(values, "+Inf"), count)
+		}
+	}
+}
+
+// formatFloat formats the quantiles and the bucket bounds like the text exposition format.
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
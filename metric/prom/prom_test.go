@@ -0,0 +1,137 @@
+package prom
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/calyptia/plugin/internal/metrictest"
+	"github.com/calyptia/plugin/metric/cmt"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestRegistry(t *testing.T) {
+	m := cmt.NewMetrics("fluentbit", "plugin")
+	reg := NewRegistry(m)
+
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "requests_total",
+		Help: "Number of requests",
+	}, []string{"code", "method"})
+	conns := prometheus.NewGauge(prometheus.GaugeOpts{Name: "connections"})
+	latency := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "latency_seconds",
+		Buckets: []float64{0.1, 1},
+	})
+	sizes := prometheus.NewSummary(prometheus.SummaryOpts{
+		Name:       "size_bytes",
+		Objectives: map[float64]float64{0.5: 0.05},
+	})
+	reg.MustRegister(requests, conns, latency, sizes)
+
+	requests.WithLabelValues("200", "GET").Add(3)
+	requests.WithLabelValues("500", "POST").Inc()
+	conns.Set(4)
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	sizes.Observe(100)
+
+	assert.NoError(t, reg.Scrape())
+
+	requests.WithLabelValues("200", "GET").Add(2)
+	conns.Sub(1)
+	latency.Observe(2)
+
+	assert.NoError(t, reg.Scrape())
+
+	assert.Equal(t, map[string]float64{
+		"requests_total{code=200,method=GET}":  5,
+		"requests_total{code=500,method=POST}": 1,
+		"connections{}":                        3,
+		"latency_seconds_bucket{le=0.1}":       1,
+		"latency_seconds_bucket{le=1}":         2,
+		"latency_seconds_bucket{le=+Inf}":      3,
+		"latency_seconds_sum{}":                2.55,
+		"latency_seconds_count{}":              3,
+		"size_bytes{quantile=0.5}":             100,
+		"size_bytes_sum{}":                     100,
+		"size_bytes_count{}":                   1,
+	}, metrictest.Values(t, m))
+	assert.Equal(t, []string{"code", "method"}, metrictest.LabelKeys(t, m, "requests_total"))
+}
+
+// testGatherer gathers the given metric families.
+type testGatherer struct {
+	mu       sync.Mutex
+	families []*dto.MetricFamily
+	err      error
+}
+
+func (g *testGatherer) Gather() ([]*dto.MetricFamily, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.families, g.err
+}
+
+func (g *testGatherer) set(families []*dto.MetricFamily, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.families, g.err = families, err
+}
+
+func counterFamily(name string, v float64) *dto.MetricFamily {
+	typ := dto.MetricType_COUNTER
+	return &dto.MetricFamily{
+		Name:   &name,
+		Type:   &typ,
+		Metric: []*dto.Metric{{Counter: &dto.Counter{Value: &v}}},
+	}
+}
+
+func TestBridgeCounterReset(t *testing.T) {
+	m := cmt.NewMetrics("fluentbit", "plugin")
+	g := &testGatherer{families: []*dto.MetricFamily{counterFamily("restarts_total", 5)}}
+	b := New(m, g)
+
+	assert.NoError(t, b.Scrape())
+	assert.Equal(t, 5.0, metrictest.Values(t, m)["restarts_total{}"])
+
+	// the counters restarting from zero add their whole value.
+	g.set([]*dto.MetricFamily{counterFamily("restarts_total", 2)}, nil)
+	assert.NoError(t, b.Scrape())
+	assert.Equal(t, 7.0, metrictest.Values(t, m)["restarts_total{}"])
+
+	// the metrics gathered along an error are copied.
+	g.set([]*dto.MetricFamily{counterFamily("restarts_total", 3)}, errors.New("collector failed"))
+	assert.EqualError(t, b.Scrape(), "collector failed")
+	assert.Equal(t, 8.0, metrictest.Values(t, m)["restarts_total{}"])
+}
+
+func TestBridgeStart(t *testing.T) {
+	m := cmt.NewMetrics("fluentbit", "plugin")
+	g := &testGatherer{err: errors.New("collector failed")}
+	b := New(m, g)
+
+	errs := make(chan error, 1)
+	b.OnError = func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+
+	b.Start(time.Millisecond)
+	assert.EqualError(t, <-errs, "collector failed")
+
+	g.set([]*dto.MetricFamily{counterFamily("restarts_total", 1)}, nil)
+
+	// the last values are copied on stop.
+	assert.NoError(t, b.Stop())
+	assert.Equal(t, 1.0, metrictest.Values(t, m)["restarts_total{}"])
+	assert.NoError(t, b.Stop())
+}
@@ -0,0 +1,133 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/calyptia/plugin/metric"
+	"github.com/calyptia/plugin/metric/cmt"
+)
+
+// metricsPrefix is the namespace and the subsystem prefixing the names of the metrics of
+// an instance, e.g. fluentbit_plugin_requests_total.
+type metricsPrefix struct {
+	namespace string
+	subsystem string
+}
+
+// defaultMetricsPrefix is the prefix of the metrics, it can be changed with
+// WithMetricsNamespace or the go.MetricsNamespace and go.MetricsSubsystem config keys.
+var defaultMetricsPrefix = metricsPrefix{namespace: "fluentbit", subsystem: "plugin"}
+
+// instanceLabelKey is the label key of the instance label added to the metrics, the one
+// of the metrics of the fluent-bit instances.
+const instanceLabelKey = "name"
+
+// WithMetricsNamespace sets the namespace and the subsystem prefixing the names of the
+// metrics of the plugin, fluentbit and plugin by default, instances can override them
+// with the go.MetricsNamespace and go.MetricsSubsystem config keys. An empty namespace or
+// subsystem is left out of the names. It panics with names other than letters, digits
+// and underscores.
+func WithMetricsNamespace(namespace, subsystem string) RegisterOption {
+	return func(r *registration) {
+		for _, s := range []string{namespace, subsystem} {
+			if s != "" && !validMetricName(s) {
+				panic(fmt.Sprintf("invalid metrics namespace %q: %q", s, r.name))
+			}
+		}
+
+		r.metricsPrefix = &metricsPrefix{namespace: namespace, subsystem: subsystem}
+	}
+}
+
+// WithInstanceLabel labels the metrics created by the plugin with the alias of their
+// instance, else its name, under the name key like the metrics of the fluent-bit
+// instances, so the instances of the plugin report their own series. The name key comes
+// first, the label values given to Add and Set still following the label keys of the
+// metric. The metrics declaring a name label key themselves are left as is, their values
+// giving it. Without it the metrics only have the label keys they declare.
+func WithInstanceLabel() RegisterOption {
+	return func(r *registration) {
+		r.instanceLabel = true
+	}
+}
+
+// pluginMetrics returns the metrics given to the plugin, labelled with the given label of
+// their instance when registered WithInstanceLabel.
+func (r *registration) pluginMetrics(m Metrics, label string) Metrics {
+	if !r.instanceLabel {
+		return m
+	}
+
+	return instanceMetrics{Metrics: m, label: label}
+}
+
+// metricsPrefixConfig reads the go.MetricsNamespace and go.MetricsSubsystem config keys.
+func metricsPrefixConfig(conf ConfigLoader, def *metricsPrefix) metricsPrefix {
+	p := defaultMetricsPrefix
+	if def != nil {
+		p = *def
+	}
+
+	if s := conf.String("go.MetricsNamespace"); s != "" {
+		if validMetricName(s) {
+			p.namespace = s
+		} else {
+			fmt.Fprintf(os.Stderr, "invalid go.MetricsNamespace %q, using %s\n", s, p.namespace)
+		}
+	}
+	if s := conf.String("go.MetricsSubsystem"); s != "" {
+		if validMetricName(s) {
+			p.subsystem = s
+		} else {
+			fmt.Fprintf(os.Stderr, "invalid go.MetricsSubsystem %q, using %s\n", s, p.subsystem)
+		}
+	}
+
+	return p
+}
+
+// validMetricName reports whether s is made of letters, digits and underscores, not
+// starting with a digit.
+func validMetricName(s string) bool {
+	for i, r := range s {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+// instanceMetrics adds the label of the instance to the metrics created by the plugins
+// and the runtime metrics, under the name key, unless they have a name label already.
+type instanceMetrics struct {
+	Metrics
+	label string
+}
+
+func (m instanceMetrics) Snapshot() ([]cmt.Metric, error) {
+	return SnapshotMetrics(m.Metrics)
+}
+
+func (m instanceMetrics) NewCounter(name, desc string, labelKeys ...string) metric.Counter {
+	if slices.Contains(labelKeys, instanceLabelKey) {
+		return m.Metrics.NewCounter(name, desc, labelKeys...)
+	}
+
+	keys := append([]string{instanceLabelKey}, labelKeys...)
+	return metric.BindCounter(m.Metrics.NewCounter(name, desc, keys...), m.label)
+}
+
+func (m instanceMetrics) NewGauge(name, desc string, labelKeys ...string) metric.Gauge {
+	if slices.Contains(labelKeys, instanceLabelKey) {
+		return m.Metrics.NewGauge(name, desc, labelKeys...)
+	}
+
+	keys := append([]string{instanceLabelKey}, labelKeys...)
+	return metric.BindGauge(m.Metrics.NewGauge(name, desc, keys...), m.label)
+}
@@ -0,0 +1,94 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/calyptia/plugin/metric/cmt"
+)
+
+func TestWithMetricsNamespace(t *testing.T) {
+	r := &registration{name: "dummy"}
+	WithMetricsNamespace("acme", "")(r)
+	assert.Equal(t, &metricsPrefix{namespace: "acme"}, r.metricsPrefix)
+
+	for _, name := range []string{"acme-corp", "1acme", "acme.logs"} {
+		assert.Panics(t, func() {
+			WithMetricsNamespace(name, "plugin")(r)
+		}, name)
+		assert.Panics(t, func() {
+			WithMetricsNamespace("acme", name)(r)
+		}, name)
+	}
+}
+
+func TestMetricsPrefixConfig(t *testing.T) {
+	assert.Equal(t, defaultMetricsPrefix, metricsPrefixConfig(testConfigLoader{}, nil))
+
+	def := &metricsPrefix{namespace: "acme", subsystem: "logs"}
+	assert.Equal(t, *def, metricsPrefixConfig(testConfigLoader{}, def))
+	assert.Equal(t, metricsPrefix{namespace: "acme", subsystem: "shipper"},
+		metricsPrefixConfig(testConfigLoader{"go.MetricsSubsystem": "shipper"}, def))
+	assert.Equal(t, metricsPrefix{namespace: "team_a", subsystem: "plugin"},
+		metricsPrefixConfig(testConfigLoader{"go.MetricsNamespace": "team_a"}, nil))
+	assert.Equal(t, defaultMetricsPrefix,
+		metricsPrefixConfig(testConfigLoader{"go.MetricsNamespace": "team-a", "go.MetricsSubsystem": "0"}, nil))
+}
+
+func TestInstanceMetrics(t *testing.T) {
+	metrics := &testNamedMetrics{values: map[string]float64{}}
+	m := instanceMetrics{Metrics: metrics, label: "my_alias"}
+
+	m.NewCounter("requests_total", "Number of requests", "status").Add(1, "200")
+	m.NewCounter("flushes_total", "Number of flushes").Add(2)
+	m.NewGauge("connections", "Number of connections").Set(3)
+
+	// the metrics with a name label get its value from the plugin.
+	m.NewGauge("queue_depth", "Depth of the queue", "name").Set(4, "other")
+
+	assert.Equal(t, map[string]float64{
+		"requests_total{my_alias,200}": 1,
+		"flushes_total{my_alias}":      2,
+		"connections{my_alias}":        3,
+		"queue_depth{other}":           4,
+	}, metrics.values)
+}
+
+func TestInstanceMetricsSnapshot(t *testing.T) {
+	var m Metrics = instanceMetrics{Metrics: cmt.NewMetrics("fluentbit", "plugin"), label: "my_alias"}
+	m.NewCounter("records_total", "Number of records", "tag").Add(3, "app")
+
+	got, err := SnapshotMetrics(m)
+	assert.NoError(t, err)
+
+	records, ok := cmt.Find(got, "fluentbit_plugin_records_total")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"name", "tag"}, records.Meta.Labels)
+	v, ok := records.Value("my_alias", "app")
+	assert.True(t, ok)
+	assert.Equal(t, 3.0, v)
+}
+
+func TestWithInstanceLabel(t *testing.T) {
+	metrics := &testNamedMetrics{values: map[string]float64{}}
+
+	// the metrics are given as is by default.
+	r := &registration{kind: inputKind, name: "test"}
+	r.pluginMetrics(metrics, "my_alias").NewCounter("requests_total", "Number of requests", "status").Add(1, "200")
+
+	WithInstanceLabel()(r)
+	r.pluginMetrics(metrics, "my_alias").NewCounter("records_total", "Number of records", "status").Add(2, "200")
+
+	assert.Equal(t, map[string]float64{
+		"requests_total{200}":         1,
+		"records_total{my_alias,200}": 2,
+	}, metrics.values)
+}
+
+func TestSnapshotMetrics(t *testing.T) {
+	// the metrics without Snapshot fail.
+	_, err := SnapshotMetrics(&testNamedMetrics{values: map[string]float64{}})
+	assert.Error(t, err)
+	_, err = SnapshotMetrics(instanceMetrics{Metrics: &testNamedMetrics{values: map[string]float64{}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,613 @@
+package plugin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+	"unsafe"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// recordReader decodes the records of a msgpack encoded chunk straight from its bytes,
+// skipping the reflection and the intermediate raw messages of the msgpack decoder.
+// The values are decoded into the types the msgpack decoder produces for map[string]any,
+// int8 for fixints, float32 for floats and so on, so plugins see the same records.
+type recordReader struct {
+	b   []byte
+	off int
+	// pooled makes the records reuse the maps of the record pool.
+	pooled bool
+	// keys interns the keys of the records, when set.
+	keys *keyCache
+	// lazy makes the messages decoded from the reader hold lazy records.
+	lazy bool
+}
+
+// Next decodes the next entry of the chunk, it returns io.EOF after the last one.
+func (r *recordReader) Next() (time.Time, map[string]any, error) {
+	n, ts, err := r.entry()
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	record, err := r.record()
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("msgpack unmarshal event record: %w", err)
+	}
+
+	if err := r.skipExtra(n); err != nil {
+		return time.Time{}, nil, err
+	}
+
+	return ts, record, nil
+}
+
+// entry decodes the header of the next entry up to its time, it returns the number of
+// elements of the entry.
+func (r *recordReader) entry() (int, time.Time, error) {
+	if r.off >= len(r.b) {
+		return 0, time.Time{}, io.EOF
+	}
+
+	c, err := r.code()
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("msgpack unmarshal: %w", err)
+	}
+
+	n, err := r.arrayLen(c)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("msgpack unmarshal: %w", err)
+	}
+
+	if n < 2 {
+		if err := r.skipN(n); err != nil {
+			return 0, time.Time{}, fmt.Errorf("msgpack unmarshal: %w", err)
+		}
+		return 0, time.Time{}, fmt.Errorf("msgpack unmarshal: expected 2 elements, got %d", n)
+	}
+
+	ts, err := r.eventTime()
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("msgpack unmarshal event time: %w", err)
+	}
+
+	return n, ts, nil
+}
+
+// skipExtra skips the elements of an entry past its record, entries are [time, record]
+// and extra elements are ignored.
+func (r *recordReader) skipExtra(n int) error {
+	if err := r.skipN(n - 2); err != nil {
+		return fmt.Errorf("msgpack unmarshal: %w", err)
+	}
+
+	return nil
+}
+
+// eventTime decodes the time of an entry, an EventTime extension.
+func (r *recordReader) eventTime() (time.Time, error) {
+	c, err := r.code()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	id, size, err := r.extHeader(c)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if id != 0 {
+		return time.Time{}, fmt.Errorf("unexpected ext id=%d", id)
+	}
+
+	return r.eventTimeBody(size)
+}
+
+func (r *recordReader) eventTimeBody(size int) (time.Time, error) {
+	if size != eventTimeBytesLen {
+		return time.Time{}, fmt.Errorf("invalid data length: got %d, wanted %d", size, eventTimeBytesLen)
+	}
+
+	b, err := r.read(size)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(int64(binary.BigEndian.Uint32(b)), int64(binary.BigEndian.Uint32(b[4:]))), nil
+}
+
+// record decodes the record of an entry, nil records are decoded to a nil map.
+func (r *recordReader) record() (map[string]any, error) {
+	c, err := r.code()
+	if err != nil {
+		return nil, err
+	}
+
+	if c == msgpcode.Nil {
+		return nil, nil
+	}
+
+	n, err := r.mapLen(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.pooled {
+		return r.fillMap(getRecord(min(n, len(r.b)-r.off)), n)
+	}
+
+	return r.mapOf(n)
+}
+
+func (r *recordReader) mapOf(n int) (map[string]any, error) {
+	return r.fillMap(make(map[string]any, min(n, len(r.b)-r.off)), n)
+}
+
+// fillMap decodes the n fields of a map into m.
+func (r *recordReader) fillMap(m map[string]any, n int) (map[string]any, error) {
+	if size, ok := r.stringFields(n); ok {
+		return r.fillStrings(m, n, size)
+	}
+
+	for i := 0; i < n; i++ {
+		c, err := r.code()
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := r.key(c)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+
+		m[k] = v
+	}
+
+	return m, nil
+}
+
+// stringFields reports whether the n fields of the map at the reader are all strings, the
+// common flat records, along with the size of their values.
+func (r *recordReader) stringFields(n int) (int, bool) {
+	s := recordReader{b: r.b, off: r.off}
+
+	size := 0
+	for i := 0; i < 2*n; i++ {
+		c, err := s.code()
+		if err != nil || !isStringCode(c) {
+			return 0, false
+		}
+
+		l, err := s.bytesLen(c)
+		if err != nil || l > len(s.b)-s.off {
+			return 0, false
+		}
+		s.off += l
+
+		// the odd fields are the values.
+		if i%2 == 1 {
+			size += l
+		}
+	}
+
+	return size, true
+}
+
+// fillStrings decodes the n string fields of a map into m, the values sharing a single
+// allocation of size bytes.
+func (r *recordReader) fillStrings(m map[string]any, n, size int) (map[string]any, error) {
+	buf := make([]byte, 0, size)
+	for i := 0; i < n; i++ {
+		c, err := r.code()
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := r.key(c)
+		if err != nil {
+			return nil, err
+		}
+
+		if c, err = r.code(); err != nil {
+			return nil, err
+		}
+
+		v, err := r.rawStr(c)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(v) == 0 {
+			m[k] = ""
+			continue
+		}
+
+		// buf never grows, so the bytes of the values are never moved nor written again.
+		buf = append(buf, v...)
+		m[k] = any(unsafe.String(&buf[len(buf)-len(v)], len(v)))
+	}
+
+	return m, nil
+}
+
+// isStringCode reports whether c starts a string.
+func isStringCode(c byte) bool {
+	return msgpcode.IsFixedString(c) || c == msgpcode.Str8 || c == msgpcode.Str16 || c == msgpcode.Str32
+}
+
+func (r *recordReader) sliceOf(n int) ([]any, error) {
+	s := make([]any, 0, min(n, len(r.b)-r.off))
+	for i := 0; i < n; i++ {
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, v)
+	}
+
+	return s, nil
+}
+
+// value decodes a value of a record.
+func (r *recordReader) value() (any, error) {
+	start := r.off
+
+	c, err := r.code()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case msgpcode.IsFixedNum(c):
+		return int8(c), nil
+	case msgpcode.IsFixedMap(c):
+		return r.mapOf(int(c & msgpcode.FixedMapMask))
+	case msgpcode.IsFixedArray(c):
+		return r.sliceOf(int(c & msgpcode.FixedArrayMask))
+	case msgpcode.IsFixedString(c):
+		return r.str(c)
+	}
+
+	switch c {
+	case msgpcode.Nil:
+		return nil, nil
+	case msgpcode.False:
+		return false, nil
+	case msgpcode.True:
+		return true, nil
+	case msgpcode.Str8, msgpcode.Str16, msgpcode.Str32:
+		return r.str(c)
+	case msgpcode.Bin8, msgpcode.Bin16, msgpcode.Bin32:
+		return r.bin(c)
+	case msgpcode.Array16, msgpcode.Array32:
+		n, err := r.arrayLen(c)
+		if err != nil {
+			return nil, err
+		}
+		return r.sliceOf(n)
+	case msgpcode.Map16, msgpcode.Map32:
+		n, err := r.mapLen(c)
+		if err != nil {
+			return nil, err
+		}
+		return r.mapOf(n)
+	case msgpcode.FixExt1, msgpcode.FixExt2, msgpcode.FixExt4, msgpcode.FixExt8, msgpcode.FixExt16,
+		msgpcode.Ext8, msgpcode.Ext16, msgpcode.Ext32:
+		return r.ext(c, start)
+	}
+
+	return r.number(c)
+}
+
+// number decodes the numbers with a type code, to their sized Go types.
+func (r *recordReader) number(c byte) (any, error) {
+	switch c {
+	case msgpcode.Float:
+		n, err := r.uint(4)
+		return math.Float32frombits(uint32(n)), err
+	case msgpcode.Double:
+		n, err := r.uint(8)
+		return math.Float64frombits(n), err
+	case msgpcode.Uint8:
+		n, err := r.uint(1)
+		return uint8(n), err
+	case msgpcode.Uint16:
+		n, err := r.uint(2)
+		return uint16(n), err
+	case msgpcode.Uint32:
+		n, err := r.uint(4)
+		return uint32(n), err
+	case msgpcode.Uint64:
+		return r.uint(8)
+	case msgpcode.Int8:
+		n, err := r.uint(1)
+		return int8(n), err
+	case msgpcode.Int16:
+		n, err := r.uint(2)
+		return int16(n), err
+	case msgpcode.Int32:
+		n, err := r.uint(4)
+		return int32(n), err
+	case msgpcode.Int64:
+		n, err := r.uint(8)
+		return int64(n), err
+	}
+
+	return nil, fmt.Errorf("msgpack: unknown code %x decoding interface{}", c)
+}
+
+// ext decodes an extension value, event times are decoded to *EventTime, the others by
+// the msgpack decoder with the extensions registered by the plugin.
+func (r *recordReader) ext(c byte, start int) (any, error) {
+	id, size, err := r.extHeader(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if id == 0 {
+		ts, err := r.eventTimeBody(size)
+		if err != nil {
+			return nil, err
+		}
+		return &EventTime{Time: ts}, nil
+	}
+
+	if _, err := r.read(size); err != nil {
+		return nil, err
+	}
+
+	var v any
+	if err := msgpack.Unmarshal(r.b[start:r.off], &v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (r *recordReader) str(c byte) (string, error) {
+	b, err := r.rawStr(c)
+	if err != nil || len(b) == 0 {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// key decodes the key of a map field, interned in the key cache.
+func (r *recordReader) key(c byte) (string, error) {
+	b, err := r.rawStr(c)
+	if err != nil || len(b) == 0 {
+		return "", err
+	}
+
+	return r.keys.intern(b), nil
+}
+
+// rawStr returns the bytes of a string, referencing the chunk.
+func (r *recordReader) rawStr(c byte) ([]byte, error) {
+	n, err := r.bytesLen(c)
+	if err != nil || n <= 0 {
+		return nil, err
+	}
+
+	return r.read(n)
+}
+
+func (r *recordReader) bin(c byte) ([]byte, error) {
+	n, err := r.bytesLen(c)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := r.read(n)
+	if err != nil {
+		return nil, err
+	}
+
+	// the chunk is owned by fluent-bit, values outlive it.
+	return append(make([]byte, 0, n), b...), nil
+}
+
+// bytesLen decodes the length of strings and binaries, nil is decoded as empty.
+func (r *recordReader) bytesLen(c byte) (int, error) {
+	if msgpcode.IsFixedString(c) {
+		return int(c & msgpcode.FixedStrMask), nil
+	}
+
+	switch c {
+	case msgpcode.Nil:
+		return 0, nil
+	case msgpcode.Str8, msgpcode.Bin8:
+		n, err := r.uint(1)
+		return int(n), err
+	case msgpcode.Str16, msgpcode.Bin16:
+		n, err := r.uint(2)
+		return int(n), err
+	case msgpcode.Str32, msgpcode.Bin32:
+		n, err := r.uint(4)
+		return int(n), err
+	}
+
+	return 0, fmt.Errorf("msgpack: invalid code=%x decoding string/bytes length", c)
+}
+
+// arrayLen decodes the length of an array, nil is decoded as empty.
+func (r *recordReader) arrayLen(c byte) (int, error) {
+	if msgpcode.IsFixedArray(c) {
+		return int(c & msgpcode.FixedArrayMask), nil
+	}
+
+	switch c {
+	case msgpcode.Nil:
+		return 0, nil
+	case msgpcode.Array16:
+		n, err := r.uint(2)
+		return int(n), err
+	case msgpcode.Array32:
+		n, err := r.uint(4)
+		return int(n), err
+	}
+
+	return 0, fmt.Errorf("msgpack: invalid code=%x decoding array length", c)
+}
+
+func (r *recordReader) mapLen(c byte) (int, error) {
+	if msgpcode.IsFixedMap(c) {
+		return int(c & msgpcode.FixedMapMask), nil
+	}
+
+	switch c {
+	case msgpcode.Map16:
+		n, err := r.uint(2)
+		return int(n), err
+	case msgpcode.Map32:
+		n, err := r.uint(4)
+		return int(n), err
+	}
+
+	return 0, fmt.Errorf("msgpack: invalid code=%x decoding map length", c)
+}
+
+// extHeader decodes the id and the length of an extension.
+func (r *recordReader) extHeader(c byte) (int8, int, error) {
+	var size int
+	switch c {
+	case msgpcode.FixExt1:
+		size = 1
+	case msgpcode.FixExt2:
+		size = 2
+	case msgpcode.FixExt4:
+		size = 4
+	case msgpcode.FixExt8:
+		size = 8
+	case msgpcode.FixExt16:
+		size = 16
+	case msgpcode.Ext8, msgpcode.Ext16, msgpcode.Ext32:
+		n, err := r.uint(1 << int(c-msgpcode.Ext8))
+		if err != nil {
+			return 0, 0, err
+		}
+		size = int(n)
+	default:
+		return 0, 0, fmt.Errorf("msgpack: invalid code=%x decoding ext", c)
+	}
+
+	id, err := r.code()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int8(id), size, nil
+}
+
+// skipN skips n values.
+func (r *recordReader) skipN(n int) error {
+	for i := 0; i < n; i++ {
+		if err := r.skip(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *recordReader) skip() error {
+	c, err := r.code()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case msgpcode.IsFixedNum(c), c == msgpcode.Nil, c == msgpcode.False, c == msgpcode.True:
+		return nil
+	case msgpcode.IsFixedMap(c):
+		return r.skipN(2 * int(c&msgpcode.FixedMapMask))
+	case msgpcode.IsFixedArray(c):
+		return r.skipN(int(c & msgpcode.FixedArrayMask))
+	}
+
+	switch c {
+	case msgpcode.Uint8, msgpcode.Int8:
+		_, err = r.read(1)
+	case msgpcode.Uint16, msgpcode.Int16:
+		_, err = r.read(2)
+	case msgpcode.Uint32, msgpcode.Int32, msgpcode.Float:
+		_, err = r.read(4)
+	case msgpcode.Uint64, msgpcode.Int64, msgpcode.Double:
+		_, err = r.read(8)
+	case msgpcode.Array16, msgpcode.Array32:
+		n, err := r.arrayLen(c)
+		if err != nil {
+			return err
+		}
+		return r.skipN(n)
+	case msgpcode.Map16, msgpcode.Map32:
+		n, err := r.mapLen(c)
+		if err != nil {
+			return err
+		}
+		return r.skipN(2 * n)
+	case msgpcode.FixExt1, msgpcode.FixExt2, msgpcode.FixExt4, msgpcode.FixExt8, msgpcode.FixExt16,
+		msgpcode.Ext8, msgpcode.Ext16, msgpcode.Ext32:
+		_, size, err := r.extHeader(c)
+		if err != nil {
+			return err
+		}
+		_, err = r.read(size)
+		return err
+	default:
+		var n int
+		if n, err = r.bytesLen(c); err == nil {
+			_, err = r.read(n)
+		}
+	}
+
+	return err
+}
+
+func (r *recordReader) code() (byte, error) {
+	if r.off >= len(r.b) {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	c := r.b[r.off]
+	r.off++
+
+	return c, nil
+}
+
+// uint reads a big endian unsigned integer of the given size.
+func (r *recordReader) uint(size int) (uint64, error) {
+	b, err := r.read(size)
+	if err != nil {
+		return 0, err
+	}
+
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+
+	return n, nil
+}
+
+// read returns the next n bytes, they are only valid until the chunk is released.
+func (r *recordReader) read(n int) ([]byte, error) {
+	if n < 0 || n > len(r.b)-r.off {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	b := r.b[r.off : r.off+n]
+	r.off += n
+
+	return b, nil
+}
@@ -0,0 +1,307 @@
+package plugin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// decodeMsg decodes a record with the msgpack decoder, the tests use it as the reference
+// of the record reader. It should be called with an already initialized decoder.
+func decodeMsg(dec *msgpack.Decoder, tag string) (Message, error) {
+	var out Message
+
+	var entry []msgpack.RawMessage
+	err := dec.Decode(&entry)
+	if errors.Is(err, io.EOF) {
+		return out, err
+	}
+
+	if err != nil {
+		return out, fmt.Errorf("msgpack unmarshal: %w", err)
+	}
+
+	if l := len(entry); l < 2 {
+		return out, fmt.Errorf("msgpack unmarshal: expected 2 elements, got %d", l)
+	}
+
+	eventTime := &EventTime{}
+	if err := msgpack.Unmarshal(entry[0], &eventTime); err != nil {
+		return out, fmt.Errorf("msgpack unmarshal event time: %w", err)
+	}
+
+	var record map[string]any
+	if err := msgpack.Unmarshal(entry[1], &record); err != nil {
+		return out, fmt.Errorf("msgpack unmarshal event record: %w", err)
+	}
+
+	out.Time = eventTime.Time.UTC()
+	out.Record = record
+	out.tag = &tag
+
+	return out, nil
+}
+
+func testRecordEntries(t testing.TB) [][]any {
+	t.Helper()
+
+	now := time.Unix(1700000000, 123456789)
+	return [][]any{
+		{&EventTime{now}, map[string]any{"message": "hello", "level": "info"}},
+		{&EventTime{now}, map[string]any{
+			"fixint":  1,
+			"negint":  -5,
+			"int8":    int8(-100),
+			"int16":   int16(-30000),
+			"int32":   int32(-2000000000),
+			"int64":   int64(math.MinInt64),
+			"uint8":   uint8(200),
+			"uint16":  uint16(60000),
+			"uint32":  uint32(4000000000),
+			"uint64":  uint64(math.MaxUint64),
+			"float32": float32(1.5),
+			"float64": 2.25,
+			"true":    true,
+			"false":   false,
+			"nil":     nil,
+		}},
+		{&EventTime{now}, map[string]any{
+			"bin":    []byte{0, 1, 2},
+			"empty":  []byte{},
+			"str16":  strings.Repeat("a", 300),
+			"array":  []any{1, "two", 3.0, []any{}, map[string]any{"k": "v"}},
+			"nested": map[string]any{"a": map[string]any{"b": []any{nil, true}}},
+			"time":   &EventTime{now},
+			"ts":     now,
+		}},
+		{&EventTime{now}, testBigRecord(20)},
+		{&EventTime{now}, map[string]any{}, "extra"},
+	}
+}
+
+func testBigRecord(n int) map[string]any {
+	m := map[string]any{}
+	for i := 0; i < n; i++ {
+		m[fmt.Sprintf("key%d", i)] = fmt.Sprintf("value %d", i)
+	}
+	return m
+}
+
+func testEncodeEntries(t testing.TB, entries [][]any) []byte {
+	t.Helper()
+
+	var b []byte
+	for _, entry := range entries {
+		rec, err := msgpack.Marshal(entry)
+		assert.NoError(t, err)
+		b = append(b, rec...)
+	}
+
+	return b
+}
+
+// testDecodeAll decodes all the records of a chunk with both decoders.
+func testDecodeAll(b []byte) (want, got []Message, wantErr, gotErr error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	for {
+		msg, err := decodeMsg(dec, "tag")
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			wantErr = err
+			break
+		}
+		want = append(want, msg)
+	}
+
+	tag := "tag"
+	rd := MsgpackCodec{}.Decode(b)
+	for {
+		msg, err := decodeMessage(rd, &tag)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			gotErr = err
+			break
+		}
+		got = append(got, msg)
+	}
+
+	return want, got, wantErr, gotErr
+}
+
+func TestRecordReader(t *testing.T) {
+	b := testEncodeEntries(t, testRecordEntries(t))
+
+	want, got, wantErr, gotErr := testDecodeAll(b)
+	assert.NoError(t, wantErr)
+	assert.NoError(t, gotErr)
+	assert.Equal(t, 5, len(got))
+	assert.Equal(t, want, got)
+
+	for _, msg := range got {
+		assert.Equal(t, "tag", msg.Tag())
+		assert.Equal(t, time.UTC, msg.Time.Location())
+	}
+}
+
+func TestRecordReaderNilRecord(t *testing.T) {
+	b := testEncodeEntries(t, [][]any{{&EventTime{time.Now()}, nil}})
+
+	_, record, err := MsgpackCodec{}.Decode(b).Next()
+	assert.NoError(t, err)
+	assert.Equal(t, nil, record)
+}
+
+func TestRecordReaderErrors(t *testing.T) {
+	now := &EventTime{time.Now()}
+
+	tt := []struct {
+		name  string
+		entry []any
+	}{
+		{name: "short", entry: []any{now}},
+		{name: "integer time", entry: []any{1700000000, map[string]any{}}},
+		{name: "string time", entry: []any{"now", map[string]any{}}},
+		{name: "array record", entry: []any{now, []any{1}}},
+		{name: "integer key", entry: []any{now, map[int]any{1: "one"}}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			b := testEncodeEntries(t, [][]any{tc.entry})
+
+			_, _, wantErr, gotErr := testDecodeAll(b)
+			assert.Error(t, wantErr)
+			assert.Error(t, gotErr)
+		})
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		b := testEncodeEntries(t, testRecordEntries(t))
+
+		for i := 1; i < len(b); i++ {
+			_, got, _, gotErr := testDecodeAll(b[:i])
+			if gotErr == nil {
+				// the chunk got cut at the end of a record.
+				assert.NotZero(t, len(got))
+				continue
+			}
+			assert.IsError(t, gotErr, io.ErrUnexpectedEOF)
+		}
+	})
+}
+
+func TestRecordReaderChunkLifetime(t *testing.T) {
+	b := testEncodeEntries(t, [][]any{{&EventTime{time.Now()}, map[string]any{"msg": "hello", "bin": []byte("raw")}}})
+
+	_, record, err := MsgpackCodec{}.Decode(b).Next()
+	assert.NoError(t, err)
+
+	// fluent-bit releases the chunk once flushed, records must not point into it.
+	clear(b)
+	assert.Equal(t, any("hello"), record["msg"])
+	assert.Equal(t, any([]byte("raw")), record["bin"])
+}
+
+func TestRecordReaderStringRecords(t *testing.T) {
+	now := &EventTime{time.Now()}
+	b := testEncodeEntries(t, [][]any{
+		{now, map[string]string{"log": "hello", "stream": "stdout", "empty": ""}},
+		{now, map[string]any{"log": strings.Repeat("x", 300), "long": strings.Repeat("y", 70000)}},
+		{now, map[string]any{"log": "nested", "labels": map[string]string{"app": "web", "tier": "frontend"}}},
+		{now, map[string]any{"log": "mixed", "status": 200}},
+		{now, map[string]string{}},
+	})
+
+	want, got, wantErr, gotErr := testDecodeAll(b)
+	assert.NoError(t, wantErr)
+	assert.NoError(t, gotErr)
+	assert.Equal(t, want, got)
+
+	// the values of the flat string records are strings like any other.
+	record := got[0].Record.(map[string]any)
+	v, ok := record["log"].(string)
+	assert.True(t, ok)
+	assert.Equal(t, "hello", v)
+	assert.True(t, record["stream"] == any("stdout"))
+	assert.Equal(t, "stdout", fmt.Sprint(record["stream"]))
+	assert.Equal(t, any(""), record["empty"])
+
+	// fluent-bit releases the chunk once flushed, records must not point into it.
+	clear(b)
+	assert.Equal(t, any("hello"), record["log"])
+	assert.Equal(t, any("web"), got[2].Record.(map[string]any)["labels"].(map[string]any)["app"])
+
+	// truncated string records fail like the others.
+	b = testEncodeEntries(t, [][]any{{now, map[string]string{"log": "hello", "stream": "stdout"}}})
+	for i := 1; i < len(b); i++ {
+		_, _, _, gotErr := testDecodeAll(b[:i])
+		assert.IsError(t, gotErr, io.ErrUnexpectedEOF)
+	}
+}
+
+func benchmarkChunk(b *testing.B, records int) []byte {
+	b.Helper()
+
+	now := time.Now()
+	entries := make([][]any, 0, records)
+	for i := 0; i < records; i++ {
+		entries = append(entries, []any{&EventTime{now}, map[string]any{
+			"log":        fmt.Sprintf("127.0.0.1 - - GET /index.html?id=%d HTTP/1.1 200 512", i),
+			"stream":     "stdout",
+			"status":     200,
+			"bytes":      512,
+			"latency_ms": 1.25,
+			"kubernetes": map[string]any{
+				"pod_name":       "web-5d9c7b7f6d-x2x9z",
+				"namespace_name": "default",
+				"labels":         map[string]any{"app": "web", "tier": "frontend"},
+			},
+		}})
+	}
+
+	return testEncodeEntries(b, entries)
+}
+
+func BenchmarkDecodeChunk(b *testing.B) {
+	for _, records := range []int{1, 100} {
+		chunk := benchmarkChunk(b, records)
+
+		b.Run(fmt.Sprintf("msgpack/%d", records), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(chunk)))
+			for i := 0; i < b.N; i++ {
+				dec := msgpack.NewDecoder(bytes.NewReader(chunk))
+				for {
+					if _, err := decodeMsg(dec, "tag"); err != nil {
+						break
+					}
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("reader/%d", records), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(chunk)))
+			for i := 0; i < b.N; i++ {
+				rd := MsgpackCodec{}.Decode(chunk)
+				for {
+					if _, _, err := rd.Next(); err != nil {
+						break
+					}
+				}
+			}
+		})
+	}
+}
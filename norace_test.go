@@ -0,0 +1,6 @@
+//go:build !race
+
+package plugin
+
+// raceEnabled reports that the tests run under the race detector.
+const raceEnabled = false
@@ -90,30 +90,29 @@ func GetRecord(dec *FLBDecoder) (ret int, ts interface{}, rec map[interface{}]in
 		return -1, 0, nil
 	}
 
-	slice := reflect.ValueOf(m)
-	if slice.Kind() != reflect.Slice || slice.Len() != 2 {
+	// the decoder decodes the arrays as []interface{}, asserted rather than reflected.
+	slice, ok := m.([]interface{})
+	if !ok || len(slice) != 2 {
 		return -2, 0, nil
 	}
 
 	var t interface{}
-	ts = slice.Index(0).Interface()
+	ts = slice[0]
 	switch ty := ts.(type) {
 	case FLBTime:
 		t = ty
 	case uint64:
 		t = ty
 	case []interface{}: // for Fluent Bit V2 metadata type of format
-		s := reflect.ValueOf(ty)
-		if s.Kind() != reflect.Slice || s.Len() < 2 {
+		if len(ty) < 2 {
 			return -4, 0, nil
 		}
-		t = s.Index(0).Interface()
+		t = ty[0]
 	default:
 		return -5, 0, nil
 	}
-	data := slice.Index(1)
 
-	md, _ := data.Interface().(map[any]any)
+	md, _ := slice[1].(map[any]any)
 
 	return 0, t, md
 }
@@ -38,12 +38,18 @@ struct flb_plugin_proxy_context {
  * It matches the one in proxy/go/go.c in fluent-bit source code.
  */
 struct flbgo_output_plugin {
-    void *_;
+    char *name;
     struct flb_api *api;
     struct flb_output_instance *o_ins;
     struct flb_plugin_proxy_context *context;
 };
 
+char *output_get_plugin_name(void *plugin)
+{
+    struct flbgo_output_plugin *p = plugin;
+    return p->name;
+}
+
 char *output_get_property(char *key, void *plugin)
 {
     struct flbgo_output_plugin *p = plugin;
@@ -56,6 +62,12 @@ void *output_get_cmt_instance(void *plugin)
     return p->api->output_get_cmt_instance(p->o_ins);
 }
 
+int output_log_check(void *plugin, int log_level)
+{
+    struct flbgo_output_plugin *p = plugin;
+    return p->api->output_log_check(p->o_ins, log_level);
+}
+
 void output_log_print_novar(void *plugin, int log_level, const char* message)
 {
     struct flbgo_output_plugin *p = plugin;
@@ -29,11 +29,17 @@
 #define FLB_PROXY_OUTPUT_PLUGIN    2
 #define FLB_PROXY_GOLANG          11
 
+/* Output event types */
+#define FLB_OUTPUT_LOGS        1
+#define FLB_OUTPUT_METRICS     2
+#define FLB_OUTPUT_TRACES      4
+
 /* Message Types */
 #define FLB_LOG_ERROR   1
 #define FLB_LOG_WARN    2
 #define FLB_LOG_INFO    3  /* default */
 #define FLB_LOG_DEBUG   4
+#define FLB_LOG_TRACE   5
 
 /* This structure is used for registration.
  * It matches the one in flb_plugin_proxy.h in fluent-bit source code.
@@ -44,6 +50,7 @@ struct flb_plugin_proxy_def {
     int flags;
     char *name;
     char *description;
+    int event_type;
 };
 
 #endif
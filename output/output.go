@@ -19,6 +19,7 @@ package output
 
 /*
 #include <stdlib.h>
+#include <pthread.h>
 #include "flb_plugin.h"
 #include "flb_output.h"
 */
@@ -40,10 +41,15 @@ const (
 	FLB_PROXY_OUTPUT_PLUGIN = C.FLB_PROXY_OUTPUT_PLUGIN
 	FLB_PROXY_GOLANG        = C.FLB_PROXY_GOLANG
 
+	FLB_OUTPUT_LOGS    = C.FLB_OUTPUT_LOGS
+	FLB_OUTPUT_METRICS = C.FLB_OUTPUT_METRICS
+	FLB_OUTPUT_TRACES  = C.FLB_OUTPUT_TRACES
+
 	FLB_LOG_ERROR = C.FLB_LOG_ERROR
 	FLB_LOG_WARN  = C.FLB_LOG_WARN
 	FLB_LOG_INFO  = C.FLB_LOG_INFO
 	FLB_LOG_DEBUG = C.FLB_LOG_DEBUG
+	FLB_LOG_TRACE = C.FLB_LOG_TRACE
 )
 
 type (
@@ -66,6 +72,13 @@ func FLBPluginRegister(def unsafe.Pointer, name, desc string) int {
 	return 0
 }
 
+// FLBPluginSetEventType sets the event types (logs, metrics, traces) the plugin accepts,
+// fluent-bit defaults to logs when it is not set.
+func FLBPluginSetEventType(def unsafe.Pointer, eventType int) {
+	p := (*FLBPluginProxyDef)(def)
+	p.event_type = C.int(eventType)
+}
+
 // FLBPluginUnregister release resources allocated by the plugin initialization
 func FLBPluginUnregister(def unsafe.Pointer) {
 	p := (*FLBPluginProxyDef)(def)
@@ -73,6 +86,11 @@ func FLBPluginUnregister(def unsafe.Pointer) {
 	C.free(unsafe.Pointer(p.description))
 }
 
+// FLBPluginName returns the name of the plugin the instance was created from.
+func FLBPluginName(plugin unsafe.Pointer) string {
+	return C.GoString(C.output_get_plugin_name(plugin))
+}
+
 func FLBPluginConfigKey(plugin unsafe.Pointer, key string) string {
 	_key := C.CString(key)
 	value := C.GoString(C.output_get_property(_key, plugin))
@@ -102,11 +120,40 @@ func FLBPluginGetContext(proxyCtx unsafe.Pointer) interface{} {
 	return v
 }
 
+// FLBPluginInstanceContext reads the context set for plugin with FLBPluginSetContext.
+func FLBPluginInstanceContext(plugin unsafe.Pointer) interface{} {
+	p := (*FLBOutPlugin)(plugin)
+	if p.context == nil {
+		return nil
+	}
+
+	return FLBPluginGetContext(p.context.remote_context)
+}
+
+// FLBPluginDeleteContext releases the context associated with proxyCtx.
+func FLBPluginDeleteContext(proxyCtx unsafe.Pointer) {
+	if _, ok := contexts.LoadAndDelete(uintptr(proxyCtx)); ok {
+		C.free(proxyCtx)
+	}
+}
+
+// FLBPluginThreadID returns an identifier of the thread invoking the callback,
+// fluent-bit runs each output worker in its own thread.
+func FLBPluginThreadID() uint64 {
+	return uint64(C.pthread_self())
+}
+
 func FLBPluginGetCMetricsContext(plugin unsafe.Pointer) (*cmetrics.Context, error) {
 	cmt := C.output_get_cmt_instance(plugin)
 	return cmetrics.NewContextFromCMTPointer(cmt)
 }
 
+// FLBPluginLogCheck reports whether the messages of the given level are logged by the
+// instance, according to its log_level.
+func FLBPluginLogCheck(plugin unsafe.Pointer, logLevel int) bool {
+	return C.output_log_check(plugin, C.int(logLevel)) != 0
+}
+
 func FLBPluginLogPrint(plugin unsafe.Pointer, log_level C.int, message string) {
 	_message := C.CString(message)
 	C.output_log_print_novar(plugin, log_level, _message)
@@ -0,0 +1,101 @@
+package output
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// RecordMap converts a record returned by GetRecord to a map of string keys, with the
+// strings, decoded as []byte, converted to strings, in the nested maps and arrays too.
+// It replaces the per-key type switch plugins write over the records: the bytes of the
+// strings are copied into buffers shared by the strings of the record, rather than one
+// allocation per string, so keeping any of them keeps the bytes of its neighbours.
+func RecordMap(rec map[any]any) map[string]any {
+	if rec == nil {
+		return nil
+	}
+
+	var c converter
+	return c.record(rec)
+}
+
+// RecordMapUnsafe is RecordMap without copying the bytes of the strings: the strings
+// reference the []byte values of the record, which must not be modified afterwards.
+// The record holds its own copy of the chunk, so the strings outlive the flush.
+func RecordMapUnsafe(rec map[any]any) map[string]any {
+	if rec == nil {
+		return nil
+	}
+
+	c := converter{zeroCopy: true}
+	return c.record(rec)
+}
+
+// minStringsBuf is the size of the buffers the bytes of the strings are copied to.
+const minStringsBuf = 256
+
+// converter converts the records decoded by GetRecord.
+type converter struct {
+	zeroCopy bool
+	// buf holds the bytes of the strings when copied. It is replaced rather than grown
+	// once full, so its bytes are never moved nor written once referenced by a string.
+	buf []byte
+}
+
+func (c *converter) record(rec map[any]any) map[string]any {
+	m := make(map[string]any, len(rec))
+	for k, v := range rec {
+		m[c.key(k)] = c.value(v)
+	}
+
+	return m
+}
+
+func (c *converter) slice(s []any) []any {
+	out := make([]any, len(s))
+	for i, v := range s {
+		out[i] = c.value(v)
+	}
+
+	return out
+}
+
+// key converts a key, the decoder decoding the string keys as strings.
+func (c *converter) key(k any) string {
+	if s, ok := k.(string); ok {
+		return s
+	}
+
+	return fmt.Sprint(k)
+}
+
+// value converts a value, the most common ones first.
+func (c *converter) value(v any) any {
+	switch v := v.(type) {
+	case []byte:
+		return c.str(v)
+	case map[any]any:
+		return c.record(v)
+	case []any:
+		return c.slice(v)
+	}
+
+	return v
+}
+
+func (c *converter) str(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+
+	if c.zeroCopy {
+		return unsafe.String(&b[0], len(b))
+	}
+
+	if cap(c.buf)-len(c.buf) < len(b) {
+		c.buf = make([]byte, 0, max(len(b), minStringsBuf))
+	}
+
+	c.buf = append(c.buf, b...)
+	return unsafe.String(&c.buf[len(c.buf)-len(b)], len(b))
+}
@@ -0,0 +1,142 @@
+package output
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// testRecord returns a record of a kubernetes log, as decoded by GetRecord.
+func testRecord(tb testing.TB) map[any]any {
+	tb.Helper()
+
+	b, err := msgpack.Marshal([]any{uint64(1588140000), map[string]any{
+		"log":    "2024-01-10T10:30:00Z INFO request served in 12ms",
+		"stream": "stdout",
+		"time":   "2024-01-10T10:30:00.123456789Z",
+		"status": 200,
+		"kubernetes": map[string]any{
+			"pod_name":       "web-7d4b9c6f5-x2x9z",
+			"namespace_name": "default",
+			"container_name": "web",
+			"labels":         map[string]any{"app": "web", "tier": "frontend"},
+		},
+		"tags":  []any{"http", "web", 3},
+		"empty": "",
+	}})
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	ret, _, rec := GetRecord(NewByteDecoder(b))
+	if ret != 0 {
+		tb.Fatalf("GetRecord returned %d", ret)
+	}
+
+	return rec
+}
+
+func TestRecordMap(t *testing.T) {
+	want := map[string]any{
+		"log":    "2024-01-10T10:30:00Z INFO request served in 12ms",
+		"stream": "stdout",
+		"time":   "2024-01-10T10:30:00.123456789Z",
+		"status": uint64(200),
+		"kubernetes": map[string]any{
+			"pod_name":       "web-7d4b9c6f5-x2x9z",
+			"namespace_name": "default",
+			"container_name": "web",
+			"labels":         map[string]any{"app": "web", "tier": "frontend"},
+		},
+		"tags":  []any{"http", "web", int64(3)},
+		"empty": "",
+	}
+
+	rec := testRecord(t)
+	if got := RecordMap(rec); !reflect.DeepEqual(want, got) {
+		t.Errorf("RecordMap() = %v, want %v", got, want)
+	}
+
+	// the strings are copied, unlike the ones of RecordMapUnsafe.
+	got, unsafeGot := RecordMap(rec), RecordMapUnsafe(rec)
+	if !reflect.DeepEqual(want, unsafeGot) {
+		t.Errorf("RecordMapUnsafe() = %v, want %v", unsafeGot, want)
+	}
+
+	b := rec["stream"].([]byte)
+	copy(b, "STDOUT")
+	if got["stream"] != "stdout" {
+		t.Errorf(`RecordMap()["stream"] = %q, want "stdout"`, got["stream"])
+	}
+	if unsafeGot["stream"] != "STDOUT" {
+		t.Errorf(`RecordMapUnsafe()["stream"] = %q, want "STDOUT"`, unsafeGot["stream"])
+	}
+
+	if RecordMap(nil) != nil || RecordMapUnsafe(nil) != nil {
+		t.Error("nil records converted to non nil maps")
+	}
+}
+
+func TestRecordMapAllocs(t *testing.T) {
+	rec := testRecord(t)
+
+	// the maps and the slice, plus one allocation per boxed string and, unless
+	// zero-copy, one for their bytes.
+	allocs := testing.AllocsPerRun(100, func() { RecordMap(rec) })
+	if allocs > 19 {
+		t.Errorf("RecordMap: %v allocations, want at most 19", allocs)
+	}
+
+	allocs = testing.AllocsPerRun(100, func() { RecordMapUnsafe(rec) })
+	if allocs > 18 {
+		t.Errorf("RecordMapUnsafe: %v allocations, want at most 18", allocs)
+	}
+}
+
+// typeSwitchRecord is the conversion plugins commonly write over the records, the
+// baseline of the benchmarks.
+func typeSwitchRecord(rec map[any]any) map[string]any {
+	m := make(map[string]any)
+	for k, v := range rec {
+		switch t := v.(type) {
+		case []byte:
+			m[k.(string)] = string(t)
+		case map[any]any:
+			m[k.(string)] = typeSwitchRecord(t)
+		case []any:
+			s := make([]any, 0, len(t))
+			for _, v := range t {
+				if b, ok := v.([]byte); ok {
+					v = string(b)
+				}
+				s = append(s, v)
+			}
+			m[k.(string)] = s
+		default:
+			m[k.(string)] = v
+		}
+	}
+
+	return m
+}
+
+func BenchmarkRecordMap(b *testing.B) {
+	rec := testRecord(b)
+
+	for _, bench := range []struct {
+		name    string
+		convert func(map[any]any) map[string]any
+	}{
+		{name: "type-switch", convert: typeSwitchRecord},
+		{name: "copy", convert: RecordMap},
+		{name: "zero-copy", convert: RecordMapUnsafe},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bench.convert(rec)
+			}
+		})
+	}
+}
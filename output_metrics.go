@@ -0,0 +1,119 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+
+	"github.com/calyptia/plugin/metric/cmt"
+)
+
+// MetricsOutputPlugin interface to represent an output fluent-bit plugin
+// receiving metrics events, decoded from cmetrics payloads.
+// Plugins registered with RegisterOutput or RegisterChunkOutput can implement
+// FlushMetrics as well to receive both the logs and the metrics routed to them.
+// Returning ErrRetry makes fluent-bit retry the metrics later.
+type MetricsOutputPlugin interface {
+	Init(ctx context.Context, fbit *Fluentbit) error
+	FlushMetrics(ctx context.Context, tag string, metrics []*cmt.Context) error
+}
+
+// RegisterMetricsOutput plugin.
+// A single output plugin can be registered per shared library.
+func RegisterMetricsOutput(name, desc string, out MetricsOutputPlugin, opts ...RegisterOption) {
+	register(&registration{
+		kind:          outputKind,
+		name:          name,
+		desc:          desc,
+		metricsOutput: out,
+	}, opts...)
+}
+
+// OutputEvents are the events, besides the logs, handled by the values of an output
+// factory, see WithOutputEvents.
+type OutputEvents int
+
+const (
+	// MetricsEvents are handled by values implementing MetricsOutputPlugin.
+	MetricsEvents OutputEvents = 1 << iota
+	// TracesEvents are handled by values implementing TracesOutputPlugin.
+	TracesEvents
+)
+
+// WithOutputEvents declares the events the values of an output registered with
+// RegisterOutputFactory handle besides the logs, so fluent-bit routes them to the
+// output, as no value exists yet when the output is registered. The events of the
+// outputs registered with a value are read from the interfaces it implements. It
+// panics when used to register other kinds of plugins.
+//
+//	plugin.RegisterOutputFactory("my-output", "", newOutput, plugin.WithOutputEvents(plugin.MetricsEvents))
+func WithOutputEvents(events OutputEvents) RegisterOption {
+	return func(r *registration) {
+		if r.kind != outputKind {
+			panic(fmt.Sprintf("output events set on %s plugin: %q", r.kind, r.name))
+		}
+
+		r.outputEvents = events
+	}
+}
+
+// eventType is the type of the events contained in a flushed payload.
+type eventType int
+
+const (
+	logsEvent eventType = iota
+	metricsEvent
+	tracesEvent
+)
+
+// payloadEventType sniffs the type of the events contained in a payload,
+// log entries are encoded as msgpack arrays while metrics and traces
+// contexts are maps, told apart by their metrics and resourceSpans keys.
+func payloadEventType(b []byte) eventType {
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	code, err := dec.PeekCode()
+	if err != nil {
+		return logsEvent
+	}
+
+	if !msgpcode.IsFixedMap(code) && code != msgpcode.Map16 && code != msgpcode.Map32 {
+		return logsEvent
+	}
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return metricsEvent
+	}
+
+	for i := 0; i < n; i++ {
+		key, err := dec.DecodeString()
+		if err != nil {
+			break
+		}
+
+		switch key {
+		case "metrics":
+			return metricsEvent
+		case "resourceSpans":
+			return tracesEvent
+		}
+
+		if err := dec.Skip(); err != nil {
+			break
+		}
+	}
+
+	return metricsEvent
+}
+
+func (o *outputInstance) pluginFlushMetrics(ctx context.Context, tag string, b []byte) error {
+	metrics, err := cmt.Decode(b)
+	if err != nil {
+		return err
+	}
+
+	return o.metricsOutput.FlushMetrics(ctx, tag, metrics)
+}
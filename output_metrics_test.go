@@ -0,0 +1,98 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/calyptia/plugin/metric/cmt"
+	"github.com/calyptia/plugin/output"
+	"github.com/calyptia/plugin/trace/ctr"
+)
+
+type testMetricsOutput struct {
+	tag     string
+	metrics []*cmt.Context
+}
+
+func (plug *testMetricsOutput) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (plug *testMetricsOutput) FlushMetrics(ctx context.Context, tag string, metrics []*cmt.Context) error {
+	plug.tag = tag
+	plug.metrics = metrics
+	return nil
+}
+
+type testLogsAndMetricsOutput struct {
+	testOutputCounter
+	testMetricsOutput
+}
+
+func (plug *testLogsAndMetricsOutput) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func TestPayloadEventType(t *testing.T) {
+	logs, err := msgpack.Marshal([]any{&EventTime{time.Now()}, map[string]any{"foo": "bar"}})
+	assert.NoError(t, err)
+	assert.Equal(t, logsEvent, payloadEventType(logs))
+
+	metrics, err := cmt.Encode(cmt.NewContext())
+	assert.NoError(t, err)
+	assert.Equal(t, metricsEvent, payloadEventType(metrics))
+
+	traces, err := ctr.Encode(ctr.NewTraces())
+	assert.NoError(t, err)
+	assert.Equal(t, tracesEvent, payloadEventType(traces))
+
+	assert.Equal(t, logsEvent, payloadEventType(nil))
+}
+
+func TestFlushMetrics(t *testing.T) {
+	plug := &testMetricsOutput{}
+	o := (&registration{metricsOutput: plug}).newOutputInstance()
+	assert.True(t, o.plugin() == plug)
+
+	gauge := cmt.NewGauge(cmt.Opts{Name: "queue_size"})
+	gauge.Set(time.Now(), 42)
+	metrics := cmt.NewContext()
+	metrics.Add(gauge)
+
+	b, err := cmt.Encode(metrics)
+	assert.NoError(t, err)
+
+	assert.NoError(t, o.pluginFlushMetrics(context.Background(), "foobar", b))
+	assert.Equal(t, "foobar", plug.tag)
+	assert.Equal(t, 1, len(plug.metrics))
+	assert.Equal(t, 42.0, plug.metrics[0].Metrics[0].Values[0].Value)
+}
+
+func TestOutputEventTypes(t *testing.T) {
+	assert.Equal(t, output.FLB_OUTPUT_LOGS, (&registration{output: &testOutputCounter{}}).outputEventTypes())
+	assert.Equal(t, output.FLB_OUTPUT_METRICS, (&registration{metricsOutput: &testMetricsOutput{}}).outputEventTypes())
+
+	// factories are not invoked to register, they declare their events.
+	var created int
+	both := &registration{kind: outputKind, name: "dummy", newOutput: func() OutputPlugin {
+		created++
+		return &testLogsAndMetricsOutput{}
+	}}
+	assert.Equal(t, output.FLB_OUTPUT_LOGS, both.outputEventTypes())
+	WithOutputEvents(MetricsEvents)(both)
+	assert.Equal(t, output.FLB_OUTPUT_LOGS|output.FLB_OUTPUT_METRICS, both.outputEventTypes())
+	WithOutputEvents(MetricsEvents | TracesEvents)(both)
+	assert.Equal(t, output.FLB_OUTPUT_LOGS|output.FLB_OUTPUT_METRICS|output.FLB_OUTPUT_TRACES, both.outputEventTypes())
+	assert.Equal(t, 0, created)
+
+	assert.NotZero(t, both.newOutputInstance().metricsOutput)
+	assert.Equal(t, 1, created)
+
+	assert.Panics(t, func() {
+		WithOutputEvents(MetricsEvents)(&registration{kind: inputKind, name: "dummy"})
+	})
+}
@@ -0,0 +1,37 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/calyptia/plugin/trace/ctr"
+)
+
+// TracesOutputPlugin interface to represent an output fluent-bit plugin
+// receiving trace events, decoded from ctraces payloads.
+// Plugins registered with RegisterOutput or RegisterChunkOutput can implement
+// FlushTraces as well to receive both the logs and the traces routed to them.
+// Returning ErrRetry makes fluent-bit retry the traces later.
+type TracesOutputPlugin interface {
+	Init(ctx context.Context, fbit *Fluentbit) error
+	FlushTraces(ctx context.Context, tag string, traces []*ctr.Traces) error
+}
+
+// RegisterTracesOutput plugin.
+// A single output plugin can be registered per shared library.
+func RegisterTracesOutput(name, desc string, out TracesOutputPlugin, opts ...RegisterOption) {
+	register(&registration{
+		kind:         outputKind,
+		name:         name,
+		desc:         desc,
+		tracesOutput: out,
+	}, opts...)
+}
+
+func (o *outputInstance) pluginFlushTraces(ctx context.Context, tag string, b []byte) error {
+	traces, err := ctr.Decode(b)
+	if err != nil {
+		return err
+	}
+
+	return o.tracesOutput.FlushTraces(ctx, tag, traces)
+}
@@ -0,0 +1,48 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/calyptia/plugin/output"
+	"github.com/calyptia/plugin/trace/ctr"
+)
+
+type testTracesOutput struct {
+	tag    string
+	traces []*ctr.Traces
+}
+
+func (plug *testTracesOutput) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (plug *testTracesOutput) FlushTraces(ctx context.Context, tag string, traces []*ctr.Traces) error {
+	plug.tag = tag
+	plug.traces = traces
+	return nil
+}
+
+func TestFlushTraces(t *testing.T) {
+	plug := &testTracesOutput{}
+	r := &registration{tracesOutput: plug}
+	assert.Equal(t, output.FLB_OUTPUT_TRACES, r.outputEventTypes())
+
+	o := r.newOutputInstance()
+	assert.True(t, o.plugin() == plug)
+
+	span := ctr.NewSpan("flush", ctr.SpanKindInternal, time.Now())
+	traces := ctr.NewTraces()
+	traces.Add(ctr.Resource{}, ctr.Scope{}, span)
+
+	b, err := ctr.Encode(traces)
+	assert.NoError(t, err)
+
+	assert.NoError(t, o.pluginFlushTraces(context.Background(), "foobar", b))
+	assert.Equal(t, "foobar", plug.tag)
+	assert.Equal(t, 1, len(plug.traces))
+	assert.Equal(t, "flush", plug.traces[0].ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+}
@@ -0,0 +1,156 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicPolicy decides what happens when the plugin code panics.
+type PanicPolicy int
+
+const (
+	// PanicError recovers the panic and reports it as an error: Init and the callbacks
+	// fail, and the Collect and Flush goroutines stop. Later flushes fail with FLB_ERROR.
+	PanicError PanicPolicy = iota
+	// PanicRestart recovers the panic like PanicError, but restarts the Collect and
+	// Flush goroutines following the restart policy, see WithRestartPolicy.
+	PanicRestart
+	// PanicAbort lets the panic crash fluent-bit, after writing a crash report
+	// when a crash report directory is configured, see FLB_GO_CRASH_DIR.
+	PanicAbort
+)
+
+func (p PanicPolicy) String() string {
+	switch p {
+	case PanicError:
+		return "error"
+	case PanicRestart:
+		return "restart"
+	case PanicAbort:
+		return "abort"
+	}
+
+	return fmt.Sprintf("PanicPolicy(%d)", int(p))
+}
+
+// WithPanicPolicy sets what happens when the plugin code panics, the default is PanicError.
+// Recovered panics are logged with their stack and counted by the panics_total metric.
+func WithPanicPolicy(p PanicPolicy) RegisterOption {
+	return func(r *registration) {
+		r.panicPolicy = p
+	}
+}
+
+// panicError is a panic recovered from the plugin code.
+type panicError struct {
+	callback string
+	value    any
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("%s: panic: %v", e.callback, e.value)
+}
+
+// supervise sets the panic and restart policies of the plugin, and the metrics
+// counting its panics and restarts.
+func (s *runState) supervise(r *registration, fbit *Fluentbit) {
+	panics := fbit.Metrics.NewCounter("panics_total",
+		"Number of panics recovered from the plugin code", "name", "callback")
+	restarts := fbit.Metrics.NewCounter("restarts_total",
+		"Number of restarts of the plugin goroutines", "name", "callback")
+	label := fbit.Instance.Label()
+
+	s.instance = fbit.Instance
+	s.panicPolicy, s.restartPolicy = r.panicPolicy, r.restartPolicyOrDefault()
+	s.sdk = newSDKMetrics(label, fbit.Metrics)
+	s.crash = crashInfo{
+		plugin:   r.name,
+		kind:     r.kind.String(),
+		version:  r.meta.Version,
+		instance: label,
+	}
+	if fbit.Conf != nil {
+		s.crash.dir = crashDir(fbit.Conf)
+	}
+	s.onPanic = func(callback string) {
+		panics.Add(1, label, callback)
+	}
+	s.onRestart = func(callback string) {
+		restarts.Add(1, label, callback)
+		s.sdk.restarted(callback)
+	}
+}
+
+// protect runs fn, recovering its panics as errors unless the policy aborts.
+func (s *runState) protect(callback string, fn func() error) (err error) {
+	defer func() {
+		if s.panicPolicy == PanicAbort {
+			if v := recover(); v != nil {
+				reportCrash(s.crash, callback, v, debug.Stack())
+				panic(v)
+			}
+			return
+		}
+
+		if v := recover(); v != nil {
+			s.logf(LogError, "%s: panic: %v\n%s", callback, v, debug.Stack())
+			if s.onPanic != nil {
+				s.onPanic(callback)
+			}
+			err = &panicError{callback: callback, value: v}
+		}
+	}()
+
+	return fn()
+}
+
+// runProtected runs fn, a long running goroutine of the plugin, restarting it with backoff
+// after a panic when the policy says so, or after an error when restartErrors is set.
+// A goroutine stopped after a panic marks the plugin as panicked.
+func (s *runState) runProtected(ctx context.Context, callback string, restartErrors bool, fn func(ctx context.Context) error) error {
+	var attempt int
+	for {
+		start := sdkClock.Now()
+		err := s.protect(callback, func() error {
+			return fn(ctx)
+		})
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+
+		var perr *panicError
+		panicked := errors.As(err, &perr)
+		if panicked && s.panicPolicy != PanicRestart || !panicked && !restartErrors {
+			s.panicked.Store(panicked)
+			return err
+		}
+
+		// a goroutine running for longer than the max backoff starts over.
+		if sdkClock.Now().Sub(start) > s.restartPolicy.MaxBackoff {
+			attempt = 0
+		}
+		attempt++
+
+		if s.restartPolicy.exhausted(attempt) {
+			s.panicked.Store(panicked)
+			return fmt.Errorf("%w (gave up after %d restarts)", err, attempt-1)
+		}
+
+		wait := s.restartPolicy.backoff(attempt)
+		s.logf(LogWarn, "%s: %v, restarting in %s", callback, err, wait)
+
+		timer := sdkClock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C():
+		}
+
+		if s.onRestart != nil {
+			s.onRestart(callback)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestProtect(t *testing.T) {
+	gauge := &testGauge{values: map[string]float64{}}
+	var s runState
+	s.supervise(&registration{}, &Fluentbit{Metrics: testMetrics{gauge: gauge}, Instance: InstanceInfo{Name: "dummy.0"}})
+
+	err := s.protect("flush", func() error {
+		panic("boom")
+	})
+	assert.EqualError(t, err, "flush: panic: boom")
+	assert.Equal(t, 1.0, gauge.get("dummy.0"))
+
+	err = s.protect("flush", func() error {
+		return errors.New("regular error")
+	})
+	assert.EqualError(t, err, "regular error")
+	assert.Equal(t, 1.0, gauge.get("dummy.0"))
+
+	s.panicPolicy = PanicAbort
+	assert.Panics(t, func() {
+		_ = s.protect("flush", func() error {
+			panic("boom")
+		})
+	})
+}
+
+func TestRunProtected(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var s runState
+	err := s.runProtected(ctx, "collect", false, func(ctx context.Context) error {
+		panic("boom")
+	})
+	assert.EqualError(t, err, "collect: panic: boom")
+	assert.True(t, s.panicked.Load())
+
+	var calls atomic.Int32
+	s = runState{panicPolicy: PanicRestart, restartPolicy: RestartPolicy{MaxRetries: -1, MinBackoff: 10 * time.Millisecond, MaxBackoff: time.Second}}
+	start := time.Now()
+	err = s.runProtected(ctx, "collect", false, func(ctx context.Context) error {
+		if calls.Add(1) == 1 {
+			panic("boom")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+	assert.True(t, time.Since(start) >= 5*time.Millisecond)
+	assert.False(t, s.panicked.Load())
+}
+
+func TestPanicPolicyString(t *testing.T) {
+	assert.Equal(t, "restart", PanicRestart.String())
+	assert.Equal(t, "PanicPolicy(9)", PanicPolicy(9).String())
+}
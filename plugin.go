@@ -1,43 +1,24 @@
 // Package plugin implements the global context and objects required to run an instance of a plugin
-// also, the interfaces for input and output plugins.
+// also, the interfaces for input, output, filter and processor plugins.
 package plugin
 
 import (
 	"context"
-	"sync"
-	"sync/atomic"
+	"fmt"
 	"time"
 
 	"github.com/calyptia/plugin/metric"
+	"github.com/calyptia/plugin/metric/cmt"
 )
 
-// atomicUint32 is used to atomically check if the plugin has been registered.
-var atomicUint32 uint32
-
-var (
-	theName   string
-	theDesc   string
-	theInput  InputPlugin
-	theOutput OutputPlugin
-)
-
-var (
-	registerWG sync.WaitGroup
-	initWG     sync.WaitGroup
-	runCtx     context.Context
-	runCancel  context.CancelFunc
-	theChannel chan Message
-)
-
-func init() {
-	registerWG.Add(1)
-	theChannel = nil
-}
-
 type Fluentbit struct {
 	Conf    ConfigLoader
 	Metrics Metrics
 	Logger  Logger
+	// Service is the fluent-bit service configuration.
+	Service ServiceConfig
+	// Instance identifies the plugin instance.
+	Instance InstanceInfo
 }
 
 // InputPlugin interface to represent an input fluent-bit plugin.
@@ -57,6 +38,18 @@ type ConfigLoader interface {
 	String(key string) string
 }
 
+// ConfigIterator is implemented by the config loaders able to list the keys set on
+// the plugin instance, so plugins can support free-form or prefixed options.
+// The fluent-bit config loaders only list the keys declared with WithConfigMap, as
+// the proxy API can only look up keys by name.
+type ConfigIterator interface {
+	ConfigLoader
+	// Keys returns the keys set on the plugin instance, sorted.
+	Keys() []string
+	// All returns all the key/value pairs set on the plugin instance.
+	All() map[string]string
+}
+
 // Logger interface to represent a fluent-bit logging mechanism.
 type Logger interface {
 	Error(format string, a ...any)
@@ -65,10 +58,50 @@ type Logger interface {
 	Debug(format string, a ...any)
 }
 
-// Metrics builder.
+// FieldLogger is a Logger with structured variants, logging a message followed by
+// key/value fields rendered as key=value, so the logs can be parsed by machines. The
+// loggers given to the plugins implement it, see Fields.
+type FieldLogger interface {
+	Logger
+	// With returns a logger adding the given key/value pairs to the fields of every
+	// message.
+	With(keyvals ...any) FieldLogger
+	// Enabled reports whether the messages of the given level are logged, according to
+	// the log_level of the instance, to skip building costly messages.
+	Enabled(level LogLevel) bool
+	Errorw(msg string, keyvals ...any)
+	Warnw(msg string, keyvals ...any)
+	Infow(msg string, keyvals ...any)
+	Debugw(msg string, keyvals ...any)
+	// Trace and Tracew log at the trace level, enabled by the go.Trace config key.
+	Trace(format string, a ...any)
+	Tracew(msg string, keyvals ...any)
+}
+
+// Metrics builder. The metrics are created with the keys of their labels, e.g. "tag" or
+// "status", their values being given when updated, see the metric package.
 type Metrics interface {
-	NewCounter(name, desc string, labelValues ...string) metric.Counter
-	NewGauge(name, desc string, labelValues ...string) metric.Gauge
+	NewCounter(name, desc string, labelKeys ...string) metric.Counter
+	NewGauge(name, desc string, labelKeys ...string) metric.Gauge
+}
+
+// MetricsSnapshotter is implemented by the metrics returning their current metric
+// families, see SnapshotMetrics.
+type MetricsSnapshotter interface {
+	Snapshot() ([]cmt.Metric, error)
+}
+
+// SnapshotMetrics returns the current metric families of the metrics of an instance, to
+// assert their values in tests or to log them. cmt.NewMetrics returns metrics kept in
+// memory for the unit tests of the plugins. It fails with the metrics not implementing
+// MetricsSnapshotter.
+func SnapshotMetrics(m Metrics) ([]cmt.Metric, error) {
+	s, ok := m.(MetricsSnapshotter)
+	if !ok {
+		return nil, fmt.Errorf("metrics %T do not support snapshots", m)
+	}
+
+	return s.Snapshot()
 }
 
 // Message struct to store a fluent-bit message this is collected (input) or flushed (output)
@@ -78,6 +111,9 @@ type Message struct {
 	// Record should be a map or a struct.
 	Record any
 	tag    *string
+	worker int
+	ack    *messageAck
+	loan   *messageLoan
 }
 
 // Tag is available at output.
@@ -88,30 +124,65 @@ func (m Message) Tag() string {
 	return *m.tag
 }
 
-// mustOnce allows to be called only once otherwise it panics.
-// This is used to register a single plugin per file.
-func mustOnce() {
-	if atomic.LoadUint32(&atomicUint32) == 1 {
-		panic("plugin already registered")
+// Worker is available at output, it is the id of the fluent-bit output worker
+// that flushed the message. Ids start at 0 and are assigned in the order the workers
+// flush for the first time, outputs without workers always report 0.
+func (m Message) Worker() int {
+	return m.worker
+}
+
+// Ack is available at output, it acknowledges the processing of the message,
+// a non nil err reports a failure, see ErrRetry. Outputs configured with
+// go.SyncFlush must acknowledge every message as fluent-bit only completes the
+// flush of a chunk once all its messages are acknowledged; it is a no-op otherwise.
+func (m Message) Ack(err error) {
+	if m.ack != nil {
+		m.ack.done(err)
 	}
+}
 
-	atomic.StoreUint32(&atomicUint32, 1)
+// Done is available at output, for plugins registered with WithMessageLoans: it gives the
+// message back to the SDK, its record being reused for the next messages, so the record
+// must not be used once done. It is a no-op otherwise.
+func (m Message) Done() {
+	if m.loan != nil {
+		m.loan.done()
+	}
 }
 
 // RegisterInput plugin.
-// This function must be called only once per file.
-func RegisterInput(name, desc string, in InputPlugin) {
-	mustOnce()
-	theName = name
-	theDesc = desc
-	theInput = in
+// A single input plugin can be registered per shared library.
+func RegisterInput(name, desc string, in InputPlugin, opts ...RegisterOption) {
+	register(&registration{
+		kind:  inputKind,
+		name:  name,
+		desc:  desc,
+		input: in,
+	}, opts...)
 }
 
 // RegisterOutput plugin.
-// This function must be called only once per file.
-func RegisterOutput(name, desc string, out OutputPlugin) {
-	mustOnce()
-	theName = name
-	theDesc = desc
-	theOutput = out
+// A single output plugin can be registered per shared library.
+// The plugin value is shared by all its configured instances, use
+// RegisterOutputFactory for plugins holding per-instance state.
+func RegisterOutput(name, desc string, out OutputPlugin, opts ...RegisterOption) {
+	register(&registration{
+		kind:   outputKind,
+		name:   name,
+		desc:   desc,
+		output: out,
+	}, opts...)
+}
+
+// RegisterOutputFactory registers an output plugin that can be configured
+// several times, newOutput is invoked once per instance so each one gets
+// its own plugin value.
+// A single output plugin can be registered per shared library.
+func RegisterOutputFactory(name, desc string, newOutput func() OutputPlugin, opts ...RegisterOption) {
+	register(&registration{
+		kind:      outputKind,
+		name:      name,
+		desc:      desc,
+		newOutput: newOutput,
+	}, opts...)
 }
@@ -0,0 +1,114 @@
+package plugin
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+)
+
+// The callbacks reuse the buffers, records and messages of their hot paths through pools,
+// following these ownership rules:
+//   - a buffer belongs to the callback getting it, which puts it back once its bytes got
+//     copied into C memory or into another buffer.
+//   - the records decoded for filters and log processors, and the messages holding them,
+//     are put back once the result is encoded: Filter and ProcessLogs must not retain the
+//     records they are given, nor the message slices, past the call.
+//   - the messages sent by inputs and the records handed to outputs are never pooled.
+
+// maxPooledBuffer is the capacity of the buffers put back in the pool, larger ones are
+// left to the GC so a burst does not pin its memory.
+const maxPooledBuffer = 4 << 20
+
+// pooling is disabled by the benchmarks to compare with fresh allocations.
+var pooling = true
+
+var (
+	bufferPool  = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+	recordPool  sync.Pool
+	messagePool sync.Pool
+)
+
+// getBuffer returns an empty buffer, to be put back with putBuffer.
+func getBuffer() *bytes.Buffer {
+	if !pooling {
+		return new(bytes.Buffer)
+	}
+
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if !pooling || buf == nil || buf.Cap() > maxPooledBuffer {
+		return
+	}
+
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// getRecord returns an empty record map, sized for n fields when new.
+func getRecord(n int) map[string]any {
+	if pooling {
+		if m, ok := recordPool.Get().(map[string]any); ok {
+			return m
+		}
+	}
+
+	return make(map[string]any, n)
+}
+
+// messageBatch holds the messages of a chunk decoded from a pool.
+type messageBatch struct {
+	msgs []Message
+}
+
+func getMessages() *messageBatch {
+	if pooling {
+		if batch, ok := messagePool.Get().(*messageBatch); ok {
+			return batch
+		}
+	}
+
+	return &messageBatch{}
+}
+
+// release puts the messages and their records back in the pools.
+func (batch *messageBatch) release() {
+	if !pooling {
+		return
+	}
+
+	for _, msg := range batch.msgs {
+		if m, ok := msg.Record.(map[string]any); ok && m != nil {
+			clear(m)
+			recordPool.Put(m)
+		}
+	}
+
+	clear(batch.msgs)
+	batch.msgs = batch.msgs[:0]
+	messagePool.Put(batch)
+}
+
+// decodeMessages decodes the messages of a chunk into a batch from the pool, the records
+// decoded by the msgpack reader being reused as well. The batch is released by the caller.
+func decodeMessages(c Codec, keys *keyCache, tag string, b []byte) (*messageBatch, error) {
+	batch := getMessages()
+
+	dec := newRecordDecoder(c, keys, b, true)
+
+	for {
+		msg, err := decodeMessage(dec, &tag)
+		if errors.Is(err, io.EOF) {
+			return batch, nil
+		}
+
+		if err != nil {
+			batch.release()
+			return nil, err
+		}
+
+		batch.msgs = append(batch.msgs, msg)
+	}
+}
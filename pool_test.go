@@ -0,0 +1,179 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestBufferPool(t *testing.T) {
+	buf := getBuffer()
+	buf.WriteString("leftover")
+	putBuffer(buf)
+
+	// buffers come back empty, whichever one the pool returns.
+	buf = getBuffer()
+	assert.Zero(t, buf.Len())
+	putBuffer(buf)
+
+	putBuffer(nil)
+	putBuffer(bytes.NewBuffer(make([]byte, 0, maxPooledBuffer+1)))
+}
+
+func TestDecodeMessagesReusesRecords(t *testing.T) {
+	now := time.Now().UTC()
+
+	b, err := encodeMessages(defaultCodec, nil, []Message{
+		{Time: now, Record: map[string]any{"a": "1", "b": "2", "c": "3"}},
+		{Time: now, Record: map[string]any{"d": "4"}},
+	})
+	assert.NoError(t, err)
+
+	for range 3 {
+		batch, err := decodeMessages(defaultCodec, nil, "tag", b)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(batch.msgs))
+
+		// the records reused from the pool hold no stale fields.
+		assert.Equal(t, map[string]any{"a": "1", "b": "2", "c": "3"}, batch.msgs[0].Record.(map[string]any))
+		assert.Equal(t, map[string]any{"d": "4"}, batch.msgs[1].Record.(map[string]any))
+		assert.Equal(t, "tag", batch.msgs[1].Tag())
+
+		batch.release()
+	}
+
+	_, err = decodeMessages(defaultCodec, nil, "tag", b[:len(b)-1])
+	assert.Error(t, err)
+}
+
+func TestCollectLogsCarryOwnsRecord(t *testing.T) {
+	defer resetRegistry()
+
+	r := prepareInput(testPluginInputCallbackCtrlC{})
+	r.prepareInputCollector()
+	defer r.runCancel()
+
+	now := time.Now()
+	first, err := encodeRecord(defaultCodec, Message{Time: now, Record: map[string]string{"first": "record"}})
+	assert.NoError(t, err)
+	second, err := encodeRecord(defaultCodec, Message{Time: now, Record: map[string]string{"second": "record"}})
+	assert.NoError(t, err)
+
+	// the second record is carried over to the next callback.
+	r.maxBatchBytes = len(first) + 1
+	r.channel <- Message{Time: now, Record: map[string]string{"first": "record"}}
+	r.channel <- Message{Time: now, Record: map[string]string{"second": "record"}}
+	r.channel <- Message{Time: now, Record: map[string]string{"third": "record"}}
+
+	b, _, err := r.collectLogs()
+	assert.NoError(t, err)
+	assert.Equal(t, first, b)
+
+	// the carried record is not overwritten by the records encoded after it.
+	b, _, err = r.collectLogs()
+	assert.NoError(t, err)
+	assert.Equal(t, second, b)
+}
+
+// testFilterPassthrough returns the messages untouched.
+type testFilterPassthrough struct{}
+
+func (testFilterPassthrough) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (testFilterPassthrough) Filter(ctx context.Context, in []Message) ([]Message, error) {
+	return in, nil
+}
+
+// benchmarkPooling runs fn with and without the pools, reporting the records
+// processed per second and the GC cycles per operation.
+func benchmarkPooling(b *testing.B, records int, fn func(b *testing.B)) {
+	for _, enabled := range []bool{true, false} {
+		name := "pooled"
+		if !enabled {
+			name = "unpooled"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			defer func(v bool) { pooling = v }(pooling)
+			pooling = enabled
+
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			fn(b)
+			b.StopTimer()
+
+			runtime.ReadMemStats(&after)
+			b.ReportMetric(float64(records*b.N)/b.Elapsed().Seconds(), "records/s")
+			b.ReportMetric(float64(after.NumGC-before.NumGC)/float64(b.N), "gc/op")
+		})
+	}
+}
+
+func BenchmarkFilterPooling(b *testing.B) {
+	const records = 1000
+
+	r := &registration{kind: filterKind, name: "bench", filter: testFilterPassthrough{}}
+	chunk := benchmarkChunk(b, records)
+
+	benchmarkPooling(b, records, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			in, out := getBuffer(), getBuffer()
+			in.Write(chunk)
+
+			if _, err := r.pluginFilter(context.Background(), "tag", in.Bytes(), out); err != nil {
+				b.Fatal(err)
+			}
+
+			putBuffer(in)
+			putBuffer(out)
+		}
+	})
+}
+
+func BenchmarkCollectPooling(b *testing.B) {
+	const records = 1000
+
+	defer resetRegistry()
+
+	r := prepareInput(testPluginInputCallbackCtrlC{})
+	r.maxBufferedMessages = records
+	r.prepareInputCollector()
+	defer r.runCancel()
+
+	// the records are encoded into the C input buffer, as by default.
+	r.inputBuf = inputBuffer(testConfigLoader{})
+	defer r.inputBuf.Close()
+
+	msgs := make([]Message, records)
+	for i := range msgs {
+		msgs[i] = Message{Time: time.Now(), Record: map[string]any{
+			"log":    fmt.Sprintf("127.0.0.1 - - GET /index.html?id=%d HTTP/1.1 200 512", i),
+			"stream": "stdout",
+			"status": 200,
+		}}
+	}
+
+	benchmarkPooling(b, records, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			for _, msg := range msgs {
+				r.channel <- msg
+			}
+			b.StartTimer()
+
+			if _, _, err := r.collectLogs(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// WithPprof serves the handler on the pprof_addr of the instances, the handler of the
+// pprof package serving the pprof profiles and the expvar variables of the process, so
+// the profiles of plugins running in production can be captured without rebuilding them:
+//
+//	plugin.RegisterOutput("my-output", "", &plug, plugin.WithPprof(pprof.Handler()))
+//
+// The profiles are opt-in, the SDK not importing net/http/pprof and expvar, which serve
+// them on the default mux of net/http to the clients of the plugins serving it. It panics
+// with a nil handler.
+func WithPprof(h http.Handler) RegisterOption {
+	return func(r *registration) {
+		if h == nil {
+			panic(fmt.Sprintf("invalid pprof handler %v: %q", h, r.name))
+		}
+
+		r.pprofHandler = h
+	}
+}
+
+// pprofServer serves the handler given to WithPprof on the pprof_addr of a plugin
+// instance, e.g.:
+//
+//	go tool pprof http://127.0.0.1:6060/debug/pprof/profile?seconds=30
+//
+// The profiles cover the whole process, the goroutines started for each instance being
+// labeled with its plugin and instance names to tell them apart, e.g. with -tagfocus.
+type pprofServer struct {
+	addr string
+	srv  *http.Server
+}
+
+// pprofAddr reads the pprof_addr config key, the address to serve the profiles on. It is
+// empty unless set, profiling being opt-in.
+func pprofAddr(conf ConfigLoader) string {
+	return conf.String("pprof_addr")
+}
+
+// startPprof serves the handler on the pprof_addr of the instance, if set. An address
+// that cannot be listened on, e.g. one shared by several instances, is logged and
+// otherwise ignored, as the plugin runs fine without profiles. So is the address of
+// plugins not registered with WithPprof.
+func startPprof(logger Logger, name string, conf ConfigLoader, h http.Handler) *pprofServer {
+	addr := pprofAddr(conf)
+	if addr == "" {
+		return nil
+	}
+
+	if h == nil {
+		fmt.Fprintf(os.Stderr, "pprof: name=%q: pprof_addr ignored, the plugin is not registered with WithPprof\n", name)
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pprof: name=%q: %v\n", name, err)
+		return nil
+	}
+
+	s := &pprofServer{
+		addr: ln.Addr().String(),
+		srv:  &http.Server{Handler: h, ReadHeaderTimeout: 10 * time.Second},
+	}
+	if logger != nil {
+		logger.Info("serving pprof on http://%s/debug/pprof/", s.addr)
+	}
+
+	spawn("pprof "+name, func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "pprof: name=%q: %v\n", name, err)
+		}
+	})
+
+	return s
+}
+
+// stop closes the listener and the connections, ongoing profiles included.
+func (s *pprofServer) stop() {
+	if s == nil {
+		return
+	}
+
+	_ = s.srv.Close()
+}
@@ -0,0 +1,29 @@
+// Package pprof serves the pprof profiles and the expvar variables of the process, on the
+// pprof_addr of the instances of the plugins registered with its handler:
+//
+//	plugin.RegisterOutput("my-output", "", &plug, plugin.WithPprof(pprof.Handler()))
+//
+// Importing it registers the handlers of net/http/pprof and expvar on the default mux of
+// net/http too, as those packages do, plugins serving the default mux to their clients,
+// e.g. HTTP inputs, should serve their own mux.
+package pprof
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+)
+
+// Handler returns the handlers of the profiles, under /debug/pprof/, and of the expvar
+// variables, under /debug/vars.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	return mux
+}
@@ -0,0 +1,83 @@
+package plugin
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestStartPprof(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "profiles of "+r.URL.Path)
+	})
+	assert.Zero(t, startPprof(nil, "dummy.0", testConfigLoader{}, h))
+
+	// the address is ignored without a handler.
+	assert.Zero(t, startPprof(nil, "dummy.0", testConfigLoader{"pprof_addr": "127.0.0.1:0"}, nil))
+
+	s := startPprof(nil, "dummy.0", testConfigLoader{"pprof_addr": "127.0.0.1:0"}, h)
+	assert.NotZero(t, s)
+
+	resp, err := http.Get("http://" + s.addr + "/debug/pprof/")
+	assert.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, "profiles of /debug/pprof/", string(b))
+
+	// the address cannot be shared with another instance.
+	assert.Zero(t, startPprof(nil, "dummy.1", testConfigLoader{"pprof_addr": s.addr}, h))
+
+	s.stop()
+	http.DefaultClient.CloseIdleConnections()
+	_, err = net.Dial("tcp", s.addr)
+	assert.Error(t, err)
+
+	var none *pprofServer
+	none.stop()
+}
+
+func TestWithPprof(t *testing.T) {
+	r := &registration{name: "dummy"}
+	h := http.NewServeMux()
+	WithPprof(h)(r)
+	assert.Equal(t, http.Handler(h), r.pprofHandler)
+
+	assert.Panics(t, func() {
+		WithPprof(nil)(r)
+	})
+}
+
+// the SDK does not serve the profiles on the default mux.
+func TestDefaultMuxWithoutPprof(t *testing.T) {
+	_, pattern := http.DefaultServeMux.Handler(httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, "", pattern)
+	_, pattern = http.DefaultServeMux.Handler(httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	assert.Equal(t, "", pattern)
+}
+
+func TestRunStateSpawnLabels(t *testing.T) {
+	s := &runState{crash: crashInfo{plugin: "dummy", instance: "dummy.0"}}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	s.spawn("labeled", func() {
+		close(started)
+		<-release
+	})
+	defer func() {
+		close(release)
+		s.goroutines.Wait()
+	}()
+	<-started
+
+	var b bytes.Buffer
+	assert.NoError(t, pprof.Lookup("goroutine").WriteTo(&b, 1))
+	assert.Contains(t, b.String(), `"instance":"dummy.0"`)
+	assert.Contains(t, b.String(), `"plugin":"dummy"`)
+}
@@ -0,0 +1,129 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/calyptia/plugin/metric/cmt"
+	"github.com/calyptia/plugin/trace/ctr"
+)
+
+// ProcessorPlugin interface to represent a processor fluent-bit plugin.
+// Processors are attached to an input or output instance and transform the
+// telemetry in-pipeline, a processor must implement at least one of the
+// signal specific interfaces (e.g. LogsProcessor).
+type ProcessorPlugin interface {
+	Init(ctx context.Context, fbit *Fluentbit) error
+}
+
+// LogsProcessor interface to represent a processor that handles log records.
+// ProcessLogs returns the messages that continue through the pipeline. The messages
+// and their records are reused once ProcessLogs returns, they must be copied to be retained.
+type LogsProcessor interface {
+	ProcessLogs(ctx context.Context, tag string, in []Message) ([]Message, error)
+}
+
+// MetricsProcessor interface to represent a processor that handles metrics.
+// The decoded cmetrics contexts can be renamed, relabeled or aggregated,
+// ProcessMetrics returns the contexts that continue through the pipeline.
+type MetricsProcessor interface {
+	ProcessMetrics(ctx context.Context, tag string, in []*cmt.Context) ([]*cmt.Context, error)
+}
+
+// TracesProcessor interface to represent a processor that handles traces.
+// The decoded ctraces contexts can be enriched or transformed,
+// ProcessTraces returns the contexts that continue through the pipeline.
+type TracesProcessor interface {
+	ProcessTraces(ctx context.Context, tag string, in []*ctr.Traces) ([]*ctr.Traces, error)
+}
+
+// RegisterProcessor plugin.
+// A single processor plugin can be registered per shared library.
+func RegisterProcessor(name, desc string, proc ProcessorPlugin, opts ...RegisterOption) {
+	if !isSignalProcessor(proc) {
+		panic("processor must implement at least one signal processor interface")
+	}
+
+	register(&registration{
+		kind:      processorKind,
+		name:      name,
+		desc:      desc,
+		processor: proc,
+	}, opts...)
+}
+
+func isSignalProcessor(proc ProcessorPlugin) bool {
+	switch proc.(type) {
+	case LogsProcessor, MetricsProcessor, TracesProcessor:
+		return true
+	}
+	return false
+}
+
+// pluginProcessLogs decodes the chunk and runs it through the registered processor, the
+// result being written to buf. ok is false when the processor does not handle logs and the
+// chunk must be left untouched. The decoded records are reused once encoded, see pool.go.
+func (r *registration) pluginProcessLogs(ctx context.Context, tag string, b []byte, buf *bytes.Buffer) (out []byte, ok bool, err error) {
+	proc, ok := r.processor.(LogsProcessor)
+	if !ok {
+		return nil, false, nil
+	}
+
+	batch, err := decodeMessages(r.recordCodec(), r.keys, tag, b)
+	if err != nil {
+		return nil, true, err
+	}
+	defer batch.release()
+
+	msgs, err := proc.ProcessLogs(ctx, tag, batch.msgs)
+	if err != nil {
+		return nil, true, err
+	}
+
+	out, err = encodeMessages(r.recordCodec(), buf, msgs)
+	return out, true, err
+}
+
+// pluginProcessMetrics decodes the metrics and runs them through the registered processor,
+// ok is false when the processor does not handle metrics and the payload must be left untouched.
+func (r *registration) pluginProcessMetrics(ctx context.Context, tag string, b []byte) (out []byte, ok bool, err error) {
+	proc, ok := r.processor.(MetricsProcessor)
+	if !ok {
+		return nil, false, nil
+	}
+
+	contexts, err := cmt.Decode(b)
+	if err != nil {
+		return nil, true, err
+	}
+
+	contexts, err = proc.ProcessMetrics(ctx, tag, contexts)
+	if err != nil {
+		return nil, true, err
+	}
+
+	out, err = cmt.Encode(contexts...)
+	return out, true, err
+}
+
+// pluginProcessTraces decodes the traces and runs them through the registered processor,
+// ok is false when the processor does not handle traces and the payload must be left untouched.
+func (r *registration) pluginProcessTraces(ctx context.Context, tag string, b []byte) (out []byte, ok bool, err error) {
+	proc, ok := r.processor.(TracesProcessor)
+	if !ok {
+		return nil, false, nil
+	}
+
+	traces, err := ctr.Decode(b)
+	if err != nil {
+		return nil, true, err
+	}
+
+	traces, err = proc.ProcessTraces(ctx, tag, traces)
+	if err != nil {
+		return nil, true, err
+	}
+
+	out, err = ctr.Encode(traces...)
+	return out, true, err
+}
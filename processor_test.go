@@ -0,0 +1,182 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/calyptia/plugin/metric/cmt"
+	"github.com/calyptia/plugin/trace/ctr"
+)
+
+type testLogsProcessor struct{}
+
+func (t testLogsProcessor) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (t testLogsProcessor) ProcessLogs(ctx context.Context, tag string, in []Message) ([]Message, error) {
+	for _, msg := range in {
+		record, ok := msg.Record.(map[string]any)
+		if !ok {
+			return nil, errors.New("unexpected record type")
+		}
+
+		record["processed_tag"] = tag
+	}
+
+	return in, nil
+}
+
+type testNoopProcessor struct{}
+
+func (t testNoopProcessor) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func TestRegisterProcessorWithoutSignal(t *testing.T) {
+	assert.Panics(t, func() {
+		RegisterProcessor("noop", "noop processor", testNoopProcessor{})
+	})
+}
+
+func TestProcessLogs(t *testing.T) {
+	now := time.Now().UTC()
+
+	in, err := encodeMessages(defaultCodec, nil, []Message{
+		{Time: now, Record: map[string]any{"foo": "bar"}},
+	})
+	assert.NoError(t, err)
+
+	r := &registration{processor: testNoopProcessor{}}
+	_, ok, err := r.pluginProcessLogs(context.Background(), "foobar", in, nil)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	r = &registration{processor: testLogsProcessor{}}
+
+	b, ok, err := r.pluginProcessLogs(context.Background(), "foobar", in, nil)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	msg, err := decodeMsg(dec, "foobar")
+	assert.NoError(t, err)
+	assert.Equal(t, now, msg.Time)
+
+	record := assertType[map[string]any](t, msg.Record)
+	assert.Equal(t, "bar", assertType[string](t, record["foo"]))
+	assert.Equal(t, "foobar", assertType[string](t, record["processed_tag"]))
+
+	_, err = decodeMsg(dec, "foobar")
+	assert.True(t, errors.Is(err, io.EOF))
+}
+
+type testMetricsProcessor struct{}
+
+func (t testMetricsProcessor) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (t testMetricsProcessor) ProcessMetrics(ctx context.Context, tag string, in []*cmt.Context) ([]*cmt.Context, error) {
+	for _, c := range in {
+		for i := range c.Metrics {
+			c.Metrics[i].Meta.Opts.Namespace = "renamed"
+			c.Metrics[i].SetLabel("tag", tag)
+		}
+	}
+
+	return in, nil
+}
+
+func TestProcessMetrics(t *testing.T) {
+	in, err := cmt.Encode(&cmt.Context{
+		Metrics: []cmt.Metric{{
+			Meta: cmt.MetricMeta{
+				Type: cmt.TypeGauge,
+				Opts: cmt.Opts{Namespace: "fluentbit", Name: "queue_depth"},
+			},
+			Values: []cmt.Value{{Value: 3}},
+		}},
+	})
+	assert.NoError(t, err)
+
+	r := &registration{processor: testLogsProcessor{}}
+	_, ok, err := r.pluginProcessMetrics(context.Background(), "foobar", in)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	r = &registration{processor: testMetricsProcessor{}}
+
+	b, ok, err := r.pluginProcessMetrics(context.Background(), "foobar", in)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	got, err := cmt.Decode(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(got))
+
+	m := got[0].Metrics[0]
+	assert.Equal(t, "renamed_queue_depth", m.FullName())
+
+	tag, ok := m.LabelValue(m.Values[0], "tag")
+	assert.True(t, ok)
+	assert.Equal(t, "foobar", tag)
+}
+
+type testTracesProcessor struct{}
+
+func (t testTracesProcessor) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (t testTracesProcessor) ProcessTraces(ctx context.Context, tag string, in []*ctr.Traces) ([]*ctr.Traces, error) {
+	for _, traces := range in {
+		for i := range traces.ResourceSpans {
+			rs := &traces.ResourceSpans[i]
+			for j := range rs.ScopeSpans {
+				for k := range rs.ScopeSpans[j].Spans {
+					rs.ScopeSpans[j].Spans[k].SetAttribute("tag", tag)
+				}
+			}
+		}
+	}
+
+	return in, nil
+}
+
+func TestProcessTraces(t *testing.T) {
+	in, err := ctr.Encode(&ctr.Traces{
+		ResourceSpans: []ctr.ResourceSpans{{
+			ScopeSpans: []ctr.ScopeSpans{{
+				Spans: []ctr.Span{{Name: "collect"}},
+			}},
+		}},
+	})
+	assert.NoError(t, err)
+
+	r := &registration{processor: testLogsProcessor{}}
+	_, ok, err := r.pluginProcessTraces(context.Background(), "foobar", in)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	r = &registration{processor: testTracesProcessor{}}
+
+	b, ok, err := r.pluginProcessTraces(context.Background(), "foobar", in)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	got, err := ctr.Decode(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(got))
+
+	span := got[0].ResourceSpans[0].ScopeSpans[0].Spans[0]
+	assert.Equal(t, "collect", span.Name)
+	assert.Equal(t, "foobar", span.Attributes["tag"])
+}
@@ -0,0 +1,120 @@
+package plugin
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ProxyFromConf returns the proxy function of an http.Transport, mirroring the fluent-bit
+// outputs: the proxy key sets the proxy for all the requests, falling back to the
+// HTTP_PROXY and HTTPS_PROXY environment variables. Hosts listed in the no_proxy key,
+// or the NO_PROXY environment variable, are reached directly.
+func ProxyFromConf(conf ConfigLoader) (func(*http.Request) (*url.URL, error), error) {
+	httpProxy, err := parseProxyURL(conf.String("proxy"))
+	if err != nil {
+		return nil, err
+	}
+
+	httpsProxy := httpProxy
+	if httpProxy == nil {
+		if httpProxy, err = parseProxyURL(getenvAny("HTTP_PROXY", "http_proxy")); err != nil {
+			return nil, err
+		}
+		if httpsProxy, err = parseProxyURL(getenvAny("HTTPS_PROXY", "https_proxy")); err != nil {
+			return nil, err
+		}
+	}
+
+	noProxy := conf.String("no_proxy")
+	if noProxy == "" {
+		noProxy = getenvAny("NO_PROXY", "no_proxy")
+	}
+	bypass := splitList(noProxy)
+
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(bypass, req.URL) {
+			return nil, nil
+		}
+
+		if req.URL.Scheme == "https" {
+			return httpsProxy, nil
+		}
+
+		return httpProxy, nil
+	}, nil
+}
+
+func parseProxyURL(s string) (*url.URL, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q", s)
+	}
+
+	return u, nil
+}
+
+func getenvAny(keys ...string) string {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// bypassProxy reports whether the URL host matches one of the no_proxy entries:
+// a wildcard, an IP address, a CIDR range or a domain, its subdomains included.
+// Entries with a port only match that port.
+func bypassProxy(entries []string, u *url.URL) bool {
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	ip := net.ParseIP(host)
+
+	for _, e := range entries {
+		if e == "*" {
+			return true
+		}
+
+		if _, ipNet, err := net.ParseCIDR(e); err == nil {
+			if ip != nil && ipNet.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		entryHost, entryPort := e, ""
+		if h, p, err := net.SplitHostPort(e); err == nil {
+			entryHost, entryPort = h, p
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+
+		if entryIP := net.ParseIP(entryHost); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+
+		domain := strings.ToLower(strings.TrimPrefix(entryHost, "*"))
+		domain = strings.TrimPrefix(domain, ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
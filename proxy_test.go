@@ -0,0 +1,50 @@
+package plugin
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func proxyFor(t *testing.T, proxy func(*http.Request) (*url.URL, error), rawURL string) string {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	assert.NoError(t, err)
+
+	u, err := proxy(req)
+	assert.NoError(t, err)
+	if u == nil {
+		return ""
+	}
+
+	return u.String()
+}
+
+func TestProxyFromConf(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "http://env-proxy:3128")
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("NO_PROXY", "")
+
+	proxy, err := ProxyFromConf(testConfigLoader{
+		"proxy":    "proxy.local:8080",
+		"no_proxy": "localhost, .internal, 10.0.0.0/8, example.com:8443",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://proxy.local:8080", proxyFor(t, proxy, "https://example.com/api"))
+	assert.Equal(t, "http://proxy.local:8080", proxyFor(t, proxy, "http://example.com:8080"))
+	assert.Equal(t, "", proxyFor(t, proxy, "https://example.com:8443"))
+	assert.Equal(t, "", proxyFor(t, proxy, "http://localhost:2020"))
+	assert.Equal(t, "", proxyFor(t, proxy, "http://api.internal"))
+	assert.Equal(t, "", proxyFor(t, proxy, "http://10.1.2.3"))
+
+	proxy, err = ProxyFromConf(testConfigLoader{})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://env-proxy:3128", proxyFor(t, proxy, "http://example.com"))
+	assert.Equal(t, "", proxyFor(t, proxy, "https://example.com"))
+
+	_, err = ProxyFromConf(testConfigLoader{"proxy": "http://"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,341 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/calyptia/plugin/internal/cmem"
+	"github.com/calyptia/plugin/metric/cmt"
+	"github.com/calyptia/plugin/trace/ctr"
+)
+
+// pluginKind represents the fluent-bit plugin type of a registration.
+type pluginKind int
+
+const (
+	inputKind pluginKind = iota + 1
+	outputKind
+	filterKind
+	processorKind
+)
+
+func (k pluginKind) String() string {
+	switch k {
+	case inputKind:
+		return "input"
+	case outputKind:
+		return "output"
+	case filterKind:
+		return "filter"
+	case processorKind:
+		return "processor"
+	}
+	return "unknown"
+}
+
+// registration holds a registered plugin along with its runtime state.
+// A shared library can register several plugins, but only one of each kind
+// since the fluent-bit callbacks without context cannot tell them apart.
+type registration struct {
+	kind pluginKind
+	name string
+	desc string
+	meta Metadata
+
+	// panicPolicy applies to the panics of the plugin code.
+	panicPolicy PanicPolicy
+	// initTimeout is the time given to Init to return.
+	initTimeout time.Duration
+	// metricsPrefix prefixes the names of the metrics, nil for the default one.
+	metricsPrefix *metricsPrefix
+	// instanceLabel labels the metrics of the plugin with their instance.
+	instanceLabel bool
+	// pprofHandler is served on the pprof_addr of the instances, nil without WithPprof.
+	pprofHandler http.Handler
+	// runtimeMetricsInterval is the interval of the runtime metrics, zero when disabled.
+	runtimeMetricsInterval time.Duration
+	// watchdogTimeout enables the watchdog of the instances, watchdogCancel
+	// makes it restart the stalled goroutines.
+	watchdogTimeout time.Duration
+	watchdogCancel  bool
+	// restartPolicy is set with WithRestartPolicy.
+	restartPolicy *RestartPolicy
+
+	// inputMode is the execution mode requested by the input.
+	inputMode InputMode
+	// oneShot makes the input collect a single time, collected is set once it did.
+	oneShot   bool
+	collected atomic.Bool
+	// scheduleSpec is the default schedule of the input, schedule the one in effect.
+	scheduleSpec string
+	schedule     schedule
+	// onSkip counts the scheduled runs skipped.
+	onSkip func(n int)
+	// overflowPolicy applies to the records sent while the input buffer is full.
+	overflowPolicy OverflowPolicy
+	// spill holds the records overflowing the buffer with OverflowSpill.
+	spill *spill
+	// ringBuffer buffers the records in ring instead of the channel, see WithRingBuffer.
+	ringBuffer bool
+	ring       *inputRing
+	// inputBuf is the C memory the records are handed to fluent-bit in.
+	inputBuf *cmem.Buffer
+	// onDrop counts the records dropped by the overflow policy.
+	onDrop func(n int)
+	// highWater and lowWater are the backpressure thresholds of the input buffer.
+	highWater, lowWater int
+	// backpressure is set while the input is held back by the high-water mark.
+	backpressure atomic.Bool
+	// drained releases the input held back once its buffer is drained.
+	drained chan struct{}
+	// collectInterval is the default interval between the collect callbacks of the input.
+	collectInterval time.Duration
+	// flushPerTag runs a Flush goroutine per tag, up to maxFlushTags of them.
+	flushPerTag  bool
+	maxFlushTags int
+	// idleTimeout is the default time without records before the input goes idle.
+	idleTimeout time.Duration
+
+	// envInterpolation expands the environment variables of the config values.
+	envInterpolation bool
+	// defaults are the config values used for the keys not set.
+	defaults map[string]string
+	// deprecated are the renamed config options.
+	deprecated []deprecatedOption
+
+	// configMap is the configuration schema declared with WithConfigMap,
+	// nativeConfigMap its C representation once requested by fluent-bit.
+	configMap       []ConfigOption
+	nativeConfigMap unsafe.Pointer
+
+	input       InputPlugin
+	output      OutputPlugin
+	chunkOutput ChunkOutputPlugin
+	// metricsOutput is set for plugins registered with RegisterMetricsOutput.
+	metricsOutput MetricsOutputPlugin
+	// tracesOutput is set for plugins registered with RegisterTracesOutput.
+	tracesOutput TracesOutputPlugin
+	filter       FilterPlugin
+	processor    ProcessorPlugin
+
+	// newOutput creates a dedicated output plugin per configured instance.
+	newOutput func() OutputPlugin
+	// outputEvents are the events handled by the values of newOutput besides the logs.
+	outputEvents OutputEvents
+
+	unregister          func()
+	maxBufferedMessages int
+	// codec encodes and decodes the records, the default codec when nil.
+	codec Codec
+	// keyCacheSize is the size of the key caches, negative when disabled. keys is the
+	// cache of filters and processors, outputs have one per instance.
+	keyCacheSize int
+	keys         *keyCache
+	// messageLoans makes outputs loan the messages they flush, see WithMessageLoans.
+	messageLoans bool
+	// lazyRecords makes outputs flush lazy records, see WithLazyRecords.
+	lazyRecords bool
+	// goroutineBudget caps the goroutines of output instances, see WithGoroutineBudget.
+	goroutineBudget int
+
+	// runState is used by inputs, filters and processors, which fluent-bit
+	// invokes without an instance context. Outputs keep theirs per instance.
+	runState
+
+	// inputInterval is the collect interval of the running input instance.
+	inputInterval time.Duration
+	// inputThreaded is set when the running input instance has its own thread.
+	inputThreaded bool
+	// maxBatchRecords and maxBatchBytes limit the records handed by an input callback.
+	maxBatchRecords, maxBatchBytes int
+	// chunkSize is the chunk size set on registration, zero for the default and negative
+	// when disabled. inputChunkSize is the one of the running instance, zero when disabled.
+	chunkSize, inputChunkSize int
+	// batchCarry is the encoded record left over by the byte limit of the last callback.
+	batchCarry []byte
+	// inputIdleTimeout is the idle timeout of the running input instance, idle is set
+	// while the input sleeps since idleSince, lastActive is when it last had records.
+	inputIdleTimeout time.Duration
+	// idleMu guards the idle state, also updated by the OnIdle and OnWake goroutines.
+	// idleHook is closed once the last of them returned, waking is set while OnWake
+	// runs, and inputPaused while fluent-bit paused the input, or it exits, so a wake
+	// does not restart its collectors.
+	idleMu                sync.Mutex
+	idle                  bool
+	idleSince, lastActive time.Time
+	idleHook              chan struct{}
+	waking, inputPaused   bool
+
+	// metricsChannel receives the metrics events of inputs implementing MetricsInput.
+	metricsChannel chan *cmt.Context
+	// tracesChannel receives the trace events of inputs implementing TracesInput.
+	tracesChannel chan *ctr.Traces
+
+	instancesMu sync.Mutex
+	instances   []*outputInstance
+}
+
+// runState holds the goroutines, channel and health state of a running plugin.
+type runState struct {
+	health    *healthReporter
+	runtime   *runtimeReporter
+	pprof     *pprofServer
+	logger    Logger
+	runCtx    context.Context
+	runCancel context.CancelFunc
+	channel   chan Message
+	// config is the snapshot of the config the plugin was last
+	// initialized or reloaded with.
+	config map[string]string
+	// panicPolicy applies to the panics of the plugin code.
+	panicPolicy PanicPolicy
+	// onPanic counts the panics recovered from the plugin code.
+	onPanic func(callback string)
+	// restartPolicy applies to the goroutines of the plugin.
+	restartPolicy RestartPolicy
+	// onRestart counts the restarts of the goroutines of the plugin.
+	onRestart func(callback string)
+	// sdk holds the metrics of the SDK for the instance.
+	sdk *sdkMetrics
+	// watchdog detects the goroutines of the plugin making no progress.
+	watchdog *watchdog
+	// instance is the fluent-bit instance running the plugin.
+	instance InstanceInfo
+	// crash identifies the instance in the crash reports of the panics let through.
+	crash crashInfo
+	// panicked is set once a goroutine of the plugin stopped after a panic.
+	panicked atomic.Bool
+	// goroutines tracks the collect and flush goroutines, so they can be
+	// waited for on exit.
+	goroutines sync.WaitGroup
+	// channelLock synchronizes the collector and flush goroutines with
+	// the channel lifecycle.
+	channelLock sync.Mutex
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []*registration
+	// nextRegister is the index of the next registration to be exposed
+	// to fluent-bit by FLBPluginRegister.
+	nextRegister int
+	// initialized is the number of instances initialized, which fluent-bit exits with
+	// FLBPluginExit or FLBPluginExitCtx, and exited the number of those that exited.
+	initialized, exited int
+)
+
+// register adds a plugin to the registry, it panics if a plugin with the
+// same name or of the same kind has already been registered.
+func register(reg *registration, opts ...RegisterOption) {
+	for _, opt := range opts {
+		opt(reg)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for _, r := range registry {
+		if r.name == reg.name {
+			panic(fmt.Sprintf("plugin %q already registered", reg.name))
+		}
+
+		if r.kind == reg.kind {
+			panic(fmt.Sprintf("%s plugin already registered: %q", reg.kind, r.name))
+		}
+	}
+
+	if reg.maxBufferedMessages == 0 {
+		reg.maxBufferedMessages = defaultMaxBufferedMessages
+	}
+	if reg.initTimeout == 0 {
+		reg.initTimeout = defaultInitTimeout
+	}
+	if reg.collectInterval == 0 {
+		reg.collectInterval = defaultCollectInterval
+	}
+	if reg.kind == filterKind || reg.kind == processorKind {
+		reg.keys = newKeyCache(reg.keyCacheSize)
+	}
+	registry = append(registry, reg)
+}
+
+// nextRegistration returns the next registration to be exposed to fluent-bit.
+// fluent-bit invokes FLBPluginRegister once per plugins.conf entry, so a
+// library listed N times exposes its first N registered plugins.
+func nextRegistration() *registration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if nextRegister >= len(registry) {
+		return nil
+	}
+
+	reg := registry[nextRegister]
+	nextRegister++
+
+	return reg
+}
+
+// initRegistration records an instance initialized, fluent-bit exiting each of them.
+func initRegistration() {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	initialized++
+}
+
+// exitRegistration records the exit of an instance and reports whether it was the last
+// one still running. FLBPluginExit does not tell which plugin exits, only how many did.
+func exitRegistration() bool {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	exited++
+	return exited >= initialized
+}
+
+// lookupRegistration finds a registration by its plugin name. When the name
+// is unknown and a single plugin has been registered, that plugin is returned.
+func lookupRegistration(name string) *registration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for _, r := range registry {
+		if r.name == name {
+			return r
+		}
+	}
+
+	if len(registry) == 1 {
+		return registry[0]
+	}
+
+	return nil
+}
+
+// registrationOf returns the registration of the given kind if any.
+func registrationOf(kind pluginKind) *registration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for _, r := range registry {
+		if r.kind == kind {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// registrations returns a copy of all the registrations.
+func registrations() []*registration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	return append([]*registration(nil), registry...)
+}
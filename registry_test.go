@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestRegisterMultiplePlugins(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	RegisterInput("dummy-input", "", testPluginInputCallbackCtrlC{})
+	RegisterOutput("dummy-output", "", &testOutputHandlerReflect{})
+
+	in := nextRegistration()
+	assert.NotZero(t, in)
+	assert.Equal(t, inputKind, in.kind)
+	assert.Equal(t, "dummy-input", in.name)
+
+	out := nextRegistration()
+	assert.NotZero(t, out)
+	assert.Equal(t, outputKind, out.kind)
+	assert.Equal(t, "dummy-output", out.name)
+
+	assert.Zero(t, nextRegistration())
+
+	assert.Equal(t, in, lookupRegistration("dummy-input"))
+	assert.Equal(t, out, lookupRegistration("dummy-output"))
+	assert.Zero(t, lookupRegistration("unknown"))
+
+	assert.Equal(t, in, registrationOf(inputKind))
+	assert.Equal(t, out, registrationOf(outputKind))
+	assert.Zero(t, registrationOf(filterKind))
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	RegisterInput("dummy", "", testPluginInputCallbackCtrlC{})
+
+	assert.Panics(t, func() {
+		RegisterOutput("dummy", "", &testOutputHandlerReflect{})
+	})
+	assert.Panics(t, func() {
+		RegisterInput("another-dummy", "", testPluginInputCallbackCtrlC{})
+	})
+}
+
+func TestLookupSingleRegistration(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	RegisterOutput("dummy", "", &testOutputHandlerReflect{})
+
+	// the name is ignored when a single plugin has been registered.
+	r := lookupRegistration("unknown")
+	assert.NotZero(t, r)
+	assert.Equal(t, "dummy", r.name)
+}
@@ -0,0 +1,102 @@
+package plugin
+
+import (
+	"context"
+	"sort"
+)
+
+// Reloader is an optional interface input, output, filter and processor plugins
+// can implement to apply a new configuration in place, without the plugin
+// instance being recreated by a fluent-bit hot reload.
+type Reloader interface {
+	Reload(ctx context.Context, conf ConfigLoader) error
+}
+
+// ConfigWatcher is an optional interface plugins can implement instead of Reloader to get
+// the keys changed by a fluent-bit hot reload, so long-lived resources like connections can
+// be kept when unrelated options change. Only the keys listed by the config loader, see
+// ConfigIterator, are compared.
+type ConfigWatcher interface {
+	ConfigChanged(ctx context.Context, conf ConfigLoader, diff ConfigDiff) error
+}
+
+// ConfigDiff lists the config keys changed between two configurations.
+type ConfigDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// IsZero reports whether no key changed.
+func (d ConfigDiff) IsZero() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Has reports whether the given key was added, removed or changed.
+func (d ConfigDiff) Has(key string) bool {
+	for _, keys := range [][]string{d.Added, d.Removed, d.Changed} {
+		for _, k := range keys {
+			if k == key {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// diffConfig compares two config snapshots, the keys are sorted.
+func diffConfig(prev, next map[string]string) ConfigDiff {
+	var diff ConfigDiff
+	for k, v := range next {
+		prevValue, ok := prev[k]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, k)
+		case prevValue != v:
+			diff.Changed = append(diff.Changed, k)
+		}
+	}
+	for k := range prev {
+		if _, ok := next[k]; !ok {
+			diff.Removed = append(diff.Removed, k)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+
+	return diff
+}
+
+// configSnapshot returns all the key/value pairs of loaders implementing ConfigIterator.
+func configSnapshot(conf ConfigLoader) map[string]string {
+	if it, ok := conf.(ConfigIterator); ok {
+		return it.All()
+	}
+
+	return nil
+}
+
+// reloadPlugin applies conf to the plugin, it reports false when the plugin
+// implements neither ConfigWatcher nor Reloader.
+func (s *runState) reloadPlugin(ctx context.Context, plug any, conf ConfigLoader) (bool, error) {
+	next := configSnapshot(conf)
+
+	var err error
+	switch p := plug.(type) {
+	case ConfigWatcher:
+		err = p.ConfigChanged(ctx, conf, diffConfig(s.config, next))
+	case Reloader:
+		err = p.Reload(ctx, conf)
+	default:
+		return false, nil
+	}
+
+	if err == nil {
+		s.config = next
+	}
+
+	return true, err
+}
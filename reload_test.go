@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+type testConfigLoader = MapConfigLoader
+
+type testReloadOutput struct {
+	testOutputCounter
+	param string
+}
+
+func (plug *testReloadOutput) Reload(ctx context.Context, conf ConfigLoader) error {
+	param := conf.String("param")
+	if param == "" {
+		return errors.New("missing param")
+	}
+
+	plug.param = param
+	return nil
+}
+
+func TestReloadPlugin(t *testing.T) {
+	var state runState
+
+	ok, err := state.reloadPlugin(context.Background(), &testOutputCounter{}, testConfigLoader{})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	plug := &testReloadOutput{param: "foo"}
+	ok, err = state.reloadPlugin(context.Background(), plug, testConfigLoader{"param": "bar"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "bar", plug.param)
+
+	ok, err = state.reloadPlugin(context.Background(), plug, testConfigLoader{})
+	assert.EqualError(t, err, "missing param")
+	assert.True(t, ok)
+	assert.Equal(t, "bar", plug.param)
+}
+
+type testWatcherOutput struct {
+	testOutputCounter
+	diffs []ConfigDiff
+}
+
+func (plug *testWatcherOutput) ConfigChanged(ctx context.Context, conf ConfigLoader, diff ConfigDiff) error {
+	plug.diffs = append(plug.diffs, diff)
+	return nil
+}
+
+func TestReloadConfigWatcher(t *testing.T) {
+	state := runState{config: map[string]string{"endpoint": "a", "timeout": "5s", "tls": "on"}}
+	plug := &testWatcherOutput{}
+
+	ok, err := state.reloadPlugin(context.Background(), plug, testConfigLoader{"endpoint": "b", "timeout": "5s", "workers": "2"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = state.reloadPlugin(context.Background(), plug, testConfigLoader{"endpoint": "b", "timeout": "5s", "workers": "2"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	assert.Equal(t, []ConfigDiff{
+		{Added: []string{"workers"}, Removed: []string{"tls"}, Changed: []string{"endpoint"}},
+		{},
+	}, plug.diffs)
+	assert.True(t, plug.diffs[0].Has("tls"))
+	assert.False(t, plug.diffs[0].Has("timeout"))
+	assert.True(t, plug.diffs[1].IsZero())
+}
@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// RestartPolicy configures the restarts of the Collect goroutine of an input after it
+// returns an error, and of the Collect and Flush goroutines after a panic with PanicRestart.
+type RestartPolicy struct {
+	// MaxRetries is the number of consecutive restarts before giving up,
+	// zero disables the restarts and a negative value never gives up.
+	MaxRetries int
+	// MinBackoff is the wait before the first restart, it doubles after each
+	// consecutive failure up to MaxBackoff. Waits are randomized by up to half.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// defaultRestartPolicy restarts forever, waiting from 1 second up to 1 minute.
+var defaultRestartPolicy = RestartPolicy{
+	MaxRetries: -1,
+	MinBackoff: time.Second,
+	MaxBackoff: time.Minute,
+}
+
+// WithRestartPolicy sets how the goroutines of the plugin are restarted after failing,
+// unset backoffs are taken from the default policy: restart forever, waiting from 1 second
+// up to 1 minute. Restarts are counted by the restarts_total metric.
+func WithRestartPolicy(p RestartPolicy) RegisterOption {
+	return func(r *registration) {
+		if p.MinBackoff <= 0 {
+			p.MinBackoff = defaultRestartPolicy.MinBackoff
+		}
+		if p.MaxBackoff < p.MinBackoff {
+			p.MaxBackoff = max(p.MinBackoff, defaultRestartPolicy.MaxBackoff)
+		}
+
+		r.restartPolicy = &p
+	}
+}
+
+// restartPolicyOrDefault returns the restart policy of the registration.
+func (r *registration) restartPolicyOrDefault() RestartPolicy {
+	if r.restartPolicy == nil {
+		return defaultRestartPolicy
+	}
+
+	return *r.restartPolicy
+}
+
+// backoff returns the wait before the given restart attempt, starting at 1.
+func (p RestartPolicy) backoff(attempt int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxBackoff)
+
+	return d/2 + rand.N(d/2+1)
+}
+
+// exhausted reports whether the given restart attempt is over the retries.
+func (p RestartPolicy) exhausted(attempt int) bool {
+	return p.MaxRetries >= 0 && attempt > p.MaxRetries
+}
@@ -0,0 +1,64 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestWithRestartPolicy(t *testing.T) {
+	r := &registration{}
+	assert.Equal(t, defaultRestartPolicy, r.restartPolicyOrDefault())
+
+	WithRestartPolicy(RestartPolicy{MaxRetries: 3})(r)
+	assert.Equal(t, RestartPolicy{MaxRetries: 3, MinBackoff: time.Second, MaxBackoff: time.Minute}, r.restartPolicyOrDefault())
+
+	WithRestartPolicy(RestartPolicy{MinBackoff: 2 * time.Minute})(r)
+	assert.Equal(t, RestartPolicy{MinBackoff: 2 * time.Minute, MaxBackoff: 2 * time.Minute}, r.restartPolicyOrDefault())
+}
+
+func TestRestartPolicyBackoff(t *testing.T) {
+	p := RestartPolicy{MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: 10 * time.Second} {
+		for range 10 {
+			got := p.backoff(attempt)
+			assert.True(t, got >= want/2 && got <= want, "attempt %d: %s", attempt, got)
+		}
+	}
+
+	assert.False(t, RestartPolicy{MaxRetries: -1}.exhausted(100))
+	assert.False(t, RestartPolicy{MaxRetries: 2}.exhausted(2))
+	assert.True(t, RestartPolicy{MaxRetries: 2}.exhausted(3))
+	assert.True(t, RestartPolicy{}.exhausted(1))
+}
+
+func TestRunProtectedRestartErrors(t *testing.T) {
+	metrics := &testNamedMetrics{values: map[string]float64{}}
+	r := &registration{}
+	WithRestartPolicy(RestartPolicy{MaxRetries: 2, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})(r)
+
+	var s runState
+	s.supervise(r, &Fluentbit{Metrics: metrics, Instance: InstanceInfo{Name: "dummy.0"}})
+
+	var calls int
+	err := s.runProtected(context.Background(), "collect", true, func(ctx context.Context) error {
+		calls++
+		return errors.New("connection refused")
+	})
+	assert.EqualError(t, err, "connection refused (gave up after 2 restarts)")
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 2.0, metrics.get("restarts_total{dummy.0,collect}"))
+	assert.Equal(t, 2.0, metrics.get("go_collect_restarts_total{dummy.0}"))
+	assert.False(t, s.panicked.Load())
+
+	calls = 0
+	err = s.runProtected(context.Background(), "flush", false, func(ctx context.Context) error {
+		calls++
+		return errors.New("connection refused")
+	})
+	assert.EqualError(t, err, "connection refused")
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,787 @@
+package plugin
+
+// The runtime of the plugins, driven by the callbacks exported to fluent-bit in cshared.go.
+// Those only convert the C arguments and results, so the runtime can be tested without
+// building a shared library.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/calyptia/plugin/input"
+	"github.com/calyptia/plugin/metric/cmt"
+	"github.com/calyptia/plugin/output"
+	"github.com/calyptia/plugin/trace/ctr"
+)
+
+// initInstance initializes an instance of the plugin with the config and the logger of
+// its fluent-bit instance, then starts its health, runtime metrics, pprof and watchdog.
+// newMetrics returns the metrics of the instance with the given prefix, backed by its
+// cmetrics context. The output instances are returned, for fluent-bit to hand them back
+// to their callbacks.
+func (r *registration) initInstance(info InstanceInfo, conf ConfigLoader, logger Logger, newMetrics func(metricsPrefix) Metrics) (*outputInstance, int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fbit := &Fluentbit{
+		Conf:     conf,
+		Metrics:  r.pluginMetrics(newMetrics(metricsPrefixConfig(conf, r.metricsPrefix)), info.Label()),
+		Service:  currentServiceConfig(),
+		Instance: info,
+		Logger:   newInstanceLogger(logger, r.name, info).withTrace(traceConfig(conf)),
+	}
+
+	var (
+		inst  *outputInstance
+		plug  initer
+		state *runState
+		// restart replaces the goroutines stalled according to the watchdog.
+		restart func()
+		err     error
+	)
+	switch r.kind {
+	case inputKind:
+		r.logger = fbit.Logger
+		plug, state, restart = r.input, &r.runState, r.restartCollect
+		state.supervise(r, fbit)
+		// the SDK keys are validated before Init, fluent-bit only exiting the instances
+		// initialized.
+		var in inputConfig
+		in, err = r.inputConfigFromConf(conf)
+		if err == nil {
+			err = state.runInit(ctx, initTimeout(conf, r.initTimeout), r.input, fbit)
+		}
+		if err != nil {
+			break
+		}
+		r.inputInterval, r.inputThreaded, r.schedule = in.interval, in.threaded, in.schedule
+		if in.maxBufferedMessages > 0 {
+			r.maxBufferedMessages = in.maxBufferedMessages
+		}
+		r.maxBatchRecords, r.maxBatchBytes = in.maxBatchRecords, in.maxBatchBytes
+		r.inputChunkSize = chunkSizeFromConf(conf, r.chunkSize)
+		if r.schedule != nil {
+			skipped := fbit.Metrics.NewCounter("input_skipped_runs_total",
+				"Number of scheduled runs skipped while the previous run was still collecting", "name")
+			label := info.Label()
+			r.onSkip = func(n int) {
+				skipped.Add(float64(n), label)
+			}
+		}
+		r.inputIdleTimeout = idleTimeout(conf, r.idleTimeout)
+		// the input of a reloaded config starts awake.
+		r.idleMu.Lock()
+		r.idle, r.inputPaused = false, false
+		r.idleMu.Unlock()
+		if r.inputBuf == nil {
+			r.inputBuf = inputBuffer(conf)
+		}
+		if r.overflowPolicy == OverflowSpill && r.spill == nil {
+			r.spill = newSpill(spillConfig(conf))
+		}
+		if r.overflowPolicy.drops() {
+			dropped := fbit.Metrics.NewCounter("input_dropped_records_total",
+				"Number of records dropped by the input while its buffer was full", "name")
+			label := info.Label()
+			r.onDrop = func(n int) {
+				dropped.Add(float64(n), label)
+				r.sdk.drop(n)
+			}
+		}
+	case filterKind:
+		r.logger = fbit.Logger
+		plug, state = r.filter, &r.runState
+		state.supervise(r, fbit)
+		// filters have no pre-run callback, so the run context is created here.
+		r.runCtx, r.runCancel = state.newRunContext()
+		err = state.runInit(ctx, initTimeout(conf, r.initTimeout), r.filter, fbit)
+	case processorKind:
+		r.logger = fbit.Logger
+		plug, state = r.processor, &r.runState
+		state.supervise(r, fbit)
+		// processors have no pre-run callback, so the run context is created here.
+		r.runCtx, r.runCancel = state.newRunContext()
+		err = state.runInit(ctx, initTimeout(conf, r.initTimeout), r.processor, fbit)
+	default:
+		inst = r.newOutputInstance()
+		inst.logger = fbit.Logger
+		inst.syncFlush, inst.syncFlushTimeout = syncFlushConfig(conf)
+		inst.copyChunks = copyChunksConfig(conf)
+		inst.goroutineBudget = goroutineBudgetConfig(conf, r.goroutineBudget)
+		inst.decodeWorkers = withinBudget(decodeWorkersConfig(conf), inst.goroutineBudget)
+		inst.debugLoans = debugLoansConfig(conf)
+		plug, state, restart = inst.plugin(), &inst.runState, inst.restartFlush
+		state.supervise(r, fbit)
+		err = state.runInit(ctx, initTimeout(conf, r.initTimeout), plug, fbit)
+		if err != nil {
+			r.removeOutputInstance(inst)
+		}
+	}
+	if err != nil {
+		state.logf(LogError, "init: %v", err)
+		if errors.Is(err, ErrRetry) {
+			return nil, input.FLB_RETRY
+		}
+		return nil, input.FLB_ERROR
+	}
+
+	logConfig(fbit.Logger, conf)
+	applyMaxProcs(fbit.Logger, conf)
+	applyGCTuning(fbit.Logger, conf)
+	r.reportDeprecations(fbit)
+	state.config = configSnapshot(conf)
+
+	if checker, ok := plug.(HealthChecker); ok {
+		state.health = newHealthReporter(info.Label(), checker, fbit.Metrics, healthCheckTimeout(conf))
+		state.health.start(healthCheckInterval(conf))
+	}
+
+	if interval := runtimeMetricsInterval(conf, r.runtimeMetricsInterval); interval > 0 {
+		state.runtime = newRuntimeReporter(info.Label(), instanceMetrics{
+			Metrics: newMetrics(runtimeMetricsPrefix(conf, r.metricsPrefix)),
+			label:   info.Label(),
+		})
+		state.runtime.start(interval)
+	}
+
+	state.pprof = startPprof(fbit.Logger, info.Label(), conf, r.pprofHandler)
+
+	if restart != nil {
+		state.startWatchdog(r, fbit, restart)
+	}
+
+	// filters and processors have no pre-run callback, so they start once initialized.
+	if r.kind == filterKind || r.kind == processorKind {
+		if err := state.onStart(plug); err != nil {
+			state.logf(LogError, "start: %v", err)
+			return nil, input.FLB_ERROR
+		}
+	}
+
+	// fluent-bit only exits the instances initialized.
+	initRegistration()
+
+	return inst, input.FLB_OK
+}
+
+// inputConfig holds the SDK config keys of an input instance.
+type inputConfig struct {
+	interval time.Duration
+	threaded bool
+	schedule schedule
+	// maxBufferedMessages is zero when the go.MaxBufferedMessages key is not set.
+	maxBufferedMessages            int
+	maxBatchRecords, maxBatchBytes int
+}
+
+// inputConfigFromConf reads and validates the SDK config keys of an input instance.
+func (r *registration) inputConfigFromConf(conf ConfigLoader) (inputConfig, error) {
+	var (
+		in  inputConfig
+		err error
+	)
+
+	if in.interval, err = collectIntervalFromConf(conf, r.collectInterval); err != nil {
+		return in, err
+	}
+
+	if in.threaded, err = BoolFromConf(conf, "threaded", r.inputMode == InputModeThreaded); err != nil {
+		return in, err
+	}
+
+	if in.schedule, err = scheduleFromConf(conf, r.scheduleSpec); err != nil {
+		return in, err
+	}
+
+	if s := conf.String("go.MaxBufferedMessages"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return in, fmt.Errorf("go.MaxBufferedMessages: invalid value %q", s)
+		}
+		in.maxBufferedMessages = n
+	}
+
+	in.maxBatchRecords, in.maxBatchBytes, err = batchLimitsFromConf(conf, r.maxBatchRecords, r.maxBatchBytes)
+
+	return in, err
+}
+
+// cleanup releases all the registered plugins and the shared states, once the last of
+// the plugins registered from the library exits: fluent-bit calls FLBPluginExit for
+// each of them.
+func cleanup() int {
+	if !exitRegistration() {
+		return input.FLB_OK
+	}
+
+	for _, r := range registrations() {
+		r.cleanup()
+	}
+
+	closeSharedStates()
+	waitSpawned(currentServiceConfig().Grace)
+
+	return input.FLB_OK
+}
+
+// cleanup stops the plugin and its instances, within the grace period of the service.
+func (r *registration) cleanup() {
+	if r.unregister != nil {
+		r.unregister()
+		r.unregister = nil
+	}
+
+	registryMu.Lock()
+	if r.nativeConfigMap != nil {
+		freeNativeConfigMap(r.nativeConfigMap)
+		r.nativeConfigMap = nil
+	}
+	registryMu.Unlock()
+
+	switch r.kind {
+	case inputKind:
+		r.stopInput()
+		r.onStop(r.input)
+		r.spill.close()
+		r.inputBuf.Close()
+		r.inputBuf = nil
+	case filterKind:
+		r.stop()
+		r.onStop(r.filter)
+	case processorKind:
+		r.stop()
+		r.onStop(r.processor)
+	}
+	r.health.stop()
+	r.runtime.stop()
+	r.pprof.stop()
+	r.watchdog.stop()
+
+	for _, inst := range r.outputInstances() {
+		inst.stop()
+		inst.health.stop()
+		inst.runtime.stop()
+		inst.pprof.stop()
+		inst.watchdog.stop()
+		inst.onStop(inst.plugin())
+	}
+}
+
+// flbPluginReset is meant to reset the plugin between tests.
+func flbPluginReset() {
+	for _, r := range resetRegistry() {
+		r.reset()
+		for _, inst := range r.outputInstances() {
+			inst.reset()
+		}
+	}
+}
+
+// resetRegistry clears the registry and returns the previous registrations.
+func resetRegistry() []*registration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	regs := registry
+	registry = nil
+	nextRegister, initialized, exited = 0, 0, 0
+
+	return regs
+}
+
+func (s *runState) reset() {
+	s.channelLock.Lock()
+	defer s.channelLock.Unlock()
+	defer func() {
+		if ret := recover(); ret != nil {
+			s.logf(LogError, "Channel is already closed")
+			return
+		}
+	}()
+
+	close(s.channel)
+}
+
+// prepareInput is a testing utility.
+func prepareInput(in InputPlugin) *registration {
+	resetRegistry()
+	RegisterInput("test-input", "", in)
+	return registrationOf(inputKind)
+}
+
+// prepareOutputFlush is a testing utility.
+func prepareOutputFlush(out OutputPlugin) *outputInstance {
+	resetRegistry()
+	RegisterOutput("test-output", "", out)
+	inst := registrationOf(outputKind).newOutputInstance()
+	FLBPluginOutputPreRun(0)
+	return inst
+}
+
+// prepareInputCollector starts the collect goroutines of the input. The channel is kept
+// across pauses, so records buffered before the input got paused are delivered on resume.
+func (r *registration) prepareInputCollector() {
+	r.channelLock.Lock()
+	defer r.channelLock.Unlock()
+
+	runCtx, runCancel := r.newRunContext()
+	r.runCtx, r.runCancel = runCtx, runCancel
+	// metrics and traces are only collected when fluent-bit invokes
+	// the callbacks draining them.
+	if _, ok := r.input.(MetricsInput); ok && !hostLacks(FeatureMetricsInput) && r.metricsChannel == nil {
+		r.metricsChannel = make(chan *cmt.Context, r.maxBufferedMessages)
+	}
+	if _, ok := r.input.(TracesInput); ok && !hostLacks(FeatureTracesInput) && r.tracesChannel == nil {
+		r.tracesChannel = make(chan *ctr.Traces, r.maxBufferedMessages)
+	}
+	if r.ringBuffer && !r.overflowPolicy.drops() && r.ring == nil {
+		r.ring = newInputRing(r.maxBufferedMessages)
+	}
+	if r.channel == nil {
+		size := r.maxBufferedMessages
+		if r.ring != nil {
+			size = 0
+		}
+		r.channel = make(chan Message, size)
+	}
+
+	if r.drained == nil {
+		r.drained = make(chan struct{}, 1)
+	}
+
+	collectCh := r.channel
+	var finished chan struct{}
+	if r.ring != nil {
+		finished = make(chan struct{})
+		r.spawn("relay "+r.name, func() {
+			r.ringInput(runCtx, collectCh, finished)
+		})
+	} else if r.relayed() {
+		collectCh, finished = make(chan Message), make(chan struct{})
+		r.spawn("relay "+r.name, func() {
+			r.relayInput(runCtx, collectCh, r.channel, finished)
+		})
+	}
+
+	// one-shot inputs are not collected again once done.
+	if !r.collected.Load() {
+		r.spawn("collect "+r.name, func() {
+			if r.schedule != nil {
+				r.runSchedule(runCtx, r.schedule, collectCh)
+				return
+			}
+
+			r.watchdog.begin()
+			defer r.watchdog.end()
+
+			err := r.runProtected(runCtx, "collect", true, func(ctx context.Context) error {
+				return r.input.Collect(ctx, collectCh)
+			})
+			if err != nil {
+				r.logf(LogError, "collect error: %v", err)
+				return
+			}
+
+			if r.oneShot && runCtx.Err() == nil {
+				if finished != nil {
+					close(finished)
+				} else {
+					r.completeInput()
+				}
+			}
+		})
+	}
+
+	if in, ok := r.input.(MetricsInput); ok && r.metricsChannel != nil {
+		ch := r.metricsChannel
+		r.spawn("collect metrics "+r.name, func() {
+			err := r.runProtected(runCtx, "collect metrics", true, func(ctx context.Context) error {
+				return in.CollectMetrics(ctx, ch)
+			})
+			if err != nil {
+				r.logf(LogError, "collect metrics error: %v", err)
+			}
+		})
+	}
+
+	if in, ok := r.input.(TracesInput); ok && r.tracesChannel != nil {
+		ch := r.tracesChannel
+		r.spawn("collect traces "+r.name, func() {
+			err := r.runProtected(runCtx, "collect traces", true, func(ctx context.Context) error {
+				return in.CollectTraces(ctx, ch)
+			})
+			if err != nil {
+				r.logf(LogError, "collect traces error: %v", err)
+			}
+		})
+	}
+
+	context.AfterFunc(runCtx, func() {
+		r.logf(LogInfo, "goroutine will be stopping")
+	})
+}
+
+// stop cancels the running goroutines. The channel is not closed, as collectors
+// may still be sending to it, buffered records are delivered once resumed.
+func (s *runState) stop() {
+	s.channelLock.Lock()
+	defer s.channelLock.Unlock()
+
+	if s.runCancel != nil {
+		s.runCancel()
+		s.runCancel = nil
+	}
+}
+
+// restartCollect replaces the collect goroutines of a running input, the stalled
+// ones are cancelled and left behind.
+func (r *registration) restartCollect() {
+	r.channelLock.Lock()
+	running := r.runCancel != nil
+	r.channelLock.Unlock()
+
+	if !running {
+		return
+	}
+
+	r.stop()
+	r.prepareInputCollector()
+}
+
+// collectLogs drains the records buffered by the input, encoded as a fluent-bit chunk.
+// full reports that fluent-bit should retry, as the buffer was full or the input is held back.
+func (r *registration) collectLogs() (b []byte, full bool, err error) {
+	defer r.sdk.observe("collect", time.Now())
+
+	var collected int
+	// the records are encoded in place into the input buffer, when available.
+	buf := bytes.NewBuffer(r.inputBuf.Available())
+	// the records are encoded one at a time into a pooled buffer, to apply the batch limits.
+	scratch := getBuffer()
+	defer putBuffer(scratch)
+
+	full = r.overflowPolicy == OverflowRetry && r.inputFull()
+	full = full || r.backpressure.Load()
+
+	// spilled records are replayed without waiting for new ones.
+	if r.takeCarry(buf) {
+		collected++
+	} else if r.spill.len() == 0 {
+		if msg, ok := r.awaitInput(); ok {
+			if _, err := r.encodeToBatch(buf, scratch, msg); err != nil {
+				return nil, false, err
+			}
+			collected++
+		}
+	}
+
+	for loop := min(r.bufferedInput(), r.maxBufferedMessages); loop > 0 && !r.batchFull(buf, collected); loop-- {
+		msg, ok, err := r.nextInput()
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			break
+		}
+
+		added, err := r.encodeToBatch(buf, scratch, msg)
+		if err != nil {
+			return nil, false, err
+		}
+		if added {
+			collected++
+		}
+	}
+
+	// the spilled records are newer than the buffered ones, replay them once the buffer is drained.
+	for loop := r.maxBufferedMessages - collected; loop > 0 && r.bufferedInput() == 0 && !r.batchFull(buf, collected); loop-- {
+		record, err := r.spill.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("spill: %w", err)
+		}
+
+		if r.addToBatch(buf, record) {
+			collected++
+		}
+	}
+
+	buffered := r.bufferedInput() + r.spill.len()
+	if r.batchCarry != nil {
+		buffered++
+	}
+
+	r.releaseInput()
+	r.checkIdle(collected > 0, sdkClock.Now())
+	r.sdk.collected(collected, buffered)
+	if collected > 0 {
+		r.watchdog.progress()
+	}
+	if collected > 0 && tracing(r.logger) {
+		TraceHex(r.logger, "collect", buf.Bytes(), "records", collected)
+	}
+
+	return buf.Bytes(), full, nil
+}
+
+// nextInput takes the next buffered record without waiting, ok is false once the buffer
+// is empty or the input is stopped.
+func (r *registration) nextInput() (msg Message, ok bool, err error) {
+	if r.ring != nil {
+		msg, ok = r.ring.pop()
+		return msg, ok, nil
+	}
+
+	select {
+	case msg, ok := <-r.channel:
+		if !ok {
+			return Message{}, false, errors.New("channel closed")
+		}
+
+		return msg, true, nil
+	case <-r.runCtx.Done():
+		err := r.runCtx.Err()
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return Message{}, false, fmt.Errorf("run: %w", err)
+		}
+	default:
+	}
+
+	return Message{}, false, nil
+}
+
+// handleFlush flushes a chunk to the instance, it is invoked concurrently by the
+// fluent-bit output workers, and by the threads of the different instances. The run
+// context and the channel are read under the flush lock, they are only replaced or
+// closed once all the in-flight flushes have returned.
+func (o *outputInstance) handleFlush(worker int, tag string, in []byte) (ret int) {
+	defer func(start time.Time) {
+		o.sdk.observe("flush", start)
+		o.sdk.flushed(ret)
+	}(time.Now())
+
+	if o.panicked.Load() {
+		o.logf(LogError, "flush: stopped after a panic worker=%d", worker)
+		return output.FLB_ERROR
+	}
+
+	o.flushLock.RLock()
+	defer o.flushLock.RUnlock()
+
+	runCtx, _, _ := o.running()
+	if runCtx == nil {
+		o.logf(LogError, "flush: not running worker=%d", worker)
+		return output.FLB_RETRY
+	}
+
+	o.watchdog.begin()
+	defer o.watchdog.end()
+
+	var err error
+	select {
+	case <-runCtx.Done():
+		err = runCtx.Err()
+		if err != nil && !errors.Is(err, context.Canceled) {
+			o.logf(LogError, "run: %s worker=%d", err, worker)
+			return output.FLB_ERROR
+		}
+
+		// sync flushes only complete once the records are delivered.
+		if o.syncFlush {
+			return output.FLB_RETRY
+		}
+
+		return output.FLB_OK
+	default:
+	}
+
+	if tracing(o.logger) {
+		TraceHex(o.logger, "flush", in, "tag", tag, "worker", worker)
+	}
+
+	chunkCtx := withChunk(runCtx, worker, tag)
+	if o.metricsOutput != nil || o.tracesOutput != nil {
+		switch payloadEventType(in) {
+		case metricsEvent:
+			if o.metricsOutput == nil {
+				break
+			}
+
+			if err := o.protect("flush", func() error { return o.pluginFlushMetrics(chunkCtx, tag, in) }); err != nil {
+				o.logf(LogError, "flush metrics: %s worker=%d", err, worker)
+				return flushResult(err)
+			}
+
+			return output.FLB_OK
+		case tracesEvent:
+			if o.tracesOutput == nil {
+				break
+			}
+
+			if err := o.protect("flush", func() error { return o.pluginFlushTraces(chunkCtx, tag, in) }); err != nil {
+				o.logf(LogError, "flush traces: %s worker=%d", err, worker)
+				return flushResult(err)
+			}
+
+			return output.FLB_OK
+		}
+	}
+
+	if o.output == nil && o.chunkOutput == nil {
+		o.logf(LogError, "flush: logs not handled worker=%d", worker)
+		return output.FLB_ERROR
+	}
+
+	if o.batchOutput != nil {
+		if err := o.protect("flush", func() error { return o.pluginFlushBatch(chunkCtx, worker, tag, in) }); err != nil {
+			o.logf(LogError, "flush batch: %s worker=%d", err, worker)
+			return flushResult(err)
+		}
+
+		return output.FLB_OK
+	}
+
+	if o.chunkOutput != nil {
+		if err := o.protect("flush", func() error { return o.pluginFlushChunk(chunkCtx, worker, tag, in) }); err != nil {
+			o.logf(LogError, "flush chunk: %s worker=%d", err, worker)
+			return flushResult(err)
+		}
+
+		return output.FLB_OK
+	}
+
+	if err := o.pluginFlush(worker, tag, in); err != nil {
+		o.logf(LogError, "flush: %s worker=%d", err, worker)
+		return flushResult(err)
+	}
+
+	return output.FLB_OK
+}
+
+func (o *outputInstance) pluginFlush(worker int, tag string, b []byte) error {
+	runCtx, ch, _ := o.running()
+	if runCtx == nil {
+		return fmt.Errorf("%q is not running: %w", o.reg.name, ErrRetry)
+	}
+	// the errors returned by Flush fail the chunk being flushed.
+	errs := o.flushErrors()
+	if o.reg.flushPerTag {
+		var release func()
+		ch, errs, release = o.tagChannel(tag)
+		defer release()
+	}
+
+	var ack *chunkAck
+	if o.syncFlush {
+		ack = newChunkAck()
+	}
+
+	dec, wait := o.chunkDecoder(b)
+	defer wait()
+
+	for {
+		select {
+		case <-runCtx.Done():
+			err := runCtx.Err()
+			if err != nil && !errors.Is(err, context.Canceled) {
+				o.logf(LogError, "run: %s worker=%d", err, worker)
+				return fmt.Errorf("run: %w", err)
+			}
+
+			// the records left were not delivered, the chunk is retried.
+			if ack != nil {
+				return fmt.Errorf("flush interrupted: %w", ErrRetry)
+			}
+
+			return nil
+		default:
+		}
+
+		msg, err := decodeMessage(dec, &tag)
+		if errors.Is(err, io.EOF) {
+			return ack.wait(runCtx, o.syncFlushTimeout, errs)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		msg.worker = worker
+		o.loan(&msg)
+		if ack != nil {
+			msg.ack = ack.message()
+		}
+		select {
+		case ch <- msg:
+			if ack != nil {
+				ack.add()
+			}
+			o.watchdog.progress()
+		case err := <-errs:
+			return err
+		case <-runCtx.Done():
+			if ack != nil {
+				return fmt.Errorf("flush interrupted: %w", ErrRetry)
+			}
+
+			return nil
+		}
+	}
+}
+
+// runFilter filters the records of a chunk, the result is written to out.
+func (r *registration) runFilter(tag string, in []byte, out *bytes.Buffer) ([]byte, error) {
+	defer r.sdk.observe("filter", time.Now())
+
+	if tracing(r.logger) {
+		TraceHex(r.logger, "filter", in, "tag", tag)
+	}
+
+	var b []byte
+	err := r.protect("filter", func() (err error) {
+		b, err = r.pluginFilter(r.runCtx, tag, in, out)
+		return err
+	})
+
+	return b, err
+}
+
+// runProcessor processes a chunk of the given event type: logs, metrics or traces.
+// Chunks of event types the processor does not handle are passed through. Processed
+// logs are written to out.
+func (r *registration) runProcessor(event, tag string, in []byte, out *bytes.Buffer) ([]byte, error) {
+	callback := "process " + event
+	defer r.sdk.observe(callback, time.Now())
+
+	var (
+		b  []byte
+		ok bool
+	)
+	err := r.protect(callback, func() (err error) {
+		switch event {
+		case "metrics":
+			b, ok, err = r.pluginProcessMetrics(r.runCtx, tag, in)
+		case "traces":
+			b, ok, err = r.pluginProcessTraces(r.runCtx, tag, in)
+		default:
+			b, ok, err = r.pluginProcessLogs(r.runCtx, tag, in, out)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return in, nil
+	}
+
+	return b, nil
+}
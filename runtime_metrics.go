@@ -0,0 +1,180 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+
+	"github.com/calyptia/plugin/flbconf"
+	"github.com/calyptia/plugin/metric"
+)
+
+// runtimeMetricsSubsystem is the subsystem of the runtime metrics, in place of the one of
+// the plugin metrics, e.g. fluentbit_runtime_go_goroutines.
+const runtimeMetricsSubsystem = "runtime"
+
+// runtimeStats are the runtime/metrics read by the runtime reporter, the cumulative ones
+// being counters.
+var runtimeStats = []struct {
+	sample  string
+	name    string
+	desc    string
+	counter bool
+}{
+	{"/sched/goroutines:goroutines", "go_goroutines", "Number of goroutines of the Go runtime", false},
+	{"/sched/gomaxprocs:threads", "go_gomaxprocs", "GOMAXPROCS of the Go runtime", false},
+	{"/memory/classes/heap/objects:bytes", "go_heap_bytes", "Bytes of the heap in use by the Go runtime", false},
+	{"/gc/heap/objects:objects", "go_heap_objects", "Number of objects in the heap of the Go runtime", false},
+	{"/gc/heap/goal:bytes", "go_heap_goal_bytes", "Heap size of the next GC cycle of the Go runtime", false},
+	{"/memory/classes/total:bytes", "go_memory_total_bytes", "Bytes of memory mapped by the Go runtime", false},
+	{"/gc/gogc:percent", "go_gc_percent", "GOGC of the Go runtime, negative when the GC is off", false},
+	{"/gc/cycles/total:gc-cycles", "go_gc_cycles_total", "Number of GC cycles of the Go runtime", true},
+	{"/cgo/go-to-c-calls:calls", "go_cgo_calls_total", "Number of calls made from Go to C", true},
+}
+
+// WithRuntimeMetrics samples the stats of the Go runtime into the metrics of the instances
+// every interval, under the runtime subsystem, so the health of the Go shim shows next to
+// the metrics of fluent-bit. They are off by default. Instances can set the interval with
+// the go.RuntimeMetricsInterval config key, zero disabling them. It panics with a non
+// positive interval.
+func WithRuntimeMetrics(interval time.Duration) RegisterOption {
+	return func(r *registration) {
+		if interval <= 0 {
+			panic(fmt.Sprintf("invalid runtime metrics interval %s: %q", interval, r.name))
+		}
+
+		r.runtimeMetricsInterval = interval
+	}
+}
+
+// runtimeMetricsInterval reads the go.RuntimeMetricsInterval config key, or its former
+// go.MemoryStatsInterval name, zero disabling the runtime metrics.
+func runtimeMetricsInterval(conf ConfigLoader, def time.Duration) time.Duration {
+	key := "go.RuntimeMetricsInterval"
+	s := conf.String(key)
+	if s == "" {
+		key = "go.MemoryStatsInterval"
+		s = conf.String(key)
+	}
+	if s == "" {
+		return def
+	}
+
+	d, err := flbconf.ParseTime(s)
+	if err != nil || d < 0 {
+		fmt.Fprintf(os.Stderr, "invalid %s %q, using %s\n", key, s, def)
+		return def
+	}
+
+	return d
+}
+
+// runtimeMetricsPrefix returns the prefix of the runtime metrics, the namespace of the
+// plugin metrics with the runtime subsystem.
+func runtimeMetricsPrefix(conf ConfigLoader, def *metricsPrefix) metricsPrefix {
+	p := metricsPrefixConfig(conf, def)
+	p.subsystem = runtimeMetricsSubsystem
+	return p
+}
+
+// runtimeReporter samples the stats of the Go runtime into the metrics of a plugin
+// instance: the goroutines, GOMAXPROCS, the heap and the memory mapped, the GOGC percent,
+// the GC cycles and pauses, and the cgo calls.
+type runtimeReporter struct {
+	name string
+	// gauges and counters hold the metrics of the stats, by their index.
+	gauges   []metric.Gauge
+	counters []metric.Counter
+	pauses   metric.Counter
+	samples  []metrics.Sample
+	// last holds the cumulative stats of the previous sample, the counters adding the
+	// difference, and lastPauses the total of the GC pauses.
+	last       []uint64
+	lastPauses time.Duration
+	cancel     context.CancelFunc
+}
+
+func newRuntimeReporter(name string, m Metrics) *runtimeReporter {
+	r := &runtimeReporter{
+		name:     name,
+		gauges:   make([]metric.Gauge, len(runtimeStats)),
+		counters: make([]metric.Counter, len(runtimeStats)),
+		samples:  make([]metrics.Sample, len(runtimeStats)),
+		last:     make([]uint64, len(runtimeStats)),
+		pauses:   m.NewCounter("go_gc_pause_seconds_total", "Time the Go runtime paused for the GC"),
+	}
+
+	for i, s := range runtimeStats {
+		r.samples[i].Name = s.sample
+		if s.counter {
+			r.counters[i] = m.NewCounter(s.name, s.desc)
+		} else {
+			r.gauges[i] = m.NewGauge(s.name, s.desc)
+		}
+	}
+
+	return r
+}
+
+// sample reads the runtime stats and updates the metrics. Stats unknown to the runtime
+// are skipped. The GC pauses are read from their total, runtime/metrics only exposing
+// their histogram.
+func (r *runtimeReporter) sample() {
+	metrics.Read(r.samples)
+	for i, s := range r.samples {
+		if s.Value.Kind() != metrics.KindUint64 {
+			continue
+		}
+
+		v := s.Value.Uint64()
+		if r.counters[i] == nil {
+			r.gauges[i].Set(float64(v))
+			continue
+		}
+
+		if v > r.last[i] {
+			r.counters[i].Add(float64(v - r.last[i]))
+		}
+		r.last[i] = v
+	}
+
+	// the pauses are not kept, only their total.
+	gc := debug.GCStats{Pause: make([]time.Duration, 0)}
+	debug.ReadGCStats(&gc)
+	if d := gc.PauseTotal - r.lastPauses; d > 0 {
+		r.pauses.Add(d.Seconds())
+	}
+	r.lastPauses = gc.PauseTotal
+}
+
+// start samples the runtime stats every interval until stopped.
+func (r *runtimeReporter) start(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	spawn("runtime metrics "+r.name, func() {
+		ticker := sdkClock.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			r.sample()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+			}
+		}
+	})
+}
+
+func (r *runtimeReporter) stop() {
+	if r == nil || r.cancel == nil {
+		return
+	}
+
+	r.cancel()
+}
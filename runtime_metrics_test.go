@@ -0,0 +1,97 @@
+package plugin
+
+import (
+	"runtime"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestRuntimeReporter(t *testing.T) {
+	metrics := &testNamedMetrics{values: map[string]float64{}}
+	r := newRuntimeReporter("dummy.0", instanceMetrics{Metrics: metrics, label: "dummy.0"})
+
+	r.sample()
+	runtime.GC()
+	r.sample()
+
+	assert.True(t, metrics.get("go_goroutines{dummy.0}") >= 1)
+	assert.Equal(t, float64(runtime.GOMAXPROCS(0)), metrics.get("go_gomaxprocs{dummy.0}"))
+	assert.True(t, metrics.get("go_heap_bytes{dummy.0}") > 0)
+	assert.True(t, metrics.get("go_heap_objects{dummy.0}") > 0)
+	assert.True(t, metrics.get("go_heap_goal_bytes{dummy.0}") > 0)
+	assert.True(t, metrics.get("go_memory_total_bytes{dummy.0}") >= metrics.get("go_heap_bytes{dummy.0}"))
+	assert.Equal(t, float64(debug.SetGCPercent(-1)), metrics.get("go_gc_percent{dummy.0}"))
+	debug.SetGCPercent(int(metrics.get("go_gc_percent{dummy.0}")))
+
+	// the counters add the cycles since the previous sample, up to the ones since.
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+	cycles := metrics.get("go_gc_cycles_total{dummy.0}")
+	assert.True(t, cycles >= 1 && cycles <= float64(gc.NumGC))
+	assert.True(t, metrics.get("go_cgo_calls_total{dummy.0}") <= float64(runtime.NumCgoCall()))
+	pauses := metrics.get("go_gc_pause_seconds_total{dummy.0}")
+	assert.True(t, pauses > 0 && pauses <= gc.PauseTotal.Seconds()+1e-9)
+
+	var none *runtimeReporter
+	none.stop()
+}
+
+func TestRuntimeReporterStart(t *testing.T) {
+	clk := useFakeClock(t)
+	metrics := &testNamedMetrics{values: map[string]float64{}}
+	r := newRuntimeReporter("dummy.0", instanceMetrics{Metrics: metrics, label: "dummy.0"})
+
+	r.start(time.Second)
+	defer r.stop()
+
+	// the stats are sampled on start, then every interval.
+	clk.awaitTimers(t, 1)
+	for _, want := range []int{1, 2} {
+		deadline := time.Now().Add(time.Second)
+		for metrics.get("go_goroutines{dummy.0}") == 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("runtime stats not sampled %d times", want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		metrics.mu.Lock()
+		clear(metrics.values)
+		metrics.mu.Unlock()
+		clk.Advance(time.Second)
+	}
+}
+
+func TestWithRuntimeMetrics(t *testing.T) {
+	r := &registration{name: "dummy"}
+	WithRuntimeMetrics(time.Minute)(r)
+	assert.Equal(t, time.Minute, r.runtimeMetricsInterval)
+
+	assert.Panics(t, func() {
+		WithRuntimeMetrics(0)(r)
+	})
+}
+
+func TestRuntimeMetricsInterval(t *testing.T) {
+	assert.Equal(t, time.Duration(0), runtimeMetricsInterval(testConfigLoader{}, 0))
+	assert.Equal(t, time.Minute, runtimeMetricsInterval(testConfigLoader{}, time.Minute))
+	assert.Equal(t, 10*time.Second, runtimeMetricsInterval(testConfigLoader{"go.RuntimeMetricsInterval": "10s"}, 0))
+	assert.Equal(t, 15*time.Second, runtimeMetricsInterval(testConfigLoader{"go.RuntimeMetricsInterval": "15"}, 0))
+	assert.Equal(t, time.Duration(0), runtimeMetricsInterval(testConfigLoader{"go.RuntimeMetricsInterval": "0"}, time.Minute))
+	assert.Equal(t, time.Minute, runtimeMetricsInterval(testConfigLoader{"go.RuntimeMetricsInterval": "-1s"}, time.Minute))
+	assert.Equal(t, time.Minute, runtimeMetricsInterval(testConfigLoader{"go.RuntimeMetricsInterval": "often"}, time.Minute))
+
+	// the former name of the key still applies.
+	assert.Equal(t, 10*time.Second, runtimeMetricsInterval(testConfigLoader{"go.MemoryStatsInterval": "10s"}, 0))
+	assert.Equal(t, time.Minute, runtimeMetricsInterval(testConfigLoader{"go.MemoryStatsInterval": "10s", "go.RuntimeMetricsInterval": "1m"}, 0))
+}
+
+func TestRuntimeMetricsPrefix(t *testing.T) {
+	assert.Equal(t, metricsPrefix{namespace: "fluentbit", subsystem: "runtime"},
+		runtimeMetricsPrefix(testConfigLoader{}, nil))
+	assert.Equal(t, metricsPrefix{namespace: "acme", subsystem: "runtime"},
+		runtimeMetricsPrefix(testConfigLoader{"go.MetricsSubsystem": "shipper"}, &metricsPrefix{namespace: "acme"}))
+}
@@ -0,0 +1,199 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/calyptia/plugin/input"
+	"github.com/calyptia/plugin/metric/cmt"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestCollectLogs(t *testing.T) {
+	defer resetRegistry()
+
+	r := prepareInput(testPluginInputCallbackCtrlC{})
+	r.prepareInputCollector()
+	defer r.runCancel()
+
+	r.channel <- Message{Time: time.Now(), Record: map[string]string{"foo": "bar"}}
+	r.channel <- Message{Time: time.Now(), Record: map[string]string{"foo": "baz"}}
+
+	b, full, err := r.collectLogs()
+	assert.NoError(t, err)
+	assert.False(t, full)
+
+	var records int
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	for {
+		_, err := decodeMsg(dec, "")
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		assert.NoError(t, err)
+		records++
+	}
+	assert.Equal(t, 2, records)
+
+	// held back inputs ask fluent-bit to retry.
+	r.backpressure.Store(true)
+	b, full, err = r.collectLogs()
+	assert.NoError(t, err)
+	assert.True(t, full)
+	assert.Zero(t, len(b))
+}
+
+type testFilterPanic struct{}
+
+func (t testFilterPanic) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (t testFilterPanic) Filter(ctx context.Context, in []Message) ([]Message, error) {
+	panic("boom")
+}
+
+func TestRunFilter(t *testing.T) {
+	in, err := encodeMessages(defaultCodec, nil, []Message{{Time: time.Now(), Record: map[string]any{"idx": 0}}})
+	assert.NoError(t, err)
+
+	r := &registration{filter: testFilterDropOdd{}}
+	b, err := r.runFilter("foobar", in, nil)
+	assert.NoError(t, err)
+	assert.NotZero(t, len(b))
+
+	r = &registration{filter: testFilterPanic{}}
+	_, err = r.runFilter("foobar", in, nil)
+	assert.EqualError(t, err, "filter: panic: boom")
+}
+
+func TestRunProcessor(t *testing.T) {
+	in, err := encodeMessages(defaultCodec, nil, []Message{{Time: time.Now(), Record: map[string]any{"foo": "bar"}}})
+	assert.NoError(t, err)
+
+	r := &registration{processor: testLogsProcessor{}}
+	b, err := r.runProcessor("logs", "foobar", in, nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, in, b)
+
+	// event types the processor does not handle are passed through.
+	b, err = r.runProcessor("metrics", "foobar", in, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, in, b)
+}
+
+type testRetryOutput struct {
+	testOutputCounter
+}
+
+func (plug *testRetryOutput) Init(ctx context.Context, fbit *Fluentbit) error {
+	return fmt.Errorf("not ready: %w", ErrRetry)
+}
+
+// testInitInput counts the calls to its Init.
+type testInitInput struct {
+	testLifecycle
+	inits int
+}
+
+func (plug *testInitInput) Init(ctx context.Context, fbit *Fluentbit) error {
+	plug.inits++
+	return nil
+}
+
+func testInstanceMetrics(metricsPrefix) Metrics {
+	return &testNamedMetrics{values: map[string]float64{}}
+}
+
+func TestInitInstance(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	RegisterFilter("test-filter", "", testFilterDropOdd{})
+	r := registrationOf(filterKind)
+	defer r.cleanup()
+
+	// filters start once initialized, with no pre-run callback.
+	inst, ret := r.initInstance(InstanceInfo{Name: "test-filter.0"}, r.configLoader(testConfigLoader{}), &testLogger{}, testInstanceMetrics)
+	assert.Equal(t, input.FLB_OK, ret)
+	assert.Zero(t, inst)
+	assert.NotZero(t, r.runCtx)
+	assert.NotZero(t, r.logger)
+
+	resetRegistry()
+	RegisterOutput("test-output", "", &testOutputCounter{})
+	r = registrationOf(outputKind)
+	defer r.cleanup()
+
+	// each output instance is returned, for fluent-bit to hand it back.
+	inst, ret = r.initInstance(InstanceInfo{Name: "test-output.0"}, r.configLoader(testConfigLoader{}), &testLogger{}, testInstanceMetrics)
+	assert.Equal(t, input.FLB_OK, ret)
+	assert.NotZero(t, inst)
+	assert.Equal(t, []*outputInstance{inst}, r.outputInstances())
+
+	resetRegistry()
+	RegisterOutput("test-output", "", &testRetryOutput{})
+	r = registrationOf(outputKind)
+
+	// the instances failing to initialize are released.
+	inst, ret = r.initInstance(InstanceInfo{Name: "test-output.0"}, r.configLoader(testConfigLoader{}), &testLogger{}, testInstanceMetrics)
+	assert.Equal(t, input.FLB_RETRY, ret)
+	assert.Zero(t, inst)
+	assert.Zero(t, len(r.outputInstances()))
+}
+
+func TestInitInstanceInvalidConfig(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	plug := &testInitInput{}
+	RegisterInput("test-input", "", plug, WithOverflowPolicy(OverflowDropNewest))
+	r := registrationOf(inputKind)
+	defer r.cleanup()
+
+	metrics := cmt.NewMetrics("fluentbit", "plugin")
+	newMetrics := func(metricsPrefix) Metrics { return metrics }
+	families := func() []string {
+		got, err := metrics.Snapshot()
+		assert.NoError(t, err)
+
+		var names []string
+		for _, m := range got {
+			names = append(names, m.Meta.Opts.Name)
+		}
+		return names
+	}
+
+	// the SDK keys are validated before Init, the instances not initialized not being
+	// exited by fluent-bit.
+	for _, conf := range []testConfigLoader{
+		{"collect_interval": "soon"},
+		{"threaded": "maybe"},
+		{"schedule": "@never"},
+		{"go.MaxBufferedMessages": "0"},
+		{"go.MaxBatchRecords": "-1"},
+	} {
+		_, ret := r.initInstance(InstanceInfo{Name: "test-input.0"}, r.configLoader(conf), &testLogger{}, newMetrics)
+		assert.Equal(t, input.FLB_ERROR, ret)
+	}
+	assert.Zero(t, plug.inits)
+	assert.NotSliceContains(t, families(), "input_skipped_runs_total")
+	assert.NotSliceContains(t, families(), "input_dropped_records_total")
+
+	_, ret := r.initInstance(InstanceInfo{Name: "test-input.0"}, r.configLoader(testConfigLoader{
+		"schedule":               "@every 1m",
+		"go.MaxBufferedMessages": "8",
+	}), &testLogger{}, newMetrics)
+	assert.Equal(t, input.FLB_OK, ret)
+	assert.Equal(t, 1, plug.inits)
+	assert.Equal(t, 8, r.maxBufferedMessages)
+	assert.NotZero(t, r.schedule)
+	assert.SliceContains(t, families(), "input_skipped_runs_total")
+	assert.SliceContains(t, families(), "input_dropped_records_total")
+}
@@ -0,0 +1,276 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithSchedule makes the input collect on a schedule instead of running Collect once for
+// the lifetime of the plugin: Collect is invoked at every scheduled time, and is expected
+// to return once the records of the run are sent. Instances can override the schedule
+// with the schedule config key. It panics when used to register other kinds of plugins
+// or with an invalid spec.
+//
+// The spec is either a standard cron expression with 5 fields (minute, hour, day of month,
+// month, day of week), a descriptor like @hourly or @daily, or an interval like @every 30s.
+// Runs are never overlapping: the ones scheduled while the previous run is still collecting
+// are skipped, and counted by the input_skipped_runs_total metric.
+func WithSchedule(spec string) RegisterOption {
+	return func(r *registration) {
+		if r.kind != inputKind {
+			panic(fmt.Sprintf("schedule set on %s plugin: %q", r.kind, r.name))
+		}
+
+		if _, err := parseSchedule(spec); err != nil || spec == "" {
+			panic(fmt.Sprintf("invalid schedule %q: %q", spec, r.name))
+		}
+
+		r.scheduleSpec = spec
+	}
+}
+
+// scheduleFromConf reads the schedule config key, the input is not scheduled
+// when neither the key nor a default spec are set.
+func scheduleFromConf(conf ConfigLoader, def string) (schedule, error) {
+	spec := conf.String("schedule")
+	if spec == "" {
+		spec = def
+	}
+
+	sched, err := parseSchedule(spec)
+	if err != nil {
+		return nil, fmt.Errorf("schedule: %w", err)
+	}
+
+	return sched, nil
+}
+
+// schedule returns the next run time after the given time,
+// the zero time when there is none.
+type schedule interface {
+	next(t time.Time) time.Time
+}
+
+// everySchedule runs at a fixed interval.
+type everySchedule time.Duration
+
+func (s everySchedule) next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// cronSchedule runs at the times matching a cron expression, each field is
+// a bit set of the matching values.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// the days match when both the day of month and day of week match, or
+	// either of them when both are restricted, like cron does.
+	domRestricted, dowRestricted bool
+}
+
+var scheduleDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseSchedule parses a cron expression, a descriptor or an interval,
+// an empty spec returns a nil schedule.
+func parseSchedule(spec string) (schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	if s, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid interval %q", s)
+		}
+
+		return everySchedule(d), nil
+	}
+
+	if s, ok := scheduleDescriptors[spec]; ok {
+		spec = s
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d: %q", len(fields), spec)
+	}
+
+	var (
+		s   cronSchedule
+		err error
+	)
+	for i, f := range []struct {
+		bits     *uint64
+		min, max int
+	}{
+		{&s.minute, 0, 59},
+		{&s.hour, 0, 23},
+		{&s.dom, 1, 31},
+		{&s.month, 1, 12},
+		{&s.dow, 0, 7},
+	} {
+		*f.bits, err = parseCronField(fields[i], f.min, f.max)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %w", i+1, err)
+		}
+	}
+
+	// sunday is both 0 and 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domRestricted, s.dowRestricted = fields[2] != "*", fields[4] != "*"
+
+	return &s, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges and
+// steps, e.g. "1,15-20,*/10", into a bit set.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			lo, err = strconv.Atoi(loStr)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+
+			hi = lo
+			if isRange {
+				hi, err = strconv.Atoi(hiStr)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+
+	return bits, nil
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<t.Weekday()) != 0
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+
+	return dom && dow
+}
+
+func (s *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+
+	// expressions like "0 0 30 2 *" never match, give up after a few years.
+	limit := t.Year() + 5
+	for t.Year() <= limit {
+		switch {
+		case s.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// runSchedule invokes Collect at every scheduled time until the context is cancelled.
+// Runs failing are logged and the next ones still happen, the runs scheduled while
+// collecting are skipped.
+func (r *registration) runSchedule(ctx context.Context, sched schedule, ch chan<- Message) {
+	at := sched.next(sdkClock.Now())
+	for !at.IsZero() {
+		timer := sdkClock.NewTimer(at.Sub(sdkClock.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		r.watchdog.begin()
+		err := r.runProtected(ctx, "collect", false, func(ctx context.Context) error {
+			return r.input.Collect(ctx, ch)
+		})
+		r.watchdog.end()
+
+		var perr *panicError
+		if errors.As(err, &perr) && r.panicked.Load() {
+			r.logf(LogError, "collect error: %v", err)
+			return
+		}
+		if err != nil {
+			r.logf(LogError, "scheduled collect error: %v", err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		now := sdkClock.Now()
+		next := sched.next(at)
+		var skipped int
+		for !next.IsZero() && !next.After(now) {
+			skipped++
+			next = sched.next(next)
+		}
+		if skipped > 0 {
+			r.skipRuns(skipped)
+		}
+
+		at = next
+	}
+}
+
+// skipRuns reports the runs skipped to avoid overlapping a run still collecting.
+func (r *registration) skipRuns(n int) {
+	if r.onSkip != nil {
+		r.onSkip(n)
+	}
+
+	if r.logger != nil {
+		r.logger.Warn("scheduled collect took too long, skipped %d runs", n)
+	}
+}
@@ -0,0 +1,142 @@
+package plugin
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// a wednesday.
+	base := time.Date(2024, time.January, 10, 10, 30, 15, 0, time.UTC)
+
+	for spec, want := range map[string]time.Time{
+		"* * * * *":      time.Date(2024, time.January, 10, 10, 31, 0, 0, time.UTC),
+		"*/15 * * * *":   time.Date(2024, time.January, 10, 10, 45, 0, 0, time.UTC),
+		"0 9-17 * * *":   time.Date(2024, time.January, 10, 11, 0, 0, 0, time.UTC),
+		"5,10 8 * * *":   time.Date(2024, time.January, 11, 8, 5, 0, 0, time.UTC),
+		"0 0 * * 0":      time.Date(2024, time.January, 14, 0, 0, 0, 0, time.UTC),
+		"0 0 * * 7":      time.Date(2024, time.January, 14, 0, 0, 0, 0, time.UTC),
+		"0 0 1 * 5":      time.Date(2024, time.January, 12, 0, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":     time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		"30 12 */10 * *": time.Date(2024, time.January, 11, 12, 30, 0, 0, time.UTC),
+		"@hourly":        time.Date(2024, time.January, 10, 11, 0, 0, 0, time.UTC),
+		"@daily":         time.Date(2024, time.January, 11, 0, 0, 0, 0, time.UTC),
+		"@yearly":        time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		"@every 90s":     base.Add(90 * time.Second),
+	} {
+		sched, err := parseSchedule(spec)
+		assert.NoError(t, err, spec)
+		assert.Equal(t, want, sched.next(base), spec)
+	}
+
+	sched, err := parseSchedule("0 0 30 2 *")
+	assert.NoError(t, err)
+	assert.Zero(t, sched.next(base))
+
+	sched, err = parseSchedule("")
+	assert.NoError(t, err)
+	assert.Zero(t, sched)
+
+	for _, spec := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@every -1s",
+		"@every soon",
+		"@sometimes",
+	} {
+		_, err := parseSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestWithSchedule(t *testing.T) {
+	r := &registration{kind: inputKind, name: "dummy"}
+	WithSchedule("@every 1m")(r)
+	assert.Equal(t, "@every 1m", r.scheduleSpec)
+
+	assert.Panics(t, func() {
+		WithSchedule("@daily")(&registration{kind: outputKind, name: "dummy"})
+	})
+	assert.Panics(t, func() {
+		WithSchedule("* * *")(&registration{kind: inputKind, name: "dummy"})
+	})
+	assert.Panics(t, func() {
+		WithSchedule("")(&registration{kind: inputKind, name: "dummy"})
+	})
+}
+
+func TestScheduleFromConf(t *testing.T) {
+	sched, err := scheduleFromConf(testConfigLoader{}, "")
+	assert.NoError(t, err)
+	assert.Zero(t, sched)
+
+	sched, err = scheduleFromConf(testConfigLoader{"schedule": "@every 5s"}, "@daily")
+	assert.NoError(t, err)
+	assert.Equal(t, schedule(everySchedule(5*time.Second)), sched)
+
+	_, err = scheduleFromConf(testConfigLoader{"schedule": "often"}, "")
+	assert.Error(t, err)
+}
+
+type testScheduledInput struct {
+	runs    atomic.Int32
+	running atomic.Int32
+	overlap atomic.Bool
+}
+
+func (plug *testScheduledInput) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (plug *testScheduledInput) Collect(ctx context.Context, ch chan<- Message) error {
+	if plug.running.Add(1) > 1 {
+		plug.overlap.Store(true)
+	}
+	defer plug.running.Add(-1)
+
+	// the first run outlasts the next scheduled runs.
+	if plug.runs.Add(1) == 1 {
+		time.Sleep(35 * time.Millisecond)
+	}
+
+	ch <- Message{Time: time.Now(), Record: map[string]string{"foo": "bar"}}
+	return nil
+}
+
+func TestRunSchedule(t *testing.T) {
+	plug := &testScheduledInput{}
+	var skipped atomic.Int32
+	r := &registration{kind: inputKind, name: "test-input", input: plug}
+	r.onSkip = func(n int) {
+		skipped.Add(int32(n))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan Message, 100)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.runSchedule(ctx, everySchedule(10*time.Millisecond), ch)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for plug.runs.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	assert.True(t, plug.runs.Load() >= 3)
+	assert.Equal(t, int(plug.runs.Load()), len(ch))
+	assert.True(t, skipped.Load() >= 2)
+	assert.False(t, plug.overlap.Load())
+}
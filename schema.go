@@ -0,0 +1,111 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// SchemaEncoder encodes the records of inputs always emitting the same keys. The keys are
+// encoded once, when the encoder is created, the records only holding their values:
+//
+//	var access = plugin.NewSchemaEncoder("remote", "method", "path", "status")
+//
+//	ch <- plugin.Message{Time: time.Now(), Record: access.Record(ip, "GET", path, 200)}
+//
+// The records are encoded by the msgpack codec, or converted to maps by the other codecs.
+type SchemaEncoder struct {
+	keys []string
+	// header is the encoded map header, and fields the encoded keys.
+	header []byte
+	fields [][]byte
+}
+
+// NewSchemaEncoder returns the encoder of the records with the given keys, in order.
+// It panics without keys or with duplicate keys.
+func NewSchemaEncoder(keys ...string) *SchemaEncoder {
+	if len(keys) == 0 {
+		panic("schema encoder without keys")
+	}
+
+	s := &SchemaEncoder{keys: keys, fields: make([][]byte, len(keys))}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	_ = enc.EncodeMapLen(len(keys))
+	s.header = bytes.Clone(buf.Bytes())
+
+	seen := make(map[string]bool, len(keys))
+	for i, k := range keys {
+		if seen[k] {
+			panic(fmt.Sprintf("invalid schema encoder key: duplicate %q", k))
+		}
+		seen[k] = true
+
+		buf.Reset()
+		_ = enc.EncodeString(k)
+		s.fields[i] = bytes.Clone(buf.Bytes())
+	}
+
+	return s
+}
+
+// Keys returns the keys of the records.
+func (s *SchemaEncoder) Keys() []string {
+	return s.keys
+}
+
+// Record returns a record holding the values of the keys, in order. It panics when the
+// number of values does not match the number of keys.
+func (s *SchemaEncoder) Record(values ...any) SchemaRecord {
+	if len(values) != len(s.keys) {
+		panic(fmt.Sprintf("invalid schema record: got %d values, want %d", len(values), len(s.keys)))
+	}
+
+	return SchemaRecord{schema: s, values: values}
+}
+
+// SchemaRecord is a record of a SchemaEncoder, encoded as a map of its keys and values.
+type SchemaRecord struct {
+	schema *SchemaEncoder
+	values []any
+}
+
+// EncodeMsgpack writes the encoded keys and encodes the values only.
+func (r SchemaRecord) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if r.schema == nil {
+		return enc.EncodeNil()
+	}
+
+	w := enc.Writer()
+	if _, err := w.Write(r.schema.header); err != nil {
+		return err
+	}
+
+	for i, v := range r.values {
+		if _, err := w.Write(r.schema.fields[i]); err != nil {
+			return err
+		}
+
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("schema record %q: %w", r.schema.keys[i], err)
+		}
+	}
+
+	return nil
+}
+
+// Map returns the record as a map.
+func (r SchemaRecord) Map() map[string]any {
+	if r.schema == nil {
+		return nil
+	}
+
+	m := make(map[string]any, len(r.values))
+	for i, v := range r.values {
+		m[r.schema.keys[i]] = v
+	}
+
+	return m
+}
@@ -0,0 +1,82 @@
+package plugin
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestSchemaEncoder(t *testing.T) {
+	s := NewSchemaEncoder("log", "status", "bytes", "ratio", "raw", "missing", "kubernetes")
+	assert.Equal(t, []string{"log", "status", "bytes", "ratio", "raw", "missing", "kubernetes"}, s.Keys())
+
+	values := []any{"GET /", 200, int64(1 << 40), 0.5, []byte("raw"), nil, map[string]any{"pod": "web"}}
+	now := time.Unix(1700000000, 1).UTC()
+
+	// the records decode as the maps of the same fields.
+	for _, c := range []Codec{MsgpackCodec{}, UgorjiCodec{}} {
+		want, err := encodeMessages(c, nil, []Message{{Time: now, Record: s.Record(values...).Map()}})
+		assert.NoError(t, err)
+		got, err := encodeMessages(c, nil, []Message{{Time: now, Record: s.Record(values...)}})
+		assert.NoError(t, err)
+
+		assert.Equal(t, testDecodeCodec(t, MsgpackCodec{}, want), testDecodeCodec(t, MsgpackCodec{}, got))
+	}
+
+	var zero SchemaRecord
+	assert.Zero(t, zero.Map())
+	b, err := encodeRecord(MsgpackCodec{}, Message{Time: now, Record: zero})
+	assert.NoError(t, err)
+	msgs := testDecodeCodec(t, MsgpackCodec{}, b)
+	assert.Zero(t, msgs[0].Record.(map[string]any))
+}
+
+func TestSchemaEncoderErrors(t *testing.T) {
+	assert.Panics(t, func() { NewSchemaEncoder() })
+	assert.Panics(t, func() { NewSchemaEncoder("log", "log") })
+	assert.Panics(t, func() { NewSchemaEncoder("log", "stream").Record("only one") })
+
+	var buf bytes.Buffer
+	err := encodeRecordTo(MsgpackCodec{}, &buf, Message{Time: time.Now(), Record: NewSchemaEncoder("ch").Record(make(chan int))})
+	assert.EqualError(t, err, `schema record "ch": msgpack: Encode(unsupported chan int)`)
+	assert.Zero(t, buf.Len())
+}
+
+// BenchmarkSchemaEncoder encodes the same records as maps and with a schema encoder.
+func BenchmarkSchemaEncoder(b *testing.B) {
+	keys := []string{"remote", "host", "user", "method", "path", "code", "size", "referer", "agent"}
+	values := []any{"127.0.0.1", "-", "-", "GET", "/index.html", 200, 512, "-", "Mozilla/5.0 (X11; Linux x86_64)"}
+
+	schema := NewSchemaEncoder(keys...)
+	records := map[string]func() any{
+		"map": func() any {
+			m := make(map[string]any, len(keys))
+			for i, k := range keys {
+				m[k] = values[i]
+			}
+			return m
+		},
+		"schema": func() any {
+			return schema.Record(values...)
+		},
+	}
+
+	for _, name := range []string{"map", "schema"} {
+		record := records[name]
+
+		b.Run(name, func(b *testing.B) {
+			var buf bytes.Buffer
+			now := time.Now()
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				if err := encodeRecordTo(MsgpackCodec{}, &buf, Message{Time: now, Record: record()}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+#!/usr/bin/env bash
+# benchcmp.sh compares the benchmarks of the SDK between a base revision and the
+# working tree with benchstat.
+#
+# usage: scripts/benchcmp.sh [base-ref] [bench-regexp]
+#
+# The base ref defaults to main and the benchmarks to the encode, decode, input callback
+# and flush ones. COUNT sets the runs of each benchmark (10 by default), BENCHSTAT the
+# benchstat command and GOFLAGS the flags of go test, e.g. -tags=ugorji.
+set -euo pipefail
+
+base=${1:-main}
+bench=${2:-'^Benchmark(Encode|Decode|InputCallback|Flush)$'}
+count=${COUNT:-10}
+benchstat=${BENCHSTAT:-"go run golang.org/x/perf/cmd/benchstat@latest"}
+
+root=$(git rev-parse --show-toplevel)
+out=$(mktemp -d)
+worktree="$out/base"
+trap 'git -C "$root" worktree remove --force "$worktree" >/dev/null 2>&1 || true; rm -rf "$out"' EXIT
+
+run() {
+	(cd "$1" && go test -run '^$' -bench "$bench" -benchmem -count "$count" . | tee "$2" >&2)
+}
+
+git -C "$root" worktree add --detach "$worktree" "$base" >/dev/null
+
+echo "running $base benchmarks" >&2
+run "$worktree" "$out/old.txt"
+echo "running working tree benchmarks" >&2
+run "$root" "$out/new.txt"
+
+$benchstat "$out/old.txt" "$out/new.txt"
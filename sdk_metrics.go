@@ -0,0 +1,97 @@
+package plugin
+
+import (
+	"time"
+
+	"github.com/calyptia/plugin/metric"
+	"github.com/calyptia/plugin/output"
+)
+
+// sdkMetrics are the metrics of the SDK itself, registered for every plugin instance
+// under fluentbit_plugin_go_* so the shim can be monitored without plugin code changes.
+type sdkMetrics struct {
+	label           string
+	channelDepth    metric.Gauge
+	emitted         metric.Counter
+	dropped         metric.Counter
+	chunksFlushed   metric.Counter
+	flushErrors     metric.Counter
+	collectRestarts metric.Counter
+	callbacks       metric.Counter
+	callbackSeconds metric.Counter
+}
+
+func newSDKMetrics(label string, metrics Metrics) *sdkMetrics {
+	return &sdkMetrics{
+		label: label,
+		channelDepth: metrics.NewGauge("go_channel_depth",
+			"Number of records buffered by the input", "name"),
+		emitted: metrics.NewCounter("go_messages_emitted_total",
+			"Number of records handed to fluent-bit by the input", "name"),
+		dropped: metrics.NewCounter("go_messages_dropped_total",
+			"Number of records dropped by the input", "name"),
+		chunksFlushed: metrics.NewCounter("go_chunks_flushed_total",
+			"Number of chunks flushed by the output", "name"),
+		flushErrors: metrics.NewCounter("go_flush_errors_total",
+			"Number of chunks the output failed to flush, including retries", "name"),
+		collectRestarts: metrics.NewCounter("go_collect_restarts_total",
+			"Number of restarts of the Collect goroutines", "name"),
+		callbacks: metrics.NewCounter("go_callbacks_total",
+			"Number of callbacks invoked by fluent-bit", "name", "callback"),
+		callbackSeconds: metrics.NewCounter("go_callback_duration_seconds_total",
+			"Time spent in the callbacks invoked by fluent-bit", "name", "callback"),
+	}
+}
+
+// observe records a callback invocation that started at the given time.
+func (m *sdkMetrics) observe(callback string, start time.Time) {
+	if m == nil {
+		return
+	}
+
+	m.callbacks.Add(1, m.label, callback)
+	m.callbackSeconds.Add(time.Since(start).Seconds(), m.label, callback)
+}
+
+// collected records the records handed to fluent-bit by an input callback,
+// and the records left buffered.
+func (m *sdkMetrics) collected(n, buffered int) {
+	if m == nil {
+		return
+	}
+
+	if n > 0 {
+		m.emitted.Add(float64(n), m.label)
+	}
+	m.channelDepth.Set(float64(buffered), m.label)
+}
+
+func (m *sdkMetrics) drop(n int) {
+	if m == nil || n == 0 {
+		return
+	}
+
+	m.dropped.Add(float64(n), m.label)
+}
+
+// flushed records the result of a flush callback.
+func (m *sdkMetrics) flushed(ret int) {
+	if m == nil {
+		return
+	}
+
+	if ret == output.FLB_OK {
+		m.chunksFlushed.Add(1, m.label)
+		return
+	}
+
+	m.flushErrors.Add(1, m.label)
+}
+
+func (m *sdkMetrics) restarted(callback string) {
+	if m == nil || callback != "collect" {
+		return
+	}
+
+	m.collectRestarts.Add(1, m.label)
+}
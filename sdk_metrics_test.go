@@ -0,0 +1,110 @@
+package plugin
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/calyptia/plugin/metric"
+	"github.com/calyptia/plugin/output"
+)
+
+// testNamedMetrics records the values of the metrics, keyed by name and label values.
+type testNamedMetrics struct {
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+type testNamedMetric struct {
+	m    *testNamedMetrics
+	name string
+}
+
+// testNamedCounter is a counter adding to a test metric.
+type testNamedCounter struct {
+	testNamedMetric
+}
+
+func (m *testNamedMetrics) NewCounter(name, desc string, labelValues ...string) metric.Counter {
+	return testNamedCounter{testNamedMetric{m: m, name: name}}
+}
+
+func (m *testNamedMetrics) NewGauge(name, desc string, labelValues ...string) metric.Gauge {
+	return testNamedMetric{m: m, name: name}
+}
+
+func (m *testNamedMetrics) get(key string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.values[key]
+}
+
+func (c testNamedMetric) Add(delta float64, labelValues ...string) {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+	c.m.values[c.key(labelValues)] += delta
+}
+
+func (c testNamedMetric) Sub(delta float64, labelValues ...string) {
+	c.Add(-delta, labelValues...)
+}
+
+func (c testNamedMetric) Set(value float64, labelValues ...string) {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+	c.m.values[c.key(labelValues)] = value
+}
+
+func (c testNamedMetric) key(labelValues []string) string {
+	return c.name + "{" + strings.Join(labelValues, ",") + "}"
+}
+
+func TestSDKMetrics(t *testing.T) {
+	metrics := &testNamedMetrics{values: map[string]float64{}}
+	m := newSDKMetrics("dummy.0", metrics)
+
+	m.collected(3, 7)
+	m.collected(0, 2)
+	m.drop(4)
+	m.flushed(output.FLB_OK)
+	m.flushed(output.FLB_RETRY)
+	m.flushed(output.FLB_ERROR)
+	m.restarted("collect")
+	m.restarted("flush")
+	m.observe("flush", time.Now().Add(-time.Second))
+
+	assert.Equal(t, 3.0, metrics.get("go_messages_emitted_total{dummy.0}"))
+	assert.Equal(t, 2.0, metrics.get("go_channel_depth{dummy.0}"))
+	assert.Equal(t, 4.0, metrics.get("go_messages_dropped_total{dummy.0}"))
+	assert.Equal(t, 1.0, metrics.get("go_chunks_flushed_total{dummy.0}"))
+	assert.Equal(t, 2.0, metrics.get("go_flush_errors_total{dummy.0}"))
+	assert.Equal(t, 1.0, metrics.get("go_collect_restarts_total{dummy.0}"))
+	assert.Equal(t, 1.0, metrics.get("go_callbacks_total{dummy.0,flush}"))
+	assert.True(t, metrics.get("go_callback_duration_seconds_total{dummy.0,flush}") >= 1)
+
+	// instances initialized without metrics are not instrumented.
+	var none *sdkMetrics
+	none.collected(1, 1)
+	none.flushed(output.FLB_OK)
+	none.observe("flush", time.Now())
+}
+
+func TestInputCallbackSDKMetrics(t *testing.T) {
+	r := prepareInput(testPluginInputCallbackCtrlC{})
+	metrics := &testNamedMetrics{values: map[string]float64{}}
+	r.sdk = newSDKMetrics("test-input", metrics)
+	r.prepareInputCollector()
+	defer r.runCancel()
+
+	r.channel <- Message{Time: time.Now(), Record: map[string]string{"Foo": "BAR"}}
+	r.channel <- Message{Time: time.Now(), Record: map[string]string{"Foo": "BAZ"}}
+
+	_, err := testFLBPluginInputCallback()
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, metrics.get("go_messages_emitted_total{test-input}"))
+	assert.Equal(t, 0.0, metrics.get("go_channel_depth{test-input}"))
+	assert.Equal(t, 1.0, metrics.get("go_callbacks_total{test-input,collect}"))
+}
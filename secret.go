@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// Secret is a sensitive config value, like a password or a token.
+// It is redacted when printed, logged or encoded, use Value to get the actual value.
+type Secret struct {
+	value string
+}
+
+// SecretFromConf returns the value of the given key as a secret.
+func SecretFromConf(conf ConfigLoader, key string) Secret {
+	return Secret{value: conf.String(key)}
+}
+
+// Value returns the secret value.
+func (s Secret) Value() string {
+	return s.value
+}
+
+// IsZero reports whether the secret is empty.
+func (s Secret) IsZero() bool {
+	return s.value == ""
+}
+
+func (s Secret) String() string {
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return redacted
+}
+
+// LogValue redacts the secret from the slog records.
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(redacted)
+}
+
+// MarshalText redacts the secret from the encoded values.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// UnmarshalText sets the secret value, it allows secrets to be loaded with Unmarshal.
+func (s *Secret) UnmarshalText(b []byte) error {
+	s.value = string(b)
+	return nil
+}
+
+// configKeys formats the sorted keys of the config. The values are left out, as any of
+// them may hold a secret, e.g. interpolated from the environment into a header.
+func configKeys(all map[string]string) string {
+	keys := make([]string, 0, len(all))
+	for k := range all {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return strings.Join(keys, " ")
+}
+
+// logConfig logs the keys set in the config of an instance at debug level.
+func logConfig(logger Logger, conf ConfigLoader) {
+	it, ok := conf.(ConfigIterator)
+	if !ok || logger == nil {
+		return
+	}
+
+	if all := it.All(); len(all) != 0 {
+		logger.Debug("config keys: %s", configKeys(all))
+	}
+}
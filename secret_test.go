@@ -0,0 +1,44 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestSecret(t *testing.T) {
+	s := SecretFromConf(testConfigLoader{"password": "hunter2"}, "password")
+	assert.Equal(t, "hunter2", s.Value())
+	assert.False(t, s.IsZero())
+
+	assert.Equal(t, redacted, fmt.Sprint(s))
+	assert.NotContains(t, fmt.Sprintf("%v %+v %#v %s", s, s, s, s), "hunter2")
+
+	b, err := json.Marshal(map[string]any{"password": s})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"password":"[REDACTED]"}`, string(b))
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("connect", "password", s)
+	assert.NotContains(t, buf.String(), "hunter2")
+
+	var cfg struct {
+		Password Secret `flb:"password,required"`
+	}
+	assert.NoError(t, Unmarshal(testConfigLoader{"password": "hunter2"}, &cfg))
+	assert.Equal(t, "hunter2", cfg.Password.Value())
+}
+
+func TestConfigKeys(t *testing.T) {
+	assert.Equal(t, "api_key endpoint header.X-Token tls.key_file",
+		configKeys(map[string]string{
+			"endpoint":       "https://example.com",
+			"api_key":        "abc",
+			"header.X-Token": "hunter2",
+			"tls.key_file":   "/etc/tls.key",
+		}))
+}
@@ -0,0 +1,44 @@
+package plugin
+
+import (
+	"sync"
+	"time"
+)
+
+// ServiceConfig is the fluent-bit [SERVICE] configuration, exposed read-only to the
+// plugins so they can align with the host, e.g. size their buffers from the flush interval.
+// The fluent-bit defaults are used when the host does not provide it.
+type ServiceConfig struct {
+	// Flush is the interval between the flushes of the output plugins.
+	Flush time.Duration
+	// Grace is the time fluent-bit waits on shutdown for the pending flushes.
+	Grace time.Duration
+	// StoragePath is the filesystem buffering path, empty when disabled.
+	StoragePath string
+	// HotReload reports whether the configuration can be reloaded at runtime.
+	HotReload bool
+}
+
+var (
+	serviceMu     sync.Mutex
+	serviceConfig = ServiceConfig{
+		Flush: time.Second,
+		Grace: 5 * time.Second,
+	}
+)
+
+// currentServiceConfig returns the service configuration known so far.
+func currentServiceConfig() ServiceConfig {
+	serviceMu.Lock()
+	defer serviceMu.Unlock()
+
+	return serviceConfig
+}
+
+// setServiceConfig updates the service configuration.
+func setServiceConfig(update func(*ServiceConfig)) {
+	serviceMu.Lock()
+	defer serviceMu.Unlock()
+
+	update(&serviceConfig)
+}
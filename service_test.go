@@ -0,0 +1,25 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestServiceConfig(t *testing.T) {
+	prev := currentServiceConfig()
+	defer setServiceConfig(func(c *ServiceConfig) { *c = prev })
+
+	assert.Equal(t, ServiceConfig{Flush: time.Second, Grace: 5 * time.Second}, prev)
+
+	setServiceConfig(func(c *ServiceConfig) {
+		c.Flush = 500 * time.Millisecond
+		c.StoragePath = "/var/lib/fluent-bit"
+	})
+	assert.Equal(t, ServiceConfig{
+		Flush:       500 * time.Millisecond,
+		Grace:       5 * time.Second,
+		StoragePath: "/var/lib/fluent-bit",
+	}, currentServiceConfig())
+}
@@ -0,0 +1,96 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+var (
+	sharedMu     sync.Mutex
+	sharedStates []sharedState
+)
+
+type sharedState interface {
+	close() error
+}
+
+// Shared is a state value, e.g. a connection pool or a cache, shared by the plugins
+// registered from the same shared library, like an input and output pair.
+// The value is created by the first plugin getting it and released once all the
+// instances of the plugins have exited, values implementing io.Closer are closed at
+// that point.
+type Shared[T any] struct {
+	newState func(ctx context.Context) (T, error)
+
+	mu    sync.Mutex
+	ok    bool
+	value T
+}
+
+// NewShared declares a shared state value, it should be called from the package
+// scope or init function registering the plugins.
+func NewShared[T any](newState func(ctx context.Context) (T, error)) *Shared[T] {
+	s := &Shared[T]{newState: newState}
+
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	sharedStates = append(sharedStates, s)
+
+	return s
+}
+
+// Get returns the shared value, creating it on the first call.
+// Failures are not cached so the next call tries to create it again.
+func (s *Shared[T]) Get(ctx context.Context) (T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ok {
+		return s.value, nil
+	}
+
+	v, err := s.newState(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	s.value, s.ok = v, true
+
+	return v, nil
+}
+
+func (s *Shared[T]) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ok {
+		return nil
+	}
+
+	var zero T
+	v := s.value
+	s.value, s.ok = zero, false
+
+	if c, ok := any(v).(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// closeSharedStates releases the shared values once all the plugins have stopped.
+func closeSharedStates() {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	for _, s := range sharedStates {
+		if err := s.close(); err != nil {
+			fmt.Fprintf(os.Stderr, "close shared state: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/calyptia/plugin/input"
+	"github.com/calyptia/plugin/output"
+)
+
+type testSharedPool struct {
+	closed bool
+}
+
+func (p *testSharedPool) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestShared(t *testing.T) {
+	var created int
+	fail := true
+	shared := NewShared(func(ctx context.Context) (*testSharedPool, error) {
+		if fail {
+			return nil, errors.New("dial failed")
+		}
+		created++
+		return &testSharedPool{}, nil
+	})
+
+	ctx := context.Background()
+
+	_, err := shared.Get(ctx)
+	assert.Error(t, err)
+
+	fail = false
+	first, err := shared.Get(ctx)
+	assert.NoError(t, err)
+	second, err := shared.Get(ctx)
+	assert.NoError(t, err)
+	assert.True(t, first == second)
+	assert.Equal(t, 1, created)
+
+	closeSharedStates()
+	assert.True(t, first.closed)
+
+	third, err := shared.Get(ctx)
+	assert.NoError(t, err)
+	assert.True(t, first != third)
+	assert.Equal(t, 2, created)
+}
+
+func TestSharedClosedOnLastExit(t *testing.T) {
+	defer resetRegistry()
+	resetRegistry()
+
+	shared := NewShared(func(ctx context.Context) (*testSharedPool, error) {
+		return &testSharedPool{}, nil
+	})
+	pool, err := shared.Get(context.Background())
+	assert.NoError(t, err)
+
+	RegisterOutputFactory("test-output", "", func() OutputPlugin {
+		return &testOutputCounter{ch: make(chan Message, 1)}
+	})
+	r := registrationOf(outputKind)
+
+	// two output instances, each exited through FLBPluginExitCtx.
+	var insts []*outputInstance
+	for i := range 2 {
+		info := InstanceInfo{Name: fmt.Sprintf("test-output.%d", i)}
+		inst, ret := r.initInstance(info, r.configLoader(testConfigLoader{}), &testLogger{}, testInstanceMetrics)
+		assert.Equal(t, input.FLB_OK, ret)
+		insts = append(insts, inst)
+	}
+
+	assert.Equal(t, output.FLB_OK, testExitCtx(insts[0]))
+	assert.False(t, pool.closed)
+	assert.Equal(t, []*outputInstance{insts[1]}, r.outputInstances())
+
+	assert.Equal(t, output.FLB_OK, testExitCtx(insts[1]))
+	assert.True(t, pool.closed)
+	assert.Zero(t, len(r.outputInstances()))
+}
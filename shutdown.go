@@ -0,0 +1,39 @@
+package plugin
+
+import "time"
+
+// waitGoroutines waits up to timeout for the goroutines of the plugin to return,
+// it reports whether they did.
+func (s *runState) waitGoroutines(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		s.goroutines.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(max(timeout, 0))
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// stopInput stops the collectors of the input and gives them the grace period of the
+// service to return. It does not deliver the records still buffered: fluent-bit pauses
+// the inputs before exiting and no longer calls their callbacks, so the records buffered
+// or spilled by then are lost, and reported.
+func (r *registration) stopInput() {
+	r.pauseInput()
+
+	if !r.waitGoroutines(currentServiceConfig().Grace) {
+		r.logf(LogError, "exit: collectors still running after the grace period")
+	}
+
+	if n := r.bufferedInput() + r.spill.len(); n > 0 {
+		r.logf(LogError, "exit: lost %d buffered records, fluent-bit no longer calls the input callbacks", n)
+	}
+}
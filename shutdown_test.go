@@ -0,0 +1,67 @@
+package plugin
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestWaitGoroutines(t *testing.T) {
+	var s runState
+	assert.True(t, s.waitGoroutines(time.Second))
+
+	release := make(chan struct{})
+	s.goroutines.Add(1)
+	go func() {
+		defer s.goroutines.Done()
+		<-release
+	}()
+
+	assert.False(t, s.waitGoroutines(10*time.Millisecond))
+	close(release)
+	assert.True(t, s.waitGoroutines(time.Second))
+}
+
+// TestOutputStopDrains makes sure the records of in-flight flushes reach
+// the plugin before the instance is stopped.
+func TestOutputStopDrains(t *testing.T) {
+	plug := &testOutputCounter{ch: make(chan Message)}
+	o := &outputInstance{reg: &registration{name: "test-output"}, output: plug}
+	assert.NoError(t, o.run())
+
+	var b []byte
+	for range 3 {
+		record, err := msgpack.Marshal([]any{&EventTime{time.Now()}, map[string]any{"foo": "bar"}})
+		assert.NoError(t, err)
+		b = append(b, record...)
+	}
+
+	flushed := make(chan error, 1)
+	o.flushLock.RLock()
+	go func() {
+		defer o.flushLock.RUnlock()
+		flushed <- o.pluginFlush(0, "foobar", b)
+	}()
+
+	var received atomic.Int32
+	go func() {
+		for range plug.ch {
+			time.Sleep(20 * time.Millisecond)
+			received.Add(1)
+		}
+	}()
+
+	o.stop()
+	assert.NoError(t, <-flushed)
+	assert.Zero(t, o.channel)
+
+	// the last record may still be processed by the plugin.
+	deadline := time.Now().Add(time.Second)
+	for received.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, int32(3), received.Load())
+}
@@ -0,0 +1,154 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// startupTimeout is how long the callbacks wait for the plugin to be registered
+// and initialized, it outlasts the init timeouts so a slow Init is not reported.
+var startupTimeout = 5 * time.Minute
+
+// startupPhase is the phase of the handshake between fluent-bit and the plugin.
+type startupPhase int
+
+const (
+	// phaseLoaded is the phase until fluent-bit registers the plugin,
+	// and again while it hot reloads.
+	phaseLoaded startupPhase = iota
+	// phaseRegistered is the phase once the plugin got registered.
+	phaseRegistered
+)
+
+func (p startupPhase) String() string {
+	switch p {
+	case phaseLoaded:
+		return "loaded"
+	case phaseRegistered:
+		return "registered"
+	}
+
+	return fmt.Sprintf("startupPhase(%d)", int(p))
+}
+
+// startup tracks the handshake between fluent-bit and the plugin: the pre-run callbacks
+// wait for the registration, and the other callbacks for the initializations in flight.
+var startup = newStartupState()
+
+type startupState struct {
+	mu    sync.Mutex
+	phase startupPhase
+	// initializing counts the Init callbacks in flight per plugin.
+	initializing map[string]int
+	// changed is closed and replaced on every change.
+	changed chan struct{}
+}
+
+func newStartupState() *startupState {
+	return &startupState{initializing: map[string]int{}, changed: make(chan struct{})}
+}
+
+// update applies fn to the state, waking up the callbacks waiting for it.
+func (s *startupState) update(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn()
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// reloading moves the plugin back to the loaded phase, until registered again.
+func (s *startupState) reloading() {
+	s.update(func() {
+		s.phase = phaseLoaded
+	})
+}
+
+// registered moves the plugin to the registered phase. Only the first registration
+// is waited for, the library might be listed only once in plugins.conf.
+func (s *startupState) registered() {
+	s.update(func() {
+		s.phase = phaseRegistered
+	})
+}
+
+// beginInit marks the initialization of the named plugin in flight, until endInit.
+func (s *startupState) beginInit(name string) {
+	s.update(func() {
+		s.initializing[name]++
+	})
+}
+
+func (s *startupState) endInit(name string) {
+	s.update(func() {
+		if s.initializing[name]--; s.initializing[name] <= 0 {
+			delete(s.initializing, name)
+		}
+	})
+}
+
+// awaitRegistered waits for the plugin to be registered. It returns an error
+// naming the stalled phase after the startup timeout.
+func (s *startupState) awaitRegistered(callback string) error {
+	return s.await(callback, func() bool {
+		return s.phase >= phaseRegistered
+	})
+}
+
+// awaitInitialized waits for the initializations in flight to complete. It returns
+// an error naming the plugins still initializing after the startup timeout.
+func (s *startupState) awaitInitialized(callback string) error {
+	return s.await(callback, func() bool {
+		return len(s.initializing) == 0
+	})
+}
+
+func (s *startupState) await(callback string, ready func() bool) error {
+	var timer *time.Timer
+	for {
+		s.mu.Lock()
+		if ready() {
+			s.mu.Unlock()
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		if timer == nil {
+			timer = time.NewTimer(startupTimeout)
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			err := s.stalled(callback)
+			fmt.Fprintf(os.Stderr, "startup: %v\n", err)
+			return err
+		}
+	}
+}
+
+// stalled describes the phase the handshake is stuck in.
+func (s *startupState) stalled(callback string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.phase < phaseRegistered {
+		return fmt.Errorf("%s: plugin not registered after %s, FLBPluginRegister was not invoked", callback, startupTimeout)
+	}
+
+	names := make([]string, 0, len(s.initializing))
+	for name := range s.initializing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return fmt.Errorf("%s: %q still initializing after %s, FLBPluginInit did not return", callback, names, startupTimeout)
+}
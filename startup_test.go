@@ -0,0 +1,68 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestStartupAwaitRegistered(t *testing.T) {
+	s := newStartupState()
+
+	done := make(chan error)
+	go func() {
+		done <- s.awaitRegistered("input pre-run")
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("pre-run did not wait for the registration")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	s.registered()
+	assert.NoError(t, <-done)
+
+	// hot reloads wait for the registration again.
+	s.reloading()
+	assert.Equal(t, phaseLoaded, s.phase)
+	s.registered()
+	assert.NoError(t, s.awaitRegistered("input pre-run"))
+}
+
+func TestStartupAwaitInitialized(t *testing.T) {
+	s := newStartupState()
+	s.registered()
+	s.beginInit("dummy")
+	s.beginInit("dummy")
+
+	done := make(chan error)
+	go func() {
+		done <- s.awaitInitialized("flush")
+	}()
+
+	s.endInit("dummy")
+	select {
+	case <-done:
+		t.Fatal("flush did not wait for the initialization")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	s.endInit("dummy")
+	assert.NoError(t, <-done)
+}
+
+func TestStartupStalled(t *testing.T) {
+	defer func(d time.Duration) { startupTimeout = d }(startupTimeout)
+	startupTimeout = 10 * time.Millisecond
+
+	s := newStartupState()
+	err := s.awaitRegistered("output pre-run")
+	assert.EqualError(t, err, "output pre-run: plugin not registered after 10ms, FLBPluginRegister was not invoked")
+
+	s.registered()
+	s.beginInit("dummy")
+	err = s.awaitInitialized("flush")
+	assert.EqualError(t, err, `flush: ["dummy"] still initializing after 10ms, FLBPluginInit did not return`)
+}
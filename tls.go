@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSConfigFromConf builds the TLS configuration from the standard fluent-bit tls.* keys:
+// tls, tls.verify, tls.ca_file, tls.crt_file, tls.key_file and tls.vhost.
+// It returns a nil config when tls is not enabled.
+func TLSConfigFromConf(conf ConfigLoader) (*tls.Config, error) {
+	enabled, err := BoolFromConf(conf, "tls", false)
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	verify, err := BoolFromConf(conf, "tls.verify", true)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         conf.String("tls.vhost"),
+		InsecureSkipVerify: !verify, //nolint:gosec // disabled on purpose with tls.verify off.
+	}
+
+	if caFile := conf.String("tls.ca_file"); caFile != "" {
+		b, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls.ca_file: %w", err)
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("tls.ca_file: no certificate found in %q", caFile)
+		}
+	}
+
+	crtFile, keyFile := conf.String("tls.crt_file"), conf.String("tls.key_file")
+	switch {
+	case crtFile != "" && keyFile != "":
+		cert, err := tls.LoadX509KeyPair(crtFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls.crt_file: %w", err)
+		}
+
+		cfg.Certificates = []tls.Certificate{cert}
+	case crtFile != "" || keyFile != "":
+		return nil, errors.New("tls.crt_file and tls.key_file must be set together")
+	}
+
+	return cfg, nil
+}
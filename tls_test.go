@@ -0,0 +1,80 @@
+package plugin
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func writeTestCert(t *testing.T) (crtFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "example.com"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	crtFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	assert.NoError(t, os.WriteFile(crtFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return crtFile, keyFile
+}
+
+func TestTLSConfigFromConf(t *testing.T) {
+	cfg, err := TLSConfigFromConf(testConfigLoader{})
+	assert.NoError(t, err)
+	assert.Zero(t, cfg)
+
+	cfg, err = TLSConfigFromConf(testConfigLoader{"tls": "on", "tls.verify": "off", "tls.vhost": "example.com"})
+	assert.NoError(t, err)
+	assert.True(t, cfg.InsecureSkipVerify)
+	assert.Equal(t, "example.com", cfg.ServerName)
+
+	crtFile, keyFile := writeTestCert(t)
+	cfg, err = TLSConfigFromConf(testConfigLoader{
+		"tls":          "on",
+		"tls.ca_file":  crtFile,
+		"tls.crt_file": crtFile,
+		"tls.key_file": keyFile,
+	})
+	assert.NoError(t, err)
+	assert.False(t, cfg.InsecureSkipVerify)
+	assert.NotZero(t, cfg.RootCAs)
+	assert.Equal(t, 1, len(cfg.Certificates))
+}
+
+func TestTLSConfigFromConfErrors(t *testing.T) {
+	_, err := TLSConfigFromConf(testConfigLoader{"tls": "maybe"})
+	assert.Error(t, err)
+
+	_, err = TLSConfigFromConf(testConfigLoader{"tls": "on", "tls.ca_file": "/nonexistent/ca.crt"})
+	assert.Error(t, err)
+
+	crtFile, _ := writeTestCert(t)
+	_, err = TLSConfigFromConf(testConfigLoader{"tls": "on", "tls.crt_file": crtFile})
+	assert.EqualError(t, err, "tls.crt_file and tls.key_file must be set together")
+}
@@ -0,0 +1,87 @@
+package ctr
+
+import (
+	"crypto/rand"
+	"time"
+)
+
+// NewTraces returns an empty traces context, ready to be filled with spans
+// built with NewSpan and NewChildSpan.
+func NewTraces() *Traces {
+	return &Traces{ResourceSpans: []ResourceSpans{}}
+}
+
+// Add appends a copy of the spans to the traces, grouped under the given
+// resource and instrumentation scope.
+func (t *Traces) Add(resource Resource, scope Scope, spans ...*Span) {
+	if resource.Attributes == nil {
+		resource.Attributes = map[string]any{}
+	}
+	if scope.Attributes == nil {
+		scope.Attributes = map[string]any{}
+	}
+
+	ss := ScopeSpans{Scope: scope, Spans: make([]Span, 0, len(spans))}
+	for _, s := range spans {
+		ss.Spans = append(ss.Spans, *s)
+	}
+
+	t.ResourceSpans = append(t.ResourceSpans, ResourceSpans{
+		Resource:   resource,
+		ScopeSpans: []ScopeSpans{ss},
+	})
+}
+
+// NewSpan returns the root span of a new trace, with random trace and span ids.
+func NewSpan(name string, kind SpanKind, start time.Time) *Span {
+	return newSpan(randomID(16), nil, name, kind, start)
+}
+
+// NewChildSpan returns a span of the same trace as parent, with a random span id.
+func NewChildSpan(parent *Span, name string, kind SpanKind, start time.Time) *Span {
+	return newSpan(parent.TraceID, parent.SpanID, name, kind, start)
+}
+
+func newSpan(traceID, parentSpanID []byte, name string, kind SpanKind, start time.Time) *Span {
+	return &Span{
+		TraceID:           append([]byte{}, traceID...),
+		SpanID:            randomID(8),
+		ParentSpanID:      append([]byte(nil), parentSpanID...),
+		Name:              name,
+		Kind:              kind,
+		StartTimeUnixNano: uint64(start.UnixNano()),
+		Attributes:        map[string]any{},
+		Events:            []Event{},
+		Links:             []Link{},
+	}
+}
+
+// End sets the end time of the span.
+func (s *Span) End(t time.Time) {
+	s.EndTimeUnixNano = uint64(t.UnixNano())
+}
+
+// AddEvent adds a timestamped event to the span.
+func (s *Span) AddEvent(t time.Time, name string, attributes map[string]any) {
+	if attributes == nil {
+		attributes = map[string]any{}
+	}
+
+	s.Events = append(s.Events, Event{
+		TimeUnixNano: uint64(t.UnixNano()),
+		Name:         name,
+		Attributes:   attributes,
+	})
+}
+
+// SetStatus sets the status of the span.
+func (s *Span) SetStatus(code StatusCode, message string) {
+	s.Status = Status{Code: code, Message: message}
+}
+
+func randomID(n int) []byte {
+	id := make([]byte, n)
+	// crypto/rand.Read never returns an error on the supported platforms.
+	_, _ = rand.Read(id)
+	return id
+}
@@ -0,0 +1,175 @@
+// Package ctr provides a Go representation of the ctraces msgpack payloads
+// exchanged with fluent-bit, so traces can be built, inspected, enriched and
+// re-encoded without going through the ctraces C library.
+package ctr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// SpanKind matches the OpenTelemetry span kinds.
+type SpanKind int
+
+const (
+	SpanKindUnspecified SpanKind = iota
+	SpanKindInternal
+	SpanKindServer
+	SpanKindClient
+	SpanKindProducer
+	SpanKindConsumer
+)
+
+// StatusCode matches the OpenTelemetry span status codes.
+type StatusCode int
+
+const (
+	StatusCodeUnset StatusCode = iota
+	StatusCodeOK
+	StatusCodeError
+)
+
+// Traces is a decoded ctraces context.
+type Traces struct {
+	ResourceSpans []ResourceSpans `msgpack:"resourceSpans"`
+}
+
+// ResourceSpans groups the spans produced by a single resource.
+type ResourceSpans struct {
+	Resource   Resource     `msgpack:"resource"`
+	SchemaURL  string       `msgpack:"schema_url"`
+	ScopeSpans []ScopeSpans `msgpack:"scopeSpans"`
+}
+
+// Resource describes the entity producing the spans.
+type Resource struct {
+	Attributes             map[string]any `msgpack:"attributes"`
+	DroppedAttributesCount uint32         `msgpack:"dropped_attributes_count"`
+}
+
+// ScopeSpans groups the spans produced by a single instrumentation scope.
+type ScopeSpans struct {
+	Scope     Scope  `msgpack:"scope"`
+	SchemaURL string `msgpack:"schema_url"`
+	Spans     []Span `msgpack:"spans"`
+}
+
+// Scope is the instrumentation scope.
+type Scope struct {
+	Name                   string         `msgpack:"name"`
+	Version                string         `msgpack:"version"`
+	Attributes             map[string]any `msgpack:"attributes"`
+	DroppedAttributesCount uint32         `msgpack:"dropped_attributes_count"`
+}
+
+// Span is a single operation within a trace.
+type Span struct {
+	TraceID                []byte         `msgpack:"trace_id"`
+	SpanID                 []byte         `msgpack:"span_id"`
+	ParentSpanID           []byte         `msgpack:"parent_span_id"`
+	TraceState             string         `msgpack:"trace_state"`
+	Name                   string         `msgpack:"name"`
+	Kind                   SpanKind       `msgpack:"kind"`
+	StartTimeUnixNano      uint64         `msgpack:"start_time_unix_nano"`
+	EndTimeUnixNano        uint64         `msgpack:"end_time_unix_nano"`
+	Attributes             map[string]any `msgpack:"attributes"`
+	DroppedAttributesCount uint32         `msgpack:"dropped_attributes_count"`
+	Events                 []Event        `msgpack:"events"`
+	DroppedEventsCount     uint32         `msgpack:"dropped_events_count"`
+	Links                  []Link         `msgpack:"links"`
+	DroppedLinksCount      uint32         `msgpack:"dropped_links_count"`
+	Status                 Status         `msgpack:"status"`
+}
+
+// Event is a timestamped annotation of a span.
+type Event struct {
+	TimeUnixNano           uint64         `msgpack:"time_unix_nano"`
+	Name                   string         `msgpack:"name"`
+	Attributes             map[string]any `msgpack:"attributes"`
+	DroppedAttributesCount uint32         `msgpack:"dropped_attributes_count"`
+}
+
+// Link points to a span of the same or a different trace.
+type Link struct {
+	TraceID                []byte         `msgpack:"trace_id"`
+	SpanID                 []byte         `msgpack:"span_id"`
+	TraceState             string         `msgpack:"trace_state"`
+	Attributes             map[string]any `msgpack:"attributes"`
+	DroppedAttributesCount uint32         `msgpack:"dropped_attributes_count"`
+}
+
+// Status of a span.
+type Status struct {
+	Code    StatusCode `msgpack:"code"`
+	Message string     `msgpack:"message"`
+}
+
+// TraceIDString returns the hex encoded trace id.
+func (s *Span) TraceIDString() string {
+	return hex.EncodeToString(s.TraceID)
+}
+
+// SpanIDString returns the hex encoded span id.
+func (s *Span) SpanIDString() string {
+	return hex.EncodeToString(s.SpanID)
+}
+
+// StartTime of the span.
+func (s *Span) StartTime() time.Time {
+	return time.Unix(0, int64(s.StartTimeUnixNano)).UTC()
+}
+
+// EndTime of the span.
+func (s *Span) EndTime() time.Time {
+	return time.Unix(0, int64(s.EndTimeUnixNano)).UTC()
+}
+
+// SetAttribute sets a span attribute, allocating the attributes map if needed.
+func (s *Span) SetAttribute(key string, value any) {
+	if s.Attributes == nil {
+		s.Attributes = map[string]any{}
+	}
+	s.Attributes[key] = value
+}
+
+// Decode all the contexts contained in a ctraces msgpack payload.
+func Decode(b []byte) ([]*Traces, error) {
+	var out []*Traces
+
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	for {
+		var traces Traces
+		err := dec.Decode(&traces)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("msgpack unmarshal traces: %w", err)
+		}
+
+		out = append(out, &traces)
+	}
+
+	return out, nil
+}
+
+// Encode contexts back into a ctraces msgpack payload.
+func Encode(traces ...*Traces) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := msgpack.NewEncoder(&buf)
+	for _, t := range traces {
+		if err := enc.Encode(t); err != nil {
+			return nil, fmt.Errorf("msgpack marshal traces: %w", err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,84 @@
+package ctr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	start := time.Date(2024, 5, 21, 18, 41, 13, 0, time.UTC)
+
+	in := &Traces{
+		ResourceSpans: []ResourceSpans{{
+			Resource: Resource{
+				Attributes: map[string]any{"service.name": "gdummy"},
+			},
+			ScopeSpans: []ScopeSpans{{
+				Scope: Scope{Name: "fluent-bit-go"},
+				Spans: []Span{{
+					TraceID:           []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+					SpanID:            []byte{0xaa, 0xbb, 0xcc, 0xdd, 0x01, 0x02, 0x03, 0x04},
+					Name:              "collect",
+					Kind:              SpanKindInternal,
+					StartTimeUnixNano: uint64(start.UnixNano()),
+					EndTimeUnixNano:   uint64(start.Add(time.Second).UnixNano()),
+					Status:            Status{Code: StatusCodeOK},
+				}},
+			}},
+		}},
+	}
+
+	b, err := Encode(in)
+	assert.NoError(t, err)
+
+	got, err := Decode(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(got))
+
+	span := got[0].ResourceSpans[0].ScopeSpans[0].Spans[0]
+	assert.Equal(t, "0102030405060708090a0b0c0d0e0f10", span.TraceIDString())
+	assert.Equal(t, "aabbccdd01020304", span.SpanIDString())
+	assert.Equal(t, start, span.StartTime())
+	assert.Equal(t, time.Second, span.EndTime().Sub(span.StartTime()))
+	assert.Equal(t, "gdummy", got[0].ResourceSpans[0].Resource.Attributes["service.name"])
+
+	span.SetAttribute("enriched", true)
+	assert.Equal(t, true, span.Attributes["enriched"])
+}
+
+func TestBuilder(t *testing.T) {
+	start := time.Date(2024, 5, 21, 18, 41, 13, 0, time.UTC)
+
+	root := NewSpan("GET /", SpanKindServer, start)
+	root.SetAttribute("http.method", "GET")
+	root.AddEvent(start.Add(time.Millisecond), "cache miss", nil)
+
+	child := NewChildSpan(root, "SELECT", SpanKindClient, start.Add(2*time.Millisecond))
+	child.SetStatus(StatusCodeError, "timeout")
+	child.End(start.Add(3 * time.Millisecond))
+	root.End(start.Add(4 * time.Millisecond))
+
+	traces := NewTraces()
+	traces.Add(Resource{Attributes: map[string]any{"service.name": "dummy"}}, Scope{Name: "go"}, root, child)
+
+	b, err := Encode(traces)
+	assert.NoError(t, err)
+
+	got, err := Decode(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(got))
+
+	spans := got[0].ResourceSpans[0].ScopeSpans[0].Spans
+	assert.Equal(t, 2, len(spans))
+	assert.Equal(t, 16, len(spans[0].TraceID))
+	assert.Equal(t, 8, len(spans[0].SpanID))
+	assert.Zero(t, len(spans[0].ParentSpanID))
+	assert.Equal(t, spans[0].TraceIDString(), spans[1].TraceIDString())
+	assert.Equal(t, spans[0].SpanID, spans[1].ParentSpanID)
+	assert.Equal(t, start.Add(4*time.Millisecond), spans[0].EndTime())
+	assert.Equal(t, "cache miss", spans[0].Events[0].Name)
+	assert.Equal(t, Status{Code: StatusCodeError, Message: "timeout"}, spans[1].Status)
+	assert.Equal(t, "dummy", got[0].ResourceSpans[0].Resource.Attributes["service.name"])
+}
@@ -0,0 +1,50 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Validator is an optional interface plugins implement to validate their
+// configuration, it is invoked before Init, which is skipped when the
+// configuration is invalid. Several errors can be reported at once with errors.Join.
+type Validator interface {
+	ValidateConfig(conf ConfigLoader) error
+}
+
+// ValidateConfig validates the configuration of the plugin the way the fluent-bit
+// initialization does, so plugins implementing Validator can be unit tested.
+// It returns nil for the other plugins.
+func ValidateConfig(plug any, conf ConfigLoader) error {
+	v, ok := plug.(Validator)
+	if !ok {
+		return nil
+	}
+
+	err := v.ValidateConfig(conf)
+	if err == nil {
+		return nil
+	}
+
+	// errors joined with errors.Join are reported one per line.
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		msgs := make([]string, 0, len(joined.Unwrap()))
+		for _, e := range joined.Unwrap() {
+			msgs = append(msgs, "\n  - "+e.Error())
+		}
+
+		return fmt.Errorf("invalid config:%s", strings.Join(msgs, ""))
+	}
+
+	return fmt.Errorf("invalid config: %w", err)
+}
+
+// initPlugin validates the configuration of the plugin and initializes it.
+func initPlugin(ctx context.Context, plug initer, fbit *Fluentbit) error {
+	if err := ValidateConfig(plug, fbit.Conf); err != nil {
+		return err
+	}
+
+	return plug.Init(ctx, fbit)
+}
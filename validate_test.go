@@ -0,0 +1,52 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+type testValidatedOutput struct {
+	testOutputCounter
+	initialized bool
+}
+
+func (plug *testValidatedOutput) ValidateConfig(conf ConfigLoader) error {
+	var errs []error
+	if conf.String("endpoint") == "" {
+		errs = append(errs, errors.New("endpoint: required"))
+	}
+	if conf.String("workers") == "0" {
+		errs = append(errs, errors.New("workers: must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+func (plug *testValidatedOutput) Init(ctx context.Context, fbit *Fluentbit) error {
+	plug.initialized = true
+	return nil
+}
+
+func TestValidateConfig(t *testing.T) {
+	plug := &testValidatedOutput{}
+
+	assert.NoError(t, ValidateConfig(plug, testConfigLoader{"endpoint": "https://example.com"}))
+	assert.NoError(t, ValidateConfig(&testOutputCounter{}, testConfigLoader{}))
+
+	err := ValidateConfig(plug, testConfigLoader{"workers": "0"})
+	assert.EqualError(t, err, "invalid config:\n  - endpoint: required\n  - workers: must be positive")
+}
+
+func TestInitPluginValidates(t *testing.T) {
+	plug := &testValidatedOutput{}
+
+	err := initPlugin(context.Background(), plug, &Fluentbit{Conf: testConfigLoader{}})
+	assert.Error(t, err)
+	assert.False(t, plug.initialized)
+
+	err = initPlugin(context.Background(), plug, &Fluentbit{Conf: testConfigLoader{"endpoint": "https://example.com"}})
+	assert.NoError(t, err)
+	assert.True(t, plug.initialized)
+}
@@ -0,0 +1,224 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+
+	"github.com/calyptia/plugin/flbconf"
+)
+
+// WithWatchdog reports the Collect goroutine of an input, or the flushes of an output,
+// making no progress for the given timeout: the goroutines are dumped to stderr and the
+// watchdog_stalls_total counter of the instance is incremented. With cancel set, the
+// context of the stalled goroutine is also cancelled and a new one is started in its place.
+// Instances can override it with the go.WatchdogTimeout and go.WatchdogCancel config keys.
+// It panics when used to register other kinds of plugins or with a non positive timeout.
+//
+// An input makes progress when fluent-bit receives records from it, so inputs emitting
+// less often than the timeout are reported as stalled.
+func WithWatchdog(timeout time.Duration, cancel bool) RegisterOption {
+	return func(r *registration) {
+		if r.kind != inputKind && r.kind != outputKind {
+			panic(fmt.Sprintf("watchdog set on %s plugin: %q", r.kind, r.name))
+		}
+
+		if timeout <= 0 {
+			panic(fmt.Sprintf("invalid watchdog timeout %s: %q", timeout, r.name))
+		}
+
+		r.watchdogTimeout, r.watchdogCancel = timeout, cancel
+	}
+}
+
+// watchdogConfig reads the go.WatchdogTimeout and go.WatchdogCancel config keys,
+// the watchdog is disabled with a zero timeout.
+func watchdogConfig(conf ConfigLoader, timeout time.Duration, cancel bool) (time.Duration, bool) {
+	if s := conf.String("go.WatchdogTimeout"); s != "" {
+		d, err := flbconf.ParseTime(s)
+		if err != nil || d < 0 {
+			fmt.Fprintf(os.Stderr, "invalid go.WatchdogTimeout %q, using %s\n", s, timeout)
+		} else {
+			timeout = d
+		}
+	}
+
+	if s := conf.String("go.WatchdogCancel"); s != "" {
+		v, err := flbconf.ParseBool(s)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid go.WatchdogCancel %q, using %t\n", s, cancel)
+		} else {
+			cancel = v
+		}
+	}
+
+	return timeout, cancel
+}
+
+// watchdog detects a callback of a plugin instance making no progress while busy.
+type watchdog struct {
+	name     string
+	callback string
+	timeout  time.Duration
+	// restart cancels the stalled goroutine and starts a new one,
+	// it is only set when the watchdog cancels stalled goroutines.
+	restart func()
+	onStall func()
+	// busy counts the goroutines expected to make progress.
+	busy atomic.Int64
+	// last is the time, in unix nanoseconds, progress was last made.
+	last atomic.Int64
+	// wake is signalled when a goroutine gets busy, waking the idle watchdog.
+	wake   chan struct{}
+	cancel context.CancelFunc
+}
+
+// startWatchdog starts the watchdog of the input or output instance, when enabled.
+func (s *runState) startWatchdog(r *registration, fbit *Fluentbit, restart func()) {
+	timeout, cancel := watchdogConfig(fbit.Conf, r.watchdogTimeout, r.watchdogCancel)
+	if timeout == 0 {
+		return
+	}
+
+	callback := "flush"
+	if r.kind == inputKind {
+		callback = "collect"
+	}
+
+	stalls := fbit.Metrics.NewCounter("watchdog_stalls_total",
+		"Number of times the plugin goroutines made no progress for the watchdog timeout", "name", "callback")
+	label := fbit.Instance.Label()
+
+	w := &watchdog{
+		name:     label,
+		callback: callback,
+		timeout:  timeout,
+		onStall: func() {
+			stalls.Add(1, label, callback)
+		},
+	}
+	if cancel {
+		w.restart = restart
+	}
+	w.progress()
+	w.start()
+
+	s.watchdog = w
+}
+
+// begin marks a goroutine as expected to make progress.
+func (w *watchdog) begin() {
+	if w == nil {
+		return
+	}
+
+	if w.busy.Add(1) == 1 {
+		w.progress()
+
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// end marks a goroutine as done, it counts as progress.
+func (w *watchdog) end() {
+	if w == nil {
+		return
+	}
+
+	w.progress()
+	w.busy.Add(-1)
+}
+
+func (w *watchdog) progress() {
+	if w == nil {
+		return
+	}
+
+	w.last.Store(sdkClock.Now().UnixNano())
+}
+
+// stalled reports whether a goroutine is busy without progress for the timeout.
+func (w *watchdog) stalled(now time.Time) bool {
+	return w.busy.Load() > 0 && now.Sub(time.Unix(0, w.last.Load())) >= w.timeout
+}
+
+// check reports a stall, restarting the stalled goroutine when configured to.
+// Stalls are reported once, until progress is made again.
+func (w *watchdog) check(now time.Time, reported bool) bool {
+	if !w.stalled(now) {
+		return false
+	}
+	if reported {
+		return true
+	}
+
+	fmt.Fprintf(os.Stderr, "watchdog: name=%q: %s made no progress for %s\n", w.name, w.callback, w.timeout)
+	_ = pprof.Lookup("goroutine").WriteTo(os.Stderr, 1)
+	w.onStall()
+
+	if w.restart == nil {
+		return true
+	}
+
+	fmt.Fprintf(os.Stderr, "watchdog: name=%q: restarting %s\n", w.name, w.callback)
+	w.restart()
+	w.progress()
+
+	return false
+}
+
+// start checks for stalls twice per timeout until stopped. The watchdog sleeps while no
+// goroutine is busy, so idle instances are not woken up.
+func (w *watchdog) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel, w.wake = cancel, make(chan struct{}, 1)
+
+	spawn("watchdog "+w.name, func() {
+		var reported bool
+		for {
+			if w.busy.Load() == 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-w.wake:
+				}
+			}
+
+			if !w.watch(ctx, &reported) {
+				return
+			}
+		}
+	})
+}
+
+// watch checks for stalls until no goroutine is busy, it returns false once stopped.
+func (w *watchdog) watch(ctx context.Context, reported *bool) bool {
+	ticker := sdkClock.NewTicker(w.timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case now := <-ticker.C():
+			*reported = w.check(now, *reported)
+			if w.busy.Load() == 0 {
+				return true
+			}
+		}
+	}
+}
+
+func (w *watchdog) stop() {
+	if w == nil || w.cancel == nil {
+		return
+	}
+
+	w.cancel()
+}
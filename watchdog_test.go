@@ -0,0 +1,143 @@
+package plugin
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestWithWatchdog(t *testing.T) {
+	r := &registration{kind: outputKind, name: "dummy"}
+	WithWatchdog(time.Minute, true)(r)
+	assert.Equal(t, time.Minute, r.watchdogTimeout)
+	assert.True(t, r.watchdogCancel)
+
+	assert.Panics(t, func() {
+		WithWatchdog(time.Minute, false)(&registration{kind: filterKind, name: "dummy"})
+	})
+	assert.Panics(t, func() {
+		WithWatchdog(0, false)(&registration{kind: inputKind, name: "dummy"})
+	})
+}
+
+func TestWatchdogConfig(t *testing.T) {
+	timeout, cancel := watchdogConfig(testConfigLoader{}, time.Minute, false)
+	assert.Equal(t, time.Minute, timeout)
+	assert.False(t, cancel)
+
+	timeout, cancel = watchdogConfig(testConfigLoader{
+		"go.WatchdogTimeout": "30s",
+		"go.WatchdogCancel":  "on",
+	}, 0, false)
+	assert.Equal(t, 30*time.Second, timeout)
+	assert.True(t, cancel)
+
+	timeout, cancel = watchdogConfig(testConfigLoader{
+		"go.WatchdogTimeout": "soon",
+		"go.WatchdogCancel":  "maybe",
+	}, time.Minute, true)
+	assert.Equal(t, time.Minute, timeout)
+	assert.True(t, cancel)
+
+	timeout, _ = watchdogConfig(testConfigLoader{"go.WatchdogTimeout": "45"}, 0, false)
+	assert.Equal(t, 45*time.Second, timeout)
+}
+
+func TestWatchdogCheck(t *testing.T) {
+	gauge := &testGauge{values: map[string]float64{}}
+	var restarts int
+	w := &watchdog{
+		name:     "dummy",
+		callback: "flush",
+		timeout:  time.Second,
+		onStall: func() {
+			gauge.Add(1, "dummy")
+		},
+	}
+	w.progress()
+
+	now := time.Now()
+	// idle goroutines are never stalled.
+	assert.False(t, w.check(now.Add(time.Minute), false))
+
+	w.begin()
+	assert.False(t, w.check(now.Add(time.Second/2), false))
+	assert.True(t, w.check(now.Add(2*time.Second), false))
+	assert.True(t, w.check(now.Add(3*time.Second), true))
+	assert.Equal(t, 1.0, gauge.get("dummy"))
+
+	w.progress()
+	assert.False(t, w.check(time.Now(), true))
+
+	w.restart = func() { restarts++ }
+	assert.False(t, w.check(time.Now().Add(2*time.Second), false))
+	assert.Equal(t, 1, restarts)
+	assert.Equal(t, 2.0, gauge.get("dummy"))
+
+	w.end()
+	assert.False(t, w.stalled(time.Now().Add(time.Minute)))
+}
+
+type testOutputStalled struct {
+	calls   atomic.Int32
+	ch      chan Message
+	release chan struct{}
+}
+
+func (plug *testOutputStalled) Init(ctx context.Context, fbit *Fluentbit) error {
+	return nil
+}
+
+func (plug *testOutputStalled) Flush(ctx context.Context, ch <-chan Message) error {
+	// the first flush goroutine ignores its context.
+	if plug.calls.Add(1) == 1 {
+		<-plug.release
+		return nil
+	}
+
+	for {
+		select {
+		case msg := <-ch:
+			plug.ch <- msg
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func TestOutputWatchdogRestart(t *testing.T) {
+	plug := &testOutputStalled{ch: make(chan Message, 1), release: make(chan struct{})}
+
+	gauge := &testGauge{values: map[string]float64{}}
+	r := &registration{kind: outputKind, name: "test-output"}
+	o := &outputInstance{reg: r, output: plug}
+	o.startWatchdog(r, &Fluentbit{
+		Conf: testConfigLoader{
+			"go.WatchdogTimeout": "50ms",
+			"go.WatchdogCancel":  "true",
+		},
+		Metrics:  testMetrics{gauge: gauge},
+		Instance: InstanceInfo{Name: "test-output.0"},
+	}, o.restartFlush)
+	defer o.watchdog.stop()
+	assert.NoError(t, o.run())
+
+	b, err := msgpack.Marshal([]any{&EventTime{time.Now()}, map[string]any{"foo": "bar"}})
+	assert.NoError(t, err)
+
+	// the pending flush is cancelled once the watchdog restarts the flush goroutine.
+	o.watchdog.begin()
+	assert.NoError(t, o.pluginFlush(0, "stalled", b))
+	o.watchdog.end()
+	assert.Equal(t, 1.0, gauge.get("test-output.0"))
+
+	assert.NoError(t, o.pluginFlush(0, "foobar", b))
+	assert.Equal(t, "foobar", (<-plug.ch).Tag())
+
+	close(plug.release)
+	o.stop()
+}